@@ -0,0 +1,490 @@
+// Package archive reads and writes migration archives: files holding exported keys
+// that can be carried to a network the source cannot reach and imported there.
+//
+// An archive starts with a header naming the export, followed by chunks of records,
+// each gzip compressed and checksummed. A manifest listing every chunk and its checksum
+// is appended once the export is complete, so an archive without one was interrupted
+// and can be resumed by appending the missing chunks.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// FormatVersion is the version of the archive format written by this package. Archives
+// of newer versions are refused.
+const FormatVersion = 1
+
+// fileMagic starts every archive
+var fileMagic = [8]byte{'R', 'V', 'M', 'A', 'R', 'C', 'H', 0}
+
+// trailerMagic ends a complete archive, after the offset of its manifest
+var trailerMagic = [8]byte{'R', 'V', 'M', 'A', 'E', 'N', 'D', 0}
+
+// trailerSize is the size of the trailer: the manifest offset and trailerMagic
+const trailerSize = 16
+
+// Frame kinds
+const (
+	frameChunk    = 'C'
+	frameManifest = 'M'
+)
+
+// frameHeaderSize is the size of the kind, length and SHA-256 checksum before a payload
+const frameHeaderSize = 1 + 4 + sha256.Size
+
+// ErrComplete is returned by OpenWriter for an archive that already has its manifest
+var ErrComplete = errors.New("archive is already complete")
+
+// Header describes an export. It is written at the start of the archive, so that a
+// resumed export can check it continues the same one.
+type Header struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	// Source names where the keys were exported from
+	Source   string   `json:"source"`
+	Database int      `json:"database"`
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+// ChunkInfo locates a chunk in the archive
+type ChunkInfo struct {
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	Keys   int    `json:"keys"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest lists the chunks of a complete archive
+type Manifest struct {
+	FormatVersion int `json:"format_version"`
+	Header
+	CompletedAt time.Time   `json:"completed_at"`
+	Keys        int64       `json:"keys"`
+	Chunks      []ChunkInfo `json:"chunks"`
+}
+
+// Writer appends chunks to an archive
+type Writer struct {
+	file   *os.File
+	header Header
+	offset int64
+	chunks []ChunkInfo
+	keys   int64
+
+	// exported holds the keys of the chunks found when an interrupted export was resumed
+	exported map[string]bool
+}
+
+// OpenWriter creates an archive at path with header, generating its ID and creation
+// time. If an interrupted archive already exists there, it is opened to be resumed
+// instead: its header is kept, a chunk left half written is cut off, and the keys of
+// its chunks are reported by Exported. An archive that is already complete is not
+// touched and ErrComplete is returned.
+func OpenWriter(path string, header Header) (*Writer, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	w := &Writer{file: file, exported: make(map[string]bool)}
+	if info.Size() == 0 {
+		err = w.create(header)
+	} else {
+		err = w.resume()
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// create writes the header of a new archive
+func (w *Writer) create(header Header) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("failed to generate archive ID: %w", err)
+	}
+	header.ID = hex.EncodeToString(id)
+	header.CreatedAt = time.Now().UTC()
+
+	data, err := json.Marshal(header)
+	if err != nil {
+		return fmt.Errorf("failed to marshal archive header: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(fileMagic[:])
+	binary.Write(&buf, binary.BigEndian, uint16(FormatVersion))
+	binary.Write(&buf, binary.BigEndian, uint32(len(data)))
+	buf.Write(data)
+
+	if _, err := w.file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write archive header: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("failed to write archive header: %w", err)
+	}
+	w.header = header
+	w.offset = int64(buf.Len())
+	return nil
+}
+
+// resume reads the chunks of an interrupted archive and cuts off anything after the
+// last complete one
+func (w *Writer) resume() error {
+	header, offset, err := readHeader(w.file)
+	if err != nil {
+		return err
+	}
+	w.header = header
+	w.offset = offset
+
+	for {
+		kind, payload, sum, err := readFrame(w.file)
+		if err != nil {
+			// A frame cut short or corrupted by the interruption, it is written again
+			break
+		}
+		if kind == frameManifest {
+			return ErrComplete
+		}
+		if kind != frameChunk {
+			break
+		}
+
+		records, err := decodeChunk(payload)
+		if err != nil {
+			break
+		}
+		for _, record := range records {
+			w.exported[record.Key] = true
+		}
+
+		size := int64(frameHeaderSize + len(payload))
+		w.chunks = append(w.chunks, ChunkInfo{Offset: w.offset, Size: size, Keys: len(records), SHA256: hex.EncodeToString(sum)})
+		w.keys += int64(len(records))
+		w.offset += size
+	}
+
+	if err := w.file.Truncate(w.offset); err != nil {
+		return fmt.Errorf("failed to truncate archive: %w", err)
+	}
+	if _, err := w.file.Seek(w.offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek archive: %w", err)
+	}
+	return nil
+}
+
+// Header returns the header of the archive, which for a resumed archive is the one it
+// was created with
+func (w *Writer) Header() Header {
+	return w.header
+}
+
+// Exported reports whether a key is in one of the chunks written before the export was
+// resumed
+func (w *Writer) Exported(key string) bool {
+	return w.exported[key]
+}
+
+// Chunks returns the number of chunks written
+func (w *Writer) Chunks() int {
+	return len(w.chunks)
+}
+
+// Keys returns the number of records written
+func (w *Writer) Keys() int64 {
+	return w.keys
+}
+
+// WriteChunk compresses records and appends them to the archive as a chunk. The chunk
+// is synced to disk before WriteChunk returns, so it survives an interruption.
+func (w *Writer) WriteChunk(records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	payload, err := encodeChunk(records)
+	if err != nil {
+		return err
+	}
+
+	frame, sum := encodeFrame(frameChunk, payload)
+	if _, err := w.file.Write(frame); err != nil {
+		return fmt.Errorf("failed to write archive chunk: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("failed to write archive chunk: %w", err)
+	}
+
+	w.chunks = append(w.chunks, ChunkInfo{Offset: w.offset, Size: int64(len(frame)), Keys: len(records), SHA256: hex.EncodeToString(sum)})
+	w.keys += int64(len(records))
+	w.offset += int64(len(frame))
+	return nil
+}
+
+// Finish appends the manifest, completing the archive, and closes it
+func (w *Writer) Finish() (*Manifest, error) {
+	manifest := &Manifest{
+		FormatVersion: FormatVersion,
+		Header:        w.header,
+		CompletedAt:   time.Now().UTC(),
+		Keys:          w.keys,
+		Chunks:        w.chunks,
+	}
+	if manifest.Chunks == nil {
+		manifest.Chunks = []ChunkInfo{}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal archive manifest: %w", err)
+	}
+
+	frame, _ := encodeFrame(frameManifest, data)
+	var trailer bytes.Buffer
+	binary.Write(&trailer, binary.BigEndian, uint64(w.offset))
+	trailer.Write(trailerMagic[:])
+
+	if _, err := w.file.Write(append(frame, trailer.Bytes()...)); err != nil {
+		return nil, fmt.Errorf("failed to write archive manifest: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return nil, fmt.Errorf("failed to write archive manifest: %w", err)
+	}
+	if err := w.file.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close archive: %w", err)
+	}
+	return manifest, nil
+}
+
+// Close closes the archive without completing it, so that the export can be resumed
+func (w *Writer) Close() error {
+	return w.file.Close()
+}
+
+// Reader reads the chunks of a complete archive
+type Reader struct {
+	file     *os.File
+	manifest *Manifest
+}
+
+// OpenReader opens a complete archive and checks its manifest
+func OpenReader(path string) (*Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	manifest, err := readManifest(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("archive %s: %w", path, err)
+	}
+	return &Reader{file: file, manifest: manifest}, nil
+}
+
+// readManifest reads the header of an archive and the manifest its trailer points to
+func readManifest(file *os.File) (*Manifest, error) {
+	header, dataStart, err := readHeader(file)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	trailer := make([]byte, trailerSize)
+	if info.Size() < dataStart+trailerSize {
+		return nil, fmt.Errorf("archive is incomplete, run the export again to finish it")
+	}
+	if _, err := file.ReadAt(trailer, info.Size()-trailerSize); err != nil {
+		return nil, fmt.Errorf("failed to read archive trailer: %w", err)
+	}
+	if !bytes.Equal(trailer[8:], trailerMagic[:]) {
+		return nil, fmt.Errorf("archive is incomplete, run the export again to finish it")
+	}
+
+	offset := int64(binary.BigEndian.Uint64(trailer[:8]))
+	if offset < dataStart || offset >= info.Size()-trailerSize {
+		return nil, fmt.Errorf("invalid manifest offset %d", offset)
+	}
+	kind, data, _, err := readFrame(io.NewSectionReader(file, offset, info.Size()-trailerSize-offset))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive manifest: %w", err)
+	}
+	if kind != frameManifest {
+		return nil, fmt.Errorf("no manifest at offset %d", offset)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse archive manifest: %w", err)
+	}
+	if manifest.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("archive manifest version %d is newer than the supported version %d", manifest.FormatVersion, FormatVersion)
+	}
+	if manifest.ID != header.ID {
+		return nil, fmt.Errorf("manifest belongs to archive %s, not %s", manifest.ID, header.ID)
+	}
+	return &manifest, nil
+}
+
+// Manifest returns the manifest of the archive
+func (r *Reader) Manifest() *Manifest {
+	return r.manifest
+}
+
+// ReadChunk reads the records of chunk i, checking them against the checksum in the
+// manifest
+func (r *Reader) ReadChunk(i int) ([]Record, error) {
+	if i < 0 || i >= len(r.manifest.Chunks) {
+		return nil, fmt.Errorf("archive has no chunk %d", i)
+	}
+	info := r.manifest.Chunks[i]
+
+	kind, payload, sum, err := readFrame(io.NewSectionReader(r.file, info.Offset, info.Size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk %d: %w", i, err)
+	}
+	if kind != frameChunk {
+		return nil, fmt.Errorf("no chunk %d at offset %d", i, info.Offset)
+	}
+	if hex.EncodeToString(sum) != info.SHA256 {
+		return nil, fmt.Errorf("chunk %d checksum does not match the manifest", i)
+	}
+
+	records, err := decodeChunk(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode chunk %d: %w", i, err)
+	}
+	if len(records) != info.Keys {
+		return nil, fmt.Errorf("chunk %d holds %d keys, the manifest lists %d", i, len(records), info.Keys)
+	}
+	return records, nil
+}
+
+// Close closes the archive
+func (r *Reader) Close() error {
+	return r.file.Close()
+}
+
+// readHeader reads the header at the start of an archive and returns it with the offset
+// of the first chunk
+func readHeader(r io.ReadSeeker) (Header, int64, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return Header{}, 0, fmt.Errorf("failed to seek archive: %w", err)
+	}
+
+	var prefix struct {
+		Magic   [8]byte
+		Version uint16
+		Length  uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &prefix); err != nil {
+		return Header{}, 0, fmt.Errorf("failed to read archive header: %w", err)
+	}
+	if prefix.Magic != fileMagic {
+		return Header{}, 0, fmt.Errorf("not a migration archive")
+	}
+	if prefix.Version > FormatVersion {
+		return Header{}, 0, fmt.Errorf("archive format version %d is newer than the supported version %d", prefix.Version, FormatVersion)
+	}
+	if prefix.Length > 1<<20 {
+		return Header{}, 0, fmt.Errorf("archive header of %d bytes is too large", prefix.Length)
+	}
+
+	data := make([]byte, prefix.Length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return Header{}, 0, fmt.Errorf("failed to read archive header: %w", err)
+	}
+	var header Header
+	if err := json.Unmarshal(data, &header); err != nil {
+		return Header{}, 0, fmt.Errorf("failed to parse archive header: %w", err)
+	}
+	return header, int64(binary.Size(prefix)) + int64(prefix.Length), nil
+}
+
+// encodeFrame prefixes payload with its kind, length and SHA-256 checksum
+func encodeFrame(kind byte, payload []byte) ([]byte, []byte) {
+	sum := sha256.Sum256(payload)
+	frame := make([]byte, 0, frameHeaderSize+len(payload))
+	frame = append(frame, kind)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(payload)))
+	frame = append(frame, sum[:]...)
+	return append(frame, payload...), sum[:]
+}
+
+// readFrame reads a frame and checks its checksum, returning its kind, payload and checksum
+func readFrame(r io.Reader) (byte, []byte, []byte, error) {
+	header := make([]byte, frameHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, nil, err
+	}
+
+	// Read up to the length instead of allocating it up front, a corrupted length must
+	// not allocate more than the data that follows
+	length := int64(binary.BigEndian.Uint32(header[1:5]))
+	payload, err := io.ReadAll(io.LimitReader(r, length))
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if int64(len(payload)) < length {
+		return 0, nil, nil, io.ErrUnexpectedEOF
+	}
+
+	sum := sha256.Sum256(payload)
+	if !bytes.Equal(sum[:], header[5:]) {
+		return 0, nil, nil, fmt.Errorf("frame checksum mismatch")
+	}
+	return header[0], payload, sum[:], nil
+}
+
+// encodeChunk gzip compresses the gob encoding of records
+func encodeChunk(records []Record) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := gob.NewEncoder(zw).Encode(records); err != nil {
+		return nil, fmt.Errorf("failed to encode archive chunk: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress archive chunk: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeChunk reverses encodeChunk
+func decodeChunk(payload []byte) ([]Record, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var records []Record
+	if err := gob.NewDecoder(zr).Decode(&records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
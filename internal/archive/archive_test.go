@@ -0,0 +1,212 @@
+package archive
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+)
+
+func testRecords(t *testing.T, keys ...string) []Record {
+	t.Helper()
+	readAt := time.UnixMilli(time.Now().UnixMilli())
+
+	var records []Record
+	for _, key := range keys {
+		record, err := NewRecord(2, key, "value of "+key, time.Time{}, readAt)
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestArchive_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.rvma")
+	readAt := time.UnixMilli(time.Now().UnixMilli())
+	expireAt := readAt.Add(time.Hour)
+
+	values := []interface{}{
+		"binary\x00\xff",
+		map[string]string{"f": "v"},
+		[]string{"b", "a", "b"},
+		[]interface{}{"m1", "m2"},
+		[]redis.Z{{Score: 1.5, Member: "z"}},
+		&client.StreamValue{
+			Entries:         []client.StreamEntry{{ID: "1-1", Fields: []string{"f", "v"}}},
+			LastGeneratedID: "1-1",
+			Groups:          []client.StreamGroup{{Name: "g", LastDeliveredID: "0-0"}},
+		},
+	}
+	var records []Record
+	for i, value := range values {
+		record, err := NewRecord(2, string(rune('a'+i)), value, expireAt, readAt)
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+
+	w, err := OpenWriter(path, Header{Source: "redis:6379", Database: 2, Patterns: []string{"*"}})
+	require.NoError(t, err)
+	require.NoError(t, w.WriteChunk(records[:3]))
+	require.NoError(t, w.WriteChunk(records[3:]))
+	manifest, err := w.Finish()
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), manifest.Keys)
+	assert.Len(t, manifest.ID, 32)
+
+	r, err := OpenReader(path)
+	require.NoError(t, err)
+	defer r.Close()
+
+	got := r.Manifest()
+	assert.Equal(t, FormatVersion, got.FormatVersion)
+	assert.Equal(t, manifest.ID, got.ID)
+	assert.Equal(t, "redis:6379", got.Source)
+	assert.Equal(t, 2, got.Database)
+	assert.Equal(t, []string{"*"}, got.Patterns)
+	require.Len(t, got.Chunks, 2)
+	assert.Equal(t, 3, got.Chunks[0].Keys)
+
+	var read []Record
+	for i := range got.Chunks {
+		chunk, err := r.ReadChunk(i)
+		require.NoError(t, err)
+		read = append(read, chunk...)
+	}
+	require.Len(t, read, len(values))
+
+	expected := []interface{}{
+		"binary\x00\xff",
+		map[string]string{"f": "v"},
+		[]string{"b", "a", "b"},
+		[]string{"m1", "m2"},
+		[]redis.Z{{Score: 1.5, Member: "z"}},
+		values[5],
+	}
+	for i, record := range read {
+		assert.Equal(t, 2, record.DB)
+		assert.Equal(t, expected[i], record.Value(), record.Key)
+		assert.Equal(t, time.Hour, record.PTTL)
+		assert.True(t, record.ExpireAt().Equal(expireAt))
+	}
+	assert.Equal(t, "set", read[3].Type)
+
+	_, err = r.ReadChunk(2)
+	assert.Error(t, err)
+}
+
+func TestArchive_Resume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.rvma")
+
+	w, err := OpenWriter(path, Header{Database: 2})
+	require.NoError(t, err)
+	id := w.Header().ID
+	require.NoError(t, w.WriteChunk(testRecords(t, "a", "b")))
+	require.NoError(t, w.Close())
+
+	// An interruption while the next chunk was written leaves part of it behind
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	complete := len(data)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	f.Write([]byte{frameChunk, 0, 0, 1, 0, 1, 2, 3})
+	f.Close()
+
+	w, err = OpenWriter(path, Header{Database: 5})
+	require.NoError(t, err)
+	assert.Equal(t, id, w.Header().ID, "the header of the interrupted export is kept")
+	assert.Equal(t, 2, w.Header().Database)
+	assert.True(t, w.Exported("a"))
+	assert.True(t, w.Exported("b"))
+	assert.False(t, w.Exported("c"))
+	assert.Equal(t, 1, w.Chunks())
+	assert.Equal(t, int64(2), w.Keys())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(complete), info.Size(), "the partial chunk is cut off")
+
+	require.NoError(t, w.WriteChunk(testRecords(t, "c")))
+	manifest, err := w.Finish()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), manifest.Keys)
+
+	r, err := OpenReader(path)
+	require.NoError(t, err)
+	defer r.Close()
+	chunk, err := r.ReadChunk(1)
+	require.NoError(t, err)
+	assert.Equal(t, "c", chunk[0].Key)
+
+	// A complete archive is not resumed
+	_, err = OpenWriter(path, Header{})
+	assert.ErrorIs(t, err, ErrComplete)
+}
+
+func TestArchive_Errors(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("incomplete", func(t *testing.T) {
+		path := filepath.Join(dir, "incomplete.rvma")
+		w, err := OpenWriter(path, Header{})
+		require.NoError(t, err)
+		require.NoError(t, w.WriteChunk(testRecords(t, "a")))
+		require.NoError(t, w.Close())
+
+		_, err = OpenReader(path)
+		assert.ErrorContains(t, err, "incomplete")
+	})
+
+	t.Run("corrupted chunk", func(t *testing.T) {
+		path := filepath.Join(dir, "corrupted.rvma")
+		w, err := OpenWriter(path, Header{})
+		require.NoError(t, err)
+		require.NoError(t, w.WriteChunk(testRecords(t, "a", "b")))
+		manifest, err := w.Finish()
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		data[manifest.Chunks[0].Offset+frameHeaderSize+10] ^= 0xFF
+		require.NoError(t, os.WriteFile(path, data, 0644))
+
+		r, err := OpenReader(path)
+		require.NoError(t, err, "the manifest is intact")
+		defer r.Close()
+		_, err = r.ReadChunk(0)
+		assert.ErrorContains(t, err, "checksum")
+	})
+
+	t.Run("newer version", func(t *testing.T) {
+		path := filepath.Join(dir, "newer.rvma")
+		w, err := OpenWriter(path, Header{})
+		require.NoError(t, err)
+		_, err = w.Finish()
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		binary.BigEndian.PutUint16(data[len(fileMagic):], FormatVersion+1)
+		require.NoError(t, os.WriteFile(path, data, 0644))
+
+		_, err = OpenReader(path)
+		assert.ErrorContains(t, err, "newer than the supported version")
+	})
+
+	t.Run("not an archive", func(t *testing.T) {
+		path := filepath.Join(dir, "dump.rdb")
+		require.NoError(t, os.WriteFile(path, []byte("REDIS0012 and more"), 0644))
+
+		_, err := OpenReader(path)
+		assert.ErrorContains(t, err, "not a migration archive")
+		_, err = OpenWriter(path, Header{})
+		assert.ErrorContains(t, err, "not a migration archive")
+	})
+}
@@ -0,0 +1,270 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+)
+
+// errWriteOnly is returned by the read operations of a Sink
+var errWriteOnly = errors.New("archive sink is write-only")
+
+// Sink is a client.DatabaseClient collecting the values written to it as records, so
+// that the processor type handlers can export keys into an archive. It implements
+// client.ExpiryClient, so values are written together with their absolute expiry.
+type Sink struct {
+	db int
+
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+// NewSink creates a sink recording keys of database db
+func NewSink(db int) *Sink {
+	return &Sink{db: db, records: make(map[string]Record)}
+}
+
+// Records returns the records written since the last call in key order, and empties
+// the sink
+func (s *Sink) Records() []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]Record, 0, len(s.records))
+	for _, record := range s.records {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Key < records[j].Key })
+	s.records = make(map[string]Record)
+	return records
+}
+
+// SetValueWithExpiry records a value and its expiry
+func (s *Sink) SetValueWithExpiry(key string, value interface{}, expireAt time.Time) error {
+	record, err := NewRecord(s.db, key, value, expireAt, time.Now())
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = record
+	return nil
+}
+
+// SetValue records a value without expiry
+func (s *Sink) SetValue(key string, value interface{}) error {
+	return s.SetValueWithExpiry(key, value, time.Time{})
+}
+
+// SetExpireAt sets the expiry of a recorded key
+func (s *Sink) SetExpireAt(key string, expireAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, exists := s.records[key]
+	if !exists {
+		return fmt.Errorf("key %s has not been written", key)
+	}
+	record.ReadAt = time.Now()
+	record.PTTL = expireAt.Sub(record.ReadAt)
+	s.records[key] = record
+	return nil
+}
+
+// SetTTL sets the time to live of a recorded key
+func (s *Sink) SetTTL(key string, ttl time.Duration) error {
+	return s.SetExpireAt(key, time.Now().Add(ttl))
+}
+
+// Connect does nothing, the sink is held in memory
+func (s *Sink) Connect() error {
+	return nil
+}
+
+// Disconnect does nothing, the sink is held in memory
+func (s *Sink) Disconnect() error {
+	return nil
+}
+
+// Ping does nothing, the sink is held in memory
+func (s *Sink) Ping() error {
+	return nil
+}
+
+// GetAllKeys fails, the sink is write-only
+func (s *Sink) GetAllKeys() ([]string, error) {
+	return nil, errWriteOnly
+}
+
+// GetKeysByPattern fails, the sink is write-only
+func (s *Sink) GetKeysByPattern(pattern string) ([]string, error) {
+	return nil, errWriteOnly
+}
+
+// GetKeyType fails, the sink is write-only
+func (s *Sink) GetKeyType(key string) (string, error) {
+	return "", errWriteOnly
+}
+
+// GetValue fails, the sink is write-only
+func (s *Sink) GetValue(key string) (interface{}, error) {
+	return nil, errWriteOnly
+}
+
+// Exists fails, the sink is write-only
+func (s *Sink) Exists(key string) (bool, error) {
+	return false, errWriteOnly
+}
+
+// GetTTL fails, the sink is write-only
+func (s *Sink) GetTTL(key string) (time.Duration, error) {
+	return 0, errWriteOnly
+}
+
+// GetExpireAt fails, the sink is write-only
+func (s *Sink) GetExpireAt(key string) (time.Time, bool, error) {
+	return time.Time{}, false, errWriteOnly
+}
+
+// ChunkSource is a read-only client.DatabaseClient over the records of a chunk, so that
+// the processor type handlers can import them. Keys that have expired since they were
+// exported do not exist.
+type ChunkSource struct {
+	records map[string]Record
+	order   []string
+}
+
+// NewChunkSource creates a source holding records
+func NewChunkSource(records []Record) *ChunkSource {
+	s := &ChunkSource{records: make(map[string]Record, len(records))}
+	for _, record := range records {
+		if _, exists := s.records[record.Key]; !exists {
+			s.order = append(s.order, record.Key)
+		}
+		s.records[record.Key] = record
+	}
+	return s
+}
+
+// lookup returns the record of a key that has not expired
+func (s *ChunkSource) lookup(key string) (Record, bool) {
+	record, exists := s.records[key]
+	if !exists || record.Expired() {
+		return Record{}, false
+	}
+	return record, true
+}
+
+// Connect does nothing, the records are held in memory
+func (s *ChunkSource) Connect() error {
+	return nil
+}
+
+// Disconnect does nothing, the records are held in memory
+func (s *ChunkSource) Disconnect() error {
+	return nil
+}
+
+// Ping does nothing, the records are held in memory
+func (s *ChunkSource) Ping() error {
+	return nil
+}
+
+// GetAllKeys returns the keys that have not expired, in chunk order
+func (s *ChunkSource) GetAllKeys() ([]string, error) {
+	keys := make([]string, 0, len(s.order))
+	for _, key := range s.order {
+		if _, exists := s.lookup(key); exists {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// GetKeysByPattern returns the keys matching a glob-style pattern
+func (s *ChunkSource) GetKeysByPattern(pattern string) ([]string, error) {
+	keys, _ := s.GetAllKeys()
+	var matched []string
+	for _, key := range keys {
+		ok, err := filepath.Match(pattern, key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if ok {
+			matched = append(matched, key)
+		}
+	}
+	return matched, nil
+}
+
+// GetKeyType returns the type of a key, "none" if it does not exist
+func (s *ChunkSource) GetKeyType(key string) (string, error) {
+	record, exists := s.lookup(key)
+	if !exists {
+		return "none", nil
+	}
+	return record.Type, nil
+}
+
+// GetValue returns the value of a key
+func (s *ChunkSource) GetValue(key string) (interface{}, error) {
+	record, exists := s.lookup(key)
+	if !exists {
+		return nil, fmt.Errorf("key %s does not exist in the archive", key)
+	}
+	return record.Value(), nil
+}
+
+// Exists reports whether a key exists and has not expired
+func (s *ChunkSource) Exists(key string) (bool, error) {
+	_, exists := s.lookup(key)
+	return exists, nil
+}
+
+// GetTTL returns the time a key has left, -1 if it has no expiry and -2 if it does not exist
+func (s *ChunkSource) GetTTL(key string) (time.Duration, error) {
+	record, exists := s.lookup(key)
+	switch {
+	case !exists:
+		return -2, nil
+	case record.PTTL < 0:
+		return -1, nil
+	default:
+		return time.Until(record.ExpireAt()), nil
+	}
+}
+
+// GetExpireAt returns the absolute expiry of a key
+func (s *ChunkSource) GetExpireAt(key string) (time.Time, bool, error) {
+	record, exists := s.lookup(key)
+	if !exists {
+		return time.Time{}, false, nil
+	}
+	return record.ExpireAt(), true, nil
+}
+
+// SetValue fails, the archive is read-only
+func (s *ChunkSource) SetValue(key string, value interface{}) error {
+	return client.ErrReadOnly
+}
+
+// SetTTL fails, the archive is read-only
+func (s *ChunkSource) SetTTL(key string, ttl time.Duration) error {
+	return client.ErrReadOnly
+}
+
+// SetValueWithExpiry fails, the archive is read-only
+func (s *ChunkSource) SetValueWithExpiry(key string, value interface{}, expireAt time.Time) error {
+	return client.ErrReadOnly
+}
+
+// SetExpireAt fails, the archive is read-only
+func (s *ChunkSource) SetExpireAt(key string, expireAt time.Time) error {
+	return client.ErrReadOnly
+}
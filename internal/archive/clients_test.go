@@ -0,0 +1,91 @@
+package archive
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+)
+
+func TestSink(t *testing.T) {
+	sink := NewSink(3)
+	expireAt := time.Now().Add(time.Minute)
+
+	require.NoError(t, sink.SetValueWithExpiry("b", []interface{}{"m"}, expireAt))
+	require.NoError(t, sink.SetValue("a", []string{"x", "y"}))
+	require.NoError(t, sink.SetValue("c", "v"))
+	require.NoError(t, sink.SetTTL("c", time.Hour))
+	assert.Error(t, sink.SetValue("d", client.MergeValue{Value: "v"}), "merging is not supported")
+	assert.Error(t, sink.SetExpireAt("missing", expireAt))
+
+	records := sink.Records()
+	require.Len(t, records, 3)
+	assert.Equal(t, []string{"a", "b", "c"}, []string{records[0].Key, records[1].Key, records[2].Key})
+
+	assert.Equal(t, "list", records[0].Type)
+	assert.Equal(t, time.Duration(-1), records[0].PTTL)
+	assert.True(t, records[0].ExpireAt().IsZero())
+
+	assert.Equal(t, "set", records[1].Type)
+	assert.Equal(t, 3, records[1].DB)
+	assert.WithinDuration(t, expireAt, records[1].ExpireAt(), time.Millisecond)
+
+	assert.InDelta(t, float64(time.Hour), float64(records[2].PTTL), float64(time.Second))
+
+	assert.Empty(t, sink.Records(), "records are returned once")
+
+	_, err := sink.GetValue("a")
+	assert.Error(t, err)
+}
+
+func TestChunkSource(t *testing.T) {
+	now := time.Now()
+	live, err := NewRecord(0, "live", []redis.Z{{Score: 2, Member: "m"}}, now.Add(time.Hour), now)
+	require.NoError(t, err)
+	persistent, err := NewRecord(0, "persistent", map[string]string{"f": "v"}, time.Time{}, now)
+	require.NoError(t, err)
+	// Exported with a minute left two minutes ago
+	expired, err := NewRecord(0, "expired", "v", now.Add(-time.Minute), now.Add(-2*time.Minute))
+	require.NoError(t, err)
+
+	source := NewChunkSource([]Record{live, persistent, expired})
+
+	keys, err := source.GetAllKeys()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"live", "persistent"}, keys)
+
+	keys, err = source.GetKeysByPattern("p*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"persistent"}, keys)
+
+	keyType, err := source.GetKeyType("live")
+	require.NoError(t, err)
+	assert.Equal(t, "zset", keyType)
+	keyType, err = source.GetKeyType("expired")
+	require.NoError(t, err)
+	assert.Equal(t, "none", keyType)
+
+	value, err := source.GetValue("persistent")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"f": "v"}, value)
+	_, err = source.GetValue("expired")
+	assert.Error(t, err)
+
+	expireAt, exists, err := source.GetExpireAt("live")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.WithinDuration(t, now.Add(time.Hour), expireAt, time.Millisecond)
+
+	ttl, err := source.GetTTL("persistent")
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(-1), ttl)
+	ttl, err = source.GetTTL("expired")
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(-2), ttl)
+
+	assert.ErrorIs(t, source.SetValue("k", "v"), client.ErrReadOnly)
+}
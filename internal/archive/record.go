@@ -0,0 +1,115 @@
+package archive
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+)
+
+// Record is a key stored in an archive
+type Record struct {
+	DB   int
+	Key  string
+	Type string
+
+	// PTTL is the time the key had left to live when it was read at ReadAt, -1 if it
+	// has no expiry. Keys keep their absolute expiry when imported, so a key exported
+	// with an hour left that is imported two hours later has expired.
+	PTTL   time.Duration
+	ReadAt time.Time
+
+	// Exactly one of the value fields is set, depending on Type. Members holds the
+	// elements of a list in order, or the members of a set.
+	String  string
+	Hash    map[string]string
+	Members []string
+	ZSet    []redis.Z
+	Stream  *client.StreamValue
+}
+
+// NewRecord creates the record of a key from a value in the shape SetValue accepts and
+// its absolute expiry, zero if the key has no expiry
+func NewRecord(db int, key string, value interface{}, expireAt, readAt time.Time) (Record, error) {
+	record := Record{DB: db, Key: key, PTTL: -1, ReadAt: readAt}
+	if !expireAt.IsZero() {
+		record.PTTL = expireAt.Sub(readAt)
+	}
+
+	switch v := value.(type) {
+	case string:
+		record.Type, record.String = "string", v
+	case map[string]string:
+		record.Type, record.Hash = "hash", v
+	case []string:
+		record.Type, record.Members = "list", v
+	case []interface{}:
+		record.Type = "set"
+		record.Members = make([]string, len(v))
+		for i, member := range v {
+			s, ok := member.(string)
+			if !ok {
+				return Record{}, fmt.Errorf("unexpected set member of type %T", member)
+			}
+			record.Members[i] = s
+		}
+	case []redis.Z:
+		record.Type = "zset"
+		record.ZSet = make([]redis.Z, len(v))
+		for i, z := range v {
+			// Members are written as strings, so that they decode without registering types
+			record.ZSet[i] = redis.Z{Score: z.Score, Member: fmt.Sprint(z.Member)}
+		}
+	case *client.StreamValue:
+		record.Type, record.Stream = "stream", v
+	default:
+		return Record{}, fmt.Errorf("unsupported value type: %T", value)
+	}
+	return record, nil
+}
+
+// Value returns the value of the record in the shape GetValue returns
+func (r Record) Value() interface{} {
+	switch r.Type {
+	case "string":
+		return r.String
+	case "hash":
+		if r.Hash == nil {
+			return map[string]string{}
+		}
+		return r.Hash
+	case "list", "set":
+		if r.Members == nil {
+			return []string{}
+		}
+		return r.Members
+	case "zset":
+		if r.ZSet == nil {
+			return []redis.Z{}
+		}
+		return r.ZSet
+	case "stream":
+		if r.Stream == nil {
+			return &client.StreamValue{}
+		}
+		return r.Stream
+	default:
+		return nil
+	}
+}
+
+// ExpireAt returns the absolute expiry of the key, zero if it has no expiry
+func (r Record) ExpireAt() time.Time {
+	if r.PTTL < 0 {
+		return time.Time{}
+	}
+	return r.ReadAt.Add(r.PTTL)
+}
+
+// Expired reports whether the key has expired by now
+func (r Record) Expired() bool {
+	expireAt := r.ExpireAt()
+	return !expireAt.IsZero() && !expireAt.After(time.Now())
+}
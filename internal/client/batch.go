@@ -0,0 +1,550 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrUnsupported is returned by optional client capabilities that the underlying client does not provide
+var ErrUnsupported = errors.New("operation not supported by client")
+
+// Unwrapper is implemented by clients that decorate another DatabaseClient
+type Unwrapper interface {
+	// Unwrap returns the decorated client
+	Unwrap() DatabaseClient
+}
+
+// Supports reports whether the innermost client behind any decorators implements capability T
+func Supports[T any](c DatabaseClient) bool {
+	for c != nil {
+		unwrapper, ok := c.(Unwrapper)
+		if !ok {
+			break
+		}
+		c = unwrapper.Unwrap()
+	}
+	_, ok := c.(T)
+	return ok
+}
+
+// KeyRecord holds everything needed to recreate a key on another database
+type KeyRecord struct {
+	Key   string
+	Type  string
+	Value interface{}
+	Err   error // Per-key read error, nil if the key was read successfully
+
+	// ExpireAt is the absolute expiry time computed from PTTL at read time, zero if the key has no expiry
+	ExpireAt time.Time
+
+	// Oversized is set for collections larger than the large data threshold, whose
+	// Value is left unread so that they can be transferred in chunks instead. Size is
+	// then their number of elements.
+	Oversized bool
+	Size      int64
+}
+
+// BatchClient is implemented by clients that can pipeline reads and writes for many keys at once
+type BatchClient interface {
+	// GetBatch reads the type, value and expiry of every key using pipelined round trips.
+	// Keys that could not be read have their Err field set and collections above the
+	// large data threshold are returned as Oversized without a value; the returned
+	// error is only set when the whole batch failed.
+	GetBatch(keys []string) ([]KeyRecord, error)
+
+	// SetBatch writes the value and expiry of every record in a MULTI/EXEC transaction of
+	// its own, all in a single pipeline. The returned slice holds one error per record; the returned error is only
+	// set when the whole batch failed.
+	SetBatch(records []KeyRecord) ([]error, error)
+
+	// ExistsBatch reports which of the keys exist using a single pipeline
+	ExistsBatch(keys []string) ([]bool, error)
+}
+
+// MergeValue wraps a value accepted by SetValue so that it is merged into an existing key
+// instead of replacing it. Hash fields and set and sorted set members are added to the
+// existing key and list elements are appended to it. Strings and streams cannot be merged
+// and are replaced.
+type MergeValue struct {
+	Value interface{}
+}
+
+// describeValue returns the data type and size of a value accepted by SetValue
+func describeValue(value interface{}) (string, int64, error) {
+	switch v := value.(type) {
+	case string:
+		return "string", int64(len(v)), nil
+	case map[string]string:
+		return "hash", int64(len(v)), nil
+	case []string:
+		return "list", int64(len(v)), nil
+	case []interface{}:
+		return "set", int64(len(v)), nil
+	case []redis.Z:
+		return "zset", int64(len(v)), nil
+	case *StreamValue:
+		return "stream", int64(len(v.Entries)), nil
+	case MergeValue:
+		return describeValue(v.Value)
+	default:
+		return "", 0, fmt.Errorf("unsupported value type: %T", value)
+	}
+}
+
+// queueWrite queues the commands that store value under key on the pipeline, replacing
+// any existing value unless value is a MergeValue
+func queueWrite(ctx context.Context, pipe redis.Pipeliner, key string, value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		pipe.Set(ctx, key, v, 0)
+	case map[string]string:
+		// Clear existing hash first, so that no stale fields are left behind
+		pipe.Del(ctx, key)
+		pipe.HMSet(ctx, key, v)
+	case []string:
+		// Clear existing list first
+		pipe.Del(ctx, key)
+
+		// Add all elements (if any)
+		if len(v) > 0 {
+			for _, item := range v {
+				pipe.RPush(ctx, key, item)
+			}
+		} else {
+			// Create empty list by pushing and popping a dummy value
+			pipe.LPush(ctx, key, "dummy")
+			pipe.LPop(ctx, key)
+		}
+	case []interface{}:
+		// For sets - clear existing set first
+		pipe.Del(ctx, key)
+
+		if len(v) > 0 {
+			pipe.SAdd(ctx, key, v...)
+		} else {
+			// Create empty set by adding and removing a dummy value
+			pipe.SAdd(ctx, key, "dummy")
+			pipe.SRem(ctx, key, "dummy")
+		}
+	case []redis.Z:
+		// For sorted sets - clear existing sorted set first
+		pipe.Del(ctx, key)
+
+		if len(v) > 0 {
+			pipe.ZAdd(ctx, key, v...)
+		} else {
+			// Create empty sorted set by adding and removing a dummy value
+			pipe.ZAdd(ctx, key, redis.Z{Score: 0, Member: "dummy"})
+			pipe.ZRem(ctx, key, "dummy")
+		}
+	case *StreamValue:
+		queueStreamWrite(ctx, pipe, key, v)
+	case MergeValue:
+		return queueMerge(ctx, pipe, key, v.Value)
+	default:
+		return fmt.Errorf("unsupported value type: %T", value)
+	}
+
+	return nil
+}
+
+// queueMerge queues the commands that merge value into the existing value under key
+func queueMerge(ctx context.Context, pipe redis.Pipeliner, key string, value interface{}) error {
+	switch v := value.(type) {
+	case map[string]string:
+		if len(v) > 0 {
+			pipe.HSet(ctx, key, v)
+		}
+	case []string:
+		if len(v) > 0 {
+			elements := make([]interface{}, len(v))
+			for i, element := range v {
+				elements[i] = element
+			}
+			pipe.RPush(ctx, key, elements...)
+		}
+	case []interface{}:
+		if len(v) > 0 {
+			pipe.SAdd(ctx, key, v...)
+		}
+	case []redis.Z:
+		if len(v) > 0 {
+			pipe.ZAdd(ctx, key, v...)
+		}
+	case MergeValue:
+		return fmt.Errorf("unsupported value type: nested %T", value)
+	default:
+		// Strings and streams are replaced
+		return queueWrite(ctx, pipe, key, value)
+	}
+
+	return nil
+}
+
+// existsBatch checks which keys exist using a single pipeline
+func existsBatch(ctx context.Context, rc redis.Cmdable, keys []string) ([]bool, error) {
+	pipe := rc.Pipeline()
+	cmds := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Exists(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to check key existence: %w", err)
+	}
+
+	exists := make([]bool, len(keys))
+	for i, cmd := range cmds {
+		exists[i] = cmd.Val() > 0
+	}
+	return exists, nil
+}
+
+// isReplyError reports whether err is an error reply from the server (as opposed to a
+// network or context error), meaning only the command that produced it has failed
+func isReplyError(err error) bool {
+	var replyErr redis.Error
+	return errors.As(err, &replyErr) && !errors.Is(err, redis.Nil)
+}
+
+// getBatch reads type, expiry and value for every key in two pipelined round trips, plus one
+// to measure collections when largeDataThreshold is positive
+func getBatch(ctx context.Context, rc redis.Cmdable, keys []string, largeDataThreshold int64) ([]KeyRecord, error) {
+	records := make([]KeyRecord, len(keys))
+
+	// First round trip: type and remaining time to live of every key
+	pipe := rc.Pipeline()
+	typeCmds := make([]*redis.StatusCmd, len(keys))
+	ttlCmds := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		typeCmds[i] = pipe.Type(ctx, key)
+		ttlCmds[i] = pipe.PTTL(ctx, key)
+	}
+	readTime := time.Now()
+	if _, err := pipe.Exec(ctx); err != nil && !isReplyError(err) {
+		return nil, fmt.Errorf("failed to read key types: %w", err)
+	}
+
+	sizes := make([]int64, len(keys))
+	if largeDataThreshold > 0 {
+		var err error
+		if sizes, err = collectionSizes(ctx, rc, keys, typeCmds); err != nil {
+			return nil, err
+		}
+	}
+
+	// Second round trip: the value of every key, using the read command for its type
+	pipe = rc.Pipeline()
+	valueCmds := make([]redis.Cmder, len(keys))
+	for i, key := range keys {
+		records[i].Key = key
+
+		keyType, err := typeCmds[i].Result()
+		if err != nil {
+			records[i].Err = fmt.Errorf("failed to get key type for %s: %w", key, err)
+			continue
+		}
+		records[i].Type = keyType
+
+		if ttl, err := ttlCmds[i].Result(); err == nil {
+			expireAt, exists, _ := expireAtFromPTTL(readTime, ttl)
+			if !exists {
+				// Key expired between TYPE and PTTL
+				records[i].Type = "none"
+				continue
+			}
+			records[i].ExpireAt = expireAt
+		}
+
+		if largeDataThreshold > 0 && sizes[i] > largeDataThreshold {
+			records[i].Oversized = true
+			records[i].Size = sizes[i]
+			continue
+		}
+
+		switch keyType {
+		case "string":
+			valueCmds[i] = pipe.Get(ctx, key)
+		case "hash":
+			valueCmds[i] = pipe.HGetAll(ctx, key)
+		case "list":
+			valueCmds[i] = pipe.LRange(ctx, key, 0, -1)
+		case "set":
+			valueCmds[i] = pipe.SMembers(ctx, key)
+		case "zset":
+			valueCmds[i] = pipe.ZRangeWithScores(ctx, key, 0, -1)
+		case "stream":
+			// Streams need several dependent commands and are read after the pipeline
+		case "none":
+			// Key expired or was deleted since it was scanned
+		default:
+			records[i].Err = fmt.Errorf("unsupported key type: %s", keyType)
+		}
+	}
+
+	if pipe.Len() > 0 {
+		if _, err := pipe.Exec(ctx); err != nil && !isReplyError(err) && !errors.Is(err, redis.Nil) {
+			return nil, fmt.Errorf("failed to read key values: %w", err)
+		}
+	}
+
+	for i, cmd := range valueCmds {
+		if records[i].Type == "stream" && records[i].Err == nil {
+			stream, err := readStream(ctx, rc, records[i].Key)
+			if err != nil {
+				records[i].Err = err
+				continue
+			}
+			records[i].Value = stream
+			continue
+		}
+
+		if cmd == nil {
+			continue
+		}
+
+		if err := cmd.Err(); err != nil {
+			if errors.Is(err, redis.Nil) {
+				// Key disappeared between the two round trips
+				records[i].Type = "none"
+				continue
+			}
+			records[i].Err = fmt.Errorf("failed to get value for %s: %w", records[i].Key, err)
+			continue
+		}
+
+		switch c := cmd.(type) {
+		case *redis.StringCmd:
+			records[i].Value = c.Val()
+		case *redis.MapStringStringCmd:
+			records[i].Value = c.Val()
+		case *redis.StringSliceCmd:
+			records[i].Value = c.Val()
+		case *redis.ZSliceCmd:
+			records[i].Value = c.Val()
+		}
+	}
+
+	return records, nil
+}
+
+// collectionSizes measures the collections among keys, leaving the size of other keys at 0
+func collectionSizes(ctx context.Context, rc redis.Cmdable, keys []string, typeCmds []*redis.StatusCmd) ([]int64, error) {
+	sizes := make([]int64, len(keys))
+
+	pipe := rc.Pipeline()
+	sizeCmds := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		switch typeCmds[i].Val() {
+		case "hash":
+			sizeCmds[i] = pipe.HLen(ctx, key)
+		case "list":
+			sizeCmds[i] = pipe.LLen(ctx, key)
+		case "set":
+			sizeCmds[i] = pipe.SCard(ctx, key)
+		case "zset":
+			sizeCmds[i] = pipe.ZCard(ctx, key)
+		}
+	}
+
+	if pipe.Len() == 0 {
+		return sizes, nil
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil && !isReplyError(err) {
+		return nil, fmt.Errorf("failed to read collection sizes: %w", err)
+	}
+
+	for i, cmd := range sizeCmds {
+		if cmd != nil {
+			sizes[i] = cmd.Val()
+		}
+	}
+
+	return sizes, nil
+}
+
+// setBatch writes every record and its expiry in a MULTI/EXEC transaction of its own, so
+// that no key is visible on the target without its expiry and a record the target rejects
+// does not abort the others
+func setBatch(ctx context.Context, rc redis.Cmdable, records []KeyRecord) ([]error, error) {
+	if cluster, ok := rc.(*redis.ClusterClient); ok {
+		return setBatchByNode(ctx, cluster, records)
+	}
+	return setBatchTx(ctx, rc, records)
+}
+
+// setBatchByNode groups records by the master node owning their key and writes each
+// group in one pipeline on that node. Records redirected with MOVED or ASK, because their
+// slot is being migrated, are written again through the cluster client, which follows
+// the redirection.
+func setBatchByNode(ctx context.Context, cluster *redis.ClusterClient, records []KeyRecord) ([]error, error) {
+	nodes := make(map[*redis.Client][]int)
+	for i, record := range records {
+		node, err := cluster.MasterForKey(ctx, record.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write batch: %w", err)
+		}
+		nodes[node] = append(nodes[node], i)
+	}
+
+	errs := make([]error, len(records))
+	var mu sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+
+	for node, indexes := range nodes {
+		wg.Add(1)
+		go func(node *redis.Client, indexes []int) {
+			defer wg.Done()
+
+			group := make([]KeyRecord, len(indexes))
+			for j, i := range indexes {
+				group[j] = records[i]
+			}
+			groupErrs, err := setBatchTx(ctx, node, group)
+			if err == nil {
+				for j := range group {
+					if isRedirect(groupErrs[j]) {
+						groupErrs[j] = setRecordTx(ctx, cluster, group[j])
+					}
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			for j, i := range indexes {
+				errs[i] = groupErrs[j]
+			}
+		}(node, indexes)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return errs, nil
+}
+
+// setRecordTx writes a record and its expiry in a MULTI/EXEC transaction of its own
+func setRecordTx(ctx context.Context, rc redis.Cmdable, record KeyRecord) error {
+	pipe := rc.TxPipeline()
+	if err := queueWrite(ctx, pipe, record.Key, record.Value); err != nil {
+		return err
+	}
+	if !record.ExpireAt.IsZero() {
+		pipe.PExpireAt(ctx, record.Key, record.ExpireAt)
+	}
+	if pipe.Len() == 0 {
+		return nil
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to set value for %s: %w", record.Key, err)
+	}
+	return nil
+}
+
+// isRedirect reports whether err is a MOVED or ASK redirection to another cluster node
+func isRedirect(err error) bool {
+	var replyErr redis.Error
+	if !errors.As(err, &replyErr) {
+		return false
+	}
+	message := replyErr.Error()
+	return strings.HasPrefix(message, "MOVED ") || strings.HasPrefix(message, "ASK ")
+}
+
+// setBatchTx sends one MULTI/EXEC transaction per record, all in a single pipeline
+func setBatchTx(ctx context.Context, rc redis.Cmdable, records []KeyRecord) ([]error, error) {
+	errs := make([]error, len(records))
+	queued := make([][]*redis.Cmd, len(records))
+	execs := make([]*redis.Cmd, len(records))
+
+	pipe := rc.Pipeline()
+	for i, record := range records {
+		writes, err := recordWrites(ctx, rc, record)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		// Typed commands cannot parse the QUEUED replies of a transaction, so the
+		// commands are sent with their arguments only
+		pipe.Do(ctx, "MULTI")
+		for _, args := range writes {
+			queued[i] = append(queued[i], pipe.Do(ctx, args...))
+		}
+		execs[i] = pipe.Do(ctx, "EXEC")
+	}
+
+	if pipe.Len() == 0 {
+		return errs, nil
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil && !isReplyError(err) {
+		return nil, fmt.Errorf("failed to write batch: %w", err)
+	}
+
+	for i, exec := range execs {
+		if exec == nil {
+			continue
+		}
+		if err := transactionError(queued[i], exec); err != nil {
+			errs[i] = fmt.Errorf("failed to set value for %s: %w", records[i].Key, err)
+		}
+	}
+
+	return errs, nil
+}
+
+// recordWrites returns the arguments of the commands that write a record and its expiry
+func recordWrites(ctx context.Context, rc redis.Cmdable, record KeyRecord) ([][]interface{}, error) {
+	// The commands are only queued to build their arguments, the pipeline is never run
+	pipe := rc.Pipeline().(*redis.Pipeline)
+	if err := queueWrite(ctx, pipe, record.Key, record.Value); err != nil {
+		return nil, err
+	}
+	if !record.ExpireAt.IsZero() {
+		pipe.PExpireAt(ctx, record.Key, record.ExpireAt)
+	}
+
+	cmds := pipe.Cmds()
+	writes := make([][]interface{}, len(cmds))
+	for i, cmd := range cmds {
+		writes[i] = cmd.Args()
+	}
+	return writes, nil
+}
+
+// transactionError returns why a transaction failed: a command the server refused to
+// queue, which discards the transaction, or the first command that failed when run
+func transactionError(queued []*redis.Cmd, exec *redis.Cmd) error {
+	for _, cmd := range queued {
+		if err := cmd.Err(); err != nil {
+			return err
+		}
+	}
+	if err := exec.Err(); err != nil {
+		return err
+	}
+
+	results, _ := exec.Val().([]interface{})
+	for _, result := range results {
+		if err, ok := result.(error); ok {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wrappingClient decorates a DatabaseClient the way the engine's recoverable client does
+type wrappingClient struct {
+	DatabaseClient
+}
+
+func (w *wrappingClient) Unwrap() DatabaseClient {
+	return w.DatabaseClient
+}
+
+func TestDescribeValue(t *testing.T) {
+	testCases := []struct {
+		name         string
+		value        interface{}
+		expectedType string
+		expectedSize int64
+	}{
+		{"string", "hello", "string", 5},
+		{"hash", map[string]string{"a": "1", "b": "2"}, "hash", 2},
+		{"list", []string{"a", "b", "c"}, "list", 3},
+		{"set", []interface{}{"a"}, "set", 1},
+		{"sorted set", []redis.Z{{Score: 1, Member: "a"}, {Score: 2, Member: "b"}}, "zset", 2},
+		{"stream", &StreamValue{Entries: []StreamEntry{{ID: "1-0", Fields: []string{"f", "v"}}}}, "stream", 1},
+		{"merged hash", MergeValue{map[string]string{"a": "1"}}, "hash", 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dataType, dataSize, err := describeValue(tc.value)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedType, dataType)
+			assert.Equal(t, tc.expectedSize, dataSize)
+		})
+	}
+
+	_, _, err := describeValue(42)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported value type")
+}
+
+func TestQueueWrite_QueuesCommandsPerType(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:0"})
+	defer rdb.Close()
+	ctx := context.Background()
+
+	testCases := []struct {
+		name         string
+		value        interface{}
+		expectedCmds int
+	}{
+		{"string uses SET", "value", 1},
+		{"hash clears and sets fields", map[string]string{"f": "v"}, 2},
+		{"list clears and pushes each element", []string{"a", "b"}, 3},
+		{"empty list", []string{}, 3},
+		{"set clears and adds members", []interface{}{"a", "b"}, 2},
+		{"sorted set clears and adds members", []redis.Z{{Score: 1, Member: "a"}}, 2},
+		{"merged hash only sets fields", MergeValue{map[string]string{"f": "v"}}, 1},
+		{"merged list appends in one push", MergeValue{[]string{"a", "b"}}, 1},
+		{"merged empty set queues nothing", MergeValue{[]interface{}{}}, 0},
+		{"merged sorted set only adds members", MergeValue{[]redis.Z{{Score: 1, Member: "a"}}}, 1},
+		{"merged string is replaced", MergeValue{"value"}, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pipe := rdb.Pipeline()
+			require.NoError(t, queueWrite(ctx, pipe, "key", tc.value))
+			assert.Equal(t, tc.expectedCmds, pipe.Len())
+		})
+	}
+
+	pipe := rdb.Pipeline()
+	assert.Error(t, queueWrite(ctx, pipe, "key", 3.14))
+	assert.Equal(t, 0, pipe.Len())
+}
+
+func TestRecordWrites_IncludeExpiry(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:0"})
+	defer rdb.Close()
+	ctx := context.Background()
+	expireAt := time.UnixMilli(1700000000000)
+
+	writes, err := recordWrites(ctx, rdb, KeyRecord{Key: "key", Value: map[string]string{"f": "v"}, ExpireAt: expireAt})
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{
+		{"del", "key"},
+		{"hmset", "key", "f", "v"},
+		{"pexpireat", "key", int64(1700000000000)},
+	}, writes)
+
+	writes, err = recordWrites(ctx, rdb, KeyRecord{Key: "key", Value: "value"})
+	require.NoError(t, err)
+	assert.Len(t, writes, 1, "keys without expiry are only written")
+
+	_, err = recordWrites(ctx, rdb, KeyRecord{Key: "key", Value: 3.14})
+	assert.Error(t, err)
+}
+
+func TestTransactionError(t *testing.T) {
+	ctx := context.Background()
+	newCmd := func(val interface{}, err error) *redis.Cmd {
+		cmd := redis.NewCmd(ctx)
+		cmd.SetVal(val)
+		cmd.SetErr(err)
+		return cmd
+	}
+	queued := func() []*redis.Cmd { return []*redis.Cmd{newCmd("QUEUED", nil), newCmd("QUEUED", nil)} }
+
+	assert.NoError(t, transactionError(queued(), newCmd([]interface{}{"OK", int64(1)}, nil)))
+
+	// A command that failed when run is reported inside the EXEC reply
+	wrongType := errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	assert.Equal(t, wrongType, transactionError(queued(), newCmd([]interface{}{"OK", wrongType}, nil)))
+
+	// A command refused when queued discards the transaction
+	refused := queued()
+	refused[1].SetErr(errors.New("OOM command not allowed when used memory > 'maxmemory'"))
+	err := transactionError(refused, newCmd(nil, errors.New("EXECABORT Transaction discarded because of previous errors.")))
+	assert.ErrorContains(t, err, "OOM", "the cause is reported rather than EXECABORT")
+}
+
+func TestSupports_LooksThroughDecorators(t *testing.T) {
+	redisClient := NewRedisClient(NewClientConfig("localhost", 6379, "", 0))
+
+	assert.True(t, Supports[BatchClient](redisClient))
+	assert.True(t, Supports[BatchClient](&wrappingClient{redisClient}))
+	assert.True(t, Supports[BatchClient](&wrappingClient{&wrappingClient{redisClient}}))
+
+	// A decorator that implements the capability itself does not hide an unsupported client
+	var plain DatabaseClient = &wrappingClient{}
+	assert.False(t, Supports[BatchClient](plain))
+}
+
+func TestIsReplyError(t *testing.T) {
+	assert.False(t, isReplyError(errors.New("dial tcp: connection refused")))
+	assert.False(t, isReplyError(redis.Nil))
+	assert.False(t, isReplyError(context.DeadlineExceeded))
+}
+
+func TestBatchMethods_NotConnected(t *testing.T) {
+	redisClient := NewRedisClient(NewClientConfig("localhost", 6379, "", 0))
+	_, err := redisClient.GetBatch([]string{"a"})
+	assert.Error(t, err)
+	_, err = redisClient.SetBatch([]KeyRecord{{Key: "a", Value: "b"}})
+	assert.Error(t, err)
+	_, err = redisClient.ExistsBatch([]string{"a"})
+	assert.Error(t, err)
+
+	valkeyClient := NewValkeyClient(NewClientConfig("localhost", 6380, "", 0))
+	_, err = valkeyClient.GetBatch([]string{"a"})
+	assert.Error(t, err)
+	_, err = valkeyClient.SetBatch([]KeyRecord{{Key: "a", Value: "b"}})
+	assert.Error(t, err)
+	_, err = valkeyClient.ExistsBatch([]string{"a"})
+	assert.Error(t, err)
+}
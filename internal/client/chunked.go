@@ -0,0 +1,191 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ChunkedClient is implemented by clients that can transfer a collection a chunk at a time,
+// so that collections too large to hold in memory can still be migrated
+type ChunkedClient interface {
+	// KeySizeAndExpiry returns the number of elements in a collection (or bytes in a
+	// string) together with the absolute expiry of the key, as ExpiryClient.GetExpireAt
+	// returns it, so that a collection's size is known without an extra round trip
+	KeySizeAndExpiry(key, keyType string) (int64, time.Time, bool, error)
+
+	// ReadChunk reads up to about count elements of a hash, set, sorted set or list starting
+	// at cursor, which is 0 for the first chunk. The chunk has the same Go type as GetValue
+	// returns for the key type. The returned cursor is 0 once the collection is exhausted.
+	// Hashes, sets and sorted sets are read with HSCAN/SSCAN/ZSCAN, so elements may be
+	// returned more than once if the key is modified while it is read.
+	ReadChunk(key, keyType string, cursor uint64, count int64) (interface{}, uint64, error)
+
+	// AppendChunk adds a chunk returned by ReadChunk to a key without clearing it first
+	AppendChunk(key, keyType string, chunk interface{}) error
+
+	// RenameKey atomically renames a key, replacing the destination if it exists
+	RenameKey(key, newKey string) error
+
+	// DeleteKey removes a key
+	DeleteKey(key string) error
+}
+
+// TempKey returns a temporary key name in the same cluster hash slot as key.
+// It returns false if no such name can be built, which is only the case for
+// keys containing '}' but no valid hash tag.
+func TempKey(key, suffix string) (string, bool) {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			// The key has a hash tag, which is kept by appending to it
+			return key + suffix, true
+		}
+	}
+
+	if strings.IndexByte(key, '}') >= 0 {
+		return "", false
+	}
+
+	// Use the whole key as the hash tag
+	return "{" + key + "}" + suffix, true
+}
+
+// readChunk reads one chunk of a collection using SCAN-family commands or an LRANGE window
+func readChunk(ctx context.Context, rc redis.Cmdable, key, keyType string, cursor uint64, count int64) (interface{}, uint64, error) {
+	switch keyType {
+	case "hash":
+		pairs, next, err := rc.HScan(ctx, key, cursor, "", count).Result()
+		if err != nil {
+			return nil, 0, err
+		}
+		fields := make(map[string]string, len(pairs)/2)
+		for i := 0; i+1 < len(pairs); i += 2 {
+			fields[pairs[i]] = pairs[i+1]
+		}
+		return fields, next, nil
+	case "set":
+		members, next, err := rc.SScan(ctx, key, cursor, "", count).Result()
+		if err != nil {
+			return nil, 0, err
+		}
+		return members, next, nil
+	case "zset":
+		pairs, next, err := rc.ZScan(ctx, key, cursor, "", count).Result()
+		if err != nil {
+			return nil, 0, err
+		}
+		members := make([]redis.Z, 0, len(pairs)/2)
+		for i := 0; i+1 < len(pairs); i += 2 {
+			score, err := strconv.ParseFloat(pairs[i+1], 64)
+			if err != nil {
+				return nil, 0, fmt.Errorf("invalid score for member %s: %w", pairs[i], err)
+			}
+			members = append(members, redis.Z{Score: score, Member: pairs[i]})
+		}
+		return members, next, nil
+	case "list":
+		// The cursor is the index of the first element of the window
+		start := int64(cursor)
+		elements, err := rc.LRange(ctx, key, start, start+count-1).Result()
+		if err != nil {
+			return nil, 0, err
+		}
+		if int64(len(elements)) < count {
+			return elements, 0, nil
+		}
+		return elements, uint64(start + count), nil
+	default:
+		return nil, 0, fmt.Errorf("chunked transfer not supported for key type: %s", keyType)
+	}
+}
+
+// appendChunk adds the elements of a chunk to a collection
+func appendChunk(ctx context.Context, rc redis.Cmdable, key, keyType string, chunk interface{}) error {
+	switch v := chunk.(type) {
+	case map[string]string:
+		if len(v) == 0 {
+			return nil
+		}
+		return rc.HSet(ctx, key, v).Err()
+	case []string:
+		if len(v) == 0 {
+			return nil
+		}
+		values := make([]interface{}, len(v))
+		for i, element := range v {
+			values[i] = element
+		}
+		if keyType == "set" {
+			return rc.SAdd(ctx, key, values...).Err()
+		}
+		return rc.RPush(ctx, key, values...).Err()
+	case []redis.Z:
+		if len(v) == 0 {
+			return nil
+		}
+		return rc.ZAdd(ctx, key, v...).Err()
+	default:
+		return fmt.Errorf("unsupported chunk type: %T", chunk)
+	}
+}
+
+// keySize returns the number of elements in a collection or the length of a string
+func keySize(ctx context.Context, rc redis.Cmdable, key, keyType string) (int64, error) {
+	cmd := sizeCmd(ctx, rc, key, keyType)
+	if cmd == nil {
+		return 0, nil
+	}
+	return cmd.Result()
+}
+
+// sizeCmd runs, or queues on a pipeline, the command returning the number of elements in
+// a collection or the length of a string. It returns nil for other types.
+func sizeCmd(ctx context.Context, rc redis.Cmdable, key, keyType string) *redis.IntCmd {
+	switch keyType {
+	case "string":
+		return rc.StrLen(ctx, key)
+	case "hash":
+		return rc.HLen(ctx, key)
+	case "list":
+		return rc.LLen(ctx, key)
+	case "set":
+		return rc.SCard(ctx, key)
+	case "zset":
+		return rc.ZCard(ctx, key)
+	case "stream":
+		return rc.XLen(ctx, key)
+	default:
+		return nil
+	}
+}
+
+// keySizeAndExpiry reads the size of a key from rc and its absolute expiry from primary,
+// in a single pipeline unless rc is a replica of primary
+func keySizeAndExpiry(ctx context.Context, rc, primary redis.Cmdable, key, keyType string) (int64, time.Time, bool, error) {
+	if rc != primary {
+		size, err := keySize(ctx, rc, key, keyType)
+		if err != nil {
+			return 0, time.Time{}, false, fmt.Errorf("failed to get size of %s: %w", key, err)
+		}
+		expireAt, exists, err := readExpireAt(ctx, primary, key)
+		return size, expireAt, exists, err
+	}
+
+	pipe := rc.Pipeline()
+	size := sizeCmd(ctx, pipe, key, keyType)
+	ttl := pipe.PTTL(ctx, key)
+	readTime := time.Now()
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, time.Time{}, false, fmt.Errorf("failed to get size and TTL of %s: %w", key, err)
+	}
+
+	expireAt, exists, err := expireAtFromPTTL(readTime, ttl.Val())
+	if size == nil {
+		return 0, expireAt, exists, err
+	}
+	return size.Val(), expireAt, exists, err
+}
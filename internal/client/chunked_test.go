@@ -0,0 +1,46 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTempKey(t *testing.T) {
+	testCases := []struct {
+		name     string
+		key      string
+		expected string
+		ok       bool
+	}{
+		{"plain key becomes the hash tag", "user:1", "{user:1}:tmp", true},
+		{"existing hash tag is kept", "{user:1}:profile", "{user:1}:profile:tmp", true},
+		{"empty braces are not a hash tag", "a{}b", "{a{}b}:tmp", false},
+		{"stray opening brace", "a{b", "{a{b}:tmp", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tempKey, ok := TempKey(tc.key, ":tmp")
+			assert.Equal(t, tc.ok, ok)
+			if tc.ok {
+				assert.Equal(t, tc.expected, tempKey)
+			}
+		})
+	}
+}
+
+func TestChunkedMethods_NotConnected(t *testing.T) {
+	for _, c := range []ChunkedClient{
+		NewRedisClient(NewClientConfig("localhost", 6379, "", 0)),
+		NewValkeyClient(NewClientConfig("localhost", 6380, "", 0)),
+	} {
+		_, _, _, err := c.KeySizeAndExpiry("a", "hash")
+		assert.Error(t, err)
+		_, _, err = c.ReadChunk("a", "hash", 0, 10)
+		assert.Error(t, err)
+		assert.Error(t, c.AppendChunk("a", "hash", map[string]string{"f": "v"}))
+		assert.Error(t, c.RenameKey("a", "b"))
+		assert.Error(t, c.DeleteKey("a"))
+	}
+}
@@ -2,6 +2,8 @@ package client
 
 import (
 	"context"
+	"net"
+	"strconv"
 	"time"
 
 	"github.com/kinyelo/redis-valkey-migration/internal/config"
@@ -22,7 +24,7 @@ type DatabaseClient interface {
 	// GetKeysByPattern retrieves keys matching a specific pattern
 	GetKeysByPattern(pattern string) ([]string, error)
 
-	// GetKeyType returns the data type of a key (string, hash, list, set, zset)
+	// GetKeyType returns the data type of a key (string, hash, list, set, zset, stream)
 	GetKeyType(key string) (string, error)
 
 	// GetValue retrieves the value for a key, handling all Redis data types
@@ -54,6 +56,36 @@ type ClientConfig struct {
 	OperationTimeout  time.Duration
 	LargeDataTimeout  time.Duration
 	TimeoutConfig     *config.TimeoutConfig
+
+	// Addrs lists the seed nodes of a cluster as host:port. Host and Port are used when empty.
+	Addrs []string
+	// Cluster connects to a cluster, discovering its nodes from the seed nodes
+	Cluster bool
+	// ReadFromReplicas sends scans and key reads to replicas instead of masters (cluster only)
+	ReadFromReplicas bool
+
+	// SentinelMaster is the name of the master to look up through the Sentinels in
+	// SentinelAddrs. When set, Host, Port and Addrs are not used.
+	SentinelMaster string
+	SentinelAddrs  []string
+
+	// Replica is a replica as host:port that scans and value reads are sent to
+	Replica string
+
+	// RDBFile is an RDB snapshot file read by RDBFileClient instead of a server
+	RDBFile string
+
+	// CommandFile is a file of RESP commands written by RESPFileClient instead of a server
+	CommandFile string
+
+	// TLS holds the TLS settings of the connection
+	TLS config.TLSConfig
+
+	// Username is the ACL user to authenticate as; empty for the default user
+	Username string
+	// Credentials supplies the username and password for every new connection. When
+	// set, it takes precedence over Username and Password.
+	Credentials CredentialsProvider
 }
 
 // DefaultTimeout is the default connection timeout
@@ -94,9 +126,28 @@ func NewClientConfigFromDatabaseConfig(dbConfig *config.DatabaseConfig, timeoutC
 		OperationTimeout:  dbConfig.OperationTimeout,
 		LargeDataTimeout:  dbConfig.LargeDataTimeout,
 		TimeoutConfig:     timeoutConfig,
+		Addrs:             dbConfig.SeedAddrs(),
+		Cluster:           dbConfig.Cluster,
+		ReadFromReplicas:  dbConfig.ReadFromReplicas,
+		TLS:               dbConfig.TLS,
+		SentinelMaster:    dbConfig.SentinelMaster,
+		SentinelAddrs:     dbConfig.Sentinels,
+		Replica:           dbConfig.Replica,
+		RDBFile:           dbConfig.RDBFile,
+		CommandFile:       dbConfig.CommandFile,
+		Username:          dbConfig.Username,
+		Credentials:       NewCredentialsProvider(dbConfig),
 	}
 }
 
+// SeedAddrs returns the addresses to connect to: Addrs, or Host and Port if Addrs is empty
+func (c *ClientConfig) SeedAddrs() []string {
+	if len(c.Addrs) > 0 {
+		return c.Addrs
+	}
+	return []string{net.JoinHostPort(c.Host, strconv.Itoa(c.Port))}
+}
+
 // Context returns a context with timeout for database operations
 func (c *ClientConfig) Context() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), c.OperationTimeout)
@@ -144,6 +195,14 @@ func (c *ClientConfig) getOperationTimeout(dataType string, dataSize int64) time
 	return baseTimeout
 }
 
+// largeDataThreshold returns the size above which collections are transferred in chunks, or 0 if not configured
+func (c *ClientConfig) largeDataThreshold() int64 {
+	if c.TimeoutConfig == nil {
+		return 0
+	}
+	return c.TimeoutConfig.LargeDataThreshold
+}
+
 // IsLargeData checks if the data size exceeds the large data threshold
 func (c *ClientConfig) IsLargeData(dataSize int64) bool {
 	if c.TimeoutConfig == nil {
@@ -0,0 +1,178 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// clusterMaxRedirects is how many MOVED and ASK redirections a command follows before failing
+const clusterMaxRedirects = 8
+
+// newUniversalClient creates a cluster client when config.Cluster is set, a Sentinel
+// failover client when config.SentinelMaster is set and a standalone client otherwise.
+// The cluster client routes every key command to the node owning its hash slot and
+// splits pipelines per node. The failover client asks Sentinel for the current master
+// whenever it opens a connection and drops its connections to the old master when
+// Sentinel announces a failover.
+func newUniversalClient(config *ClientConfig) (redis.UniversalClient, error) {
+	tlsConfig, err := newTLSConfig(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := config.SeedAddrs()
+
+	var credentials func(ctx context.Context) (string, string, error)
+	if config.Credentials != nil {
+		credentials = config.Credentials.Credentials
+	}
+
+	if config.SentinelMaster != "" {
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:                 config.SentinelMaster,
+			SentinelAddrs:              config.SentinelAddrs,
+			Username:                   config.Username,
+			Password:                   config.Password,
+			CredentialsProviderContext: credentials,
+			DB:                         config.Database,
+			TLSConfig:                  tlsConfig,
+		}), nil
+	}
+
+	if config.Cluster {
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:                      addrs,
+			Username:                   config.Username,
+			Password:                   config.Password,
+			CredentialsProviderContext: credentials,
+			TLSConfig:                  tlsConfig,
+			// Read-only commands go to a replica of the shard when one is available
+			ReadOnly: config.ReadFromReplicas,
+			// Keys keep moving between nodes while a cluster is being resharded
+			MaxRedirects: clusterMaxRedirects,
+		}), nil
+	}
+
+	return redis.NewClient(&redis.Options{
+		Addr:                       addrs[0],
+		Username:                   config.Username,
+		Password:                   config.Password,
+		CredentialsProviderContext: credentials,
+		DB:                         config.Database,
+		TLSConfig:                  tlsConfig,
+	}), nil
+}
+
+// scanKeys returns the keys matching pattern. On a cluster every master, or one replica
+// of every shard when replicas is set, is scanned in parallel.
+func scanKeys(ctx context.Context, uc redis.UniversalClient, pattern string, replicas bool) ([]string, error) {
+	cluster, ok := uc.(*redis.ClusterClient)
+	if !ok {
+		return scanNode(ctx, uc, pattern)
+	}
+
+	var mu sync.Mutex
+	var keys []string
+	// A key can be reported by two nodes while its slot is being migrated
+	seen := make(map[string]bool)
+	collect := func(ctx context.Context, node *redis.Client) error {
+		nodeKeys, err := scanNode(ctx, node, pattern)
+		if err != nil {
+			return fmt.Errorf("node %s: %w", node.Options().Addr, err)
+		}
+
+		mu.Lock()
+		for _, key := range nodeKeys {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+		mu.Unlock()
+		return nil
+	}
+
+	if !replicas {
+		if err := cluster.ForEachMaster(ctx, collect); err != nil {
+			return nil, err
+		}
+		return keys, nil
+	}
+
+	slots, err := cluster.ClusterSlots(ctx).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster slots: %w", err)
+	}
+	replicaAddrs, masterAddrs := shardScanTargets(slots)
+
+	if err := cluster.ForEachSlave(ctx, func(ctx context.Context, node *redis.Client) error {
+		if !replicaAddrs[node.Options().Addr] {
+			return nil
+		}
+		return collect(ctx, node)
+	}); err != nil {
+		return nil, err
+	}
+
+	if len(masterAddrs) > 0 {
+		if err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			if !masterAddrs[node.Options().Addr] {
+				return nil
+			}
+			return collect(ctx, node)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+// scanNode scans the keys matching pattern on a single server
+func scanNode(ctx context.Context, rc redis.Cmdable, pattern string) ([]string, error) {
+	var keys []string
+	iter := rc.Scan(ctx, 0, pattern, 0).Iterator()
+
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// shardScanTargets picks the node to scan for every shard of a cluster: its first replica,
+// or its master if it has no replica. Each shard is scanned exactly once, so that no key
+// is returned twice.
+func shardScanTargets(slots []redis.ClusterSlot) (replicas, masters map[string]bool) {
+	replicas = make(map[string]bool)
+	masters = make(map[string]bool)
+	seen := make(map[string]bool)
+
+	for _, slot := range slots {
+		if len(slot.Nodes) == 0 {
+			continue
+		}
+
+		// A master serving several slot ranges is listed once per range
+		master := slot.Nodes[0].Addr
+		if seen[master] {
+			continue
+		}
+		seen[master] = true
+
+		if len(slot.Nodes) > 1 {
+			replicas[slot.Nodes[1].Addr] = true
+		} else {
+			masters[master] = true
+		}
+	}
+
+	return replicas, masters
+}
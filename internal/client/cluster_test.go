@@ -0,0 +1,189 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/config"
+)
+
+func TestShardScanTargets(t *testing.T) {
+	slots := []redis.ClusterSlot{
+		{Start: 0, End: 5460, Nodes: []redis.ClusterNode{{Addr: "10.0.0.1:6379"}, {Addr: "10.0.0.4:6379"}, {Addr: "10.0.0.5:6379"}}},
+		{Start: 5461, End: 10922, Nodes: []redis.ClusterNode{{Addr: "10.0.0.2:6379"}}},
+		{Start: 10923, End: 12000, Nodes: []redis.ClusterNode{{Addr: "10.0.0.3:6379"}, {Addr: "10.0.0.6:6379"}}},
+		// The same shard serving a second slot range
+		{Start: 12001, End: 16383, Nodes: []redis.ClusterNode{{Addr: "10.0.0.3:6379"}, {Addr: "10.0.0.6:6379"}}},
+		{Start: 0, End: 0},
+	}
+
+	replicas, masters := shardScanTargets(slots)
+
+	assert.Equal(t, map[string]bool{"10.0.0.4:6379": true, "10.0.0.6:6379": true}, replicas)
+	assert.Equal(t, map[string]bool{"10.0.0.2:6379": true}, masters, "shards without replicas are scanned on their master")
+}
+
+func TestNewUniversalClient(t *testing.T) {
+	standalone := NewClientConfig("localhost", 6379, "", 2)
+	standaloneClient, err := newUniversalClient(standalone)
+	require.NoError(t, err)
+	defer standaloneClient.Close()
+	assert.IsType(t, &redis.Client{}, standaloneClient)
+
+	cluster := NewClientConfig("localhost", 7000, "", 0)
+	cluster.Cluster = true
+	cluster.ReadFromReplicas = true
+	clusterClient, err := newUniversalClient(cluster)
+	require.NoError(t, err)
+	defer clusterClient.Close()
+	assert.IsType(t, &redis.ClusterClient{}, clusterClient)
+	assert.True(t, clusterClient.(*redis.ClusterClient).Options().ReadOnly)
+	assert.Equal(t, []string{"localhost:7000"}, clusterClient.(*redis.ClusterClient).Options().Addrs)
+}
+
+func TestRedisClient_Connect_ClusterInvalidPort(t *testing.T) {
+	clientConfig := NewClientConfig("localhost", 99999, "", 0)
+	clientConfig.ConnectionTimeout = 1 * time.Second
+	clientConfig.Cluster = true
+	client := NewRedisClient(clientConfig)
+
+	err := client.Connect()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to connect to Redis")
+	assert.NoError(t, client.Disconnect())
+}
+
+func TestNewClientConfigFromDatabaseConfig_Cluster(t *testing.T) {
+	clientConfig := NewClientConfigFromDatabaseConfig(&config.DatabaseConfig{
+		Host:             "redis-cluster",
+		Port:             7000,
+		Cluster:          true,
+		ReadFromReplicas: true,
+	}, nil)
+
+	assert.True(t, clientConfig.Cluster)
+	assert.True(t, clientConfig.ReadFromReplicas)
+}
+
+func TestSetBatchByNode_ConnectionError(t *testing.T) {
+	rdb := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{"localhost:0"}, MaxRedirects: -1})
+	defer rdb.Close()
+
+	records := []KeyRecord{
+		{Key: "foo", Type: "string", Value: "1"},
+		{Key: "bar", Type: "string", Value: "2"},
+		{Key: "{foo}.bar", Type: "string", Value: "3"},
+	}
+
+	errs, err := setBatchByNode(context.Background(), rdb, records)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to write batch")
+	assert.Nil(t, errs)
+
+	errs, err = setBatchByNode(context.Background(), rdb, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, errs)
+}
+
+func TestRunTransaction_ClusterUnsupported(t *testing.T) {
+	rdb := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{"localhost:0"}, MaxRedirects: -1})
+	defer rdb.Close()
+
+	errs, err := runTransaction(context.Background(), rdb, [][]interface{}{{"SET", "a", "1"}, {"SET", "b", "1"}})
+	assert.ErrorIs(t, err, ErrUnsupported)
+	assert.Nil(t, errs)
+}
+
+func TestIsRedirect(t *testing.T) {
+	assert.True(t, isRedirect(fmt.Errorf("failed to set value for foo: %w", replyError("MOVED 3999 127.0.0.1:6381"))))
+	assert.True(t, isRedirect(replyError("ASK 3999 127.0.0.1:6381")))
+	assert.False(t, isRedirect(replyError("ERR wrong number of arguments")))
+	assert.False(t, isRedirect(nil))
+	assert.False(t, isRedirect(errors.New("MOVED 3999 127.0.0.1:6381")), "only server replies are redirections")
+	assert.False(t, isRedirect(fmt.Errorf("failed to set value for foo: %w", errors.New("ERR wrong number of arguments"))))
+}
+
+func TestClientConfig_SeedAddrs(t *testing.T) {
+	clientConfig := NewClientConfig("valkey", 7000, "", 0)
+	assert.Equal(t, []string{"valkey:7000"}, clientConfig.SeedAddrs())
+
+	clientConfig.Addrs = []string{"valkey-1:7000", "valkey-2:7000"}
+	assert.Equal(t, []string{"valkey-1:7000", "valkey-2:7000"}, clientConfig.SeedAddrs())
+
+	// IPv6 hosts are bracketed
+	assert.Equal(t, []string{"[::1]:7000"}, NewClientConfig("::1", 7000, "", 0).SeedAddrs())
+}
+
+func TestNewClientConfigFromDatabaseConfig_SeedNodes(t *testing.T) {
+	clientConfig := NewClientConfigFromDatabaseConfig(&config.DatabaseConfig{
+		Host:    "valkey-1",
+		Port:    7000,
+		Cluster: true,
+		Nodes:   []string{"valkey-1:7000", "valkey-2:7000", "valkey-3:7000"},
+	}, nil)
+
+	assert.Equal(t, []string{"valkey-1:7000", "valkey-2:7000", "valkey-3:7000"}, clientConfig.SeedAddrs())
+}
+
+func TestValkeyClient_Connect_ClusterInvalidPort(t *testing.T) {
+	clientConfig := NewClientConfig("localhost", 99999, "", 0)
+	clientConfig.ConnectionTimeout = 1 * time.Second
+	clientConfig.Cluster = true
+	client := NewValkeyClient(clientConfig)
+
+	err := client.Connect()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to connect to Valkey")
+	assert.NoError(t, client.Disconnect())
+}
+
+func TestNewUniversalClient_Sentinel(t *testing.T) {
+	clientConfig := NewClientConfig("localhost", 6379, "", 2)
+	clientConfig.SentinelMaster = "mymaster"
+	clientConfig.SentinelAddrs = []string{"sentinel-1:26379", "sentinel-2:26379"}
+
+	uc, err := newUniversalClient(clientConfig)
+	require.NoError(t, err)
+	defer uc.Close()
+
+	failover, ok := uc.(*redis.Client)
+	require.True(t, ok)
+	assert.Equal(t, "FailoverClient", failover.Options().Addr)
+	assert.Equal(t, 2, failover.Options().DB)
+}
+
+func TestNewClientConfigFromDatabaseConfig_Sentinel(t *testing.T) {
+	clientConfig := NewClientConfigFromDatabaseConfig(&config.DatabaseConfig{
+		Host:           "localhost",
+		Port:           6379,
+		SentinelMaster: "mymaster",
+		Sentinels:      []string{"sentinel-1:26379"},
+	}, nil)
+
+	assert.Equal(t, "mymaster", clientConfig.SentinelMaster)
+	assert.Equal(t, []string{"sentinel-1:26379"}, clientConfig.SentinelAddrs)
+}
+
+func TestReconnect_FailureKeepsConnection(t *testing.T) {
+	clientConfig := NewClientConfig("localhost", 99999, "", 0)
+	clientConfig.ConnectionTimeout = 500 * time.Millisecond
+
+	redisClient := NewRedisClient(clientConfig)
+	err := redisClient.Reconnect()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to reconnect to Redis")
+	assert.Nil(t, redisClient.conn(), "a failed reconnect does not replace the connection")
+
+	valkeyClient := NewValkeyClient(clientConfig)
+	err = valkeyClient.Reconnect()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to reconnect to Valkey")
+	assert.Nil(t, valkeyClient.conn())
+}
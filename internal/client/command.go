@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CommandClient is implemented by clients that can run arbitrary commands, which is how
+// the write commands of a replication stream are replayed on the target
+type CommandClient interface {
+	// RunCommands runs the commands in order using a single pipeline. The returned slice
+	// holds one error per command; the returned error is only set when the whole
+	// pipeline failed. On a cluster, commands without a key run on an arbitrary master.
+	RunCommands(commands [][]interface{}) ([]error, error)
+
+	// RunTransaction runs the commands in order in a single MULTI/EXEC transaction, so
+	// that they all run or none does. The returned slice holds one error per command.
+	// If the server refused to queue some of them, none ran, their errors are set and
+	// ErrTransactionDiscarded is returned. Any other returned error leaves it unknown
+	// whether the transaction ran. Cluster clients do not support it.
+	RunTransaction(commands [][]interface{}) ([]error, error)
+}
+
+// ErrTransactionDiscarded is returned by RunTransaction when the server refused to queue
+// some of the commands, in which case none of them ran
+var ErrTransactionDiscarded = errors.New("transaction discarded because of refused commands")
+
+// runCommands runs the commands using a single pipeline and collects their reply errors
+func runCommands(ctx context.Context, rc redis.Cmdable, commands [][]interface{}) ([]error, error) {
+	pipe := rc.Pipeline()
+	cmds := make([]*redis.Cmd, len(commands))
+	for i, args := range commands {
+		cmds[i] = pipe.Do(ctx, args...)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil && !isReplyError(err) && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	errs := make([]error, len(cmds))
+	for i, cmd := range cmds {
+		if err := cmd.Err(); err != nil && !errors.Is(err, redis.Nil) {
+			errs[i] = err
+		}
+	}
+	return errs, nil
+}
+
+// runTransaction runs the commands in a single MULTI/EXEC transaction and collects their
+// reply errors
+func runTransaction(ctx context.Context, rc redis.Cmdable, commands [][]interface{}) ([]error, error) {
+	if _, ok := rc.(*redis.ClusterClient); ok {
+		return nil, fmt.Errorf("transactions of arbitrary commands are not supported on a cluster: %w", ErrUnsupported)
+	}
+
+	// Typed commands cannot parse the QUEUED replies of a transaction, so the commands
+	// are sent with their arguments only
+	pipe := rc.Pipeline()
+	pipe.Do(ctx, "MULTI")
+	queued := make([]*redis.Cmd, len(commands))
+	for i, args := range commands {
+		queued[i] = pipe.Do(ctx, args...)
+	}
+	exec := pipe.Do(ctx, "EXEC")
+
+	if _, err := pipe.Exec(ctx); err != nil && !isReplyError(err) && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	errs := make([]error, len(commands))
+	discarded := false
+	for i, cmd := range queued {
+		if err := cmd.Err(); err != nil {
+			errs[i] = err
+			discarded = true
+		}
+	}
+	if discarded {
+		return errs, ErrTransactionDiscarded
+	}
+	if err := exec.Err(); err != nil {
+		return nil, fmt.Errorf("transaction failed: %w", err)
+	}
+
+	results, _ := exec.Val().([]interface{})
+	for i, result := range results {
+		if err, ok := result.(error); ok && i < len(errs) {
+			errs[i] = err
+		}
+	}
+	return errs, nil
+}
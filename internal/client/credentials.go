@@ -0,0 +1,95 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/config"
+)
+
+// CredentialsProvider supplies the username and password a connection authenticates
+// with. It is called for every new connection, so short-lived secrets are fetched again
+// when a connection is re-established after they expire.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (username, password string, err error)
+}
+
+// StaticCredentials authenticates with a fixed username and password
+type StaticCredentials struct {
+	Username string
+	Password string
+}
+
+// Credentials returns the fixed username and password
+func (c StaticCredentials) Credentials(ctx context.Context) (string, string, error) {
+	return c.Username, c.Password, nil
+}
+
+// FileCredentials reads the password from a file, ignoring surrounding whitespace
+type FileCredentials struct {
+	Username string
+	Path     string
+}
+
+// Credentials reads the password file
+func (c FileCredentials) Credentials(ctx context.Context) (string, string, error) {
+	data, err := os.ReadFile(c.Path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read password file: %w", err)
+	}
+	return c.Username, strings.TrimSpace(string(data)), nil
+}
+
+// EnvCredentials reads the password from an environment variable
+type EnvCredentials struct {
+	Username string
+	Variable string
+}
+
+// Credentials reads the password variable
+func (c EnvCredentials) Credentials(ctx context.Context) (string, string, error) {
+	password, ok := os.LookupEnv(c.Variable)
+	if !ok {
+		return "", "", fmt.Errorf("password environment variable %s is not set", c.Variable)
+	}
+	return c.Username, password, nil
+}
+
+// CommandCredentials runs a shell command and uses its standard output, without the
+// trailing newline, as the password
+type CommandCredentials struct {
+	Username string
+	Command  string
+}
+
+// Credentials runs the password command
+func (c CommandCredentials) Credentials(ctx context.Context) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.Command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", "", fmt.Errorf("password command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return c.Username, strings.TrimRight(stdout.String(), "\r\n"), nil
+}
+
+// NewCredentialsProvider returns the provider for the password source configured in
+// dbConfig: a password file, environment variable or command, or the password itself
+func NewCredentialsProvider(dbConfig *config.DatabaseConfig) CredentialsProvider {
+	switch {
+	case dbConfig.PasswordFile != "":
+		return FileCredentials{Username: dbConfig.Username, Path: dbConfig.PasswordFile}
+	case dbConfig.PasswordEnv != "":
+		return EnvCredentials{Username: dbConfig.Username, Variable: dbConfig.PasswordEnv}
+	case dbConfig.PasswordCommand != "":
+		return CommandCredentials{Username: dbConfig.Username, Command: dbConfig.PasswordCommand}
+	default:
+		return StaticCredentials{Username: dbConfig.Username, Password: dbConfig.Password}
+	}
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/config"
+)
+
+func TestNewCredentialsProvider(t *testing.T) {
+	testCases := []struct {
+		name     string
+		dbConfig config.DatabaseConfig
+		expected CredentialsProvider
+	}{
+		{"password", config.DatabaseConfig{Username: "app", Password: "secret"}, StaticCredentials{Username: "app", Password: "secret"}},
+		{"file", config.DatabaseConfig{Username: "app", PasswordFile: "/run/secret"}, FileCredentials{Username: "app", Path: "/run/secret"}},
+		{"env", config.DatabaseConfig{Username: "app", PasswordEnv: "SECRET"}, EnvCredentials{Username: "app", Variable: "SECRET"}},
+		{"command", config.DatabaseConfig{Username: "app", PasswordCommand: "echo secret"}, CommandCredentials{Username: "app", Command: "echo secret"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, NewCredentialsProvider(&tc.dbConfig))
+		})
+	}
+}
+
+func TestFileCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(path, []byte("first\n"), 0600))
+	provider := FileCredentials{Username: "app", Path: path}
+
+	username, password, err := provider.Credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "app", username)
+	assert.Equal(t, "first", password)
+
+	// A rotated secret is picked up by the next connection
+	require.NoError(t, os.WriteFile(path, []byte("second"), 0600))
+	_, password, err = provider.Credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "second", password)
+
+	_, _, err = FileCredentials{Path: path + ".missing"}.Credentials(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to read password file")
+}
+
+func TestEnvCredentials(t *testing.T) {
+	t.Setenv("RVM_TEST_SECRET", "secret")
+
+	username, password, err := EnvCredentials{Username: "app", Variable: "RVM_TEST_SECRET"}.Credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "app", username)
+	assert.Equal(t, "secret", password)
+
+	_, _, err = EnvCredentials{Variable: "RVM_TEST_SECRET_MISSING"}.Credentials(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "RVM_TEST_SECRET_MISSING is not set")
+}
+
+func TestCommandCredentials(t *testing.T) {
+	username, password, err := CommandCredentials{Username: "app", Command: "printf 'tok en\\n'"}.Credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "app", username)
+	assert.Equal(t, "tok en", password)
+
+	_, _, err = CommandCredentials{Command: "echo denied >&2; exit 3"}.Credentials(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "password command failed")
+	assert.Contains(t, err.Error(), "denied")
+}
+
+func TestNewClientConfigFromDatabaseConfig_Credentials(t *testing.T) {
+	clientConfig := NewClientConfigFromDatabaseConfig(&config.DatabaseConfig{
+		Host:         "redis",
+		Port:         6379,
+		Username:     "migration",
+		PasswordFile: "/run/secrets/redis",
+	}, nil)
+
+	assert.Equal(t, "migration", clientConfig.Username)
+	assert.Equal(t, FileCredentials{Username: "migration", Path: "/run/secrets/redis"}, clientConfig.Credentials)
+}
+
+func TestRedisClient_Connect_AuthenticatesWithProvider(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	// Capture the handshake of the first connection, then drop it
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 4096)
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _ := conn.Read(buf)
+		received <- string(buf[:n])
+	}()
+
+	t.Setenv("RVM_TEST_SECRET", "provider-secret")
+	addr := listener.Addr().(*net.TCPAddr)
+	clientConfig := NewClientConfig("127.0.0.1", addr.Port, "ignored-password", 0)
+	clientConfig.ConnectionTimeout = 500 * time.Millisecond
+	clientConfig.Credentials = EnvCredentials{Username: "migration", Variable: "RVM_TEST_SECRET"}
+	client := NewRedisClient(clientConfig)
+	defer client.Disconnect()
+
+	assert.Error(t, client.Connect())
+
+	select {
+	case handshake := <-received:
+		assert.Contains(t, handshake, "migration")
+		assert.Contains(t, handshake, "provider-secret")
+		assert.NotContains(t, handshake, "ignored-password")
+	case <-time.After(3 * time.Second):
+		t.Fatal("no connection was made")
+	}
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CutoverClient is implemented by clients that can freeze writes on the server while
+// the last changes are moved to the target
+type CutoverClient interface {
+	// PauseWrites blocks write commands with CLIENT PAUSE WRITE, on every master of a
+	// cluster, for at most timeout. Reads keep being served, and the server unpauses
+	// by itself once timeout has passed.
+	PauseWrites(timeout time.Duration) error
+
+	// UnpauseWrites lets write commands through again before the pause times out
+	UnpauseWrites() error
+
+	// ReplicationOffset returns the master_repl_offset of the server, the position of
+	// its last write in the replication stream. It returns ErrUnsupported for a cluster,
+	// where every master has a stream of its own.
+	ReplicationOffset() (int64, error)
+}
+
+// pauseWrites pauses write commands on every master for timeout
+func pauseWrites(ctx context.Context, uc redis.UniversalClient, timeout time.Duration) error {
+	ms := timeout.Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+
+	pause := func(ctx context.Context, rc redis.UniversalClient) error {
+		return rc.Do(ctx, "CLIENT", "PAUSE", ms, "WRITE").Err()
+	}
+	if cluster, ok := uc.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			if err := pause(ctx, node); err != nil {
+				return fmt.Errorf("node %s: %w", node.Options().Addr, err)
+			}
+			return nil
+		})
+	}
+	return pause(ctx, uc)
+}
+
+// unpauseWrites lifts the pause on every master
+func unpauseWrites(ctx context.Context, uc redis.UniversalClient) error {
+	if cluster, ok := uc.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			if err := node.ClientUnpause(ctx).Err(); err != nil {
+				return fmt.Errorf("node %s: %w", node.Options().Addr, err)
+			}
+			return nil
+		})
+	}
+	return uc.ClientUnpause(ctx).Err()
+}
+
+// replicationOffset reads master_repl_offset from the replication section of INFO
+func replicationOffset(ctx context.Context, rc redis.Cmdable) (int64, error) {
+	if _, ok := rc.(*redis.ClusterClient); ok {
+		return 0, ErrUnsupported
+	}
+
+	info, err := readInfo(ctx, rc, "replication")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read replication info: %w", err)
+	}
+	offset, err := strconv.ParseInt(info["master_repl_offset"], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid master_repl_offset %q", info["master_repl_offset"])
+	}
+	return offset, nil
+}
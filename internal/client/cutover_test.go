@@ -0,0 +1,96 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// commandServer replies +OK to every command except HELLO and reports the commands
+func commandServer(t *testing.T) (string, <-chan []string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan []string, 100)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+
+		for {
+			args, err := readCommand(reader)
+			if err != nil {
+				return
+			}
+			if strings.EqualFold(args[0], "hello") {
+				fmt.Fprint(conn, "-ERR unknown command 'HELLO'\r\n")
+				continue
+			}
+			received <- args
+			fmt.Fprint(conn, "+OK\r\n")
+		}
+	}()
+
+	return listener.Addr().String(), received
+}
+
+func TestPauseWrites(t *testing.T) {
+	addr, received := commandServer(t)
+	uc := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1})
+	defer uc.Close()
+
+	ctx := context.Background()
+	require.NoError(t, pauseWrites(ctx, uc, 1500*time.Millisecond))
+	require.NoError(t, pauseWrites(ctx, uc, 0))
+	require.NoError(t, unpauseWrites(ctx, uc))
+
+	var commands []string
+	for len(commands) < 3 {
+		select {
+		case args := <-received:
+			// Skip the connection setup go-redis sends
+			if strings.EqualFold(args[0], "client") && strings.Contains(strings.ToUpper(args[1]), "PAUSE") {
+				commands = append(commands, strings.ToUpper(strings.Join(args, " ")))
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("received %v", commands)
+		}
+	}
+
+	assert.Equal(t, []string{
+		"CLIENT PAUSE 1500 WRITE",
+		"CLIENT PAUSE 1 WRITE",
+		"CLIENT UNPAUSE",
+	}, commands)
+}
+
+func TestReplicationOffset(t *testing.T) {
+	offset, err := replicationOffset(context.Background(), infoCmdable{info: "# Replication\r\nrole:master\r\nmaster_repl_offset:5000\r\n"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5000), offset)
+
+	_, err = replicationOffset(context.Background(), infoCmdable{info: "# Replication\r\nrole:master\r\n"})
+	assert.ErrorContains(t, err, "invalid master_repl_offset")
+
+	_, err = replicationOffset(context.Background(), infoCmdable{err: errors.New("connection refused")})
+	assert.ErrorContains(t, err, "failed to read replication info")
+
+	cluster := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{"127.0.0.1:1"}})
+	defer cluster.Close()
+	_, err = replicationOffset(context.Background(), cluster)
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
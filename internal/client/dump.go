@@ -0,0 +1,219 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrDumpIncompatible is returned for keys whose DUMP payload was rejected by the target,
+// usually because the source serializes with a newer RDB version than the target understands
+var ErrDumpIncompatible = errors.New("DUMP payload rejected by target")
+
+// DumpRecord holds the serialized form of a key as produced by DUMP, with the metadata
+// needed to restore it exactly
+type DumpRecord struct {
+	Key      string
+	Type     string
+	Payload  string
+	ExpireAt time.Time     // Absolute expiry time, zero if the key has no TTL
+	IdleTime time.Duration // LRU idle time, -1 if the source uses an LFU eviction policy
+	Freq     int64         // LFU access frequency, -1 if the source does not use an LFU eviction policy
+	Err      error         // Per-key read error, nil if the key was dumped successfully
+}
+
+// RDBVersion returns the RDB version stored in the payload footer, or 0 if the payload is too short
+func (r *DumpRecord) RDBVersion() int {
+	return payloadRDBVersion(r.Payload)
+}
+
+// payloadRDBVersion returns the RDB version stored in the footer of a DUMP payload, or 0
+// if the payload is too short
+func payloadRDBVersion(payload string) int {
+	// The payload ends with a 2 byte little endian RDB version and an 8 byte CRC64
+	if len(payload) < 10 {
+		return 0
+	}
+	footer := payload[len(payload)-10:]
+	return int(binary.LittleEndian.Uint16([]byte(footer[:2])))
+}
+
+// DumpRestorer is implemented by clients that can copy keys in their serialized form
+type DumpRestorer interface {
+	// DumpKeys serializes every key with DUMP using a single pipeline. Keys that could not
+	// be dumped have their Err field set and keys that no longer exist have type "none".
+	// The returned error is only set when the whole batch failed.
+	DumpKeys(keys []string) ([]DumpRecord, error)
+
+	// RestoreKeys recreates every record with RESTORE ... REPLACE ABSTTL using a single
+	// pipeline, keeping its expiry and LRU/LFU information. The returned slice holds one
+	// error per record, wrapping ErrDumpIncompatible if the target rejected the payload;
+	// the returned error is only set when the whole batch failed.
+	RestoreKeys(records []DumpRecord) ([]error, error)
+}
+
+// RDBVersionProber is implemented by clients that can tell which RDB version their
+// server serializes values with, which is also the newest version it can RESTORE
+type RDBVersionProber interface {
+	// RDBVersion dumps a key and returns the RDB version of its payload. An existing key
+	// is dumped if there is one, otherwise a temporary key that is created and deleted
+	// in the same transaction.
+	RDBVersion() (int, error)
+}
+
+// rdbVersion reads the RDB version the server serializes values with from a DUMP payload
+func rdbVersion(ctx context.Context, uc redis.UniversalClient) (int, error) {
+	key, err := uc.RandomKey(ctx).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, fmt.Errorf("failed to probe RDB version: %w", err)
+	}
+	if err == nil {
+		payload, err := uc.Dump(ctx, key).Result()
+		if err == nil {
+			return payloadRDBVersion(payload), nil
+		}
+		if !errors.Is(err, redis.Nil) {
+			return 0, fmt.Errorf("failed to probe RDB version: %w", err)
+		}
+		// The key expired or was deleted since RANDOMKEY
+	}
+
+	probe := "redis-valkey-migration:rdb-probe:" + rand.Text()
+	pipe := uc.TxPipeline()
+	pipe.Set(ctx, probe, "", 0)
+	dump := pipe.Dump(ctx, probe)
+	pipe.Del(ctx, probe)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to probe RDB version: %w", err)
+	}
+	return payloadRDBVersion(dump.Val()), nil
+}
+
+// dumpKeys serializes keys with DUMP, reading their type, expiry and access information first
+func dumpKeys(ctx context.Context, rc redis.Cmdable, keys []string) ([]DumpRecord, error) {
+	records := make([]DumpRecord, len(keys))
+
+	// OBJECT, TYPE and PTTL do not touch the key, but DUMP does, so it has to come last
+	pipe := rc.Pipeline()
+	idleCmds := make([]*redis.DurationCmd, len(keys))
+	freqCmds := make([]*redis.IntCmd, len(keys))
+	typeCmds := make([]*redis.StatusCmd, len(keys))
+	ttlCmds := make([]*redis.DurationCmd, len(keys))
+	dumpCmds := make([]*redis.StringCmd, len(keys))
+	for i, key := range keys {
+		idleCmds[i] = pipe.ObjectIdleTime(ctx, key)
+		freqCmds[i] = pipe.ObjectFreq(ctx, key)
+		// Route the OBJECT subcommands by their key on a cluster
+		idleCmds[i].SetFirstKeyPos(2)
+		freqCmds[i].SetFirstKeyPos(2)
+		typeCmds[i] = pipe.Type(ctx, key)
+		ttlCmds[i] = pipe.PTTL(ctx, key)
+		dumpCmds[i] = pipe.Dump(ctx, key)
+	}
+
+	readTime := time.Now()
+	if _, err := pipe.Exec(ctx); err != nil && !isReplyError(err) && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("failed to dump keys: %w", err)
+	}
+
+	for i, key := range keys {
+		records[i] = DumpRecord{Key: key, IdleTime: -1, Freq: -1}
+
+		keyType, err := typeCmds[i].Result()
+		if err != nil {
+			records[i].Err = fmt.Errorf("failed to get key type for %s: %w", key, err)
+			continue
+		}
+		records[i].Type = keyType
+		if keyType == "none" {
+			// Key expired or was deleted since it was scanned
+			continue
+		}
+
+		payload, err := dumpCmds[i].Result()
+		if errors.Is(err, redis.Nil) {
+			// Key disappeared between TYPE and DUMP
+			records[i].Type = "none"
+			continue
+		}
+		if err != nil {
+			records[i].Err = fmt.Errorf("failed to dump %s: %w", key, err)
+			continue
+		}
+		records[i].Payload = payload
+
+		// PTTL is relative to when the server ran it, so anchor it to the time the
+		// pipeline was sent; this never extends the key's lifetime
+		if ttl, err := ttlCmds[i].Result(); err == nil && ttl > 0 {
+			records[i].ExpireAt = readTime.Add(ttl)
+		}
+
+		// Only one of these succeeds, depending on the source's maxmemory-policy
+		if idle, err := idleCmds[i].Result(); err == nil {
+			records[i].IdleTime = idle
+		}
+		if freq, err := freqCmds[i].Result(); err == nil {
+			records[i].Freq = freq
+		}
+	}
+
+	return records, nil
+}
+
+// restoreKeys recreates keys from their DUMP payloads in a single pipelined round trip
+func restoreKeys(ctx context.Context, rc redis.Cmdable, records []DumpRecord) ([]error, error) {
+	errs := make([]error, len(records))
+
+	pipe := rc.Pipeline()
+	cmds := make([]*redis.Cmd, len(records))
+	for i, record := range records {
+		var ttl int64
+		if !record.ExpireAt.IsZero() {
+			ttl = record.ExpireAt.UnixMilli()
+		}
+
+		args := []interface{}{"RESTORE", record.Key, ttl, record.Payload, "REPLACE", "ABSTTL"}
+
+		// RESTORE accepts either IDLETIME or FREQ, never both
+		if record.Freq >= 0 {
+			args = append(args, "FREQ", record.Freq)
+		} else if record.IdleTime >= 0 {
+			args = append(args, "IDLETIME", int64(record.IdleTime/time.Second))
+		}
+
+		cmds[i] = pipe.Do(ctx, args...)
+	}
+
+	if len(cmds) == 0 {
+		return errs, nil
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil && !isReplyError(err) {
+		return nil, fmt.Errorf("failed to restore keys: %w", err)
+	}
+
+	for i, cmd := range cmds {
+		err := cmd.Err()
+		if err == nil {
+			continue
+		}
+		if isDumpPayloadRejected(err) {
+			errs[i] = fmt.Errorf("%w: %s (RDB version %d): %v", ErrDumpIncompatible, records[i].Key, records[i].RDBVersion(), err)
+			continue
+		}
+		errs[i] = fmt.Errorf("failed to restore %s: %w", records[i].Key, err)
+	}
+
+	return errs, nil
+}
+
+// isDumpPayloadRejected reports whether err is the target refusing a payload it cannot load
+func isDumpPayloadRejected(err error) bool {
+	return isReplyError(err) && strings.Contains(err.Error(), "payload version or checksum are wrong")
+}
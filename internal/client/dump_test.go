@@ -0,0 +1,54 @@
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// replyError mimics an error reply returned by the server
+type replyError string
+
+func (e replyError) Error() string { return string(e) }
+
+func (replyError) RedisError() {}
+
+func TestDumpRecord_RDBVersion(t *testing.T) {
+	// DUMP payload layout for a string "bar": type, value, RDB version 11 and an 8 byte CRC64
+	record := DumpRecord{Payload: "\x00\x03bar\x0b\x00\x8a\x9b\x8b\xa6\x9f\xd5\x0c\x9c"}
+	assert.Equal(t, 11, record.RDBVersion())
+
+	record = DumpRecord{Payload: "short"}
+	assert.Equal(t, 0, record.RDBVersion())
+}
+
+func TestIsDumpPayloadRejected(t *testing.T) {
+	assert.True(t, isDumpPayloadRejected(replyError("ERR DUMP payload version or checksum are wrong")))
+	assert.False(t, isDumpPayloadRejected(replyError("BUSYKEY Target key name already exists.")))
+	assert.False(t, isDumpPayloadRejected(errors.New("payload version or checksum are wrong")))
+}
+
+func TestDumpRestoreMethods_NotConnected(t *testing.T) {
+	redisClient := NewRedisClient(NewClientConfig("localhost", 6379, "", 0))
+	_, err := redisClient.DumpKeys([]string{"a"})
+	require.Error(t, err)
+	_, err = redisClient.RestoreKeys([]DumpRecord{{Key: "a"}})
+	require.Error(t, err)
+	_, err = redisClient.RDBVersion()
+	require.Error(t, err)
+
+	valkeyClient := NewValkeyClient(NewClientConfig("localhost", 6380, "", 0))
+	_, err = valkeyClient.DumpKeys([]string{"a"})
+	require.Error(t, err)
+	_, err = valkeyClient.RestoreKeys([]DumpRecord{{Key: "a"}})
+	require.Error(t, err)
+	_, err = valkeyClient.RDBVersion()
+	require.Error(t, err)
+
+	assert.True(t, Supports[DumpRestorer](redisClient))
+	assert.True(t, Supports[DumpRestorer](valkeyClient))
+	assert.True(t, Supports[RDBVersionProber](redisClient))
+	assert.True(t, Supports[RDBVersionProber](valkeyClient))
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ExpiryClient is implemented by clients that read and write key expiry as absolute,
+// millisecond-precise times, so that the time spent transferring a key does not
+// extend its lifetime
+type ExpiryClient interface {
+	// GetExpireAt returns the absolute expiry time of a key, computed from PTTL at read time.
+	// The time is zero if the key has no expiry; exists is false if the key does not exist.
+	GetExpireAt(key string) (expireAt time.Time, exists bool, err error)
+
+	// SetValueWithExpiry stores a value and its expiry with PEXPIREAT in a single MULTI/EXEC
+	// transaction, so the key is never visible without its expiry. A zero expireAt stores
+	// the value without expiry.
+	SetValueWithExpiry(key string, value interface{}, expireAt time.Time) error
+
+	// SetExpireAt sets the absolute expiry time of an existing key with PEXPIREAT
+	SetExpireAt(key string, expireAt time.Time) error
+}
+
+// readExpireAt reads the remaining time to live of a key with PTTL and converts it to an
+// absolute time. The request time is used as reference, so the result never extends the
+// key's lifetime.
+func readExpireAt(ctx context.Context, rc redis.Cmdable, key string) (time.Time, bool, error) {
+	readTime := time.Now()
+	ttl, err := rc.PTTL(ctx, key).Result()
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to get TTL for %s: %w", key, err)
+	}
+	return expireAtFromPTTL(readTime, ttl)
+}
+
+// expireAtFromPTTL converts a PTTL reply read at readTime to an absolute expiry time
+func expireAtFromPTTL(readTime time.Time, ttl time.Duration) (time.Time, bool, error) {
+	switch {
+	case ttl == -2:
+		// Key does not exist
+		return time.Time{}, false, nil
+	case ttl < 0:
+		// Key has no expiry
+		return time.Time{}, true, nil
+	default:
+		return readTime.Add(ttl), true, nil
+	}
+}
+
+// setValueWithExpiry writes a value and its expiry in a single transaction
+func setValueWithExpiry(ctx context.Context, rc redis.Cmdable, key string, value interface{}, expireAt time.Time) error {
+	pipe := rc.TxPipeline()
+	if err := queueWrite(ctx, pipe, key, value); err != nil {
+		return err
+	}
+	if !expireAt.IsZero() {
+		pipe.PExpireAt(ctx, key, expireAt)
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpireAtFromPTTL(t *testing.T) {
+	readTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		ttl      time.Duration
+		expected time.Time
+		exists   bool
+	}{
+		{"missing key", -2, time.Time{}, false},
+		{"no expiry", -1, time.Time{}, true},
+		{"millisecond precision is kept", 1500 * time.Millisecond, readTime.Add(1500 * time.Millisecond), true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expireAt, exists, err := expireAtFromPTTL(readTime, tc.ttl)
+			require.NoError(t, err)
+			assert.Equal(t, tc.exists, exists)
+			assert.Equal(t, tc.expected, expireAt)
+		})
+	}
+}
+
+func TestExpiryMethods_NotConnected(t *testing.T) {
+	for _, c := range []ExpiryClient{
+		NewRedisClient(NewClientConfig("localhost", 6379, "", 0)),
+		NewValkeyClient(NewClientConfig("localhost", 6380, "", 0)),
+	} {
+		_, _, err := c.GetExpireAt("a")
+		assert.Error(t, err)
+		assert.Error(t, c.SetValueWithExpiry("a", "v", time.Now().Add(time.Minute)))
+		assert.Error(t, c.SetExpireAt("a", time.Now().Add(time.Minute)))
+	}
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyspaceClient is implemented by clients that can list the logical databases holding
+// keys, so that every database of a server can be migrated in one run
+type KeyspaceClient interface {
+	// Keyspace returns the number of keys of every database that holds any, by database
+	// number. A cluster only has database 0.
+	Keyspace() (map[int]int64, error)
+}
+
+// keyspace reads the key counts of the databases from INFO keyspace, summed over every
+// master of a cluster
+func keyspace(ctx context.Context, uc redis.UniversalClient) (map[int]int64, error) {
+	if cluster, ok := uc.(*redis.ClusterClient); ok {
+		var mu sync.Mutex
+		counts := make(map[int]int64)
+		err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			nodeCounts, err := nodeKeyspace(ctx, node)
+			if err != nil {
+				return fmt.Errorf("node %s: %w", node.Options().Addr, err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for db, n := range nodeCounts {
+				counts[db] += n
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return counts, nil
+	}
+	return nodeKeyspace(ctx, uc)
+}
+
+// nodeKeyspace reads the key counts of the databases of a single server
+func nodeKeyspace(ctx context.Context, rc redis.Cmdable) (map[int]int64, error) {
+	info, err := readInfo(ctx, rc, "keyspace")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyspace info: %w", err)
+	}
+	return parseKeyspace(info)
+}
+
+// parseKeyspace parses the dbN:keys=...,expires=...,avg_ttl=... fields of INFO keyspace
+func parseKeyspace(info map[string]string) (map[int]int64, error) {
+	counts := make(map[int]int64)
+	for name, value := range info {
+		number, ok := strings.CutPrefix(name, "db")
+		if !ok {
+			continue
+		}
+		db, err := strconv.Atoi(number)
+		if err != nil {
+			continue
+		}
+
+		for _, field := range strings.Split(value, ",") {
+			if keys, ok := strings.CutPrefix(field, "keys="); ok {
+				n, err := strconv.ParseInt(keys, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid key count in keyspace info %s:%s", name, value)
+				}
+				if n > 0 {
+					counts[db] = n
+				}
+			}
+		}
+	}
+	return counts, nil
+}
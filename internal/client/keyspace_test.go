@@ -0,0 +1,28 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeKeyspace(t *testing.T) {
+	info := "# Keyspace\r\ndb0:keys=120,expires=3,avg_ttl=5000\r\ndb3:keys=7,expires=0,avg_ttl=0\r\ndb9:keys=0,expires=0,avg_ttl=0\r\n"
+
+	counts, err := nodeKeyspace(context.Background(), infoCmdable{info: info})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int64{0: 120, 3: 7}, counts, "empty databases are left out")
+
+	counts, err = nodeKeyspace(context.Background(), infoCmdable{info: "# Keyspace\r\n"})
+	require.NoError(t, err)
+	assert.Empty(t, counts)
+
+	_, err = nodeKeyspace(context.Background(), infoCmdable{info: "db1:keys=many\r\n"})
+	assert.ErrorContains(t, err, "invalid key count")
+
+	_, err = nodeKeyspace(context.Background(), infoCmdable{err: errors.New("connection refused")})
+	assert.ErrorContains(t, err, "failed to read keyspace info")
+}
@@ -0,0 +1,249 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyEvent is a change to a key reported by a keyevent notification
+type KeyEvent struct {
+	// Event is the notification type, such as set, hset, del, expired or rename_to
+	Event string
+	Key   string
+
+	// Err is set instead of Event and Key when the subscription was interrupted; changes
+	// made until it was restored were not reported
+	Err error
+}
+
+// NotificationClient is implemented by clients that can report changes to keys through
+// keyspace notifications
+type NotificationClient interface {
+	// EnableKeyNotifications makes sure the server publishes keyevent notifications for
+	// every key type, expiry and eviction, adding the missing classes to
+	// notify-keyspace-events with CONFIG SET. It returns ErrNotificationConfigUnavailable
+	// if the setting cannot be read, for example because CONFIG is disabled.
+	EnableKeyNotifications() error
+
+	// SubscribeKeyEvents subscribes to the keyevent notifications of the client's database,
+	// on every master of a cluster. Events are delivered on the returned channel until ctx
+	// is done; an interrupted subscription is restored automatically.
+	SubscribeKeyEvents(ctx context.Context) (<-chan KeyEvent, error)
+}
+
+// ErrNotificationConfigUnavailable is returned when notify-keyspace-events cannot be read,
+// so it is unknown whether keyspace notifications are enabled
+var ErrNotificationConfigUnavailable = errors.New("notify-keyspace-events cannot be read")
+
+// notifyKeyEventFlags are the notify-keyspace-events flags needed to see every change:
+// keyevent notifications (E) for generic, string, list, set, hash, sorted set, expired,
+// evicted and stream events. The A flag is an alias for all of the classes.
+const notifyKeyEventFlags = "Eg$lshzxet"
+
+// keyEventBuffer is how many events can be waiting to be consumed before the subscription
+// stops reading, leaving further messages in the socket buffers
+const keyEventBuffer = 10000
+
+// resubscribeDelay is how long to wait before restoring an interrupted subscription
+const resubscribeDelay = time.Second
+
+// missingNotifyFlags returns the flags of notifyKeyEventFlags that current does not enable
+func missingNotifyFlags(current string) string {
+	var missing strings.Builder
+	for _, flag := range notifyKeyEventFlags {
+		if strings.ContainsRune(current, flag) {
+			continue
+		}
+		if flag != 'E' && strings.ContainsRune(current, 'A') {
+			continue
+		}
+		missing.WriteRune(flag)
+	}
+	return missing.String()
+}
+
+// enableKeyNotifications enables the missing keyevent notification classes on the server,
+// or on every master of a cluster
+func enableKeyNotifications(ctx context.Context, uc redis.UniversalClient) error {
+	if cluster, ok := uc.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			if err := enableNodeNotifications(ctx, node); err != nil {
+				return fmt.Errorf("node %s: %w", node.Options().Addr, err)
+			}
+			return nil
+		})
+	}
+	return enableNodeNotifications(ctx, uc)
+}
+
+// enableNodeNotifications enables the missing keyevent notification classes on one server
+func enableNodeNotifications(ctx context.Context, rc redis.Cmdable) error {
+	config, err := rc.ConfigGet(ctx, "notify-keyspace-events").Result()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNotificationConfigUnavailable, err)
+	}
+
+	current := config["notify-keyspace-events"]
+	missing := missingNotifyFlags(current)
+	if missing == "" {
+		return nil
+	}
+
+	if err := rc.ConfigSet(ctx, "notify-keyspace-events", current+missing).Err(); err != nil {
+		return fmt.Errorf("keyspace notifications are set to %q and could not be enabled, add %q to notify-keyspace-events: %w", current, missing, err)
+	}
+	return nil
+}
+
+// subscribeKeyEvents subscribes to the keyevent notifications of database db, waiting at
+// most timeout for the subscription to be confirmed. The connection is looked up with conn
+// again whenever the subscription has to be restored, so that a client replaced by
+// Reconnect is picked up.
+func subscribeKeyEvents(ctx context.Context, conn func() redis.UniversalClient, db int, timeout time.Duration) (<-chan KeyEvent, error) {
+	pattern := fmt.Sprintf("__keyevent@%d__:*", db)
+	prefix := strings.TrimSuffix(pattern, "*")
+
+	subs, err := pSubscribeAll(ctx, conn(), pattern, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to keyspace notifications: %w", err)
+	}
+
+	events := make(chan KeyEvent, keyEventBuffer)
+	go func() {
+		defer close(events)
+		for {
+			err := receiveAll(ctx, subs, prefix, events)
+			if ctx.Err() != nil {
+				return
+			}
+			interrupted := KeyEvent{Err: fmt.Errorf("keyspace notification subscription interrupted: %w", err)}
+			if !sendKeyEvent(ctx, events, interrupted) {
+				return
+			}
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(resubscribeDelay):
+				}
+				if subs, err = pSubscribeAll(ctx, conn(), pattern, timeout); err == nil {
+					break
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// pSubscribeAll subscribes to pattern on the server, or on every master of a cluster,
+// since keyspace notifications are only published by the node owning the key
+func pSubscribeAll(ctx context.Context, uc redis.UniversalClient, pattern string, timeout time.Duration) ([]*redis.PubSub, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var subs []*redis.PubSub
+	if cluster, ok := uc.(*redis.ClusterClient); ok {
+		var mu sync.Mutex
+		err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			sub := node.PSubscribe(ctx, pattern)
+			mu.Lock()
+			subs = append(subs, sub)
+			mu.Unlock()
+			return nil
+		})
+		if err != nil {
+			closePubSubs(subs)
+			return nil, err
+		}
+	} else {
+		subs = append(subs, uc.PSubscribe(ctx, pattern))
+	}
+
+	// Wait for every subscription to be confirmed, so that no change made after
+	// this returns is missed
+	for _, sub := range subs {
+		if _, err := sub.Receive(ctx); err != nil {
+			closePubSubs(subs)
+			return nil, err
+		}
+	}
+
+	return subs, nil
+}
+
+// receiveAll forwards the notifications of every subscription to events until one of them
+// fails or ctx is done. The subscriptions are closed when it returns.
+func receiveAll(ctx context.Context, subs []*redis.PubSub, prefix string, events chan<- KeyEvent) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(subs))
+	for _, sub := range subs {
+		go func(sub *redis.PubSub) {
+			errs <- receiveKeyEvents(ctx, sub, prefix, events)
+		}(sub)
+	}
+
+	var err error
+	received := 0
+	select {
+	case err = <-errs:
+		received++
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	// Receive blocks until the connection is closed, whatever the context
+	cancel()
+	closePubSubs(subs)
+	for ; received < len(subs); received++ {
+		<-errs
+	}
+
+	return err
+}
+
+// receiveKeyEvents forwards the notifications of one subscription to events until it fails
+func receiveKeyEvents(ctx context.Context, sub *redis.PubSub, prefix string, events chan<- KeyEvent) error {
+	for {
+		msg, err := sub.Receive(ctx)
+		if err != nil {
+			return err
+		}
+
+		if m, ok := msg.(*redis.Message); ok {
+			event := KeyEvent{Event: strings.TrimPrefix(m.Channel, prefix), Key: m.Payload}
+			if !sendKeyEvent(ctx, events, event) {
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// sendKeyEvent delivers an event, returning false if ctx is done first
+func sendKeyEvent(ctx context.Context, events chan<- KeyEvent, event KeyEvent) bool {
+	select {
+	case events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// closePubSubs closes every subscription
+func closePubSubs(subs []*redis.PubSub) {
+	for _, sub := range subs {
+		sub.Close()
+	}
+}
@@ -0,0 +1,195 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMissingNotifyFlags(t *testing.T) {
+	testCases := []struct {
+		current string
+		missing string
+	}{
+		{"", "Eg$lshzxet"},
+		{"KEA", ""},
+		{"AE", ""},
+		{"Ex", "g$lshzet"},
+		{"KA", "E"},
+		{"Eg$lshzxet", ""},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.missing, missingNotifyFlags(tc.current), "current flags %q", tc.current)
+	}
+}
+
+// configCmdable replies to CONFIG GET and records CONFIG SET; every other command is unimplemented
+type configCmdable struct {
+	redis.Cmdable
+	value  string
+	getErr error
+	setErr error
+	set    string
+}
+
+func (c *configCmdable) ConfigGet(ctx context.Context, parameter string) *redis.MapStringStringCmd {
+	cmd := redis.NewMapStringStringCmd(ctx, "config", "get", parameter)
+	if c.getErr != nil {
+		cmd.SetErr(c.getErr)
+	} else {
+		cmd.SetVal(map[string]string{parameter: c.value})
+	}
+	return cmd
+}
+
+func (c *configCmdable) ConfigSet(ctx context.Context, parameter, value string) *redis.StatusCmd {
+	cmd := redis.NewStatusCmd(ctx, "config", "set", parameter, value)
+	if c.setErr != nil {
+		cmd.SetErr(c.setErr)
+	} else {
+		c.set = value
+	}
+	return cmd
+}
+
+func TestEnableNodeNotifications(t *testing.T) {
+	t.Run("adds missing flags", func(t *testing.T) {
+		rc := &configCmdable{value: "Kx"}
+		require.NoError(t, enableNodeNotifications(context.Background(), rc))
+		assert.Equal(t, "KxEg$lshzet", rc.set)
+	})
+
+	t.Run("already enabled", func(t *testing.T) {
+		rc := &configCmdable{value: "KEA"}
+		require.NoError(t, enableNodeNotifications(context.Background(), rc))
+		assert.Equal(t, "", rc.set, "CONFIG SET is not sent")
+	})
+
+	t.Run("config disabled", func(t *testing.T) {
+		rc := &configCmdable{getErr: errors.New("ERR unknown command 'CONFIG'")}
+		err := enableNodeNotifications(context.Background(), rc)
+		assert.ErrorIs(t, err, ErrNotificationConfigUnavailable)
+	})
+
+	t.Run("set denied", func(t *testing.T) {
+		rc := &configCmdable{setErr: errors.New("NOPERM this user has no permissions")}
+		err := enableNodeNotifications(context.Background(), rc)
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrNotificationConfigUnavailable)
+		assert.Contains(t, err.Error(), "add \"Eg$lshzxet\" to notify-keyspace-events")
+	})
+}
+
+// pubSubServer accepts one connection, confirms PSUBSCRIBE and then publishes the given
+// notifications as pmessage pushes
+func pubSubServer(t *testing.T, notifications [][2]string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+
+		for {
+			args, err := readCommand(reader)
+			if err != nil {
+				return
+			}
+
+			switch strings.ToLower(args[0]) {
+			case "psubscribe":
+				fmt.Fprintf(conn, "*3\r\n$10\r\npsubscribe\r\n$%d\r\n%s\r\n:1\r\n", len(args[1]), args[1])
+				for _, n := range notifications {
+					fmt.Fprintf(conn, "*4\r\n$8\r\npmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n",
+						len(args[1]), args[1], len(n[0]), n[0], len(n[1]), n[1])
+				}
+			case "hello":
+				fmt.Fprint(conn, "-ERR unknown command 'HELLO'\r\n")
+			default:
+				fmt.Fprint(conn, "+OK\r\n")
+			}
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// readCommand reads a command sent as a RESP array of bulk strings
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		if _, err := reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func TestSubscribeKeyEvents(t *testing.T) {
+	addr := pubSubServer(t, [][2]string{
+		{"__keyevent@3__:set", "user:1"},
+		{"__keyevent@3__:del", "user:2"},
+		{"__keyevent@3__:expired", "session:1"},
+	})
+
+	uc := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1})
+	defer uc.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := subscribeKeyEvents(ctx, func() redis.UniversalClient { return uc }, 3, 2*time.Second)
+	require.NoError(t, err)
+
+	var received []KeyEvent
+	for len(received) < 3 {
+		select {
+		case event := <-events:
+			require.NoError(t, event.Err)
+			received = append(received, event)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("received %d of 3 events", len(received))
+		}
+	}
+
+	assert.Equal(t, []KeyEvent{
+		{Event: "set", Key: "user:1"},
+		{Event: "del", Key: "user:2"},
+		{Event: "expired", Key: "session:1"},
+	}, received)
+
+	cancel()
+	for range events {
+		// Drain until the subscription closes the channel
+	}
+}
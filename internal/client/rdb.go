@@ -0,0 +1,855 @@
+package client
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RDB opcodes that precede keys or carry metadata
+const (
+	rdbOpSlotInfo      = 0xF4
+	rdbOpFunction2     = 0xF5
+	rdbOpFunctionPreGA = 0xF6
+	rdbOpModuleAux     = 0xF7
+	rdbOpIdle          = 0xF8
+	rdbOpFreq          = 0xF9
+	rdbOpAux           = 0xFA
+	rdbOpResizeDB      = 0xFB
+	rdbOpExpireTimeMs  = 0xFC
+	rdbOpExpireTime    = 0xFD
+	rdbOpSelectDB      = 0xFE
+	rdbOpEOF           = 0xFF
+)
+
+// RDB value types and encodings
+const (
+	rdbTypeString           = 0
+	rdbTypeList             = 1
+	rdbTypeSet              = 2
+	rdbTypeZSet             = 3
+	rdbTypeHash             = 4
+	rdbTypeZSet2            = 5
+	rdbTypeModule2          = 7
+	rdbTypeHashZipmap       = 9
+	rdbTypeListZiplist      = 10
+	rdbTypeSetIntset        = 11
+	rdbTypeZSetZiplist      = 12
+	rdbTypeHashZiplist      = 13
+	rdbTypeListQuicklist    = 14
+	rdbTypeStreamListpacks  = 15
+	rdbTypeHashListpack     = 16
+	rdbTypeZSetListpack     = 17
+	rdbTypeListQuicklist2   = 18
+	rdbTypeStreamListpacks2 = 19
+	rdbTypeSetListpack      = 20
+	rdbTypeStreamListpacks3 = 21
+	rdbTypeHashMetadata     = 24
+	rdbTypeHashListpackEx   = 25
+)
+
+// rdbMaxVersion is the newest RDB format version the parser reads (Redis 7.4)
+const rdbMaxVersion = 12
+
+// rdbReadChunk is the most bytes allocated at once for a string, so that a corrupt
+// length allocates no more than the bytes actually there
+const rdbReadChunk = 1 << 20
+
+// rdbMaxPrealloc is the most elements allocated ahead for a collection, whose count
+// cannot be checked against the snapshot size when the size is not known
+const rdbMaxPrealloc = 1024
+
+// rdbChecksumTable is the CRC-64/Jones table RDB checksums are computed with
+var rdbChecksumTable = crc64.MakeTable(0x95AC9329AC4BC9B5)
+
+// RDBEntry is a key read from an RDB snapshot. Value has the shape SetValue accepts for
+// the type. Keys of module types have the type "module" and no value.
+type RDBEntry struct {
+	DB    int
+	Key   string
+	Type  string
+	Value interface{}
+
+	// ExpireAt is the absolute expiry of the key, zero if the key has no expiry
+	ExpireAt time.Time
+}
+
+// RDBParser reads the keys of an RDB snapshot one at a time. Hash field expirations,
+// functions and module auxiliary data are skipped.
+type RDBParser struct {
+	r       *bufio.Reader
+	crc     uint64
+	version int
+	db      int
+	started bool
+	done    bool
+
+	// offset counts the bytes read, and entryOffset is the offset of the value type of
+	// the last key Next returned
+	offset      int64
+	entryOffset int64
+
+	// size is the number of bytes of the snapshot, -1 if it is not known
+	size int64
+
+	// Aux holds the auxiliary fields of the snapshot read so far, such as redis-ver
+	Aux map[string]string
+}
+
+// NewRDBParser creates a parser reading an RDB snapshot from r. The parser does not read
+// past the end of the snapshot when r is a *bufio.Reader.
+func NewRDBParser(r io.Reader) *RDBParser {
+	return NewRDBParserWithSize(r, -1)
+}
+
+// NewRDBParserWithSize creates a parser reading an RDB snapshot of size bytes from r,
+// which rejects lengths larger than the rest of the snapshot before allocating them.
+// size is -1 if it is not known.
+func NewRDBParserWithSize(r io.Reader, size int64) *RDBParser {
+	return &RDBParser{
+		r:    bufio.NewReader(r),
+		size: size,
+		Aux:  make(map[string]string),
+	}
+}
+
+// Next returns the next key of the snapshot, or io.EOF once the end of the snapshot has
+// been read and its checksum verified
+func (p *RDBParser) Next() (*RDBEntry, error) {
+	if p.done {
+		return nil, io.EOF
+	}
+
+	if !p.started {
+		if err := p.readHeader(); err != nil {
+			return nil, err
+		}
+		p.started = true
+	}
+
+	var expireAt time.Time
+	for {
+		op, err := p.readByte()
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case rdbOpEOF:
+			p.done = true
+			if err := p.readChecksum(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		case rdbOpSelectDB:
+			db, err := p.readLength()
+			if err != nil {
+				return nil, err
+			}
+			p.db = int(db)
+		case rdbOpResizeDB:
+			// Hash table size hints for the database and its expires
+			if err := p.skipLengths(2); err != nil {
+				return nil, err
+			}
+		case rdbOpSlotInfo:
+			if err := p.skipLengths(3); err != nil {
+				return nil, err
+			}
+		case rdbOpAux:
+			key, err := p.readString()
+			if err != nil {
+				return nil, err
+			}
+			value, err := p.readString()
+			if err != nil {
+				return nil, err
+			}
+			p.Aux[key] = value
+		case rdbOpExpireTimeMs:
+			ms, err := p.readMillisecondTime()
+			if err != nil {
+				return nil, err
+			}
+			expireAt = ms
+		case rdbOpExpireTime:
+			buf, err := p.read(4)
+			if err != nil {
+				return nil, err
+			}
+			expireAt = time.Unix(int64(int32(binary.LittleEndian.Uint32(buf))), 0)
+		case rdbOpFreq:
+			if _, err := p.readByte(); err != nil {
+				return nil, err
+			}
+		case rdbOpIdle:
+			if err := p.skipLengths(1); err != nil {
+				return nil, err
+			}
+		case rdbOpModuleAux:
+			if err := p.skipModuleAux(); err != nil {
+				return nil, err
+			}
+		case rdbOpFunction2:
+			// Functions are not keys and are left to be loaded on the target separately
+			if _, err := p.readBytes(); err != nil {
+				return nil, err
+			}
+		case rdbOpFunctionPreGA:
+			return nil, fmt.Errorf("RDB snapshot contains functions in a pre-release format")
+		default:
+			p.entryOffset = p.offset - 1
+			entry, err := p.readEntry(op)
+			if err != nil {
+				return nil, err
+			}
+			entry.ExpireAt = expireAt
+			return entry, nil
+		}
+	}
+}
+
+// readHeader reads and checks the magic string and format version
+func (p *RDBParser) readHeader() error {
+	header, err := p.read(9)
+	if err != nil {
+		return fmt.Errorf("failed to read RDB header: %w", err)
+	}
+	if string(header[:5]) != "REDIS" {
+		return fmt.Errorf("not an RDB snapshot: bad magic %q", header[:5])
+	}
+
+	version, err := strconv.Atoi(string(header[5:]))
+	if err != nil {
+		return fmt.Errorf("not an RDB snapshot: bad version %q", header[5:])
+	}
+	if version < 1 || version > rdbMaxVersion {
+		return fmt.Errorf("RDB version %d is not supported, the newest supported version is %d", version, rdbMaxVersion)
+	}
+	p.version = version
+	return nil
+}
+
+// readChecksum reads the checksum following the EOF opcode and compares it with the
+// checksum of everything read before. A zero checksum means checksums were disabled.
+func (p *RDBParser) readChecksum() error {
+	// Checksums were added in version 5
+	if p.version < 5 {
+		return nil
+	}
+
+	expected := p.crc
+	buf, err := p.read(8)
+	if err != nil {
+		return fmt.Errorf("failed to read RDB checksum: %w", err)
+	}
+	if checksum := binary.LittleEndian.Uint64(buf); checksum != 0 && checksum != expected {
+		return fmt.Errorf("RDB checksum mismatch: snapshot says %016x, computed %016x", checksum, expected)
+	}
+	return nil
+}
+
+// readEntry reads the key and value of a key of the given value type
+func (p *RDBParser) readEntry(valueType byte) (*RDBEntry, error) {
+	key, err := p.readString()
+	if err != nil {
+		return nil, err
+	}
+
+	keyType, value, err := p.readValue(valueType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read value of %s: %w", key, err)
+	}
+
+	return &RDBEntry{DB: p.db, Key: key, Type: keyType, Value: value}, nil
+}
+
+// readValue reads a value of the given value type and returns its key type
+func (p *RDBParser) readValue(valueType byte) (string, interface{}, error) {
+	switch valueType {
+	case rdbTypeString:
+		value, err := p.readString()
+		return "string", value, err
+	case rdbTypeList:
+		items, err := p.readStrings()
+		return "list", items, err
+	case rdbTypeSet:
+		items, err := p.readStrings()
+		return "set", toMembers(items), err
+	case rdbTypeZSet, rdbTypeZSet2:
+		members, err := p.readZSet(valueType == rdbTypeZSet2)
+		return "zset", members, err
+	case rdbTypeHash:
+		fields, err := p.readHash()
+		return "hash", fields, err
+	case rdbTypeHashMetadata:
+		fields, err := p.readHashMetadata()
+		return "hash", fields, err
+	case rdbTypeModule2:
+		// Module values cannot be recreated without the module, skip them
+		if err := p.skipLengths(1); err != nil {
+			return "", nil, err
+		}
+		return "module", nil, p.skipModuleValue()
+	case rdbTypeStreamListpacks, rdbTypeStreamListpacks2, rdbTypeStreamListpacks3:
+		stream, err := p.readStream(valueType)
+		return "stream", stream, err
+	case rdbTypeListQuicklist, rdbTypeListQuicklist2:
+		items, err := p.readQuicklist(valueType == rdbTypeListQuicklist2)
+		return "list", items, err
+	case rdbTypeHashListpackEx:
+		// Minimum field expiry, which the fields repeat
+		if _, err := p.read(8); err != nil {
+			return "", nil, err
+		}
+		blob, err := p.readBytes()
+		if err != nil {
+			return "", nil, err
+		}
+		fields, err := parseListpackHashEx(blob)
+		return "hash", fields, err
+	case rdbTypeHashZipmap, rdbTypeListZiplist, rdbTypeSetIntset, rdbTypeSetListpack,
+		rdbTypeZSetZiplist, rdbTypeZSetListpack, rdbTypeHashZiplist, rdbTypeHashListpack:
+		return p.readEncodedValue(valueType)
+	default:
+		return "", nil, fmt.Errorf("unsupported RDB value type %d", valueType)
+	}
+}
+
+// readEncodedValue reads a value stored as a single encoded blob
+func (p *RDBParser) readEncodedValue(valueType byte) (string, interface{}, error) {
+	blob, err := p.readBytes()
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch valueType {
+	case rdbTypeHashZipmap:
+		fields, err := parseZipmap(blob)
+		return "hash", fields, err
+	case rdbTypeListZiplist:
+		items, err := parseZiplist(blob)
+		return "list", items, err
+	case rdbTypeSetIntset:
+		items, err := parseIntset(blob)
+		return "set", toMembers(items), err
+	case rdbTypeSetListpack:
+		items, err := parseListpack(blob)
+		return "set", toMembers(items), err
+	case rdbTypeZSetZiplist:
+		items, err := parseZiplist(blob)
+		if err != nil {
+			return "", nil, err
+		}
+		members, err := pairsToZSet(items)
+		return "zset", members, err
+	case rdbTypeZSetListpack:
+		items, err := parseListpack(blob)
+		if err != nil {
+			return "", nil, err
+		}
+		members, err := pairsToZSet(items)
+		return "zset", members, err
+	case rdbTypeHashZiplist:
+		items, err := parseZiplist(blob)
+		if err != nil {
+			return "", nil, err
+		}
+		fields, err := pairsToHash(items)
+		return "hash", fields, err
+	case rdbTypeHashListpack:
+		items, err := parseListpack(blob)
+		if err != nil {
+			return "", nil, err
+		}
+		fields, err := pairsToHash(items)
+		return "hash", fields, err
+	default:
+		return "", nil, fmt.Errorf("unsupported RDB value type %d", valueType)
+	}
+}
+
+// readStrings reads a length followed by that many strings
+func (p *RDBParser) readStrings() ([]string, error) {
+	n, err := p.readCount()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]string, 0, min(n, rdbMaxPrealloc))
+	for i := 0; i < n; i++ {
+		item, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// readZSet reads a sorted set stored as member/score pairs, with binary scores from
+// RDB version 8 on and scores as strings before
+func (p *RDBParser) readZSet(binaryScores bool) ([]redis.Z, error) {
+	n, err := p.readCount()
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]redis.Z, 0, min(n, rdbMaxPrealloc))
+	for i := 0; i < n; i++ {
+		member, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+
+		var score float64
+		if binaryScores {
+			buf, err := p.read(8)
+			if err != nil {
+				return nil, err
+			}
+			score = math.Float64frombits(binary.LittleEndian.Uint64(buf))
+		} else {
+			score, err = p.readStringScore()
+			if err != nil {
+				return nil, err
+			}
+		}
+		members = append(members, redis.Z{Score: score, Member: member})
+	}
+	return members, nil
+}
+
+// readStringScore reads a score stored as a length-prefixed decimal string
+func (p *RDBParser) readStringScore() (float64, error) {
+	n, err := p.readByte()
+	if err != nil {
+		return 0, err
+	}
+
+	switch n {
+	case 253:
+		return math.NaN(), nil
+	case 254:
+		return math.Inf(1), nil
+	case 255:
+		return math.Inf(-1), nil
+	}
+
+	buf, err := p.read(int(n))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(string(buf), 64)
+}
+
+// readHash reads a hash stored as field/value pairs
+func (p *RDBParser) readHash() (map[string]string, error) {
+	n, err := p.readCount()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]string, min(n, rdbMaxPrealloc))
+	for i := 0; i < n; i++ {
+		field, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+		fields[field] = value
+	}
+	return fields, nil
+}
+
+// readHashMetadata reads a hash whose fields carry their own expiry. Expired fields are
+// dropped and the expiry of the others is not kept.
+func (p *RDBParser) readHashMetadata() (map[string]string, error) {
+	minExpire, err := p.read(8)
+	if err != nil {
+		return nil, err
+	}
+	base := int64(binary.LittleEndian.Uint64(minExpire))
+
+	n, err := p.readCount()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixMilli()
+	fields := make(map[string]string, min(n, rdbMaxPrealloc))
+	for i := 0; i < n; i++ {
+		// Expiry relative to the minimum expiry, plus one; zero if the field has none
+		ttl, err := p.readLength()
+		if err != nil {
+			return nil, err
+		}
+		field, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+		if ttl != 0 && base+int64(ttl)-1 <= now {
+			continue
+		}
+		fields[field] = value
+	}
+	return fields, nil
+}
+
+// readQuicklist reads a list stored as a sequence of ziplists, or of listpacks and plain
+// elements in the second version of the encoding
+func (p *RDBParser) readQuicklist(version2 bool) ([]string, error) {
+	nodes, err := p.readLength()
+	if err != nil {
+		return nil, err
+	}
+
+	var items []string
+	for i := uint64(0); i < nodes; i++ {
+		container := uint64(quicklistPacked)
+		if version2 {
+			if container, err = p.readLength(); err != nil {
+				return nil, err
+			}
+		}
+
+		blob, err := p.readBytes()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case container == quicklistPlain:
+			items = append(items, string(blob))
+		case version2:
+			node, err := parseListpack(blob)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, node...)
+		default:
+			node, err := parseZiplist(blob)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, node...)
+		}
+	}
+
+	if items == nil {
+		items = []string{}
+	}
+	return items, nil
+}
+
+// Quicklist node containers
+const (
+	quicklistPlain  = 1
+	quicklistPacked = 2
+)
+
+// skipModuleAux skips module auxiliary data
+func (p *RDBParser) skipModuleAux() error {
+	// Module ID and the opcode and value of when the data was saved
+	if err := p.skipLengths(3); err != nil {
+		return err
+	}
+	return p.skipModuleValue()
+}
+
+// Module value opcodes
+const (
+	rdbModuleOpEOF    = 0
+	rdbModuleOpSInt   = 1
+	rdbModuleOpUInt   = 2
+	rdbModuleOpFloat  = 3
+	rdbModuleOpDouble = 4
+	rdbModuleOpString = 5
+)
+
+// skipModuleValue skips a module value, which is a sequence of typed fields ending with
+// an EOF opcode
+func (p *RDBParser) skipModuleValue() error {
+	for {
+		op, err := p.readLength()
+		if err != nil {
+			return err
+		}
+
+		switch op {
+		case rdbModuleOpEOF:
+			return nil
+		case rdbModuleOpSInt, rdbModuleOpUInt:
+			err = p.skipLengths(1)
+		case rdbModuleOpFloat:
+			_, err = p.read(4)
+		case rdbModuleOpDouble:
+			_, err = p.read(8)
+		case rdbModuleOpString:
+			_, err = p.readBytes()
+		default:
+			return fmt.Errorf("unknown module value opcode %d", op)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readByte reads a single byte and adds it to the checksum
+func (p *RDBParser) readByte() (byte, error) {
+	b, err := p.r.ReadByte()
+	if err != nil {
+		return 0, rdbReadError(err)
+	}
+	p.crc = rdbChecksumTable[byte(p.crc)^b] ^ p.crc>>8
+	p.offset++
+	return b, nil
+}
+
+// read reads exactly n bytes and adds them to the checksum. Large strings are read a
+// chunk at a time, so that a snapshot ending early does not allocate all of n.
+func (p *RDBParser) read(n int) ([]byte, error) {
+	buf := make([]byte, 0, min(n, rdbReadChunk))
+	for len(buf) < n {
+		chunk := min(n-len(buf), rdbReadChunk)
+		buf = slices.Grow(buf, chunk)
+		if _, err := io.ReadFull(p.r, buf[len(buf):len(buf)+chunk]); err != nil {
+			return nil, rdbReadError(err)
+		}
+		p.crc = rdbChecksum(p.crc, buf[len(buf):len(buf)+chunk])
+		buf = buf[:len(buf)+chunk]
+	}
+	p.offset += int64(n)
+	return buf, nil
+}
+
+// checkLength returns a length read from the snapshot as an int, failing if it is larger
+// than the rest of the snapshot, where each unit takes at least one byte
+func (p *RDBParser) checkLength(n uint64) (int, error) {
+	if n > math.MaxInt || (p.size >= 0 && n > uint64(max(p.size-p.offset, 0))) {
+		return 0, p.corrupt()
+	}
+	return int(n), nil
+}
+
+// corrupt returns the error for a corrupt snapshot at the current offset
+func (p *RDBParser) corrupt() error {
+	return fmt.Errorf("%w at offset %d", errCorruptEncoding, p.offset)
+}
+
+// readCount reads the number of elements of a value
+func (p *RDBParser) readCount() (int, error) {
+	n, err := p.readLength()
+	if err != nil {
+		return 0, err
+	}
+	return p.checkLength(n)
+}
+
+// rdbChecksum adds data to an RDB checksum. The standard library inverts the checksum
+// before and after every update, which Redis does not.
+func rdbChecksum(crc uint64, data []byte) uint64 {
+	return ^crc64.Update(^crc, rdbChecksumTable, data)
+}
+
+// rdbReadError reports a snapshot that ended early as truncated
+func rdbReadError(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("RDB snapshot is truncated: %w", io.ErrUnexpectedEOF)
+	}
+	return err
+}
+
+// readMillisecondTime reads a little-endian Unix time in milliseconds
+func (p *RDBParser) readMillisecondTime() (time.Time, error) {
+	buf, err := p.read(8)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(int64(binary.LittleEndian.Uint64(buf))), nil
+}
+
+// readLengthEncoding reads a length, or the format of a specially encoded string when
+// encoded is set
+func (p *RDBParser) readLengthEncoding() (length uint64, encoded bool, err error) {
+	b, err := p.readByte()
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch b >> 6 {
+	case 0:
+		return uint64(b & 0x3F), false, nil
+	case 1:
+		next, err := p.readByte()
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(b&0x3F)<<8 | uint64(next), false, nil
+	case 2:
+		switch b {
+		case 0x80:
+			buf, err := p.read(4)
+			if err != nil {
+				return 0, false, err
+			}
+			return uint64(binary.BigEndian.Uint32(buf)), false, nil
+		case 0x81:
+			buf, err := p.read(8)
+			if err != nil {
+				return 0, false, err
+			}
+			return binary.BigEndian.Uint64(buf), false, nil
+		default:
+			return 0, false, fmt.Errorf("unknown RDB length encoding %#x", b)
+		}
+	default:
+		return uint64(b & 0x3F), true, nil
+	}
+}
+
+// readLength reads a plain length
+func (p *RDBParser) readLength() (uint64, error) {
+	length, encoded, err := p.readLengthEncoding()
+	if err != nil {
+		return 0, err
+	}
+	if encoded {
+		return 0, fmt.Errorf("unexpected encoded string where a length was expected")
+	}
+	return length, nil
+}
+
+// skipLengths reads and discards n lengths
+func (p *RDBParser) skipLengths(n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := p.readLength(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RDB string encodings
+const (
+	rdbEncodingInt8  = 0
+	rdbEncodingInt16 = 1
+	rdbEncodingInt32 = 2
+	rdbEncodingLZF   = 3
+)
+
+// readString reads a string in any of its encodings
+func (p *RDBParser) readString() (string, error) {
+	buf, err := p.readBytes()
+	return string(buf), err
+}
+
+// readBytes reads a string in any of its encodings as bytes. Integer encoded strings are
+// returned in decimal and compressed strings decompressed.
+func (p *RDBParser) readBytes() ([]byte, error) {
+	length, encoded, err := p.readLengthEncoding()
+	if err != nil {
+		return nil, err
+	}
+	if !encoded {
+		n, err := p.checkLength(length)
+		if err != nil {
+			return nil, err
+		}
+		return p.read(n)
+	}
+
+	switch length {
+	case rdbEncodingInt8:
+		buf, err := p.read(1)
+		if err != nil {
+			return nil, err
+		}
+		return strconv.AppendInt(nil, int64(int8(buf[0])), 10), nil
+	case rdbEncodingInt16:
+		buf, err := p.read(2)
+		if err != nil {
+			return nil, err
+		}
+		return strconv.AppendInt(nil, int64(int16(binary.LittleEndian.Uint16(buf))), 10), nil
+	case rdbEncodingInt32:
+		buf, err := p.read(4)
+		if err != nil {
+			return nil, err
+		}
+		return strconv.AppendInt(nil, int64(int32(binary.LittleEndian.Uint32(buf))), 10), nil
+	case rdbEncodingLZF:
+		compressedLen, err := p.readCount()
+		if err != nil {
+			return nil, err
+		}
+		length, err := p.readLength()
+		if err != nil {
+			return nil, err
+		}
+		if length > math.MaxInt {
+			return nil, p.corrupt()
+		}
+		compressed, err := p.read(compressedLen)
+		if err != nil {
+			return nil, err
+		}
+		return lzfDecompress(compressed, int(length))
+	default:
+		return nil, fmt.Errorf("unknown RDB string encoding %d", length)
+	}
+}
+
+// toMembers converts set members to the shape SetValue expects for sets
+func toMembers(items []string) []interface{} {
+	members := make([]interface{}, len(items))
+	for i, item := range items {
+		members[i] = item
+	}
+	return members
+}
+
+// pairsToHash converts alternating fields and values to a hash
+func pairsToHash(items []string) (map[string]string, error) {
+	if len(items)%2 != 0 {
+		return nil, fmt.Errorf("hash encoding holds an odd number of elements")
+	}
+	fields := make(map[string]string, len(items)/2)
+	for i := 0; i < len(items); i += 2 {
+		fields[items[i]] = items[i+1]
+	}
+	return fields, nil
+}
+
+// pairsToZSet converts alternating members and scores to a sorted set
+func pairsToZSet(items []string) ([]redis.Z, error) {
+	if len(items)%2 != 0 {
+		return nil, fmt.Errorf("sorted set encoding holds an odd number of elements")
+	}
+	members := make([]redis.Z, 0, len(items)/2)
+	for i := 0; i < len(items); i += 2 {
+		score, err := strconv.ParseFloat(items[i+1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sorted set score %q", items[i+1])
+		}
+		members = append(members, redis.Z{Score: score, Member: items[i]})
+	}
+	return members, nil
+}
@@ -0,0 +1,671 @@
+package client
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// errCorruptEncoding is returned when an encoded value ends early or holds an unknown encoding
+var errCorruptEncoding = errors.New("corrupt RDB value encoding")
+
+// blobReader reads the elements of an encoded value
+type blobReader struct {
+	b   []byte
+	pos int
+}
+
+// next returns the next n bytes
+func (r *blobReader) next(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.b) {
+		return nil, errCorruptEncoding
+	}
+	buf := r.b[r.pos : r.pos+n]
+	r.pos += n
+	return buf, nil
+}
+
+// byte returns the next byte
+func (r *blobReader) byte() (byte, error) {
+	buf, err := r.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return buf[0], nil
+}
+
+// int returns the next n bytes as a little-endian signed integer
+func (r *blobReader) int(n int) (int64, error) {
+	buf, err := r.next(n)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for i := n - 1; i >= 0; i-- {
+		v = v<<8 | uint64(buf[i])
+	}
+	// Sign-extend from n bytes
+	shift := 64 - 8*uint(n)
+	return int64(v<<shift) >> shift, nil
+}
+
+// lzfMaxRatio bounds how much larger than its input LZF data decompresses to: a back
+// reference of two bytes copies up to 264 bytes
+const lzfMaxRatio = 132
+
+// lzfDecompress decompresses LZF compressed data into a buffer of the expected length
+func lzfDecompress(in []byte, length int) ([]byte, error) {
+	if length < 0 || length > len(in)*lzfMaxRatio {
+		return nil, errCorruptEncoding
+	}
+
+	out := make([]byte, 0, length)
+	for i := 0; i < len(in); {
+		ctrl := int(in[i])
+		i++
+
+		// Literal run of ctrl+1 bytes
+		if ctrl < 32 {
+			if i+ctrl+1 > len(in) || len(out)+ctrl+1 > length {
+				return nil, errCorruptEncoding
+			}
+			out = append(out, in[i:i+ctrl+1]...)
+			i += ctrl + 1
+			continue
+		}
+
+		// Back reference of at least three bytes into the output
+		n := ctrl >> 5
+		if n == 7 {
+			if i >= len(in) {
+				return nil, errCorruptEncoding
+			}
+			n += int(in[i])
+			i++
+		}
+		if i >= len(in) {
+			return nil, errCorruptEncoding
+		}
+		ref := len(out) - (ctrl&0x1F)<<8 - int(in[i]) - 1
+		i++
+		if ref < 0 || len(out)+n+2 > length {
+			return nil, errCorruptEncoding
+		}
+		// The reference may overlap the bytes being copied
+		for j := 0; j < n+2; j++ {
+			out = append(out, out[ref+j])
+		}
+	}
+
+	if len(out) != length {
+		return nil, fmt.Errorf("LZF data decompressed to %d bytes, expected %d", len(out), length)
+	}
+	return out, nil
+}
+
+// parseZiplist returns the elements of a ziplist, with integers in decimal
+func parseZiplist(b []byte) ([]string, error) {
+	// Total bytes, offset of the last entry and number of entries
+	r := &blobReader{b: b, pos: 10}
+	items := []string{}
+
+	for {
+		prevLen, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		if prevLen == 0xFF {
+			return items, nil
+		}
+		if prevLen == 0xFE {
+			if _, err := r.next(4); err != nil {
+				return nil, err
+			}
+		}
+
+		enc, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+
+		var item []byte
+		var value int64
+		isInt := true
+		switch {
+		case enc>>6 == 0:
+			isInt = false
+			item, err = r.next(int(enc & 0x3F))
+		case enc>>6 == 1:
+			var low byte
+			if low, err = r.byte(); err == nil {
+				isInt = false
+				item, err = r.next(int(enc&0x3F)<<8 | int(low))
+			}
+		case enc == 0x80:
+			var length []byte
+			if length, err = r.next(4); err == nil {
+				isInt = false
+				item, err = r.next(int(binary.BigEndian.Uint32(length)))
+			}
+		case enc == 0xC0:
+			value, err = r.int(2)
+		case enc == 0xD0:
+			value, err = r.int(4)
+		case enc == 0xE0:
+			value, err = r.int(8)
+		case enc == 0xF0:
+			value, err = r.int(3)
+		case enc == 0xFE:
+			value, err = r.int(1)
+		case enc >= 0xF1 && enc <= 0xFD:
+			// Immediate value from 0 to 12
+			value = int64(enc&0x0F) - 1
+		default:
+			return nil, errCorruptEncoding
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if isInt {
+			items = append(items, strconv.FormatInt(value, 10))
+		} else {
+			items = append(items, string(item))
+		}
+	}
+}
+
+// parseListpack returns the elements of a listpack, with integers in decimal
+func parseListpack(b []byte) ([]string, error) {
+	// Total bytes and number of elements
+	r := &blobReader{b: b, pos: 6}
+	items := []string{}
+
+	for {
+		start := r.pos
+		enc, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		if enc == 0xFF {
+			return items, nil
+		}
+
+		var item []byte
+		var value int64
+		isInt := true
+		switch {
+		case enc&0x80 == 0:
+			value = int64(enc)
+		case enc&0xC0 == 0x80:
+			isInt = false
+			item, err = r.next(int(enc & 0x3F))
+		case enc&0xE0 == 0xC0:
+			var low byte
+			if low, err = r.byte(); err == nil {
+				// 13-bit signed integer
+				value = int64(enc&0x1F)<<8 | int64(low)
+				if value >= 1<<12 {
+					value -= 1 << 13
+				}
+			}
+		case enc&0xF0 == 0xE0:
+			var low byte
+			if low, err = r.byte(); err == nil {
+				isInt = false
+				item, err = r.next(int(enc&0x0F)<<8 | int(low))
+			}
+		case enc == 0xF0:
+			var length []byte
+			if length, err = r.next(4); err == nil {
+				isInt = false
+				item, err = r.next(int(binary.LittleEndian.Uint32(length)))
+			}
+		case enc == 0xF1:
+			value, err = r.int(2)
+		case enc == 0xF2:
+			value, err = r.int(3)
+		case enc == 0xF3:
+			value, err = r.int(4)
+		case enc == 0xF4:
+			value, err = r.int(8)
+		default:
+			return nil, errCorruptEncoding
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if isInt {
+			items = append(items, strconv.FormatInt(value, 10))
+		} else {
+			items = append(items, string(item))
+		}
+
+		// Skip the length of the entry stored after it for backward traversal
+		if _, err := r.next(listpackBacklenSize(r.pos - start)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// listpackBacklenSize returns how many bytes the back length of an entry of n bytes takes
+func listpackBacklenSize(n int) int {
+	switch {
+	case n <= 127:
+		return 1
+	case n < 16383:
+		return 2
+	case n < 2097151:
+		return 3
+	case n < 268435455:
+		return 4
+	default:
+		return 5
+	}
+}
+
+// parseListpackHashEx returns the fields of a hash stored as field, value and expiry
+// triplets. Expired fields are dropped and the expiry of the others is not kept.
+func parseListpackHashEx(b []byte) (map[string]string, error) {
+	items, err := parseListpack(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(items)%3 != 0 {
+		return nil, fmt.Errorf("hash encoding holds %d elements, not field, value and expiry triplets", len(items))
+	}
+
+	now := time.Now().UnixMilli()
+	fields := make(map[string]string, len(items)/3)
+	for i := 0; i < len(items); i += 3 {
+		expireAt, err := strconv.ParseInt(items[i+2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hash field expiry %q", items[i+2])
+		}
+		if expireAt != 0 && expireAt <= now {
+			continue
+		}
+		fields[items[i]] = items[i+1]
+	}
+	return fields, nil
+}
+
+// parseIntset returns the members of an intset in decimal
+func parseIntset(b []byte) ([]string, error) {
+	r := &blobReader{b: b}
+	header, err := r.next(8)
+	if err != nil {
+		return nil, err
+	}
+	size := int(binary.LittleEndian.Uint32(header[:4]))
+	count := int(binary.LittleEndian.Uint32(header[4:]))
+	if size != 2 && size != 4 && size != 8 || count > (len(b)-8)/size {
+		return nil, errCorruptEncoding
+	}
+
+	items := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		value, err := r.int(size)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, strconv.FormatInt(value, 10))
+	}
+	return items, nil
+}
+
+// parseZipmap returns the fields of a hash stored as a zipmap
+func parseZipmap(b []byte) (map[string]string, error) {
+	// Number of entries, which is not reliable above 253
+	r := &blobReader{b: b, pos: 1}
+	fields := make(map[string]string)
+
+	readLen := func() (int, bool, error) {
+		n, err := r.byte()
+		if err != nil {
+			return 0, false, err
+		}
+		switch n {
+		case 0xFF:
+			return 0, true, nil
+		case 0xFE:
+			buf, err := r.next(4)
+			if err != nil {
+				return 0, false, err
+			}
+			return int(binary.LittleEndian.Uint32(buf)), false, nil
+		default:
+			return int(n), false, nil
+		}
+	}
+
+	for {
+		keyLen, end, err := readLen()
+		if err != nil {
+			return nil, err
+		}
+		if end {
+			return fields, nil
+		}
+		key, err := r.next(keyLen)
+		if err != nil {
+			return nil, err
+		}
+
+		valueLen, end, err := readLen()
+		if err != nil {
+			return nil, err
+		}
+		if end {
+			return nil, errCorruptEncoding
+		}
+		// Unused bytes left after the value by in-place updates
+		free, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		value, err := r.next(valueLen)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := r.next(int(free)); err != nil {
+			return nil, err
+		}
+
+		fields[string(key)] = string(value)
+	}
+}
+
+// Flags of the entries of a stream listpack
+const (
+	streamItemDeleted    = 1
+	streamItemSameFields = 2
+)
+
+// readStream reads a stream stored as listpacks, with its IDs, consumer groups and
+// pending entries
+func (p *RDBParser) readStream(valueType byte) (*StreamValue, error) {
+	stream := &StreamValue{}
+
+	nodes, err := p.readLength()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < nodes; i++ {
+		// Each listpack stores the entries as differences to the ID it is keyed by
+		master, err := p.readBytes()
+		if err != nil {
+			return nil, err
+		}
+		if len(master) != 16 {
+			return nil, fmt.Errorf("stream listpack key has %d bytes, expected 16", len(master))
+		}
+		blob, err := p.readBytes()
+		if err != nil {
+			return nil, err
+		}
+		entries, err := parseStreamListpack(master, blob)
+		if err != nil {
+			return nil, err
+		}
+		stream.Entries = append(stream.Entries, entries...)
+	}
+
+	// Number of entries, which the listpacks already hold, and the last generated ID
+	values, err := p.readLengths(3)
+	if err != nil {
+		return nil, err
+	}
+	stream.LastGeneratedID = formatStreamID(values[1], values[2])
+
+	if valueType >= rdbTypeStreamListpacks2 {
+		// First entry ID, max deleted ID and entries added
+		values, err := p.readLengths(5)
+		if err != nil {
+			return nil, err
+		}
+		stream.MaxDeletedID = formatStreamID(values[2], values[3])
+		stream.EntriesAdded = int64(values[4])
+	}
+
+	groups, err := p.readLength()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < groups; i++ {
+		group, err := p.readStreamGroup(valueType)
+		if err != nil {
+			return nil, err
+		}
+		stream.Groups = append(stream.Groups, group)
+	}
+
+	return stream, nil
+}
+
+// readStreamGroup reads a consumer group with its pending entries and consumers
+func (p *RDBParser) readStreamGroup(valueType byte) (StreamGroup, error) {
+	var group StreamGroup
+
+	name, err := p.readString()
+	if err != nil {
+		return group, err
+	}
+	lastID, err := p.readLengths(2)
+	if err != nil {
+		return group, err
+	}
+	group.Name = name
+	group.LastDeliveredID = formatStreamID(lastID[0], lastID[1])
+
+	if valueType >= rdbTypeStreamListpacks2 {
+		entriesRead, err := p.readLength()
+		if err != nil {
+			return group, err
+		}
+		// An unknown count is stored as -1
+		group.EntriesRead = int64(entriesRead)
+	}
+
+	// Pending entries of the group, whose consumers are listed with the consumers
+	pendingCount, err := p.readCount()
+	if err != nil {
+		return group, err
+	}
+	pending := make(map[string]int, min(pendingCount, rdbMaxPrealloc))
+	for i := 0; i < pendingCount; i++ {
+		id, err := p.readRawStreamID()
+		if err != nil {
+			return group, err
+		}
+		deliveredAt, err := p.readMillisecondTime()
+		if err != nil {
+			return group, err
+		}
+		deliveryCount, err := p.readLength()
+		if err != nil {
+			return group, err
+		}
+
+		idle := time.Since(deliveredAt)
+		if idle < 0 {
+			idle = 0
+		}
+		pending[id] = len(group.Pending)
+		group.Pending = append(group.Pending, StreamPendingEntry{
+			ID:            id,
+			Idle:          idle,
+			DeliveryCount: int64(deliveryCount),
+		})
+	}
+
+	consumers, err := p.readLength()
+	if err != nil {
+		return group, err
+	}
+	for i := uint64(0); i < consumers; i++ {
+		consumer, err := p.readString()
+		if err != nil {
+			return group, err
+		}
+		// Seen time, and active time from the third version on
+		times := 1
+		if valueType >= rdbTypeStreamListpacks3 {
+			times = 2
+		}
+		if _, err := p.read(8 * times); err != nil {
+			return group, err
+		}
+
+		owned, err := p.readLength()
+		if err != nil {
+			return group, err
+		}
+		for j := uint64(0); j < owned; j++ {
+			id, err := p.readRawStreamID()
+			if err != nil {
+				return group, err
+			}
+			index, ok := pending[id]
+			if !ok {
+				return group, fmt.Errorf("consumer %s owns %s, which is not pending in group %s", consumer, id, name)
+			}
+			group.Pending[index].Consumer = consumer
+		}
+		group.Consumers = append(group.Consumers, consumer)
+	}
+
+	return group, nil
+}
+
+// readLengths reads n lengths
+func (p *RDBParser) readLengths(n int) ([]uint64, error) {
+	values := make([]uint64, n)
+	for i := range values {
+		value, err := p.readLength()
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// readRawStreamID reads a stream ID stored as 16 big-endian bytes
+func (p *RDBParser) readRawStreamID() (string, error) {
+	buf, err := p.read(16)
+	if err != nil {
+		return "", err
+	}
+	return formatStreamID(binary.BigEndian.Uint64(buf[:8]), binary.BigEndian.Uint64(buf[8:])), nil
+}
+
+// formatStreamID formats the two parts of a stream ID
+func formatStreamID(ms, seq uint64) string {
+	return strconv.FormatUint(ms, 10) + "-" + strconv.FormatUint(seq, 10)
+}
+
+// parseStreamListpack returns the entries of a stream listpack. The first entry of the
+// listpack is a master entry with the entry counts and the fields shared by entries
+// flagged with the same fields.
+func parseStreamListpack(master, blob []byte) ([]StreamEntry, error) {
+	items, err := parseListpack(blob)
+	if err != nil {
+		return nil, err
+	}
+
+	pos := 0
+	next := func() (string, error) {
+		if pos >= len(items) {
+			return "", errCorruptEncoding
+		}
+		pos++
+		return items[pos-1], nil
+	}
+	nextInt := func() (int64, error) {
+		item, err := next()
+		if err != nil {
+			return 0, err
+		}
+		return strconv.ParseInt(item, 10, 64)
+	}
+
+	header := make([]int64, 3)
+	for i := range header {
+		if header[i], err = nextInt(); err != nil {
+			return nil, err
+		}
+	}
+	count, deleted, fieldCount := header[0], header[1], header[2]
+	// Every entry and field takes at least one element
+	n := int64(len(items))
+	if count < 0 || deleted < 0 || fieldCount < 0 || count > n || deleted > n || fieldCount > n || count+deleted+fieldCount > n {
+		return nil, errCorruptEncoding
+	}
+
+	masterFields := make([]string, fieldCount)
+	for i := range masterFields {
+		if masterFields[i], err = next(); err != nil {
+			return nil, err
+		}
+	}
+	// Master entry terminator
+	if _, err := next(); err != nil {
+		return nil, err
+	}
+
+	masterMs := binary.BigEndian.Uint64(master[:8])
+	masterSeq := binary.BigEndian.Uint64(master[8:])
+
+	entries := make([]StreamEntry, 0, count)
+	for i := int64(0); i < count+deleted; i++ {
+		values := make([]int64, 3)
+		for j := range values {
+			if values[j], err = nextInt(); err != nil {
+				return nil, err
+			}
+		}
+		flags, msDiff, seqDiff := values[0], values[1], values[2]
+
+		var fields []string
+		if flags&streamItemSameFields != 0 {
+			for _, field := range masterFields {
+				value, err := next()
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, field, value)
+			}
+		} else {
+			n, err := nextInt()
+			if err != nil {
+				return nil, err
+			}
+			for j := int64(0); j < 2*n; j++ {
+				item, err := next()
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, item)
+			}
+		}
+
+		// Number of elements of the entry, for backward traversal
+		if _, err := next(); err != nil {
+			return nil, err
+		}
+
+		if flags&streamItemDeleted != 0 {
+			continue
+		}
+		entries = append(entries, StreamEntry{
+			ID:     formatStreamID(masterMs+uint64(msDiff), masterSeq+uint64(seqDiff)),
+			Fields: fields,
+		})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,389 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrReadOnly is returned by the write operations of clients that can only be migrated from
+var ErrReadOnly = errors.New("source is read-only")
+
+// rdbFileKey locates a key in an RDB file
+type rdbFileKey struct {
+	// offset is where the value type of the key's entry starts
+	offset   int64
+	keyType  string
+	expireAt time.Time
+}
+
+// RDBFileClient implements DatabaseClient for an RDB snapshot file, such as a dump.rdb
+// backup, so that keys can be migrated without a running server. Connect indexes the
+// keys of the configured database; values are read from the file when requested.
+// Keys expire as they would on a server loading the file now. Write operations fail
+// with ErrReadOnly.
+type RDBFileClient struct {
+	config *ClientConfig
+
+	// mu guards the file and the index, which Connect and Disconnect replace
+	mu       sync.RWMutex
+	file     *os.File
+	size     int64
+	version  int
+	keys     map[string]rdbFileKey
+	order    []string
+	keyspace map[int]int64
+}
+
+// NewRDBFileClient creates a client reading the RDB file in config.RDBFile, and the
+// database config.Database of it
+func NewRDBFileClient(config *ClientConfig) *RDBFileClient {
+	return &RDBFileClient{config: config}
+}
+
+// Connect opens the RDB file and indexes its keys, verifying the checksum of the file
+func (c *RDBFileClient) Connect() error {
+	file, err := os.Open(c.config.RDBFile)
+	if err != nil {
+		return fmt.Errorf("failed to open RDB file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open RDB file: %w", err)
+	}
+
+	keys := make(map[string]rdbFileKey)
+	var order []string
+	keyspace := make(map[int]int64)
+
+	parser := NewRDBParserWithSize(file, info.Size())
+	for {
+		entry, err := parser.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("failed to read RDB file %s: %w", c.config.RDBFile, err)
+		}
+		if !rdbKeyExpired(entry.ExpireAt) {
+			keyspace[entry.DB]++
+		}
+		if entry.DB != c.config.Database {
+			continue
+		}
+
+		if _, exists := keys[entry.Key]; !exists {
+			order = append(order, entry.Key)
+		}
+		keys[entry.Key] = rdbFileKey{offset: parser.entryOffset, keyType: entry.Type, expireAt: entry.ExpireAt}
+	}
+
+	c.mu.Lock()
+	old := c.file
+	c.file, c.size, c.version, c.keys, c.order, c.keyspace = file, info.Size(), parser.version, keys, order, keyspace
+	c.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// Disconnect closes the RDB file
+func (c *RDBFileClient) Disconnect() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file, c.keys, c.order, c.keyspace = nil, nil, nil, nil
+	return err
+}
+
+// Keyspace returns the number of keys in every database of the file that holds any, as
+// counted when the file was indexed
+func (c *RDBFileClient) Keyspace() (map[int]int64, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.file == nil {
+		return nil, fmt.Errorf("RDB file not loaded")
+	}
+	counts := make(map[int]int64, len(c.keyspace))
+	for db, n := range c.keyspace {
+		counts[db] = n
+	}
+	return counts, nil
+}
+
+// lookup returns a key that has not expired
+func (c *RDBFileClient) lookup(key string) (rdbFileKey, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.file == nil {
+		return rdbFileKey{}, false, fmt.Errorf("RDB file not loaded")
+	}
+	entry, exists := c.keys[key]
+	if !exists || rdbKeyExpired(entry.expireAt) {
+		return rdbFileKey{}, false, nil
+	}
+	return entry, true, nil
+}
+
+// rdbKeyExpired reports whether a key with the given expiry has expired
+func rdbKeyExpired(expireAt time.Time) bool {
+	return !expireAt.IsZero() && !expireAt.After(time.Now())
+}
+
+// readValue reads the value of an indexed key from the file
+func (c *RDBFileClient) readValue(key string, entry rdbFileKey) (interface{}, error) {
+	if entry.keyType == "module" {
+		return nil, fmt.Errorf("key %s has a module type, its value cannot be migrated", key)
+	}
+
+	c.mu.RLock()
+	file, size, version := c.file, c.size, c.version
+	c.mu.RUnlock()
+	if file == nil {
+		return nil, fmt.Errorf("RDB file not loaded")
+	}
+
+	parser := NewRDBParserWithSize(io.NewSectionReader(file, entry.offset, size-entry.offset), size-entry.offset)
+	parser.version = version
+	valueType, err := parser.readByte()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := parser.readString(); err != nil {
+		return nil, err
+	}
+
+	_, value, err := parser.readValue(valueType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read value of %s: %w", key, err)
+	}
+
+	// The parser returns set members the way SetValue takes them, GetValue returns
+	// them as strings
+	if members, ok := value.([]interface{}); ok {
+		set := make([]string, len(members))
+		for i, member := range members {
+			set[i] = member.(string)
+		}
+		return set, nil
+	}
+	return value, nil
+}
+
+// GetAllKeys returns the keys of the database that have not expired, in file order
+func (c *RDBFileClient) GetAllKeys() ([]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.file == nil {
+		return nil, fmt.Errorf("RDB file not loaded")
+	}
+
+	keys := make([]string, 0, len(c.order))
+	for _, key := range c.order {
+		if !rdbKeyExpired(c.keys[key].expireAt) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// GetKeysByPattern returns the keys matching a glob-style pattern
+func (c *RDBFileClient) GetKeysByPattern(pattern string) ([]string, error) {
+	keys, err := c.GetAllKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []string
+	for _, key := range keys {
+		ok, err := filepath.Match(pattern, key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if ok {
+			matched = append(matched, key)
+		}
+	}
+	return matched, nil
+}
+
+// ScanNodes returns the only node of the file
+func (c *RDBFileClient) ScanNodes() ([]string, error) {
+	return []string{StandaloneNode}, nil
+}
+
+// ScanPage returns the keys that have not expired among the count keys starting at
+// cursor in file order, and the position of the next page. Unlike SCAN, it never returns
+// a key twice.
+func (c *RDBFileClient) ScanPage(node string, cursor uint64, pattern string, count int64) ([]string, uint64, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.file == nil {
+		return nil, 0, fmt.Errorf("RDB file not loaded")
+	}
+	if node != StandaloneNode {
+		return nil, 0, fmt.Errorf("cannot scan node %s of an RDB file", node)
+	}
+	if count < 1 {
+		count = 10
+	}
+
+	start := min(cursor, uint64(len(c.order)))
+	end := min(start+uint64(count), uint64(len(c.order)))
+	var keys []string
+	for _, key := range c.order[start:end] {
+		if rdbKeyExpired(c.keys[key].expireAt) {
+			continue
+		}
+		ok, err := filepath.Match(pattern, key)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if ok {
+			keys = append(keys, key)
+		}
+	}
+
+	if end == uint64(len(c.order)) {
+		return keys, 0, nil
+	}
+	return keys, end, nil
+}
+
+// GetKeyType returns the type of a key, "none" if it does not exist
+func (c *RDBFileClient) GetKeyType(key string) (string, error) {
+	entry, exists, err := c.lookup(key)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "none", nil
+	}
+	return entry.keyType, nil
+}
+
+// GetValue reads the value of a key from the file
+func (c *RDBFileClient) GetValue(key string) (interface{}, error) {
+	entry, exists, err := c.lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("key %s does not exist in the RDB file", key)
+	}
+	return c.readValue(key, entry)
+}
+
+// SetValue fails, the RDB file is read-only
+func (c *RDBFileClient) SetValue(key string, value interface{}) error {
+	return ErrReadOnly
+}
+
+// Exists reports whether a key exists and has not expired
+func (c *RDBFileClient) Exists(key string) (bool, error) {
+	_, exists, err := c.lookup(key)
+	return exists, err
+}
+
+// Ping checks that the file has been loaded
+func (c *RDBFileClient) Ping() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.file == nil {
+		return fmt.Errorf("RDB file not loaded")
+	}
+	return nil
+}
+
+// GetTTL returns the time a key has left, -1 if it has no expiry and -2 if it does not exist
+func (c *RDBFileClient) GetTTL(key string) (time.Duration, error) {
+	entry, exists, err := c.lookup(key)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case !exists:
+		return -2, nil
+	case entry.expireAt.IsZero():
+		return -1, nil
+	default:
+		return time.Until(entry.expireAt), nil
+	}
+}
+
+// SetTTL fails, the RDB file is read-only
+func (c *RDBFileClient) SetTTL(key string, ttl time.Duration) error {
+	return ErrReadOnly
+}
+
+// GetExpireAt returns the expiry of a key as stored in the file
+func (c *RDBFileClient) GetExpireAt(key string) (time.Time, bool, error) {
+	entry, exists, err := c.lookup(key)
+	if err != nil || !exists {
+		return time.Time{}, false, err
+	}
+	return entry.expireAt, true, nil
+}
+
+// SetValueWithExpiry fails, the RDB file is read-only
+func (c *RDBFileClient) SetValueWithExpiry(key string, value interface{}, expireAt time.Time) error {
+	return ErrReadOnly
+}
+
+// SetExpireAt fails, the RDB file is read-only
+func (c *RDBFileClient) SetExpireAt(key string, expireAt time.Time) error {
+	return ErrReadOnly
+}
+
+// GetBatch reads the type, value and expiry of several keys from the file
+func (c *RDBFileClient) GetBatch(keys []string) ([]KeyRecord, error) {
+	records := make([]KeyRecord, len(keys))
+	for i, key := range keys {
+		entry, exists, err := c.lookup(key)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			records[i] = KeyRecord{Key: key, Type: "none"}
+			continue
+		}
+
+		records[i] = KeyRecord{Key: key, Type: entry.keyType, ExpireAt: entry.expireAt}
+		records[i].Value, records[i].Err = c.readValue(key, entry)
+	}
+	return records, nil
+}
+
+// SetBatch fails, the RDB file is read-only
+func (c *RDBFileClient) SetBatch(records []KeyRecord) ([]error, error) {
+	return nil, ErrReadOnly
+}
+
+// ExistsBatch reports which of the keys exist and have not expired
+func (c *RDBFileClient) ExistsBatch(keys []string) ([]bool, error) {
+	exists := make([]bool, len(keys))
+	for i, key := range keys {
+		var err error
+		if _, exists[i], err = c.lookup(key); err != nil {
+			return nil, err
+		}
+	}
+	return exists, nil
+}
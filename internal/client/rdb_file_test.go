@@ -0,0 +1,222 @@
+package client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeRDBFile writes a snapshot with keys in databases 0 and 3 and returns its path
+func writeRDBFile(t *testing.T, expireAt time.Time) string {
+	t.Helper()
+
+	b := newRDBBuilder(12)
+	b.op(rdbOpAux).str("redis-ver").str("7.2.4")
+	b.op(rdbOpSelectDB).length(0)
+	b.op(rdbTypeString).str("user:1").str("alice")
+	b.op(rdbOpExpireTimeMs).ms(expireAt)
+	b.op(rdbTypeHashListpack).str("user:2").str(listpack("name", "bob", "age", 42))
+	b.op(rdbOpExpireTimeMs).ms(time.Now().Add(-time.Minute))
+	b.op(rdbTypeString).str("expired").str("gone")
+	var intset bytes.Buffer
+	binary.Write(&intset, binary.LittleEndian, []uint32{2, 2})
+	binary.Write(&intset, binary.LittleEndian, []int16{3, 9})
+	b.op(rdbTypeSetIntset).str("ids").str(intset.String())
+	b.op(rdbTypeZSetZiplist).str("scores").str(ziplist("c", 3))
+	b.op(rdbTypeListQuicklist2).str("queue").length(1).length(quicklistPacked).str(listpack("a", "b"))
+	b.op(rdbTypeModule2).str("bloom").length(42).length(rdbModuleOpEOF)
+
+	b.op(rdbOpSelectDB).length(3)
+	b.op(rdbTypeString).str("user:1").str("other db")
+
+	path := filepath.Join(t.TempDir(), "dump.rdb")
+	require.NoError(t, os.WriteFile(path, b.end(), 0644))
+	return path
+}
+
+func TestRDBFileClient_Read(t *testing.T) {
+	expireAt := time.UnixMilli(time.Now().Add(time.Hour).UnixMilli())
+	c := NewRDBFileClient(&ClientConfig{RDBFile: writeRDBFile(t, expireAt)})
+	require.NoError(t, c.Connect())
+	defer c.Disconnect()
+	require.NoError(t, c.Ping())
+
+	keys, err := c.GetAllKeys()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user:1", "user:2", "ids", "scores", "queue", "bloom"}, keys, "expired keys are skipped")
+
+	keys, err = c.GetKeysByPattern("user:*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user:1", "user:2"}, keys)
+
+	keyType, err := c.GetKeyType("user:2")
+	require.NoError(t, err)
+	assert.Equal(t, "hash", keyType)
+	keyType, err = c.GetKeyType("expired")
+	require.NoError(t, err)
+	assert.Equal(t, "none", keyType)
+
+	expected := map[string]interface{}{
+		"user:1": "alice",
+		"user:2": map[string]string{"name": "bob", "age": "42"},
+		"ids":    []string{"3", "9"},
+		"scores": []redis.Z{{Score: 3, Member: "c"}},
+		"queue":  []string{"a", "b"},
+	}
+	for key, value := range expected {
+		got, err := c.GetValue(key)
+		require.NoError(t, err, key)
+		assert.Equal(t, value, got, key)
+	}
+	_, err = c.GetValue("expired")
+	assert.Error(t, err)
+	_, err = c.GetValue("bloom")
+	assert.ErrorContains(t, err, "module type")
+
+	ttl, err := c.GetTTL("user:1")
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(-1), ttl)
+	ttl, err = c.GetTTL("user:2")
+	require.NoError(t, err)
+	assert.Greater(t, ttl, 59*time.Minute)
+	ttl, err = c.GetTTL("expired")
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(-2), ttl)
+
+	at, exists, err := c.GetExpireAt("user:2")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.True(t, at.Equal(expireAt))
+
+	exists, err = c.Exists("expired")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestRDBFileClient_Batch(t *testing.T) {
+	expireAt := time.UnixMilli(time.Now().Add(time.Hour).UnixMilli())
+	c := NewRDBFileClient(&ClientConfig{RDBFile: writeRDBFile(t, expireAt)})
+	require.NoError(t, c.Connect())
+	defer c.Disconnect()
+
+	records, err := c.GetBatch([]string{"user:1", "user:2", "missing", "bloom"})
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	assert.Equal(t, KeyRecord{Key: "user:1", Type: "string", Value: "alice"}, records[0])
+	assert.Equal(t, "hash", records[1].Type)
+	assert.True(t, records[1].ExpireAt.Equal(expireAt))
+	assert.Equal(t, KeyRecord{Key: "missing", Type: "none"}, records[2])
+	assert.Error(t, records[3].Err, "module values cannot be read")
+
+	exists, err := c.ExistsBatch([]string{"user:1", "expired", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, false, false}, exists)
+}
+
+func TestRDBFileClient_Database(t *testing.T) {
+	c := NewRDBFileClient(&ClientConfig{RDBFile: writeRDBFile(t, time.Now().Add(time.Hour)), Database: 3})
+	require.NoError(t, c.Connect())
+	defer c.Disconnect()
+
+	keys, err := c.GetAllKeys()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user:1"}, keys)
+
+	value, err := c.GetValue("user:1")
+	require.NoError(t, err)
+	assert.Equal(t, "other db", value)
+
+	keyspace, err := c.Keyspace()
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int64{0: 6, 3: 1}, keyspace, "expired keys are not counted")
+}
+
+func TestRDBFileClient_ScanPage(t *testing.T) {
+	c := NewRDBFileClient(&ClientConfig{RDBFile: writeRDBFile(t, time.Now().Add(time.Hour))})
+	require.NoError(t, c.Connect())
+	defer c.Disconnect()
+
+	nodes, err := c.ScanNodes()
+	require.NoError(t, err)
+	assert.Equal(t, []string{StandaloneNode}, nodes)
+
+	var pages [][]string
+	var cursor uint64
+	for {
+		keys, next, err := c.ScanPage(StandaloneNode, cursor, "*", 3)
+		require.NoError(t, err)
+		pages = append(pages, keys)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	assert.Equal(t, [][]string{{"user:1", "user:2"}, {"ids", "scores", "queue"}, {"bloom"}}, pages, "expired keys are skipped")
+
+	keys, next, err := c.ScanPage(StandaloneNode, 3, "user:*", 10)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+	assert.Zero(t, next)
+
+	_, _, err = c.ScanPage("127.0.0.1:7000", 0, "*", 10)
+	assert.Error(t, err)
+}
+
+func TestRDBFileClient_ReadOnly(t *testing.T) {
+	c := NewRDBFileClient(&ClientConfig{RDBFile: writeRDBFile(t, time.Now().Add(time.Hour))})
+	require.NoError(t, c.Connect())
+	defer c.Disconnect()
+
+	assert.ErrorIs(t, c.SetValue("k", "v"), ErrReadOnly)
+	assert.ErrorIs(t, c.SetTTL("k", time.Minute), ErrReadOnly)
+	assert.ErrorIs(t, c.SetExpireAt("k", time.Now()), ErrReadOnly)
+	assert.ErrorIs(t, c.SetValueWithExpiry("k", "v", time.Now()), ErrReadOnly)
+	_, err := c.SetBatch([]KeyRecord{{Key: "k", Type: "string", Value: "v"}})
+	assert.ErrorIs(t, err, ErrReadOnly)
+}
+
+func TestRDBFileClient_Errors(t *testing.T) {
+	c := NewRDBFileClient(&ClientConfig{RDBFile: filepath.Join(t.TempDir(), "missing.rdb")})
+	assert.Error(t, c.Connect())
+	assert.ErrorContains(t, c.Ping(), "not loaded")
+	_, err := c.GetAllKeys()
+	assert.Error(t, err)
+
+	// A corrupted file fails its checksum
+	path := writeRDBFile(t, time.Now().Add(time.Hour))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[len(data)-1] ^= 0xFF
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	c = NewRDBFileClient(&ClientConfig{RDBFile: path})
+	assert.Error(t, c.Connect())
+
+	// A truncated file fails instead of returning the keys read so far, the string cut
+	// short claiming more bytes than the file has left
+	require.NoError(t, os.WriteFile(path, data[:len(data)/2], 0644))
+	c = NewRDBFileClient(&ClientConfig{RDBFile: path})
+	assert.ErrorIs(t, c.Connect(), errCorruptEncoding)
+
+	// A length beyond the end of the file is reported with its offset
+	b := newRDBBuilder(12)
+	b.op(rdbTypeString).str("key").length(1 << 30)
+	require.NoError(t, os.WriteFile(path, b.end(), 0644))
+	c = NewRDBFileClient(&ClientConfig{RDBFile: path})
+	err = c.Connect()
+	assert.ErrorIs(t, err, errCorruptEncoding)
+	assert.ErrorContains(t, err, "at offset 19")
+
+	// Keys cannot be read once the file is closed
+	c = NewRDBFileClient(&ClientConfig{RDBFile: writeRDBFile(t, time.Now().Add(time.Hour))})
+	require.NoError(t, c.Connect())
+	require.NoError(t, c.Disconnect())
+	_, err = c.GetValue("user:1")
+	assert.Error(t, err)
+}
@@ -0,0 +1,473 @@
+package client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rdbBuilder writes RDB snapshots for tests
+type rdbBuilder struct {
+	bytes.Buffer
+}
+
+func newRDBBuilder(version int) *rdbBuilder {
+	b := &rdbBuilder{}
+	fmt.Fprintf(b, "REDIS%04d", version)
+	return b
+}
+
+func (b *rdbBuilder) length(n int) *rdbBuilder {
+	switch {
+	case n < 1<<6:
+		b.WriteByte(byte(n))
+	case n < 1<<14:
+		b.WriteByte(0x40 | byte(n>>8))
+		b.WriteByte(byte(n))
+	default:
+		b.WriteByte(0x80)
+		binary.Write(b, binary.BigEndian, uint32(n))
+	}
+	return b
+}
+
+func (b *rdbBuilder) str(s string) *rdbBuilder {
+	b.length(len(s))
+	b.WriteString(s)
+	return b
+}
+
+func (b *rdbBuilder) op(op byte) *rdbBuilder {
+	b.WriteByte(op)
+	return b
+}
+
+func (b *rdbBuilder) ms(t time.Time) *rdbBuilder {
+	binary.Write(b, binary.LittleEndian, uint64(t.UnixMilli()))
+	return b
+}
+
+func (b *rdbBuilder) streamID(ms, seq uint64) *rdbBuilder {
+	binary.Write(b, binary.BigEndian, ms)
+	binary.Write(b, binary.BigEndian, seq)
+	return b
+}
+
+// end writes the EOF opcode and the checksum of the snapshot
+func (b *rdbBuilder) end() []byte {
+	b.WriteByte(rdbOpEOF)
+	binary.Write(b, binary.LittleEndian, rdbChecksum(0, b.Bytes()))
+	return b.Bytes()
+}
+
+// listpack encodes strings and ints as a listpack
+func listpack(items ...interface{}) string {
+	var entries bytes.Buffer
+	for _, item := range items {
+		var entry []byte
+		switch v := item.(type) {
+		case string:
+			entry = append([]byte{0x80 | byte(len(v))}, v...)
+		case int:
+			switch {
+			case v >= 0 && v < 128:
+				entry = []byte{byte(v)}
+			case v >= -4096 && v < 4096:
+				u := uint16(v) & 0x1FFF
+				entry = []byte{0xC0 | byte(u>>8), byte(u)}
+			case v >= -32768 && v < 32768:
+				entry = []byte{0xF1, byte(v), byte(v >> 8)}
+			default:
+				entry = binary.LittleEndian.AppendUint64([]byte{0xF4}, uint64(v))
+			}
+		}
+		entries.Write(entry)
+		entries.WriteByte(byte(len(entry)))
+	}
+	entries.WriteByte(0xFF)
+
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, uint32(6+entries.Len()))
+	binary.Write(&b, binary.LittleEndian, uint16(len(items)))
+	b.Write(entries.Bytes())
+	return b.String()
+}
+
+// ziplist encodes strings and ints as a ziplist
+func ziplist(items ...interface{}) string {
+	var entries bytes.Buffer
+	prev := 0
+	for _, item := range items {
+		var entry []byte
+		switch v := item.(type) {
+		case string:
+			entry = append([]byte{byte(prev), byte(len(v))}, v...)
+		case int:
+			if v >= 0 && v <= 12 {
+				entry = []byte{byte(prev), 0xF1 + byte(v)}
+			} else {
+				entry = []byte{byte(prev), 0xC0, byte(v), byte(v >> 8)}
+			}
+		}
+		entries.Write(entry)
+		prev = len(entry)
+	}
+	entries.WriteByte(0xFF)
+
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, uint32(10+entries.Len()))
+	binary.Write(&b, binary.LittleEndian, uint32(0))
+	binary.Write(&b, binary.LittleEndian, uint16(len(items)))
+	b.Write(entries.Bytes())
+	return b.String()
+}
+
+func readAllEntries(t *testing.T, data []byte) []*RDBEntry {
+	t.Helper()
+	parser := NewRDBParser(bytes.NewReader(data))
+
+	var entries []*RDBEntry
+	for {
+		entry, err := parser.Next()
+		if err == io.EOF {
+			return entries
+		}
+		require.NoError(t, err)
+		entries = append(entries, entry)
+	}
+}
+
+func TestRDBChecksum(t *testing.T) {
+	assert.Equal(t, uint64(0xe9c6d914c4b8d9ca), rdbChecksum(0, []byte("123456789")))
+	// Updating in parts gives the same checksum
+	assert.Equal(t, rdbChecksum(0, []byte("123456789")), rdbChecksum(rdbChecksum(0, []byte("1234")), []byte("56789")))
+}
+
+func TestLZFDecompress(t *testing.T) {
+	// A literal "a" followed by a back reference repeating it nine times
+	out, err := lzfDecompress([]byte{0x00, 'a', 0xE0, 0x00, 0x00}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "aaaaaaaaaa", string(out))
+
+	_, err = lzfDecompress([]byte{0x00, 'a', 0xE0, 0x00, 0x00}, 11)
+	assert.Error(t, err)
+
+	_, err = lzfDecompress([]byte{0x20, 0x05}, 3)
+	assert.Error(t, err, "reference before the start of the output")
+}
+
+func TestRDBParser_Types(t *testing.T) {
+	expireAt := time.UnixMilli(time.Now().Add(time.Hour).UnixMilli())
+
+	b := newRDBBuilder(12)
+	b.op(rdbOpAux).str("redis-ver").str("7.2.4")
+	b.op(rdbOpSelectDB).length(2)
+	b.op(rdbOpResizeDB).length(12).length(1)
+
+	b.op(rdbOpExpireTimeMs).ms(expireAt)
+	b.op(rdbTypeString).str("plain").str("hello")
+	// Integer encoded string
+	b.op(rdbTypeString).str("counter").op(0xC1)
+	binary.Write(b, binary.LittleEndian, int16(-300))
+	// LZF compressed string
+	b.op(rdbTypeString).str("compressed").op(0xC3).length(5).length(10)
+	b.Write([]byte{0x00, 'a', 0xE0, 0x00, 0x00})
+
+	b.op(rdbTypeList).str("oldlist").length(2).str("x").str("y")
+	b.op(rdbTypeListQuicklist2).str("list").length(2).
+		length(quicklistPacked).str(listpack("a", 1, -5)).
+		length(quicklistPlain).str("big")
+	b.op(rdbTypeListQuicklist).str("ziplist-quicklist").length(1).str(ziplist("q", 7))
+	b.op(rdbTypeListZiplist).str("ziplist-list").str(ziplist("z", 300))
+
+	b.op(rdbTypeSet).str("oldset").length(1).str("m")
+	b.op(rdbTypeSetListpack).str("set").str(listpack("m1", 2))
+	var intset bytes.Buffer
+	binary.Write(&intset, binary.LittleEndian, []uint32{2, 2})
+	binary.Write(&intset, binary.LittleEndian, []int16{-1, 7})
+	b.op(rdbTypeSetIntset).str("intset").str(intset.String())
+
+	b.op(rdbTypeZSet2).str("zset").length(1).str("member")
+	binary.Write(b, binary.LittleEndian, 1.5)
+	b.op(rdbTypeZSet).str("oldzset").length(1).str("member").op(3).WriteString("2.5")
+	b.op(rdbTypeZSetListpack).str("lpzset").str(listpack("a", 1, "b", "2.5"))
+	b.op(rdbTypeZSetZiplist).str("zlzset").str(ziplist("c", 3))
+
+	b.op(rdbTypeHash).str("hash").length(1).str("f").str("v")
+	b.op(rdbTypeHashListpack).str("lphash").str(listpack("f", "v", "n", 10))
+	b.op(rdbTypeHashZiplist).str("zlhash").str(ziplist("f", "v"))
+	zipmap := []byte{1, 1, 'f', 1, 2, 'v', 0, 0, 0xFF}
+	b.op(rdbTypeHashZipmap).str("zmhash").str(string(zipmap))
+
+	entries := readAllEntries(t, b.end())
+
+	byKey := make(map[string]*RDBEntry)
+	for _, entry := range entries {
+		assert.Equal(t, 2, entry.DB)
+		byKey[entry.Key] = entry
+	}
+
+	assert.Equal(t, &RDBEntry{DB: 2, Key: "plain", Type: "string", Value: "hello", ExpireAt: expireAt}, byKey["plain"])
+	assert.True(t, byKey["counter"].ExpireAt.IsZero(), "expiry only applies to the next key")
+
+	expected := map[string]interface{}{
+		"counter":           "-300",
+		"compressed":        "aaaaaaaaaa",
+		"oldlist":           []string{"x", "y"},
+		"list":              []string{"a", "1", "-5", "big"},
+		"ziplist-quicklist": []string{"q", "7"},
+		"ziplist-list":      []string{"z", "300"},
+		"oldset":            []interface{}{"m"},
+		"set":               []interface{}{"m1", "2"},
+		"intset":            []interface{}{"-1", "7"},
+		"zset":              []redis.Z{{Score: 1.5, Member: "member"}},
+		"oldzset":           []redis.Z{{Score: 2.5, Member: "member"}},
+		"lpzset":            []redis.Z{{Score: 1, Member: "a"}, {Score: 2.5, Member: "b"}},
+		"zlzset":            []redis.Z{{Score: 3, Member: "c"}},
+		"hash":              map[string]string{"f": "v"},
+		"lphash":            map[string]string{"f": "v", "n": "10"},
+		"zlhash":            map[string]string{"f": "v"},
+		"zmhash":            map[string]string{"f": "v"},
+	}
+	for key, value := range expected {
+		require.Contains(t, byKey, key)
+		assert.Equal(t, value, byKey[key].Value, key)
+	}
+	assert.Len(t, entries, len(expected)+1)
+}
+
+func TestRDBParser_Aux(t *testing.T) {
+	b := newRDBBuilder(11)
+	b.op(rdbOpAux).str("redis-ver").str("7.0.0")
+	b.op(rdbOpFunction2).str("#!lua name=lib\nredis.register_function('f', function() end)")
+	parser := NewRDBParser(bytes.NewReader(b.end()))
+
+	_, err := parser.Next()
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "7.0.0", parser.Aux["redis-ver"])
+}
+
+func TestRDBParser_HashFieldExpiry(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute).UnixMilli()
+	future := now.Add(time.Hour).UnixMilli()
+
+	b := newRDBBuilder(12)
+	b.op(rdbTypeHashListpackEx).str("lp").ms(time.UnixMilli(past)).
+		str(listpack("gone", "1", int(past), "kept", "2", int(future), "plain", "3", 0))
+
+	entries := readAllEntries(t, b.end())
+	require.Len(t, entries, 1)
+	assert.Equal(t, map[string]string{"kept": "2", "plain": "3"}, entries[0].Value)
+
+	b = newRDBBuilder(12)
+	b.op(rdbTypeHashMetadata).str("meta").ms(time.UnixMilli(past)).length(3)
+	// Field expiry relative to the minimum expiry, plus one
+	b.length(1).str("gone").str("1")
+	b.length(int(future-past) + 1).str("kept").str("2")
+	b.length(0).str("plain").str("3")
+
+	entries = readAllEntries(t, b.end())
+	require.Len(t, entries, 1)
+	assert.Equal(t, "hash", entries[0].Type)
+	assert.Equal(t, map[string]string{"kept": "2", "plain": "3"}, entries[0].Value)
+}
+
+func TestRDBParser_Stream(t *testing.T) {
+	deliveredAt := time.Now().Add(-time.Minute)
+
+	node := listpack(
+		// Master entry: 2 entries, 1 deleted, shared field "f"
+		2, 1, 1, "f", 0,
+		// Entry with the master fields
+		streamItemSameFields, 0, 0, "v1", 4,
+		// Entry with its own fields
+		0, 1, 0, 2, "a", "1", "b", "2", 8,
+		// Deleted entry
+		streamItemDeleted|streamItemSameFields, 2, 0, "gone", 4,
+	)
+
+	b := newRDBBuilder(12)
+	b.op(rdbTypeStreamListpacks3).str("events").length(1)
+	var master rdbBuilder
+	master.streamID(1000, 0)
+	b.str(master.String()).str(node)
+	// Length and last generated ID
+	b.length(2).length(1002).length(0)
+	// First ID, max deleted ID and entries added
+	b.length(1000).length(0).length(1002).length(0).length(3)
+	// One group with one pending entry owned by one consumer
+	b.length(1).str("workers").length(1001).length(0).length(2)
+	b.length(1).streamID(1001, 0).ms(deliveredAt).length(3)
+	b.length(1).str("alice").ms(deliveredAt).ms(deliveredAt).length(1).streamID(1001, 0)
+
+	entries := readAllEntries(t, b.end())
+	require.Len(t, entries, 1)
+	assert.Equal(t, "stream", entries[0].Type)
+
+	stream, ok := entries[0].Value.(*StreamValue)
+	require.True(t, ok)
+	assert.Equal(t, []StreamEntry{
+		{ID: "1000-0", Fields: []string{"f", "v1"}},
+		{ID: "1001-0", Fields: []string{"a", "1", "b", "2"}},
+	}, stream.Entries)
+	assert.Equal(t, "1002-0", stream.LastGeneratedID)
+	assert.Equal(t, "1002-0", stream.MaxDeletedID)
+	assert.Equal(t, int64(3), stream.EntriesAdded)
+
+	require.Len(t, stream.Groups, 1)
+	group := stream.Groups[0]
+	assert.Equal(t, "workers", group.Name)
+	assert.Equal(t, "1001-0", group.LastDeliveredID)
+	assert.Equal(t, int64(2), group.EntriesRead)
+	assert.Equal(t, []string{"alice"}, group.Consumers)
+	require.Len(t, group.Pending, 1)
+	assert.Equal(t, "1001-0", group.Pending[0].ID)
+	assert.Equal(t, "alice", group.Pending[0].Consumer)
+	assert.Equal(t, int64(3), group.Pending[0].DeliveryCount)
+	assert.InDelta(t, time.Minute.Seconds(), group.Pending[0].Idle.Seconds(), 5)
+}
+
+func TestRDBParser_ModuleKeysAreSkipped(t *testing.T) {
+	b := newRDBBuilder(12)
+	// Module aux data: module ID, when opcode and value, then an unsigned integer field
+	b.op(rdbOpModuleAux).length(42).length(rdbModuleOpUInt).length(2).
+		length(rdbModuleOpUInt).length(7).length(rdbModuleOpEOF)
+	b.op(rdbTypeModule2).str("bloom").length(42).
+		length(rdbModuleOpString).str("bits").length(rdbModuleOpDouble)
+	binary.Write(b, binary.LittleEndian, 0.01)
+	b.length(rdbModuleOpEOF)
+	b.op(rdbTypeString).str("after").str("value")
+
+	entries := readAllEntries(t, b.end())
+	require.Len(t, entries, 2)
+	assert.Equal(t, &RDBEntry{Key: "bloom", Type: "module"}, entries[0])
+	assert.Equal(t, "value", entries[1].Value)
+}
+
+func TestRDBParser_Errors(t *testing.T) {
+	valid := newRDBBuilder(12)
+	valid.op(rdbTypeString).str("key").str("value")
+	data := valid.end()
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		corrupt := append([]byte(nil), data...)
+		corrupt[len(corrupt)-1] ^= 0xFF
+		parser := NewRDBParser(bytes.NewReader(corrupt))
+		_, err := parser.Next()
+		require.NoError(t, err)
+		_, err = parser.Next()
+		assert.ErrorContains(t, err, "checksum mismatch")
+	})
+
+	t.Run("disabled checksum", func(t *testing.T) {
+		unchecked := append([]byte(nil), data[:len(data)-8]...)
+		unchecked = append(unchecked, make([]byte, 8)...)
+		assert.Len(t, readAllEntries(t, unchecked), 1)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		parser := NewRDBParser(bytes.NewReader(data[:15]))
+		_, err := parser.Next()
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+
+	t.Run("bad magic", func(t *testing.T) {
+		_, err := NewRDBParser(bytes.NewReader([]byte("HELLO0012"))).Next()
+		assert.ErrorContains(t, err, "not an RDB snapshot")
+	})
+
+	t.Run("newer version", func(t *testing.T) {
+		_, err := NewRDBParser(bytes.NewReader(newRDBBuilder(99).end())).Next()
+		assert.ErrorContains(t, err, "RDB version 99 is not supported")
+	})
+
+	t.Run("unknown type", func(t *testing.T) {
+		b := newRDBBuilder(12)
+		b.op(30).str("key")
+		_, err := NewRDBParser(bytes.NewReader(b.end())).Next()
+		assert.ErrorContains(t, err, "unsupported RDB value type 30")
+	})
+
+	t.Run("length beyond the snapshot", func(t *testing.T) {
+		b := newRDBBuilder(12)
+		b.op(rdbTypeString).str("key").length(1 << 30)
+		corrupt := b.end()
+		_, err := NewRDBParserWithSize(bytes.NewReader(corrupt), int64(len(corrupt))).Next()
+		assert.ErrorIs(t, err, errCorruptEncoding)
+		assert.ErrorContains(t, err, "at offset 19")
+
+		// Without a size, the string is read a chunk at a time until the snapshot ends
+		_, err = NewRDBParser(bytes.NewReader(corrupt)).Next()
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+
+	t.Run("length above the int range", func(t *testing.T) {
+		corrupt := []byte("REDIS0001\x00\x81\xff\xff\xff\xff\xff\xff\xff\xff")
+		_, err := NewRDBParser(bytes.NewReader(corrupt)).Next()
+		assert.ErrorIs(t, err, errCorruptEncoding)
+	})
+
+	t.Run("collection count beyond the snapshot", func(t *testing.T) {
+		b := newRDBBuilder(12)
+		b.op(rdbTypeSet).str("key").length(1 << 30)
+		corrupt := b.end()
+		_, err := NewRDBParserWithSize(bytes.NewReader(corrupt), int64(len(corrupt))).Next()
+		assert.ErrorIs(t, err, errCorruptEncoding)
+	})
+}
+
+func FuzzRDBParser(f *testing.F) {
+	valid := newRDBBuilder(12)
+	valid.op(rdbOpAux).str("redis-ver").str("7.2.4")
+	valid.op(rdbOpSelectDB).length(0)
+	valid.op(rdbTypeString).str("key").str("value")
+	valid.op(rdbTypeList).str("list").length(2).str("a").str("b")
+	valid.op(rdbTypeHashListpack).str("hash").str(listpack("field", "value"))
+	valid.op(rdbTypeSetIntset).str("set").str("\x02\x00\x00\x00\x01\x00\x00\x00\x07\x00")
+	f.Add(valid.end())
+	f.Add([]byte("REDIS00010\x8100000000"))
+	f.Add([]byte("REDIS0012\x00\x03key\xc3\x05\x0a\x00a\xe0\x00\x00"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, parser := range []*RDBParser{
+			NewRDBParser(bytes.NewReader(data)),
+			NewRDBParserWithSize(bytes.NewReader(data), int64(len(data))),
+		} {
+			for {
+				if _, err := parser.Next(); err != nil {
+					break
+				}
+			}
+		}
+	})
+}
+
+func TestParseListpack_Encodings(t *testing.T) {
+	// 12-bit string length, 32-bit integer and 64-bit integer
+	long := string(bytes.Repeat([]byte("x"), 100))
+	var entries bytes.Buffer
+	entries.Write([]byte{0xE0, 100})
+	entries.WriteString(long)
+	entries.Write([]byte{102})
+	entries.Write([]byte{0xF3, 0x00, 0x00, 0x00, 0x80, 5})
+	entries.Write([]byte{0xF4})
+	binary.Write(&entries, binary.LittleEndian, int64(1)<<40)
+	entries.WriteByte(9)
+	entries.WriteByte(0xFF)
+
+	blob := append(make([]byte, 6), entries.Bytes()...)
+	items, err := parseListpack(blob)
+	require.NoError(t, err)
+	assert.Equal(t, []string{long, "-2147483648", "1099511627776"}, items)
+
+	_, err = parseListpack(blob[:len(blob)-1])
+	assert.ErrorIs(t, err, errCorruptEncoding)
+}
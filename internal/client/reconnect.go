@@ -0,0 +1,10 @@
+package client
+
+// Reconnector is implemented by clients that can replace their connection with a new one.
+// A client connected through Sentinel looks up the current master again, so that
+// retries after a failover do not keep going to the old master.
+type Reconnector interface {
+	// Reconnect opens a new connection and closes the old one once the new one works.
+	// Commands still running on the old connection fail and can be retried.
+	Reconnect() error
+}
@@ -1,16 +1,25 @@
 package client
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
-// RedisClient implements DatabaseClient for Redis
+// RedisClient implements DatabaseClient for Redis, standalone or cluster
 type RedisClient struct {
-	client *redis.Client
+	// mu guards client and replica, which Reconnect replaces while other goroutines use them
+	mu     sync.RWMutex
+	client redis.UniversalClient
 	config *ClientConfig
+
+	// replica receives the heavy reads while useReplica is set
+	replica    redis.UniversalClient
+	useReplica atomic.Bool
 }
 
 // NewRedisClient creates a new Redis client
@@ -22,34 +31,210 @@ func NewRedisClient(config *ClientConfig) *RedisClient {
 
 // Connect establishes a connection to Redis
 func (r *RedisClient) Connect() error {
-	r.client = redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", r.config.Host, r.config.Port),
-		Password: r.config.Password,
-		DB:       r.config.Database,
-	})
+	uc, err := newUniversalClient(r.config)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	r.mu.Lock()
+	r.client = uc
+	r.mu.Unlock()
 
 	ctx, cancel := r.config.ConnectionContext()
 	defer cancel()
 
 	// Test the connection
-	if err := r.client.Ping(ctx).Err(); err != nil {
+	if err := uc.Ping(ctx).Err(); err != nil {
 		return fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
+	if r.config.Replica != "" {
+		replica, err := connectReplica(r.config)
+		if err != nil {
+			return fmt.Errorf("failed to connect to Redis replica: %w", err)
+		}
+		r.mu.Lock()
+		r.replica = replica
+		r.mu.Unlock()
+		r.useReplica.Store(true)
+	}
+
 	return nil
 }
 
 // Disconnect closes the Redis connection
 func (r *RedisClient) Disconnect() error {
-	if r.client != nil {
-		return r.client.Close()
+	r.mu.RLock()
+	replica := r.replica
+	r.mu.RUnlock()
+	if replica != nil {
+		replica.Close()
+	}
+
+	if uc := r.conn(); uc != nil {
+		return uc.Close()
+	}
+	return nil
+}
+
+// Reconnect replaces the Redis connection with a new one, looking up the current master
+// again when connected through Sentinel
+func (r *RedisClient) Reconnect() error {
+	uc, err := newUniversalClient(r.config)
+	if err != nil {
+		return fmt.Errorf("failed to reconnect to Redis: %w", err)
+	}
+
+	ctx, cancel := r.config.ConnectionContext()
+	defer cancel()
+
+	if err := uc.Ping(ctx).Err(); err != nil {
+		uc.Close()
+		return fmt.Errorf("failed to reconnect to Redis: %w", err)
+	}
+
+	var replica redis.UniversalClient
+	if r.config.Replica != "" {
+		if replica, err = connectReplica(r.config); err != nil {
+			uc.Close()
+			return fmt.Errorf("failed to reconnect to Redis replica: %w", err)
+		}
+	}
+
+	r.mu.Lock()
+	old, oldReplica := r.client, r.replica
+	r.client, r.replica = uc, replica
+	r.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	if oldReplica != nil {
+		oldReplica.Close()
 	}
 	return nil
 }
 
+// conn returns the current connection to the primary
+func (r *RedisClient) conn() redis.UniversalClient {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.client
+}
+
+// reader returns the connection heavy reads go to: the replica while it is in use,
+// the primary otherwise
+func (r *RedisClient) reader() redis.UniversalClient {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.replica != nil && r.useReplica.Load() {
+		return r.replica
+	}
+	return r.client
+}
+
+// readingFromReplica reports whether heavy reads currently go to the replica
+func (r *RedisClient) readingFromReplica() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.replica != nil && r.useReplica.Load()
+}
+
+// ReplicationLag returns how many bytes the replica is behind the primary
+func (r *RedisClient) ReplicationLag() (int64, error) {
+	if r.conn() == nil {
+		return 0, fmt.Errorf("Redis client not connected")
+	}
+
+	r.mu.RLock()
+	replica := r.replica
+	r.mu.RUnlock()
+	if replica == nil {
+		return 0, ErrUnsupported
+	}
+
+	ctx, cancel := r.config.OperationContext("info", 0)
+	defer cancel()
+
+	return replicationLag(ctx, r.conn(), replica)
+}
+
+// UseReplica switches the heavy reads to the replica, or back to the primary
+func (r *RedisClient) UseReplica(enabled bool) {
+	r.useReplica.Store(enabled)
+}
+
+// EnableKeyNotifications enables keyevent notifications for every change on the primary
+func (r *RedisClient) EnableKeyNotifications() error {
+	if r.conn() == nil {
+		return fmt.Errorf("Redis client not connected")
+	}
+
+	ctx, cancel := r.config.OperationContext("config", 0)
+	defer cancel()
+
+	return enableKeyNotifications(ctx, r.conn())
+}
+
+// SubscribeKeyEvents subscribes to the keyevent notifications of the primary
+func (r *RedisClient) SubscribeKeyEvents(ctx context.Context) (<-chan KeyEvent, error) {
+	if r.conn() == nil {
+		return nil, fmt.Errorf("Redis client not connected")
+	}
+
+	return subscribeKeyEvents(ctx, r.conn, r.config.Database, r.config.ConnectionTimeout)
+}
+
+// PauseWrites blocks write commands on the primary for at most timeout
+func (r *RedisClient) PauseWrites(timeout time.Duration) error {
+	if r.conn() == nil {
+		return fmt.Errorf("Redis client not connected")
+	}
+
+	ctx, cancel := r.config.OperationContext("client", 0)
+	defer cancel()
+
+	return pauseWrites(ctx, r.conn(), timeout)
+}
+
+// UnpauseWrites lets write commands through on the primary again
+func (r *RedisClient) UnpauseWrites() error {
+	if r.conn() == nil {
+		return fmt.Errorf("Redis client not connected")
+	}
+
+	ctx, cancel := r.config.OperationContext("client", 0)
+	defer cancel()
+
+	return unpauseWrites(ctx, r.conn())
+}
+
+// ReplicationOffset returns the replication offset of the primary
+func (r *RedisClient) ReplicationOffset() (int64, error) {
+	if r.conn() == nil {
+		return 0, fmt.Errorf("Redis client not connected")
+	}
+
+	ctx, cancel := r.config.OperationContext("info", 0)
+	defer cancel()
+
+	return replicationOffset(ctx, r.conn())
+}
+
+// Keyspace returns the number of keys in every non-empty database
+func (r *RedisClient) Keyspace() (map[int]int64, error) {
+	if r.conn() == nil {
+		return nil, fmt.Errorf("Redis client not connected")
+	}
+
+	ctx, cancel := r.config.OperationContext("info", 0)
+	defer cancel()
+
+	return keyspace(ctx, r.conn())
+}
+
 // GetAllKeys retrieves all keys from Redis
 func (r *RedisClient) GetAllKeys() ([]string, error) {
-	if r.client == nil {
+	if r.conn() == nil {
 		return nil, fmt.Errorf("Redis client not connected")
 	}
 
@@ -57,14 +242,8 @@ func (r *RedisClient) GetAllKeys() ([]string, error) {
 	ctx, cancel := r.config.OperationContext("scan", 0)
 	defer cancel()
 
-	var keys []string
-	iter := r.client.Scan(ctx, 0, "*", 0).Iterator()
-
-	for iter.Next(ctx) {
-		keys = append(keys, iter.Val())
-	}
-
-	if err := iter.Err(); err != nil {
+	keys, err := scanKeys(ctx, r.reader(), "*", r.config.ReadFromReplicas)
+	if err != nil {
 		return nil, fmt.Errorf("failed to scan keys: %w", err)
 	}
 
@@ -73,7 +252,7 @@ func (r *RedisClient) GetAllKeys() ([]string, error) {
 
 // GetKeysByPattern retrieves keys matching a specific pattern from Redis
 func (r *RedisClient) GetKeysByPattern(pattern string) ([]string, error) {
-	if r.client == nil {
+	if r.conn() == nil {
 		return nil, fmt.Errorf("Redis client not connected")
 	}
 
@@ -81,23 +260,51 @@ func (r *RedisClient) GetKeysByPattern(pattern string) ([]string, error) {
 	ctx, cancel := r.config.OperationContext("scan", 0)
 	defer cancel()
 
-	var keys []string
-	iter := r.client.Scan(ctx, 0, pattern, 0).Iterator()
+	keys, err := scanKeys(ctx, r.reader(), pattern, r.config.ReadFromReplicas)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan keys with pattern %s: %w", pattern, err)
+	}
+
+	return keys, nil
+}
 
-	for iter.Next(ctx) {
-		keys = append(keys, iter.Val())
+// ScanNodes returns the nodes whose keys are scanned
+func (r *RedisClient) ScanNodes() ([]string, error) {
+	if r.conn() == nil {
+		return nil, fmt.Errorf("Redis client not connected")
 	}
 
-	if err := iter.Err(); err != nil {
-		return nil, fmt.Errorf("failed to scan keys with pattern %s: %w", pattern, err)
+	ctx, cancel := r.config.OperationContext("scan", 0)
+	defer cancel()
+
+	nodes, err := scanNodes(ctx, r.reader(), r.config.ReadFromReplicas)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes to scan: %w", err)
 	}
 
-	return keys, nil
+	return nodes, nil
+}
+
+// ScanPage returns a page of the keys of a node matching pattern
+func (r *RedisClient) ScanPage(node string, cursor uint64, pattern string, count int64) ([]string, uint64, error) {
+	if r.conn() == nil {
+		return nil, 0, fmt.Errorf("Redis client not connected")
+	}
+
+	ctx, cancel := r.config.OperationContext("scan", count)
+	defer cancel()
+
+	keys, next, err := scanPage(ctx, r.reader(), node, cursor, pattern, count)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to scan keys: %w", err)
+	}
+
+	return keys, next, nil
 }
 
 // GetKeyType returns the data type of a Redis key
 func (r *RedisClient) GetKeyType(key string) (string, error) {
-	if r.client == nil {
+	if r.conn() == nil {
 		return "", fmt.Errorf("Redis client not connected")
 	}
 
@@ -105,7 +312,7 @@ func (r *RedisClient) GetKeyType(key string) (string, error) {
 	ctx, cancel := r.config.OperationContext("type", 0)
 	defer cancel()
 
-	keyType, err := r.client.Type(ctx, key).Result()
+	keyType, err := r.conn().Type(ctx, key).Result()
 	if err != nil {
 		return "", fmt.Errorf("failed to get key type for %s: %w", key, err)
 	}
@@ -115,7 +322,7 @@ func (r *RedisClient) GetKeyType(key string) (string, error) {
 
 // GetValue retrieves the value for a key, handling all Redis data types
 func (r *RedisClient) GetValue(key string) (interface{}, error) {
-	if r.client == nil {
+	if r.conn() == nil {
 		return nil, fmt.Errorf("Redis client not connected")
 	}
 
@@ -134,17 +341,20 @@ func (r *RedisClient) GetValue(key string) (interface{}, error) {
 	ctx, cancel := r.config.OperationContext(keyType, dataSize)
 	defer cancel()
 
+	reader := r.reader()
 	switch keyType {
 	case "string":
-		return r.client.Get(ctx, key).Result()
+		return reader.Get(ctx, key).Result()
 	case "hash":
-		return r.client.HGetAll(ctx, key).Result()
+		return reader.HGetAll(ctx, key).Result()
 	case "list":
-		return r.client.LRange(ctx, key, 0, -1).Result()
+		return reader.LRange(ctx, key, 0, -1).Result()
 	case "set":
-		return r.client.SMembers(ctx, key).Result()
+		return reader.SMembers(ctx, key).Result()
 	case "zset":
-		return r.client.ZRangeWithScores(ctx, key, 0, -1).Result()
+		return reader.ZRangeWithScores(ctx, key, 0, -1).Result()
+	case "stream":
+		return readStream(ctx, reader, key)
 	case "none":
 		return nil, fmt.Errorf("key does not exist")
 	default:
@@ -157,136 +367,273 @@ func (r *RedisClient) estimateDataSize(key, keyType string) (int64, error) {
 	ctx, cancel := r.config.OperationContext("size", 0)
 	defer cancel()
 
-	switch keyType {
-	case "string":
-		// For strings, get the length
-		length, err := r.client.StrLen(ctx, key).Result()
-		return length, err
-	case "hash":
-		// For hashes, get the number of fields
-		length, err := r.client.HLen(ctx, key).Result()
-		return length, err
-	case "list":
-		// For lists, get the length
-		length, err := r.client.LLen(ctx, key).Result()
-		return length, err
-	case "set":
-		// For sets, get the cardinality
-		length, err := r.client.SCard(ctx, key).Result()
-		return length, err
-	case "zset":
-		// For sorted sets, get the cardinality
-		length, err := r.client.ZCard(ctx, key).Result()
-		return length, err
-	case "none":
-		// Key doesn't exist, size is 0
-		return 0, nil
-	default:
-		return 0, nil
-	}
+	return keySize(ctx, r.reader(), key, keyType)
 }
 
 // SetValue stores a value for a key, handling all Redis data types
 func (r *RedisClient) SetValue(key string, value interface{}) error {
-	if r.client == nil {
+	if r.conn() == nil {
 		return fmt.Errorf("Redis client not connected")
 	}
 
 	// Determine data type and size for timeout calculation
-	var dataType string
-	var dataSize int64
-
-	switch v := value.(type) {
-	case string:
-		dataType = "string"
-		dataSize = int64(len(v))
-		ctx, cancel := r.config.OperationContext(dataType, dataSize)
-		defer cancel()
-		return r.client.Set(ctx, key, v, 0).Err()
-	case map[string]string:
-		dataType = "hash"
-		dataSize = int64(len(v))
-		ctx, cancel := r.config.OperationContext(dataType, dataSize)
-		defer cancel()
-		return r.client.HMSet(ctx, key, v).Err()
-	case []string:
-		dataType = "list"
-		dataSize = int64(len(v))
-		ctx, cancel := r.config.OperationContext(dataType, dataSize)
-		defer cancel()
-
-		// Clear existing list first
-		pipe := r.client.Pipeline()
-		pipe.Del(ctx, key)
-
-		// Add all elements (if any)
-		if len(v) > 0 {
-			for _, item := range v {
-				pipe.RPush(ctx, key, item)
-			}
-		} else {
-			// Create empty list by pushing and popping a dummy value
-			pipe.LPush(ctx, key, "dummy")
-			pipe.LPop(ctx, key)
-		}
+	dataType, dataSize, err := describeValue(value)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := r.config.OperationContext(dataType, dataSize)
+	defer cancel()
+
+	return setValueWithExpiry(ctx, r.conn(), key, value, time.Time{})
+}
 
-		_, err := pipe.Exec(ctx)
+// SetValueWithExpiry stores a value and its absolute expiry in a single transaction
+func (r *RedisClient) SetValueWithExpiry(key string, value interface{}, expireAt time.Time) error {
+	if r.conn() == nil {
+		return fmt.Errorf("Redis client not connected")
+	}
+
+	// Determine data type and size for timeout calculation
+	dataType, dataSize, err := describeValue(value)
+	if err != nil {
 		return err
-	case []interface{}:
-		dataType = "set"
-		dataSize = int64(len(v))
-		ctx, cancel := r.config.OperationContext(dataType, dataSize)
-		defer cancel()
-
-		// For sets - clear existing set first
-		pipe := r.client.Pipeline()
-		pipe.Del(ctx, key)
-
-		if len(v) > 0 {
-			pipe.SAdd(ctx, key, v...)
-		} else {
-			// Create empty set by adding and removing a dummy value
-			pipe.SAdd(ctx, key, "dummy")
-			pipe.SRem(ctx, key, "dummy")
+	}
+
+	ctx, cancel := r.config.OperationContext(dataType, dataSize)
+	defer cancel()
+
+	return setValueWithExpiry(ctx, r.conn(), key, value, expireAt)
+}
+
+// GetBatch reads the type, value and TTL of several keys using pipelined round trips
+func (r *RedisClient) GetBatch(keys []string) ([]KeyRecord, error) {
+	if r.conn() == nil {
+		return nil, fmt.Errorf("Redis client not connected")
+	}
+
+	ctx, cancel := r.config.OperationContext("batch", int64(len(keys)))
+	defer cancel()
+
+	if !r.readingFromReplica() {
+		return getBatch(ctx, r.conn(), keys, r.config.largeDataThreshold())
+	}
+
+	records, err := getBatch(ctx, r.reader(), keys, r.config.largeDataThreshold())
+	if err != nil {
+		return nil, err
+	}
+
+	// Expiry is owned by the primary, a replica only learns about expired keys once
+	// the primary propagates their deletion
+	expireAts, exists, err := readExpiries(ctx, r.conn(), keys)
+	if err != nil {
+		return nil, err
+	}
+	for i := range records {
+		if records[i].Err != nil || records[i].Type == "none" {
+			continue
 		}
+		if !exists[i] {
+			records[i] = KeyRecord{Key: records[i].Key, Type: "none"}
+			continue
+		}
+		records[i].ExpireAt = expireAts[i]
+	}
 
-		_, err := pipe.Exec(ctx)
-		return err
-	case []redis.Z:
-		dataType = "zset"
-		dataSize = int64(len(v))
-		ctx, cancel := r.config.OperationContext(dataType, dataSize)
-		defer cancel()
-
-		// For sorted sets - clear existing sorted set first
-		pipe := r.client.Pipeline()
-		pipe.Del(ctx, key)
-
-		if len(v) > 0 {
-			pipe.ZAdd(ctx, key, v...)
-		} else {
-			// Create empty sorted set by adding and removing a dummy value
-			pipe.ZAdd(ctx, key, redis.Z{Score: 0, Member: "dummy"})
-			pipe.ZRem(ctx, key, "dummy")
+	return records, nil
+}
+
+// SetBatch writes several keys and their TTLs using a single pipeline
+func (r *RedisClient) SetBatch(records []KeyRecord) ([]error, error) {
+	if r.conn() == nil {
+		return nil, fmt.Errorf("Redis client not connected")
+	}
+
+	ctx, cancel := r.config.OperationContext("batch", int64(len(records)))
+	defer cancel()
+
+	return setBatch(ctx, r.conn(), records)
+}
+
+// RunCommands runs several commands in order using a single pipeline
+func (r *RedisClient) RunCommands(commands [][]interface{}) ([]error, error) {
+	if r.conn() == nil {
+		return nil, fmt.Errorf("Redis client not connected")
+	}
+
+	ctx, cancel := r.config.OperationContext("batch", int64(len(commands)))
+	defer cancel()
+
+	return runCommands(ctx, r.conn(), commands)
+}
+
+// RunTransaction runs several commands in order in a single MULTI/EXEC transaction
+func (r *RedisClient) RunTransaction(commands [][]interface{}) ([]error, error) {
+	if r.conn() == nil {
+		return nil, fmt.Errorf("Redis client not connected")
+	}
+
+	ctx, cancel := r.config.OperationContext("batch", int64(len(commands)))
+	defer cancel()
+
+	return runTransaction(ctx, r.conn(), commands)
+}
+
+// ExistsBatch checks which of several Redis keys exist using a single pipeline
+func (r *RedisClient) ExistsBatch(keys []string) ([]bool, error) {
+	if r.conn() == nil {
+		return nil, fmt.Errorf("Redis client not connected")
+	}
+
+	ctx, cancel := r.config.OperationContext("batch", int64(len(keys)))
+	defer cancel()
+
+	return existsBatch(ctx, r.conn(), keys)
+}
+
+// DumpKeys serializes several keys with DUMP using a single pipeline
+func (r *RedisClient) DumpKeys(keys []string) ([]DumpRecord, error) {
+	if r.conn() == nil {
+		return nil, fmt.Errorf("Redis client not connected")
+	}
+
+	ctx, cancel := r.config.OperationContext("dump", int64(len(keys)))
+	defer cancel()
+
+	if !r.readingFromReplica() {
+		return dumpKeys(ctx, r.conn(), keys)
+	}
+
+	records, err := dumpKeys(ctx, r.reader(), keys)
+	if err != nil {
+		return nil, err
+	}
+
+	// As in GetBatch, the expiry is read from the primary
+	expireAts, exists, err := readExpiries(ctx, r.conn(), keys)
+	if err != nil {
+		return nil, err
+	}
+	for i := range records {
+		if records[i].Err != nil || records[i].Type == "none" {
+			continue
 		}
+		if !exists[i] {
+			records[i] = DumpRecord{Key: records[i].Key, Type: "none", IdleTime: -1, Freq: -1}
+			continue
+		}
+		records[i].ExpireAt = expireAts[i]
+	}
+
+	return records, nil
+}
 
-		_, err := pipe.Exec(ctx)
+// RestoreKeys recreates several keys from their DUMP payloads using a single pipeline
+func (r *RedisClient) RestoreKeys(records []DumpRecord) ([]error, error) {
+	if r.conn() == nil {
+		return nil, fmt.Errorf("Redis client not connected")
+	}
+
+	ctx, cancel := r.config.OperationContext("dump", int64(len(records)))
+	defer cancel()
+
+	return restoreKeys(ctx, r.conn(), records)
+}
+
+// RDBVersion returns the RDB version the server serializes values with
+func (r *RedisClient) RDBVersion() (int, error) {
+	if r.conn() == nil {
+		return 0, fmt.Errorf("Redis client not connected")
+	}
+
+	ctx, cancel := r.config.OperationContext("dump", 1)
+	defer cancel()
+
+	return rdbVersion(ctx, r.conn())
+}
+
+// KeySizeAndExpiry returns the number of elements in a collection or the length of a
+// string, and the absolute expiry of the key, in a single round trip
+func (r *RedisClient) KeySizeAndExpiry(key, keyType string) (int64, time.Time, bool, error) {
+	if r.conn() == nil {
+		return 0, time.Time{}, false, fmt.Errorf("Redis client not connected")
+	}
+
+	ctx, cancel := r.config.OperationContext("size", 0)
+	defer cancel()
+
+	return keySizeAndExpiry(ctx, r.reader(), r.conn(), key, keyType)
+}
+
+// ReadChunk reads one chunk of a hash, set, sorted set or list
+func (r *RedisClient) ReadChunk(key, keyType string, cursor uint64, count int64) (interface{}, uint64, error) {
+	if r.conn() == nil {
+		return nil, 0, fmt.Errorf("Redis client not connected")
+	}
+
+	ctx, cancel := r.config.OperationContext(keyType, count)
+	defer cancel()
+
+	chunk, next, err := readChunk(ctx, r.reader(), key, keyType, cursor, count)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read chunk of %s: %w", key, err)
+	}
+
+	return chunk, next, nil
+}
+
+// AppendChunk adds a chunk of elements to a hash, set, sorted set or list
+func (r *RedisClient) AppendChunk(key, keyType string, chunk interface{}) error {
+	if r.conn() == nil {
+		return fmt.Errorf("Redis client not connected")
+	}
+
+	_, chunkSize, err := describeValue(chunk)
+	if err != nil {
 		return err
-	default:
-		return fmt.Errorf("unsupported value type: %T", value)
 	}
+
+	ctx, cancel := r.config.OperationContext(keyType, chunkSize)
+	defer cancel()
+
+	return appendChunk(ctx, r.conn(), key, keyType, chunk)
+}
+
+// RenameKey atomically renames a key, replacing the destination if it exists
+func (r *RedisClient) RenameKey(key, newKey string) error {
+	if r.conn() == nil {
+		return fmt.Errorf("Redis client not connected")
+	}
+
+	ctx, cancel := r.config.OperationContext("rename", 0)
+	defer cancel()
+
+	return r.conn().Rename(ctx, key, newKey).Err()
+}
+
+// DeleteKey removes a key, reclaiming its memory in the background
+func (r *RedisClient) DeleteKey(key string) error {
+	if r.conn() == nil {
+		return fmt.Errorf("Redis client not connected")
+	}
+
+	ctx, cancel := r.config.OperationContext("delete", 0)
+	defer cancel()
+
+	return r.conn().Unlink(ctx, key).Err()
 }
 
 // Exists checks if a key exists in Redis
 func (r *RedisClient) Exists(key string) (bool, error) {
-	if r.client == nil {
+	if r.conn() == nil {
 		return false, fmt.Errorf("Redis client not connected")
 	}
 
 	ctx, cancel := r.config.OperationContext("exists", 0)
 	defer cancel()
 
-	count, err := r.client.Exists(ctx, key).Result()
+	count, err := r.conn().Exists(ctx, key).Result()
 	if err != nil {
 		return false, fmt.Errorf("failed to check key existence for %s: %w", key, err)
 	}
@@ -296,26 +643,26 @@ func (r *RedisClient) Exists(key string) (bool, error) {
 
 // Ping tests the Redis connection
 func (r *RedisClient) Ping() error {
-	if r.client == nil {
+	if r.conn() == nil {
 		return fmt.Errorf("Redis client not connected")
 	}
 
 	ctx, cancel := r.config.ConnectionContext()
 	defer cancel()
 
-	return r.client.Ping(ctx).Err()
+	return r.conn().Ping(ctx).Err()
 }
 
 // GetTTL returns the time-to-live for a Redis key
 func (r *RedisClient) GetTTL(key string) (time.Duration, error) {
-	if r.client == nil {
+	if r.conn() == nil {
 		return 0, fmt.Errorf("Redis client not connected")
 	}
 
 	ctx, cancel := r.config.OperationContext("ttl", 0)
 	defer cancel()
 
-	ttl, err := r.client.TTL(ctx, key).Result()
+	ttl, err := r.conn().TTL(ctx, key).Result()
 	if err != nil {
 		return 0, fmt.Errorf("failed to get TTL for %s: %w", key, err)
 	}
@@ -325,12 +672,36 @@ func (r *RedisClient) GetTTL(key string) (time.Duration, error) {
 
 // SetTTL sets the time-to-live for a Redis key
 func (r *RedisClient) SetTTL(key string, ttl time.Duration) error {
-	if r.client == nil {
+	if r.conn() == nil {
+		return fmt.Errorf("Redis client not connected")
+	}
+
+	ctx, cancel := r.config.OperationContext("ttl", 0)
+	defer cancel()
+
+	return r.conn().Expire(ctx, key, ttl).Err()
+}
+
+// GetExpireAt returns the absolute, millisecond-precise expiry time of a Redis key
+func (r *RedisClient) GetExpireAt(key string) (time.Time, bool, error) {
+	if r.conn() == nil {
+		return time.Time{}, false, fmt.Errorf("Redis client not connected")
+	}
+
+	ctx, cancel := r.config.OperationContext("ttl", 0)
+	defer cancel()
+
+	return readExpireAt(ctx, r.conn(), key)
+}
+
+// SetExpireAt sets the absolute expiry time of a Redis key
+func (r *RedisClient) SetExpireAt(key string, expireAt time.Time) error {
+	if r.conn() == nil {
 		return fmt.Errorf("Redis client not connected")
 	}
 
 	ctx, cancel := r.config.OperationContext("ttl", 0)
 	defer cancel()
 
-	return r.client.Expire(ctx, key, ttl).Err()
+	return r.conn().PExpireAt(ctx, key, expireAt).Err()
 }
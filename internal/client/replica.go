@@ -0,0 +1,145 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ReplicaClient is implemented by clients that can send the heavy reads of a migration,
+// such as SCAN, value reads, DUMP and chunk reads, to a replica of the source. PING,
+// TTL and existence checks keep going to the primary.
+type ReplicaClient interface {
+	// ReplicationLag returns how many bytes of the primary's replication stream the
+	// replica has not processed yet. It returns ErrUnsupported if no replica is configured.
+	ReplicationLag() (int64, error)
+
+	// UseReplica switches the heavy reads to the replica, or back to the primary
+	UseReplica(enabled bool)
+}
+
+// newReplicaClient creates a standalone client for the replica in config.Replica, with
+// the credentials and TLS settings of the primary
+func newReplicaClient(config *ClientConfig) (redis.UniversalClient, error) {
+	replicaConfig := *config
+	replicaConfig.Addrs = []string{config.Replica}
+	replicaConfig.Cluster = false
+	replicaConfig.ReadFromReplicas = false
+	replicaConfig.SentinelMaster = ""
+	replicaConfig.SentinelAddrs = nil
+
+	return newUniversalClient(&replicaConfig)
+}
+
+// connectReplica opens a connection to the replica and checks that it is one
+func connectReplica(config *ClientConfig) (redis.UniversalClient, error) {
+	uc, err := newReplicaClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := config.ConnectionContext()
+	defer cancel()
+
+	info, err := readInfo(ctx, uc, "replication")
+	if err != nil {
+		uc.Close()
+		return nil, err
+	}
+	if role := info["role"]; role != "slave" {
+		uc.Close()
+		return nil, fmt.Errorf("%s is not a replica, its role is %q", config.Replica, role)
+	}
+
+	return uc, nil
+}
+
+// replicationLag compares the replication offset of the primary with the offset the
+// replica has processed
+func replicationLag(ctx context.Context, primary, replica redis.Cmdable) (int64, error) {
+	// Read the replica first, so that a write between the two reads counts as lag
+	replicaInfo, err := readInfo(ctx, replica, "replication")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read replica replication info: %w", err)
+	}
+	primaryInfo, err := readInfo(ctx, primary, "replication")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read primary replication info: %w", err)
+	}
+
+	// The replica may have been promoted by a failover
+	if role := replicaInfo["role"]; role != "slave" {
+		return 0, fmt.Errorf("replica role is %q, not a replica anymore", role)
+	}
+	if status := replicaInfo["master_link_status"]; status != "up" {
+		return 0, fmt.Errorf("replica link to its primary is %s", status)
+	}
+
+	primaryOffset, err := strconv.ParseInt(primaryInfo["master_repl_offset"], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid primary replication offset %q", primaryInfo["master_repl_offset"])
+	}
+	replicaOffset, err := strconv.ParseInt(replicaInfo["slave_repl_offset"], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid replica replication offset %q", replicaInfo["slave_repl_offset"])
+	}
+
+	if lag := primaryOffset - replicaOffset; lag > 0 {
+		return lag, nil
+	}
+	return 0, nil
+}
+
+// readInfo runs INFO for one section and parses the reply
+func readInfo(ctx context.Context, rc redis.Cmdable, section string) (map[string]string, error) {
+	info, err := rc.Info(ctx, section).Result()
+	if err != nil {
+		return nil, err
+	}
+	return parseInfo(info), nil
+}
+
+// parseInfo parses the field:value lines of an INFO reply, skipping section headers
+func parseInfo(info string) map[string]string {
+	fields := make(map[string]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			fields[name] = value
+		}
+	}
+
+	return fields
+}
+
+// readExpiries reads the expiry of several keys with PTTL in a single pipeline. Keys that
+// do not exist are reported as missing.
+func readExpiries(ctx context.Context, rc redis.Cmdable, keys []string) ([]time.Time, []bool, error) {
+	pipe := rc.Pipeline()
+	cmds := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.PTTL(ctx, key)
+	}
+	readTime := time.Now()
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, nil, fmt.Errorf("failed to read key expiries: %w", err)
+	}
+
+	expireAts := make([]time.Time, len(keys))
+	exists := make([]bool, len(keys))
+	for i, cmd := range cmds {
+		expireAts[i], exists[i], _ = expireAtFromPTTL(readTime, cmd.Val())
+	}
+
+	return expireAts, exists, nil
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// infoCmdable replies to INFO with a fixed reply; every other command is unimplemented
+type infoCmdable struct {
+	redis.Cmdable
+	info string
+	err  error
+}
+
+func (c infoCmdable) Info(ctx context.Context, section ...string) *redis.StringCmd {
+	cmd := redis.NewStringCmd(ctx, "info")
+	if c.err != nil {
+		cmd.SetErr(c.err)
+	} else {
+		cmd.SetVal(c.info)
+	}
+	return cmd
+}
+
+func TestParseInfo(t *testing.T) {
+	info := "# Replication\r\nrole:slave\r\nmaster_host:10.0.0.1\r\nmaster_link_status:up\r\nslave_repl_offset:1500\r\n\r\n# Other\r\nmaster_replid:abc:def\r\n"
+
+	fields := parseInfo(info)
+	assert.Equal(t, "slave", fields["role"])
+	assert.Equal(t, "10.0.0.1", fields["master_host"])
+	assert.Equal(t, "1500", fields["slave_repl_offset"])
+	assert.Equal(t, "abc:def", fields["master_replid"], "only the first colon separates the value")
+	assert.NotContains(t, fields, "# Replication")
+}
+
+func TestReplicationLag(t *testing.T) {
+	primary := infoCmdable{info: "# Replication\r\nrole:master\r\nmaster_repl_offset:5000\r\n"}
+
+	testCases := []struct {
+		name    string
+		primary infoCmdable
+		replica infoCmdable
+		lag     int64
+		errMsg  string
+	}{
+		{"behind", primary, infoCmdable{info: "role:slave\r\nmaster_link_status:up\r\nslave_repl_offset:3976\r\n"}, 1024, ""},
+		{"caught up", primary, infoCmdable{info: "role:slave\r\nmaster_link_status:up\r\nslave_repl_offset:5000\r\n"}, 0, ""},
+		{"offset read after primary moved on", primary, infoCmdable{info: "role:slave\r\nmaster_link_status:up\r\nslave_repl_offset:5100\r\n"}, 0, ""},
+		{"link down", primary, infoCmdable{info: "role:slave\r\nmaster_link_status:down\r\nslave_repl_offset:3976\r\n"}, 0, "replica link to its primary is down"},
+		{"not a replica", primary, infoCmdable{info: "role:master\r\nmaster_repl_offset:0\r\n"}, 0, "replica role is \"master\""},
+		{"replica unreachable", primary, infoCmdable{err: errors.New("connection refused")}, 0, "failed to read replica replication info"},
+		{"primary unreachable", infoCmdable{err: errors.New("connection refused")}, infoCmdable{info: "role:slave\r\nmaster_link_status:up\r\nslave_repl_offset:1\r\n"}, 0, "failed to read primary replication info"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lag, err := replicationLag(context.Background(), tc.primary, tc.replica)
+			if tc.errMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.lag, lag)
+		})
+	}
+}
+
+func TestNewReplicaClient(t *testing.T) {
+	clientConfig := NewClientConfig("primary", 6379, "secret", 2)
+	clientConfig.SentinelMaster = "mymaster"
+	clientConfig.SentinelAddrs = []string{"sentinel-1:26379"}
+	clientConfig.Replica = "replica-1:6379"
+
+	uc, err := newReplicaClient(clientConfig)
+	require.NoError(t, err)
+	defer uc.Close()
+
+	standalone, ok := uc.(*redis.Client)
+	require.True(t, ok, "the replica is always a standalone connection")
+	assert.Equal(t, "replica-1:6379", standalone.Options().Addr)
+	assert.Equal(t, "secret", standalone.Options().Password)
+	assert.Equal(t, 2, standalone.Options().DB)
+	assert.Equal(t, "mymaster", clientConfig.SentinelMaster, "the primary configuration is not modified")
+}
+
+func TestRedisClient_ReaderRouting(t *testing.T) {
+	primary := redis.NewClient(&redis.Options{Addr: "primary:6379"})
+	replica := redis.NewClient(&redis.Options{Addr: "replica-1:6379"})
+	defer primary.Close()
+	defer replica.Close()
+
+	redisClient := NewRedisClient(NewClientConfig("primary", 6379, "", 0))
+	redisClient.client = primary
+	assert.Same(t, primary, redisClient.reader())
+
+	_, err := redisClient.ReplicationLag()
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	redisClient.replica = replica
+	redisClient.UseReplica(true)
+	assert.Same(t, replica, redisClient.reader())
+	assert.Same(t, primary, redisClient.conn(), "PING, TTL and existence checks stay on the primary")
+
+	redisClient.UseReplica(false)
+	assert.Same(t, primary, redisClient.reader())
+}
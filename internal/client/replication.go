@@ -0,0 +1,515 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// replicationTimeout is how long the source may stay silent before the connection is
+	// considered dead. The source pings its replicas every 10 seconds by default and
+	// sends newlines while it prepares a snapshot.
+	replicationTimeout = time.Minute
+	// replicationAckInterval is how often the applied offset is acknowledged to the source
+	replicationAckInterval = time.Second
+	// replicationFlushCommands is how many commands are handled before they are flushed
+	// while the source keeps sending
+	replicationFlushCommands = 1000
+)
+
+// ReplicationPosition identifies a point of the replication stream of a source: the
+// replication ID, the offset of the last byte applied and the database selected there
+type ReplicationPosition struct {
+	ReplID string `json:"repl_id"`
+	Offset int64  `json:"offset"`
+	DB     int    `json:"db"`
+}
+
+// ReplicationHandler applies what a source sends to its replica
+type ReplicationHandler interface {
+	// FullResync is called when the source starts over with a snapshot, which replaces
+	// everything received before
+	FullResync(position ReplicationPosition) error
+
+	// SnapshotEntry is called for every key of the snapshot
+	SnapshotEntry(entry *RDBEntry) error
+
+	// Command is called for every write command of the replication stream, with the
+	// database it applies to
+	Command(db int, args []string) error
+
+	// Flush applies everything handled so far. Once it returns, the replication
+	// continues from position after a reconnect.
+	Flush(position ReplicationPosition) error
+}
+
+// Replication follows a Redis server the way its replicas do: it asks for a partial
+// resynchronization from the last flushed position, or loads a snapshot when the source
+// cannot continue from there, and then receives every write command the source runs.
+// Cluster sources are not supported.
+type Replication struct {
+	config *ClientConfig
+
+	// mu guards position, which is only changed by Run but can be read at any time
+	mu       sync.Mutex
+	position ReplicationPosition
+
+	// writeMu serializes writes to the connection, which acknowledgements share with
+	// replies to the source
+	writeMu sync.Mutex
+}
+
+// NewReplication creates a replication of the server config connects to, continuing
+// from position; an empty position starts with a full resynchronization
+func NewReplication(config *ClientConfig, position ReplicationPosition) *Replication {
+	return &Replication{
+		config:   config,
+		position: position,
+	}
+}
+
+// Position returns the position the replication continues from
+func (r *Replication) Position() ReplicationPosition {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.position
+}
+
+// setPosition records the position the replication continues from
+func (r *Replication) setPosition(position ReplicationPosition) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.position = position
+}
+
+// Run connects to the source and passes what it sends to handler until ctx is cancelled
+// or the connection fails. Run can be called again to reconnect and continue from the
+// last flushed position.
+func (r *Replication) Run(ctx context.Context, handler ReplicationHandler) error {
+	if r.config.Cluster {
+		return fmt.Errorf("replication from a cluster is not supported")
+	}
+
+	conn, err := r.dial(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Unblock reads when ctx is cancelled
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+		}
+		conn.Close()
+	}()
+
+	err = r.replicate(ctx, conn, handler)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// replicate runs the handshake and then handles the snapshot, if any, and the stream
+func (r *Replication) replicate(ctx context.Context, conn net.Conn, handler ReplicationHandler) error {
+	reader := bufio.NewReader(conn)
+
+	if err := r.handshake(ctx, conn, reader); err != nil {
+		return err
+	}
+
+	replID, offset := "?", int64(-1)
+	if r.position.ReplID != "" {
+		replID, offset = r.position.ReplID, r.position.Offset+1
+	}
+	if err := r.send(conn, "PSYNC", replID, strconv.FormatInt(offset, 10)); err != nil {
+		return err
+	}
+	reply, err := readStatus(reader)
+	if err != nil {
+		return fmt.Errorf("PSYNC failed: %w", err)
+	}
+
+	fields := strings.Fields(reply)
+	switch {
+	case len(fields) == 3 && fields[0] == "FULLRESYNC":
+		offset, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid FULLRESYNC reply %q", reply)
+		}
+		r.setPosition(ReplicationPosition{ReplID: fields[1], Offset: offset})
+		if err := handler.FullResync(r.position); err != nil {
+			return err
+		}
+		if err := r.loadSnapshot(reader, handler); err != nil {
+			return err
+		}
+		if err := handler.Flush(r.position); err != nil {
+			return err
+		}
+	case len(fields) >= 1 && fields[0] == "CONTINUE":
+		// The source changes its replication ID after a failover and continues the
+		// history of the old one
+		if len(fields) == 2 {
+			position := r.position
+			position.ReplID = fields[1]
+			r.setPosition(position)
+		}
+	default:
+		return fmt.Errorf("unexpected PSYNC reply %q", reply)
+	}
+
+	return r.stream(ctx, conn, reader, handler)
+}
+
+// dial connects to the source, looking up the current master through Sentinel when
+// configured
+func (r *Replication) dial(ctx context.Context) (net.Conn, error) {
+	addr := r.config.SeedAddrs()[0]
+	if r.config.SentinelMaster != "" {
+		var err error
+		if addr, err = r.sentinelMaster(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	tlsConfig, err := newTLSConfig(r.config.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: r.config.ConnectionTimeout}
+	var conn net.Conn
+	if tlsConfig != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s for replication: %w", addr, err)
+	}
+
+	return &deadlineConn{Conn: conn, timeout: replicationTimeout}, nil
+}
+
+// sentinelMaster asks the Sentinels for the address of the current master
+func (r *Replication) sentinelMaster(ctx context.Context) (string, error) {
+	tlsConfig, err := newTLSConfig(r.config.TLS)
+	if err != nil {
+		return "", err
+	}
+
+	var lastErr error
+	for _, sentinelAddr := range r.config.SentinelAddrs {
+		sentinel := redis.NewSentinelClient(&redis.Options{
+			Addr:        sentinelAddr,
+			DialTimeout: r.config.ConnectionTimeout,
+			TLSConfig:   tlsConfig,
+		})
+		addr, err := sentinel.GetMasterAddrByName(ctx, r.config.SentinelMaster).Result()
+		sentinel.Close()
+		if err == nil && len(addr) == 2 {
+			return net.JoinHostPort(addr[0], addr[1]), nil
+		}
+		if err == nil {
+			err = fmt.Errorf("unexpected reply %v", addr)
+		}
+		lastErr = fmt.Errorf("sentinel %s: %w", sentinelAddr, err)
+	}
+	return "", fmt.Errorf("failed to look up master %s: %w", r.config.SentinelMaster, lastErr)
+}
+
+// handshake authenticates and announces the capabilities of the replica
+func (r *Replication) handshake(ctx context.Context, conn net.Conn, reader *bufio.Reader) error {
+	username, password := r.config.Username, r.config.Password
+	if r.config.Credentials != nil {
+		var err error
+		if username, password, err = r.config.Credentials.Credentials(ctx); err != nil {
+			return err
+		}
+	}
+
+	var commands [][]string
+	if password != "" {
+		if username != "" {
+			commands = append(commands, []string{"AUTH", username, password})
+		} else {
+			commands = append(commands, []string{"AUTH", password})
+		}
+	}
+	commands = append(commands,
+		[]string{"PING"},
+		// eof: the snapshot may be streamed without a known length
+		// psync2: the source may continue after a failover
+		[]string{"REPLCONF", "capa", "eof", "capa", "psync2"})
+
+	for _, args := range commands {
+		if err := r.send(conn, args...); err != nil {
+			return err
+		}
+		if _, err := readStatus(reader); err != nil {
+			return fmt.Errorf("replication handshake failed at %s: %w", args[0], err)
+		}
+	}
+	return nil
+}
+
+// loadSnapshot passes the keys of the snapshot that follows a full resynchronization to
+// handler. The snapshot is sent with its length, or followed by a random end marker when
+// the source streams it without writing it to disk first.
+func (r *Replication) loadSnapshot(reader *bufio.Reader, handler ReplicationHandler) error {
+	var header string
+	for header == "" {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read snapshot header: %w", err)
+		}
+		// Bare newlines keep the connection alive while the snapshot is prepared
+		header = strings.TrimRight(line, "\r\n")
+	}
+	if header[0] == '-' {
+		return fmt.Errorf("source failed to send snapshot: %s", header[1:])
+	}
+	if header[0] != '$' {
+		return fmt.Errorf("unexpected snapshot header %q", header)
+	}
+
+	var source io.Reader = reader
+	var limited *io.LimitedReader
+	var eofMark string
+	size := int64(-1)
+	if strings.HasPrefix(header, "$EOF:") {
+		eofMark = header[len("$EOF:"):]
+	} else {
+		length, err := strconv.ParseInt(header[1:], 10, 64)
+		if err != nil {
+			return fmt.Errorf("unexpected snapshot header %q", header)
+		}
+		limited = &io.LimitedReader{R: reader, N: length}
+		source = limited
+		size = length
+	}
+
+	parser := NewRDBParserWithSize(source, size)
+	for {
+		entry, err := parser.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load snapshot: %w", err)
+		}
+		if err := handler.SnapshotEntry(entry); err != nil {
+			return err
+		}
+	}
+
+	if limited != nil {
+		if _, err := io.Copy(io.Discard, limited); err != nil {
+			return fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		return nil
+	}
+
+	mark := make([]byte, len(eofMark))
+	if _, err := io.ReadFull(reader, mark); err != nil {
+		return fmt.Errorf("failed to read snapshot end marker: %w", err)
+	}
+	if string(mark) != eofMark {
+		return fmt.Errorf("snapshot end marker mismatch")
+	}
+	return nil
+}
+
+// stream handles the commands the source propagates, flushing them whenever no more
+// data is waiting, and acknowledges the flushed offset every second
+func (r *Replication) stream(ctx context.Context, conn net.Conn, reader *bufio.Reader, handler ReplicationHandler) error {
+	offset, db := r.position.Offset, r.position.DB
+	pending := 0
+
+	// acked holds the offset acknowledged to the source, which waits for it in WAIT
+	var ackMu sync.Mutex
+	acked := r.position.Offset
+	ack := func() error {
+		ackMu.Lock()
+		defer ackMu.Unlock()
+		return r.send(conn, "REPLCONF", "ACK", strconv.FormatInt(acked, 10))
+	}
+
+	ackDone := make(chan struct{})
+	defer close(ackDone)
+	go func() {
+		ticker := time.NewTicker(replicationAckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ackDone:
+				return
+			case <-ticker.C:
+				if ack() != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	flush := func() error {
+		if pending > 0 {
+			if err := handler.Flush(ReplicationPosition{ReplID: r.position.ReplID, Offset: offset, DB: db}); err != nil {
+				return err
+			}
+			pending = 0
+		}
+		r.setPosition(ReplicationPosition{ReplID: r.position.ReplID, Offset: offset, DB: db})
+
+		ackMu.Lock()
+		acked = offset
+		ackMu.Unlock()
+		return nil
+	}
+
+	for {
+		if reader.Buffered() == 0 || pending >= replicationFlushCommands {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+
+		args, size, err := readReplicatedCommand(reader)
+		if err != nil {
+			return fmt.Errorf("replication stream failed: %w", err)
+		}
+
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			// Keepalive, only counted in the offset
+		case "SELECT":
+			if len(args) != 2 {
+				return fmt.Errorf("invalid SELECT in replication stream")
+			}
+			if db, err = strconv.Atoi(args[1]); err != nil {
+				return fmt.Errorf("invalid SELECT in replication stream: %w", err)
+			}
+		case "REPLCONF":
+			// GETACK asks for the offset, which the source counts before the command
+			if len(args) > 1 && strings.EqualFold(args[1], "GETACK") {
+				if err := flush(); err != nil {
+					return err
+				}
+				if err := ack(); err != nil {
+					return err
+				}
+			}
+		default:
+			if err := handler.Command(db, args); err != nil {
+				return err
+			}
+			pending++
+		}
+		offset += int64(size)
+	}
+}
+
+// send writes a command to the source
+func (r *Replication) send(conn net.Conn, args ...string) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(conn, b.String()); err != nil {
+		return fmt.Errorf("failed to write to source: %w", err)
+	}
+	return nil
+}
+
+// readStatus reads a status reply, returning error replies as errors
+func readStatus(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+
+	switch {
+	case strings.HasPrefix(line, "+"):
+		return line[1:], nil
+	case strings.HasPrefix(line, "-"):
+		return "", errors.New(line[1:])
+	default:
+		return "", fmt.Errorf("unexpected reply %q", line)
+	}
+}
+
+// readReplicatedCommand reads a command sent as an array of bulk strings and returns its
+// arguments and the number of bytes it took
+func readReplicatedCommand(reader *bufio.Reader) ([]string, int, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, 0, err
+	}
+	size := len(line)
+	if !strings.HasPrefix(line, "*") {
+		return nil, 0, fmt.Errorf("unexpected data %q", strings.TrimRight(line, "\r\n"))
+	}
+	count, err := strconv.Atoi(strings.TrimRight(line[1:], "\r\n"))
+	if err != nil || count < 1 {
+		return nil, 0, fmt.Errorf("invalid command header %q", strings.TrimRight(line, "\r\n"))
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, 0, err
+		}
+		size += len(line)
+		if !strings.HasPrefix(line, "$") {
+			return nil, 0, fmt.Errorf("invalid argument header %q", strings.TrimRight(line, "\r\n"))
+		}
+		length, err := strconv.Atoi(strings.TrimRight(line[1:], "\r\n"))
+		if err != nil || length < 0 {
+			return nil, 0, fmt.Errorf("invalid argument header %q", strings.TrimRight(line, "\r\n"))
+		}
+
+		buf := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, 0, err
+		}
+		size += len(buf)
+		args[i] = string(buf[:length])
+	}
+
+	return args, size, nil
+}
+
+// deadlineConn fails reads on which the connection stays silent for longer than timeout
+type deadlineConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+// Read extends the read deadline before every read
+func (c *deadlineConn) Read(b []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
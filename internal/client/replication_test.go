@@ -0,0 +1,260 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// respCommand encodes a command the way a source propagates it
+func respCommand(args ...string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return b.String()
+}
+
+// replicationServer accepts one replica: it answers the handshake, replies to PSYNC
+// with psyncReply followed by payload and then reports every command the replica sends
+func replicationServer(t *testing.T, psyncReply, payload string) (string, <-chan []string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan []string, 100)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+
+		for {
+			args, err := readCommand(reader)
+			if err != nil {
+				return
+			}
+			received <- args
+
+			switch strings.ToUpper(args[0]) {
+			case "PING":
+				fmt.Fprint(conn, "+PONG\r\n")
+			case "PSYNC":
+				fmt.Fprintf(conn, "+%s\r\n%s", psyncReply, payload)
+			case "REPLCONF":
+				if strings.EqualFold(args[1], "ACK") {
+					continue
+				}
+				fmt.Fprint(conn, "+OK\r\n")
+			default:
+				fmt.Fprint(conn, "+OK\r\n")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), received
+}
+
+// recordingHandler records what a replication passes to it
+type recordingHandler struct {
+	mu       sync.Mutex
+	resyncs  []ReplicationPosition
+	entries  []*RDBEntry
+	commands []string
+	flushed  []ReplicationPosition
+}
+
+func (h *recordingHandler) FullResync(position ReplicationPosition) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.resyncs = append(h.resyncs, position)
+	return nil
+}
+
+func (h *recordingHandler) SnapshotEntry(entry *RDBEntry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, entry)
+	return nil
+}
+
+func (h *recordingHandler) Command(db int, args []string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.commands = append(h.commands, fmt.Sprintf("%d %s", db, strings.Join(args, " ")))
+	return nil
+}
+
+func (h *recordingHandler) Flush(position ReplicationPosition) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.flushed = append(h.flushed, position)
+	return nil
+}
+
+// runUntilAck runs the replication until the source receives an acknowledgement of offset
+func runUntilAck(t *testing.T, replication *Replication, handler ReplicationHandler, received <-chan []string, offset int64) [][]string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- replication.Run(ctx, handler) }()
+
+	var commands [][]string
+	ack := []string{"REPLCONF", "ACK", fmt.Sprint(offset)}
+	for {
+		select {
+		case args := <-received:
+			commands = append(commands, args)
+			if assert.ObjectsAreEqual(ack, args) {
+				cancel()
+				assert.ErrorIs(t, <-done, context.Canceled)
+				return commands
+			}
+		case err := <-done:
+			t.Fatalf("replication stopped: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no acknowledgement of offset %d, received %v", offset, commands)
+		}
+	}
+}
+
+func TestReplication_FullResync(t *testing.T) {
+	b := newRDBBuilder(12)
+	b.op(rdbTypeString).str("snapshot").str("value")
+	snapshot := string(b.end())
+
+	stream := respCommand("SELECT", "0") +
+		respCommand("SET", "a", "1") +
+		respCommand("PING") +
+		respCommand("SELECT", "1") +
+		respCommand("DEL", "b")
+	payload := fmt.Sprintf("\n\n$%d\r\n%s", len(snapshot), snapshot) + stream
+
+	addr, received := replicationServer(t, "FULLRESYNC 8de1787ba490483314a4d30f1c628bc5025eb761 100", payload)
+	config := &ClientConfig{Addrs: []string{addr}, Password: "secret", ConnectionTimeout: time.Second}
+	replication := NewReplication(config, ReplicationPosition{})
+	handler := &recordingHandler{}
+
+	end := int64(100 + len(stream))
+	commands := runUntilAck(t, replication, handler, received, end)
+
+	assert.Equal(t, []string{"AUTH", "secret"}, commands[0])
+	assert.Equal(t, []string{"PING"}, commands[1])
+	assert.Equal(t, []string{"REPLCONF", "capa", "eof", "capa", "psync2"}, commands[2])
+	assert.Equal(t, []string{"PSYNC", "?", "-1"}, commands[3])
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	replID := "8de1787ba490483314a4d30f1c628bc5025eb761"
+	assert.Equal(t, []ReplicationPosition{{ReplID: replID, Offset: 100}}, handler.resyncs)
+	require.Len(t, handler.entries, 1)
+	assert.Equal(t, "snapshot", handler.entries[0].Key)
+	assert.Equal(t, []string{"0 SET a 1", "1 DEL b"}, handler.commands)
+	assert.Equal(t, ReplicationPosition{ReplID: replID, Offset: 100}, handler.flushed[0], "snapshot is flushed before the stream")
+	assert.Equal(t, ReplicationPosition{ReplID: replID, Offset: end, DB: 1}, handler.flushed[len(handler.flushed)-1])
+	assert.Equal(t, ReplicationPosition{ReplID: replID, Offset: end, DB: 1}, replication.Position())
+}
+
+func TestReplication_DisklessSnapshot(t *testing.T) {
+	b := newRDBBuilder(12)
+	b.op(rdbTypeString).str("key").str("value")
+	mark := strings.Repeat("x", 40)
+	stream := respCommand("SET", "key", "new")
+	payload := "$EOF:" + mark + "\r\n" + string(b.end()) + mark + stream
+
+	addr, received := replicationServer(t, "FULLRESYNC abc 0", payload)
+	replication := NewReplication(&ClientConfig{Addrs: []string{addr}, ConnectionTimeout: time.Second}, ReplicationPosition{})
+	handler := &recordingHandler{}
+
+	runUntilAck(t, replication, handler, received, int64(len(stream)))
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	require.Len(t, handler.entries, 1)
+	assert.Equal(t, "value", handler.entries[0].Value)
+	assert.Equal(t, []string{"0 SET key new"}, handler.commands)
+}
+
+func TestReplication_PartialResync(t *testing.T) {
+	stream := respCommand("SET", "k", "v") + respCommand("REPLCONF", "GETACK", "*")
+
+	addr, received := replicationServer(t, "CONTINUE newid", stream)
+	replication := NewReplication(&ClientConfig{Addrs: []string{addr}, ConnectionTimeout: time.Second},
+		ReplicationPosition{ReplID: "oldid", Offset: 500, DB: 3})
+	handler := &recordingHandler{}
+
+	// GETACK is answered with the offset before it
+	applied := int64(500 + len(respCommand("SET", "k", "v")))
+	commands := runUntilAck(t, replication, handler, received, applied)
+
+	assert.Contains(t, commands, []string{"PSYNC", "oldid", "501"})
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	assert.Empty(t, handler.resyncs)
+	assert.Equal(t, []string{"3 SET k v"}, handler.commands)
+	assert.Equal(t, ReplicationPosition{ReplID: "newid", Offset: applied, DB: 3}, handler.flushed[0])
+}
+
+func TestReplication_Errors(t *testing.T) {
+	t.Run("cluster", func(t *testing.T) {
+		replication := NewReplication(&ClientConfig{Cluster: true}, ReplicationPosition{})
+		assert.ErrorContains(t, replication.Run(context.Background(), &recordingHandler{}), "cluster")
+	})
+
+	t.Run("PSYNC rejected", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer listener.Close()
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			for {
+				args, err := readCommand(reader)
+				if err != nil {
+					return
+				}
+				if args[0] == "PSYNC" {
+					fmt.Fprint(conn, "-NOMASTERLINK Can't SYNC while not connected with my master\r\n")
+					continue
+				}
+				fmt.Fprint(conn, "+OK\r\n")
+			}
+		}()
+
+		replication := NewReplication(&ClientConfig{Addrs: []string{listener.Addr().String()}, ConnectionTimeout: time.Second}, ReplicationPosition{})
+		err = replication.Run(context.Background(), &recordingHandler{})
+		assert.ErrorContains(t, err, "NOMASTERLINK")
+	})
+}
+
+func TestReadReplicatedCommand(t *testing.T) {
+	data := respCommand("SET", "key", "va\r\nlue") + "+OK\r\n"
+	reader := bufio.NewReader(strings.NewReader(data))
+
+	args, size, err := readReplicatedCommand(reader)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SET", "key", "va\r\nlue"}, args)
+	assert.Equal(t, len(data)-len("+OK\r\n"), size)
+
+	_, _, err = readReplicatedCommand(reader)
+	assert.ErrorContains(t, err, "unexpected data")
+}
@@ -0,0 +1,277 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrWriteOnly is returned by the read operations of clients that can only be migrated to
+var ErrWriteOnly = errors.New("target is write-only")
+
+// CommandFileStdout is the command file name that writes the commands to standard output
+const CommandFileStdout = "-"
+
+// commandFileStdout is the standard output the process started with, kept so that the
+// commands still reach it when log output is moved away from standard output
+var commandFileStdout = os.Stdout
+
+// commandFileStdoutUsed records that commands were written to standard output, which
+// cannot be inspected like a file to find out
+var commandFileStdoutUsed atomic.Bool
+
+// RESPFileClient implements DatabaseClient for a file of raw RESP commands, such as the
+// input of valkey-cli --pipe, so that a migration can be reviewed, shipped and replayed
+// later instead of being written to a running Valkey. Values are written with the same
+// commands as ValkeyClient: a MULTI/EXEC transaction per key that replaces the key
+// with its typed writes and sets its expiry with PEXPIREAT.
+//
+// The file is appended to, so a resumed migration adds the remaining keys to it. No key
+// exists in the file as far as the migration is concerned, and read operations fail with
+// ErrWriteOnly, so a migration to a command file cannot be verified.
+type RESPFileClient struct {
+	config *ClientConfig
+
+	// recorder queues the commands of a write, which its hook encodes instead of sending
+	recorder *redis.Client
+
+	// mu guards the file, so that the commands of concurrent writes are not interleaved
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewRESPFileClient creates a client writing commands to the file config.CommandFile,
+// or to standard output if it is CommandFileStdout
+func NewRESPFileClient(config *ClientConfig) *RESPFileClient {
+	c := &RESPFileClient{config: config}
+	c.recorder = redis.NewClient(&redis.Options{Addr: "command-file:0"})
+	c.recorder.AddHook(respFileHook{client: c})
+	return c
+}
+
+// respFileHook writes the commands processed by the recorder to the file instead of
+// sending them to a server
+type respFileHook struct {
+	client *RESPFileClient
+}
+
+// DialHook leaves dialing alone, the recorder never connects
+func (h respFileHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+// ProcessHook writes a single command
+func (h respFileHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		return h.client.writeCommands([]redis.Cmder{cmd})
+	}
+}
+
+// ProcessPipelineHook writes the commands of a pipeline or, wrapped in MULTI and EXEC,
+// of a transaction
+func (h respFileHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		return h.client.writeCommands(cmds)
+	}
+}
+
+// writeCommands encodes commands and writes them to the file in one piece
+func (c *RESPFileClient) writeCommands(cmds []redis.Cmder) error {
+	var buf []byte
+	for _, cmd := range cmds {
+		buf = appendRESPCommand(buf, cmd.Args())
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return fmt.Errorf("command file not open")
+	}
+	if _, err := c.file.Write(buf); err != nil {
+		return fmt.Errorf("failed to write command file: %w", err)
+	}
+	return nil
+}
+
+// appendRESPCommand appends a command to buf as a RESP array of bulk strings
+func appendRESPCommand(buf []byte, args []interface{}) []byte {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		value := respArg(arg)
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(value)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, value...)
+		buf = append(buf, '\r', '\n')
+	}
+	return buf
+}
+
+// respArg formats a command argument the way go-redis sends it
+func respArg(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Connect opens the command file for appending, and selects the configured database
+// for the commands that follow. Database 0 is selected too when commands of an earlier
+// client, possibly for another database, precede them. Connecting again while the file
+// is open does nothing.
+func (c *RESPFileClient) Connect() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file != nil {
+		return nil
+	}
+
+	var file *os.File
+	var appending bool
+	if c.config.CommandFile == CommandFileStdout {
+		file = commandFileStdout
+		appending = commandFileStdoutUsed.Swap(true)
+	} else {
+		var err error
+		file, err = os.OpenFile(c.config.CommandFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open command file: %w", err)
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("failed to open command file: %w", err)
+		}
+		appending = info.Size() > 0
+	}
+
+	if c.config.Database != 0 || appending {
+		selectCmd := appendRESPCommand(nil, []interface{}{"SELECT", c.config.Database})
+		if _, err := file.Write(selectCmd); err != nil {
+			c.closeFile(file)
+			return fmt.Errorf("failed to write command file: %w", err)
+		}
+	}
+
+	c.file = file
+	return nil
+}
+
+// closeFile closes file unless it is standard output, which is synced instead when it
+// is redirected to a file. Pipes and terminals cannot be synced.
+func (c *RESPFileClient) closeFile(file *os.File) error {
+	if file == commandFileStdout {
+		if err := file.Sync(); err != nil && !errors.Is(err, syscall.EINVAL) && !errors.Is(err, syscall.ENOTSUP) {
+			return err
+		}
+		return nil
+	}
+	return file.Close()
+}
+
+// Disconnect closes the command file
+func (c *RESPFileClient) Disconnect() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+	err := c.closeFile(c.file)
+	c.file = nil
+	return err
+}
+
+// Ping checks that the command file is open
+func (c *RESPFileClient) Ping() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return fmt.Errorf("command file not open")
+	}
+	return nil
+}
+
+// SetValue writes the commands that replace key with value
+func (c *RESPFileClient) SetValue(key string, value interface{}) error {
+	return c.SetValueWithExpiry(key, value, time.Time{})
+}
+
+// SetValueWithExpiry writes the commands that replace key with value and set its expiry
+// in one transaction
+func (c *RESPFileClient) SetValueWithExpiry(key string, value interface{}, expireAt time.Time) error {
+	return setValueWithExpiry(context.Background(), c.recorder, key, value, expireAt)
+}
+
+// SetExpireAt writes a PEXPIREAT command
+func (c *RESPFileClient) SetExpireAt(key string, expireAt time.Time) error {
+	return c.recorder.PExpireAt(context.Background(), key, expireAt).Err()
+}
+
+// SetTTL writes a PEXPIRE command
+func (c *RESPFileClient) SetTTL(key string, ttl time.Duration) error {
+	return c.recorder.PExpire(context.Background(), key, ttl).Err()
+}
+
+// Exists reports that key does not exist, every key is written to the file as new
+func (c *RESPFileClient) Exists(key string) (bool, error) {
+	return false, nil
+}
+
+// GetAllKeys fails, the command file is write-only
+func (c *RESPFileClient) GetAllKeys() ([]string, error) {
+	return nil, ErrWriteOnly
+}
+
+// GetKeysByPattern fails, the command file is write-only
+func (c *RESPFileClient) GetKeysByPattern(pattern string) ([]string, error) {
+	return nil, ErrWriteOnly
+}
+
+// GetKeyType fails, the command file is write-only
+func (c *RESPFileClient) GetKeyType(key string) (string, error) {
+	return "", ErrWriteOnly
+}
+
+// GetValue fails, the command file is write-only
+func (c *RESPFileClient) GetValue(key string) (interface{}, error) {
+	return nil, ErrWriteOnly
+}
+
+// GetTTL fails, the command file is write-only
+func (c *RESPFileClient) GetTTL(key string) (time.Duration, error) {
+	return 0, ErrWriteOnly
+}
+
+// GetExpireAt fails, the command file is write-only
+func (c *RESPFileClient) GetExpireAt(key string) (time.Time, bool, error) {
+	return time.Time{}, false, ErrWriteOnly
+}
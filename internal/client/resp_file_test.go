@@ -0,0 +1,160 @@
+package client
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// resp encodes commands the way they are expected in a command file
+func resp(commands ...[]string) string {
+	var b strings.Builder
+	for _, args := range commands {
+		b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+		for _, arg := range args {
+			b.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+		}
+	}
+	return b.String()
+}
+
+func TestRESPFileClient_Write(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.resp")
+	c := NewRESPFileClient(&ClientConfig{CommandFile: path, Database: 2})
+	require.NoError(t, c.Connect())
+
+	expireAt := time.UnixMilli(1893456000000)
+	require.NoError(t, c.SetValueWithExpiry("s", "v\r\n", expireAt))
+	require.NoError(t, c.SetValue("l", []string{"a", "b"}))
+	require.NoError(t, c.SetValue("z", []redis.Z{{Score: 1.5, Member: "m"}}))
+	require.NoError(t, c.SetValue("x", []interface{}{"m"}))
+	require.NoError(t, c.SetTTL("x", 2*time.Second))
+	require.NoError(t, c.Disconnect())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	expected := resp(
+		[]string{"SELECT", "2"},
+		[]string{"multi"}, []string{"set", "s", "v\r\n"}, []string{"pexpireat", "s", "1893456000000"}, []string{"exec"},
+		[]string{"multi"}, []string{"del", "l"}, []string{"rpush", "l", "a"}, []string{"rpush", "l", "b"}, []string{"exec"},
+		[]string{"multi"}, []string{"del", "z"}, []string{"zadd", "z", "1.5", "m"}, []string{"exec"},
+		[]string{"multi"}, []string{"del", "x"}, []string{"sadd", "x", "m"}, []string{"exec"},
+		[]string{"pexpire", "x", "2000"},
+	)
+	assert.Equal(t, expected, string(data))
+
+	// Connecting again appends to the file
+	require.NoError(t, c.Connect())
+	require.NoError(t, c.SetValue("s", "w"))
+	require.NoError(t, c.Disconnect())
+
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, expected+resp(
+		[]string{"SELECT", "2"},
+		[]string{"multi"}, []string{"set", "s", "w"}, []string{"exec"},
+	), string(data))
+}
+
+func TestRESPFileClient_Stream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.resp")
+	c := NewRESPFileClient(&ClientConfig{CommandFile: path})
+	require.NoError(t, c.Connect())
+
+	require.NoError(t, c.SetValue("st", &StreamValue{
+		Entries:         []StreamEntry{{ID: "1-1", Fields: []string{"f", "v"}}},
+		LastGeneratedID: "1-1",
+		Groups:          []StreamGroup{{Name: "g", LastDeliveredID: "0-0", Consumers: []string{"c"}}},
+	}))
+	require.NoError(t, c.Disconnect())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, resp(
+		[]string{"multi"},
+		[]string{"del", "st"},
+		[]string{"xadd", "st", "1-1", "f", "v"},
+		[]string{"XSETID", "st", "1-1"},
+		[]string{"XGROUP", "CREATE", "st", "g", "0-0"},
+		[]string{"xgroup", "createconsumer", "st", "g", "c"},
+		[]string{"exec"},
+	), string(data))
+}
+
+func TestRESPFileClient_StdoutPipe(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	require.NoError(t, err)
+	defer reader.Close()
+	defer writer.Close()
+
+	stdout := commandFileStdout
+	commandFileStdout = writer
+	commandFileStdoutUsed.Store(false)
+	defer func() {
+		commandFileStdout = stdout
+		commandFileStdoutUsed.Store(false)
+	}()
+
+	c := NewRESPFileClient(&ClientConfig{CommandFile: CommandFileStdout})
+	require.NoError(t, c.Connect())
+	require.NoError(t, c.SetExpireAt("k", time.UnixMilli(1893456000000)))
+	require.NoError(t, c.Disconnect(), "a pipe cannot be synced")
+	require.NoError(t, writer.Close())
+
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, resp([]string{"pexpireat", "k", "1893456000000"}), string(data))
+}
+
+func TestRESPFileClient_SelectWhenAppending(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.resp")
+
+	// Databases 3 and then 0, written one after the other
+	for _, db := range []int{3, 0} {
+		c := NewRESPFileClient(&ClientConfig{CommandFile: path, Database: db})
+		require.NoError(t, c.Connect())
+		require.NoError(t, c.SetExpireAt("k", time.UnixMilli(1893456000000)))
+		require.NoError(t, c.Disconnect())
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, resp(
+		[]string{"SELECT", "3"},
+		[]string{"pexpireat", "k", "1893456000000"},
+		[]string{"SELECT", "0"},
+		[]string{"pexpireat", "k", "1893456000000"},
+	), string(data))
+}
+
+func TestRESPFileClient_WriteOnly(t *testing.T) {
+	c := NewRESPFileClient(&ClientConfig{CommandFile: filepath.Join(t.TempDir(), "commands.resp")})
+
+	assert.Error(t, c.Ping(), "the file is not open")
+	assert.Error(t, c.SetValue("k", "v"))
+
+	require.NoError(t, c.Connect())
+	defer c.Disconnect()
+	assert.NoError(t, c.Ping())
+
+	exists, err := c.Exists("k")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	_, err = c.GetValue("k")
+	assert.ErrorIs(t, err, ErrWriteOnly)
+	_, err = c.GetAllKeys()
+	assert.ErrorIs(t, err, ErrWriteOnly)
+	assert.Error(t, c.SetValue("k", 42), "unsupported value type")
+
+	assert.True(t, Supports[ExpiryClient](c))
+	assert.False(t, Supports[BatchClient](c))
+}
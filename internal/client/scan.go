@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// StandaloneNode is the name ScanNodes gives the only node of a server that is not a cluster
+const StandaloneNode = ""
+
+// ScanClient is implemented by clients that can list their keys a page at a time, so that
+// keys can be migrated while they are still being discovered and discovery can continue
+// from where an interrupted migration stopped
+type ScanClient interface {
+	// ScanNodes returns the nodes whose keys are scanned: StandaloneNode for a single
+	// server, the address of every master, or of one node of every shard when reading
+	// from replicas, for a cluster
+	ScanNodes() ([]string, error)
+
+	// ScanPage returns a page of about count keys of node matching pattern, starting at
+	// cursor, which is 0 for the first page, and the cursor of the next page, which is 0
+	// once every key of the node has been returned. Like SCAN, it may return a key more
+	// than once, and keys added or removed while the node is scanned may be left out.
+	ScanPage(node string, cursor uint64, pattern string, count int64) ([]string, uint64, error)
+}
+
+// scanNodes returns the nodes scanned for the keys of uc. On a cluster these are every
+// master, or one replica of every shard when replicas is set, see shardScanTargets.
+func scanNodes(ctx context.Context, uc redis.UniversalClient, replicas bool) ([]string, error) {
+	cluster, ok := uc.(*redis.ClusterClient)
+	if !ok {
+		return []string{StandaloneNode}, nil
+	}
+
+	var nodes []string
+	if replicas {
+		slots, err := cluster.ClusterSlots(ctx).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cluster slots: %w", err)
+		}
+		replicaAddrs, masterAddrs := shardScanTargets(slots)
+		for addr := range replicaAddrs {
+			nodes = append(nodes, addr)
+		}
+		for addr := range masterAddrs {
+			nodes = append(nodes, addr)
+		}
+	} else {
+		var mu sync.Mutex
+		err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			mu.Lock()
+			defer mu.Unlock()
+			nodes = append(nodes, node.Options().Addr)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Scanned in the same order every time, which makes progress easier to follow
+	sort.Strings(nodes)
+	return nodes, nil
+}
+
+// scanPage reads one SCAN page of a node of uc
+func scanPage(ctx context.Context, uc redis.UniversalClient, node string, cursor uint64, pattern string, count int64) ([]string, uint64, error) {
+	cluster, ok := uc.(*redis.ClusterClient)
+	if !ok {
+		if node != StandaloneNode {
+			return nil, 0, fmt.Errorf("cannot scan node %s, not connected to a cluster", node)
+		}
+		return uc.Scan(ctx, cursor, pattern, count).Result()
+	}
+
+	// Only the node with the address scans, so its results need no lock
+	var keys []string
+	var next uint64
+	found := false
+	scan := func(ctx context.Context, client *redis.Client) error {
+		if client.Options().Addr != node {
+			return nil
+		}
+		found = true
+		var err error
+		keys, next, err = client.Scan(ctx, cursor, pattern, count).Result()
+		return err
+	}
+
+	if err := cluster.ForEachMaster(ctx, scan); err != nil {
+		return nil, 0, fmt.Errorf("node %s: %w", node, err)
+	}
+	if !found {
+		if err := cluster.ForEachSlave(ctx, scan); err != nil {
+			return nil, 0, fmt.Errorf("node %s: %w", node, err)
+		}
+	}
+	if !found {
+		return nil, 0, fmt.Errorf("node %s is no longer part of the cluster", node)
+	}
+	return keys, next, nil
+}
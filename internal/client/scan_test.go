@@ -0,0 +1,31 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanNodes_Standalone(t *testing.T) {
+	rc := redis.NewClient(&redis.Options{Addr: "localhost:1"})
+	defer rc.Close()
+
+	nodes, err := scanNodes(context.Background(), rc, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{StandaloneNode}, nodes)
+
+	_, _, err = scanPage(context.Background(), rc, "10.0.0.1:6379", 0, "*", 100)
+	assert.ErrorContains(t, err, "not connected to a cluster")
+}
+
+func TestRedisClient_ScanWithoutConnection(t *testing.T) {
+	c := NewRedisClient(NewClientConfig("localhost", 6379, "", 0))
+
+	_, err := c.ScanNodes()
+	assert.ErrorContains(t, err, "not connected")
+	_, _, err = c.ScanPage(StandaloneNode, 0, "*", 100)
+	assert.ErrorContains(t, err, "not connected")
+}
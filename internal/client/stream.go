@@ -0,0 +1,214 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// emptyStreamGroup is a throwaway consumer group used to create a stream without
+// entries. Its name is random so that it never collides with a group of the stream.
+var emptyStreamGroup = "redis-valkey-migration-" + rand.Text()
+
+// StreamValue holds the entries and metadata of a stream key
+type StreamValue struct {
+	Entries []StreamEntry
+
+	// LastGeneratedID is the ID of the last entry ever added, which may be newer than
+	// the last entry still in the stream if entries were deleted or trimmed
+	LastGeneratedID string
+
+	// EntriesAdded and MaxDeletedID record how many entries were ever added and the
+	// newest deleted or trimmed ID. They are only reported by Redis 7.0 and later;
+	// MaxDeletedID is empty when the source does not report them.
+	EntriesAdded int64
+	MaxDeletedID string
+
+	Groups []StreamGroup
+}
+
+// StreamEntry is a single stream entry with its field/value pairs in insertion order
+type StreamEntry struct {
+	ID     string
+	Fields []string
+}
+
+// StreamGroup describes a consumer group of a stream
+type StreamGroup struct {
+	Name            string
+	LastDeliveredID string
+	EntriesRead     int64
+	Consumers       []string
+	Pending         []StreamPendingEntry
+}
+
+// StreamPendingEntry is an entry delivered to a consumer but not yet acknowledged
+type StreamPendingEntry struct {
+	ID            string
+	Consumer      string
+	Idle          time.Duration
+	DeliveryCount int64
+}
+
+// readStream reads the entries, IDs and consumer groups of a stream in two pipelined round trips
+func readStream(ctx context.Context, rc redis.Cmdable, key string) (*StreamValue, error) {
+	// First round trip: entries, stream metadata and consumer groups
+	pipe := rc.Pipeline()
+	rangeCmd := pipe.Do(ctx, "XRANGE", key, "-", "+")
+	infoCmd := pipe.XInfoStream(ctx, key)
+	groupsCmd := pipe.XInfoGroups(ctx, key)
+	// Route the XINFO subcommands by their key on a cluster
+	infoCmd.SetFirstKeyPos(2)
+	groupsCmd.SetFirstKeyPos(2)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to read stream %s: %w", key, err)
+	}
+
+	entries, err := parseStreamEntries(rangeCmd.Val())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stream %s: %w", key, err)
+	}
+
+	info := infoCmd.Val()
+	stream := &StreamValue{
+		Entries:         entries,
+		LastGeneratedID: info.LastGeneratedID,
+		EntriesAdded:    info.EntriesAdded,
+		MaxDeletedID:    info.MaxDeletedEntryID,
+	}
+
+	groups := groupsCmd.Val()
+	if len(groups) == 0 {
+		return stream, nil
+	}
+
+	// Second round trip: consumers and pending entries of every group
+	pipe = rc.Pipeline()
+	consumerCmds := make([]*redis.XInfoConsumersCmd, len(groups))
+	pendingCmds := make([]*redis.XPendingExtCmd, len(groups))
+	for i, group := range groups {
+		consumerCmds[i] = pipe.XInfoConsumers(ctx, key, group.Name)
+		consumerCmds[i].SetFirstKeyPos(2)
+		if group.Pending > 0 {
+			pendingCmds[i] = pipe.XPendingExt(ctx, &redis.XPendingExtArgs{
+				Stream: key,
+				Group:  group.Name,
+				Start:  "-",
+				End:    "+",
+				Count:  group.Pending,
+			})
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to read consumer groups of stream %s: %w", key, err)
+	}
+
+	stream.Groups = make([]StreamGroup, len(groups))
+	for i, group := range groups {
+		stream.Groups[i] = StreamGroup{
+			Name:            group.Name,
+			LastDeliveredID: group.LastDeliveredID,
+			EntriesRead:     group.EntriesRead,
+		}
+		for _, consumer := range consumerCmds[i].Val() {
+			stream.Groups[i].Consumers = append(stream.Groups[i].Consumers, consumer.Name)
+		}
+		if pendingCmds[i] == nil {
+			continue
+		}
+		for _, pending := range pendingCmds[i].Val() {
+			stream.Groups[i].Pending = append(stream.Groups[i].Pending, StreamPendingEntry{
+				ID:            pending.ID,
+				Consumer:      pending.Consumer,
+				Idle:          pending.Idle,
+				DeliveryCount: pending.RetryCount,
+			})
+		}
+	}
+
+	return stream, nil
+}
+
+// parseStreamEntries converts a raw XRANGE reply into stream entries, keeping field order
+func parseStreamEntries(reply interface{}) ([]StreamEntry, error) {
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected XRANGE reply: %T", reply)
+	}
+
+	entries := make([]StreamEntry, 0, len(items))
+	for _, item := range items {
+		pair, ok := item.([]interface{})
+		if !ok || len(pair) != 2 {
+			return nil, fmt.Errorf("unexpected stream entry: %v", item)
+		}
+
+		id, ok := pair[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected stream entry ID: %v", pair[0])
+		}
+
+		rawFields, _ := pair[1].([]interface{})
+		fields := make([]string, len(rawFields))
+		for i, field := range rawFields {
+			fields[i] = fmt.Sprint(field)
+		}
+
+		entries = append(entries, StreamEntry{ID: id, Fields: fields})
+	}
+
+	return entries, nil
+}
+
+// queueStreamWrite queues the commands that recreate a stream, its IDs and its consumer groups
+func queueStreamWrite(ctx context.Context, pipe redis.Pipeliner, key string, stream *StreamValue) {
+	// Clear existing stream first
+	pipe.Del(ctx, key)
+
+	if len(stream.Entries) > 0 {
+		// Add entries with their original IDs
+		for _, entry := range stream.Entries {
+			pipe.XAdd(ctx, &redis.XAddArgs{Stream: key, ID: entry.ID, Values: entry.Fields})
+		}
+	} else {
+		// Create empty stream by creating and destroying a dummy consumer group
+		pipe.XGroupCreateMkStream(ctx, key, emptyStreamGroup, "$")
+		pipe.XGroupDestroy(ctx, key, emptyStreamGroup)
+	}
+
+	// Restore the last generated ID so new entries keep increasing past deleted ones.
+	// Redis does not store the MAXLEN/MINID used by XADD or XTRIM; the entries-added
+	// counter and max deleted ID are what trimming leaves behind, so copy those.
+	if stream.LastGeneratedID != "" && stream.LastGeneratedID != "0-0" {
+		args := []interface{}{"XSETID", key, stream.LastGeneratedID}
+		if stream.MaxDeletedID != "" {
+			args = append(args, "ENTRIESADDED", stream.EntriesAdded, "MAXDELETEDID", stream.MaxDeletedID)
+		}
+		pipe.Do(ctx, args...)
+	}
+
+	for _, group := range stream.Groups {
+		args := []interface{}{"XGROUP", "CREATE", key, group.Name, group.LastDeliveredID}
+		if stream.MaxDeletedID != "" && group.EntriesRead > 0 {
+			args = append(args, "ENTRIESREAD", group.EntriesRead)
+		}
+		pipe.Do(ctx, args...).SetFirstKeyPos(2)
+
+		for _, consumer := range group.Consumers {
+			pipe.XGroupCreateConsumer(ctx, key, group.Name, consumer)
+		}
+
+		// FORCE adds the entry to the pending list even though it was never delivered
+		// on this server. Entries that were deleted from the stream are skipped by the server.
+		for _, pending := range group.Pending {
+			pipe.Do(ctx, "XCLAIM", key, group.Name, pending.Consumer, 0, pending.ID,
+				"IDLE", strconv.FormatInt(pending.Idle.Milliseconds(), 10),
+				"RETRYCOUNT", pending.DeliveryCount,
+				"FORCE", "JUSTID")
+		}
+	}
+}
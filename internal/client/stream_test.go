@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStreamEntries(t *testing.T) {
+	reply := []interface{}{
+		[]interface{}{"1-0", []interface{}{"event", "created", "id", "42"}},
+		[]interface{}{"1-1", []interface{}{"event", "updated"}},
+	}
+
+	entries, err := parseStreamEntries(reply)
+	require.NoError(t, err)
+	assert.Equal(t, []StreamEntry{
+		{ID: "1-0", Fields: []string{"event", "created", "id", "42"}},
+		{ID: "1-1", Fields: []string{"event", "updated"}},
+	}, entries)
+
+	entries, err = parseStreamEntries([]interface{}{})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	_, err = parseStreamEntries("unexpected")
+	assert.Error(t, err)
+
+	_, err = parseStreamEntries([]interface{}{[]interface{}{"1-0"}})
+	assert.Error(t, err)
+}
+
+func TestQueueStreamWrite(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:0"})
+	defer rdb.Close()
+	ctx := context.Background()
+
+	stream := &StreamValue{
+		Entries: []StreamEntry{
+			{ID: "1-0", Fields: []string{"a", "1"}},
+			{ID: "2-0", Fields: []string{"b", "2"}},
+		},
+		LastGeneratedID: "3-0",
+		EntriesAdded:    3,
+		MaxDeletedID:    "3-0",
+		Groups: []StreamGroup{
+			{
+				Name:            "workers",
+				LastDeliveredID: "2-0",
+				EntriesRead:     2,
+				Consumers:       []string{"worker-1"},
+				Pending:         []StreamPendingEntry{{ID: "2-0", Consumer: "worker-1", Idle: 1500 * time.Millisecond, DeliveryCount: 3}},
+			},
+		},
+	}
+
+	pipe := rdb.Pipeline()
+	require.NoError(t, queueWrite(ctx, pipe, "events", stream))
+
+	var commands [][]interface{}
+	for _, cmd := range pipe.(*redis.Pipeline).Cmds() {
+		commands = append(commands, cmd.Args())
+	}
+
+	require.Len(t, commands, 7)
+	assert.Equal(t, []interface{}{"del", "events"}, commands[0])
+	assert.Equal(t, []interface{}{"xadd", "events", "1-0", "a", "1"}, commands[1])
+	assert.Equal(t, []interface{}{"xadd", "events", "2-0", "b", "2"}, commands[2])
+	assert.Equal(t, []interface{}{"XSETID", "events", "3-0", "ENTRIESADDED", int64(3), "MAXDELETEDID", "3-0"}, commands[3])
+	assert.Equal(t, []interface{}{"XGROUP", "CREATE", "events", "workers", "2-0", "ENTRIESREAD", int64(2)}, commands[4])
+	assert.Equal(t, []interface{}{"xgroup", "createconsumer", "events", "workers", "worker-1"}, commands[5])
+	assert.Equal(t, []interface{}{"XCLAIM", "events", "workers", "worker-1", 0, "2-0", "IDLE", "1500", "RETRYCOUNT", int64(3), "FORCE", "JUSTID"}, commands[6])
+}
+
+func TestQueueStreamWrite_EmptyStreamFromOlderServer(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:0"})
+	defer rdb.Close()
+	ctx := context.Background()
+
+	// Servers before Redis 7.0 do not report entries-added or max-deleted-entry-id
+	stream := &StreamValue{
+		LastGeneratedID: "5-0",
+		Groups:          []StreamGroup{{Name: "workers", LastDeliveredID: "5-0", EntriesRead: 4}},
+	}
+
+	pipe := rdb.Pipeline()
+	require.NoError(t, queueWrite(ctx, pipe, "events", stream))
+
+	var commands [][]interface{}
+	for _, cmd := range pipe.(*redis.Pipeline).Cmds() {
+		commands = append(commands, cmd.Args())
+	}
+
+	require.Len(t, commands, 5)
+	assert.Equal(t, []interface{}{"xgroup", "create", "events", emptyStreamGroup, "$", "mkstream"}, commands[1])
+	assert.Equal(t, []interface{}{"xgroup", "destroy", "events", emptyStreamGroup}, commands[2])
+	assert.Equal(t, []interface{}{"XSETID", "events", "5-0"}, commands[3])
+	assert.Equal(t, []interface{}{"XGROUP", "CREATE", "events", "workers", "5-0"}, commands[4])
+}
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/config"
+)
+
+// writeTestCertificate writes a self-signed certificate for localhost and its key as PEM
+// files, returning their paths
+func writeTestCertificate(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func TestNewTLSConfig_Disabled(t *testing.T) {
+	tlsConfig, err := newTLSConfig(config.TLSConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, tlsConfig)
+}
+
+func TestNewTLSConfig_Options(t *testing.T) {
+	dir := t.TempDir()
+	caFile, _ := writeTestCertificate(t, dir, "ca")
+	certFile, keyFile := writeTestCertificate(t, dir, "client")
+
+	tlsConfig, err := newTLSConfig(config.TLSConfig{
+		Enabled:            true,
+		CAFile:             caFile,
+		CertFile:           certFile,
+		KeyFile:            keyFile,
+		ServerName:         "redis.internal",
+		InsecureSkipVerify: true,
+	})
+	require.NoError(t, err)
+	assert.NotNil(t, tlsConfig.RootCAs)
+	assert.Len(t, tlsConfig.Certificates, 1)
+	assert.Equal(t, "redis.internal", tlsConfig.ServerName)
+	assert.True(t, tlsConfig.InsecureSkipVerify)
+	assert.Equal(t, uint16(tls.VersionTLS12), tlsConfig.MinVersion)
+}
+
+func TestNewTLSConfig_InvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir, "client")
+	notPEM := filepath.Join(dir, "not-pem")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0600))
+
+	testCases := []struct {
+		name     string
+		settings config.TLSConfig
+		errMsg   string
+	}{
+		{"missing CA file", config.TLSConfig{Enabled: true, CAFile: filepath.Join(dir, "missing")}, "failed to read TLS CA file"},
+		{"CA file without certificates", config.TLSConfig{Enabled: true, CAFile: notPEM}, "contains no PEM certificates"},
+		{"mismatched key", config.TLSConfig{Enabled: true, CertFile: certFile, KeyFile: notPEM}, "failed to load TLS client certificate"},
+		{"missing certificate", config.TLSConfig{Enabled: true, CertFile: filepath.Join(dir, "missing"), KeyFile: keyFile}, "failed to load TLS client certificate"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newTLSConfig(tc.settings)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tc.errMsg)
+		})
+	}
+}
+
+func TestNewTLSConfig_MutualTLSHandshake(t *testing.T) {
+	dir := t.TempDir()
+	serverCertFile, serverKeyFile := writeTestCertificate(t, dir, "server")
+	clientCertFile, clientKeyFile := writeTestCertificate(t, dir, "client")
+
+	serverCert, err := tls.LoadX509KeyPair(serverCertFile, serverKeyFile)
+	require.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	clientPEM, err := os.ReadFile(clientCertFile)
+	require.NoError(t, err)
+	require.True(t, clientCAs.AppendCertsFromPEM(clientPEM))
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.(*tls.Conn).Handshake()
+	}()
+
+	tlsConfig, err := newTLSConfig(config.TLSConfig{
+		Enabled:    true,
+		CAFile:     serverCertFile,
+		CertFile:   clientCertFile,
+		KeyFile:    clientKeyFile,
+		ServerName: "localhost",
+	})
+	require.NoError(t, err)
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), tlsConfig)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.NoError(t, conn.Handshake())
+	assert.True(t, conn.ConnectionState().HandshakeComplete)
+}
+
+func TestRedisClient_Connect_InvalidTLSConfig(t *testing.T) {
+	clientConfig := NewClientConfig("localhost", 6379, "", 0)
+	clientConfig.TLS = config.TLSConfig{Enabled: true, CAFile: filepath.Join(t.TempDir(), "missing")}
+	client := NewRedisClient(clientConfig)
+
+	err := client.Connect()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to connect to Redis")
+	assert.Contains(t, err.Error(), "failed to read TLS CA file")
+}
@@ -2,15 +2,18 @@ package client
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
-// ValkeyClient implements DatabaseClient for Valkey
+// ValkeyClient implements DatabaseClient for Valkey, standalone or cluster
 // Since Valkey is Redis-compatible, we use the same Redis client library
 type ValkeyClient struct {
-	client *redis.Client
+	// mu guards client, which Reconnect replaces while other goroutines use it
+	mu     sync.RWMutex
+	client redis.UniversalClient
 	config *ClientConfig
 }
 
@@ -23,17 +26,19 @@ func NewValkeyClient(config *ClientConfig) *ValkeyClient {
 
 // Connect establishes a connection to Valkey
 func (v *ValkeyClient) Connect() error {
-	v.client = redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", v.config.Host, v.config.Port),
-		Password: v.config.Password,
-		DB:       v.config.Database,
-	})
+	uc, err := newUniversalClient(v.config)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Valkey: %w", err)
+	}
+	v.mu.Lock()
+	v.client = uc
+	v.mu.Unlock()
 
 	ctx, cancel := v.config.ConnectionContext()
 	defer cancel()
 
 	// Test the connection
-	if err := v.client.Ping(ctx).Err(); err != nil {
+	if err := uc.Ping(ctx).Err(); err != nil {
 		return fmt.Errorf("failed to connect to Valkey: %w", err)
 	}
 
@@ -42,15 +47,49 @@ func (v *ValkeyClient) Connect() error {
 
 // Disconnect closes the Valkey connection
 func (v *ValkeyClient) Disconnect() error {
-	if v.client != nil {
-		return v.client.Close()
+	if uc := v.conn(); uc != nil {
+		return uc.Close()
 	}
 	return nil
 }
 
+// Reconnect replaces the Valkey connection with a new one, looking up the current master
+// again when connected through Sentinel
+func (v *ValkeyClient) Reconnect() error {
+	uc, err := newUniversalClient(v.config)
+	if err != nil {
+		return fmt.Errorf("failed to reconnect to Valkey: %w", err)
+	}
+
+	ctx, cancel := v.config.ConnectionContext()
+	defer cancel()
+
+	if err := uc.Ping(ctx).Err(); err != nil {
+		uc.Close()
+		return fmt.Errorf("failed to reconnect to Valkey: %w", err)
+	}
+
+	v.mu.Lock()
+	old := v.client
+	v.client = uc
+	v.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// conn returns the current connection
+func (v *ValkeyClient) conn() redis.UniversalClient {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.client
+}
+
 // GetAllKeys retrieves all keys from Valkey
 func (v *ValkeyClient) GetAllKeys() ([]string, error) {
-	if v.client == nil {
+	if v.conn() == nil {
 		return nil, fmt.Errorf("Valkey client not connected")
 	}
 
@@ -58,14 +97,8 @@ func (v *ValkeyClient) GetAllKeys() ([]string, error) {
 	ctx, cancel := v.config.OperationContext("scan", 0)
 	defer cancel()
 
-	var keys []string
-	iter := v.client.Scan(ctx, 0, "*", 0).Iterator()
-
-	for iter.Next(ctx) {
-		keys = append(keys, iter.Val())
-	}
-
-	if err := iter.Err(); err != nil {
+	keys, err := scanKeys(ctx, v.conn(), "*", false)
+	if err != nil {
 		return nil, fmt.Errorf("failed to scan keys: %w", err)
 	}
 
@@ -74,7 +107,7 @@ func (v *ValkeyClient) GetAllKeys() ([]string, error) {
 
 // GetKeysByPattern retrieves keys matching a specific pattern from Valkey
 func (v *ValkeyClient) GetKeysByPattern(pattern string) ([]string, error) {
-	if v.client == nil {
+	if v.conn() == nil {
 		return nil, fmt.Errorf("Valkey client not connected")
 	}
 
@@ -82,14 +115,8 @@ func (v *ValkeyClient) GetKeysByPattern(pattern string) ([]string, error) {
 	ctx, cancel := v.config.OperationContext("scan", 0)
 	defer cancel()
 
-	var keys []string
-	iter := v.client.Scan(ctx, 0, pattern, 0).Iterator()
-
-	for iter.Next(ctx) {
-		keys = append(keys, iter.Val())
-	}
-
-	if err := iter.Err(); err != nil {
+	keys, err := scanKeys(ctx, v.conn(), pattern, false)
+	if err != nil {
 		return nil, fmt.Errorf("failed to scan keys with pattern %s: %w", pattern, err)
 	}
 
@@ -98,7 +125,7 @@ func (v *ValkeyClient) GetKeysByPattern(pattern string) ([]string, error) {
 
 // GetKeyType returns the data type of a Valkey key
 func (v *ValkeyClient) GetKeyType(key string) (string, error) {
-	if v.client == nil {
+	if v.conn() == nil {
 		return "", fmt.Errorf("Valkey client not connected")
 	}
 
@@ -106,7 +133,7 @@ func (v *ValkeyClient) GetKeyType(key string) (string, error) {
 	ctx, cancel := v.config.OperationContext("type", 0)
 	defer cancel()
 
-	keyType, err := v.client.Type(ctx, key).Result()
+	keyType, err := v.conn().Type(ctx, key).Result()
 	if err != nil {
 		return "", fmt.Errorf("failed to get key type for %s: %w", key, err)
 	}
@@ -116,7 +143,7 @@ func (v *ValkeyClient) GetKeyType(key string) (string, error) {
 
 // GetValue retrieves the value for a key, handling all Valkey data types
 func (v *ValkeyClient) GetValue(key string) (interface{}, error) {
-	if v.client == nil {
+	if v.conn() == nil {
 		return nil, fmt.Errorf("Valkey client not connected")
 	}
 
@@ -137,15 +164,17 @@ func (v *ValkeyClient) GetValue(key string) (interface{}, error) {
 
 	switch keyType {
 	case "string":
-		return v.client.Get(ctx, key).Result()
+		return v.conn().Get(ctx, key).Result()
 	case "hash":
-		return v.client.HGetAll(ctx, key).Result()
+		return v.conn().HGetAll(ctx, key).Result()
 	case "list":
-		return v.client.LRange(ctx, key, 0, -1).Result()
+		return v.conn().LRange(ctx, key, 0, -1).Result()
 	case "set":
-		return v.client.SMembers(ctx, key).Result()
+		return v.conn().SMembers(ctx, key).Result()
 	case "zset":
-		return v.client.ZRangeWithScores(ctx, key, 0, -1).Result()
+		return v.conn().ZRangeWithScores(ctx, key, 0, -1).Result()
+	case "stream":
+		return readStream(ctx, v.conn(), key)
 	case "none":
 		return nil, fmt.Errorf("key does not exist")
 	default:
@@ -158,136 +187,222 @@ func (v *ValkeyClient) estimateDataSize(key, keyType string) (int64, error) {
 	ctx, cancel := v.config.OperationContext("size", 0)
 	defer cancel()
 
-	switch keyType {
-	case "string":
-		// For strings, get the length
-		length, err := v.client.StrLen(ctx, key).Result()
-		return length, err
-	case "hash":
-		// For hashes, get the number of fields
-		length, err := v.client.HLen(ctx, key).Result()
-		return length, err
-	case "list":
-		// For lists, get the length
-		length, err := v.client.LLen(ctx, key).Result()
-		return length, err
-	case "set":
-		// For sets, get the cardinality
-		length, err := v.client.SCard(ctx, key).Result()
-		return length, err
-	case "zset":
-		// For sorted sets, get the cardinality
-		length, err := v.client.ZCard(ctx, key).Result()
-		return length, err
-	case "none":
-		// Key doesn't exist, size is 0
-		return 0, nil
-	default:
-		return 0, nil
-	}
+	return keySize(ctx, v.conn(), key, keyType)
 }
 
 // SetValue stores a value for a key, handling all Valkey data types
 func (v *ValkeyClient) SetValue(key string, value interface{}) error {
-	if v.client == nil {
+	if v.conn() == nil {
 		return fmt.Errorf("Valkey client not connected")
 	}
 
 	// Determine data type and size for timeout calculation
-	var dataType string
-	var dataSize int64
-
-	switch val := value.(type) {
-	case string:
-		dataType = "string"
-		dataSize = int64(len(val))
-		ctx, cancel := v.config.OperationContext(dataType, dataSize)
-		defer cancel()
-		return v.client.Set(ctx, key, val, 0).Err()
-	case map[string]string:
-		dataType = "hash"
-		dataSize = int64(len(val))
-		ctx, cancel := v.config.OperationContext(dataType, dataSize)
-		defer cancel()
-		return v.client.HMSet(ctx, key, val).Err()
-	case []string:
-		dataType = "list"
-		dataSize = int64(len(val))
-		ctx, cancel := v.config.OperationContext(dataType, dataSize)
-		defer cancel()
-
-		// Clear existing list first
-		pipe := v.client.Pipeline()
-		pipe.Del(ctx, key)
-
-		// Add all elements (if any)
-		if len(val) > 0 {
-			for _, item := range val {
-				pipe.RPush(ctx, key, item)
-			}
-		} else {
-			// Create empty list by pushing and popping a dummy value
-			pipe.LPush(ctx, key, "dummy")
-			pipe.LPop(ctx, key)
-		}
-
-		_, err := pipe.Exec(ctx)
+	dataType, dataSize, err := describeValue(value)
+	if err != nil {
 		return err
-	case []interface{}:
-		dataType = "set"
-		dataSize = int64(len(val))
-		ctx, cancel := v.config.OperationContext(dataType, dataSize)
-		defer cancel()
-
-		// For sets - clear existing set first
-		pipe := v.client.Pipeline()
-		pipe.Del(ctx, key)
-
-		if len(val) > 0 {
-			pipe.SAdd(ctx, key, val...)
-		} else {
-			// Create empty set by adding and removing a dummy value
-			pipe.SAdd(ctx, key, "dummy")
-			pipe.SRem(ctx, key, "dummy")
-		}
-
-		_, err := pipe.Exec(ctx)
+	}
+
+	ctx, cancel := v.config.OperationContext(dataType, dataSize)
+	defer cancel()
+
+	return setValueWithExpiry(ctx, v.conn(), key, value, time.Time{})
+}
+
+// SetValueWithExpiry stores a value and its absolute expiry in a single transaction
+func (v *ValkeyClient) SetValueWithExpiry(key string, value interface{}, expireAt time.Time) error {
+	if v.conn() == nil {
+		return fmt.Errorf("Valkey client not connected")
+	}
+
+	// Determine data type and size for timeout calculation
+	dataType, dataSize, err := describeValue(value)
+	if err != nil {
 		return err
-	case []redis.Z:
-		dataType = "zset"
-		dataSize = int64(len(val))
-		ctx, cancel := v.config.OperationContext(dataType, dataSize)
-		defer cancel()
-
-		// For sorted sets - clear existing sorted set first
-		pipe := v.client.Pipeline()
-		pipe.Del(ctx, key)
-
-		if len(val) > 0 {
-			pipe.ZAdd(ctx, key, val...)
-		} else {
-			// Create empty sorted set by adding and removing a dummy value
-			pipe.ZAdd(ctx, key, redis.Z{Score: 0, Member: "dummy"})
-			pipe.ZRem(ctx, key, "dummy")
-		}
-
-		_, err := pipe.Exec(ctx)
+	}
+
+	ctx, cancel := v.config.OperationContext(dataType, dataSize)
+	defer cancel()
+
+	return setValueWithExpiry(ctx, v.conn(), key, value, expireAt)
+}
+
+// GetBatch reads the type, value and TTL of several keys using pipelined round trips
+func (v *ValkeyClient) GetBatch(keys []string) ([]KeyRecord, error) {
+	if v.conn() == nil {
+		return nil, fmt.Errorf("Valkey client not connected")
+	}
+
+	ctx, cancel := v.config.OperationContext("batch", int64(len(keys)))
+	defer cancel()
+
+	return getBatch(ctx, v.conn(), keys, v.config.largeDataThreshold())
+}
+
+// SetBatch writes several keys and their TTLs using a single pipeline
+func (v *ValkeyClient) SetBatch(records []KeyRecord) ([]error, error) {
+	if v.conn() == nil {
+		return nil, fmt.Errorf("Valkey client not connected")
+	}
+
+	ctx, cancel := v.config.OperationContext("batch", int64(len(records)))
+	defer cancel()
+
+	return setBatch(ctx, v.conn(), records)
+}
+
+// RunCommands runs several commands in order using a single pipeline
+func (v *ValkeyClient) RunCommands(commands [][]interface{}) ([]error, error) {
+	if v.conn() == nil {
+		return nil, fmt.Errorf("Valkey client not connected")
+	}
+
+	ctx, cancel := v.config.OperationContext("batch", int64(len(commands)))
+	defer cancel()
+
+	return runCommands(ctx, v.conn(), commands)
+}
+
+// RunTransaction runs several commands in order in a single MULTI/EXEC transaction
+func (v *ValkeyClient) RunTransaction(commands [][]interface{}) ([]error, error) {
+	if v.conn() == nil {
+		return nil, fmt.Errorf("Valkey client not connected")
+	}
+
+	ctx, cancel := v.config.OperationContext("batch", int64(len(commands)))
+	defer cancel()
+
+	return runTransaction(ctx, v.conn(), commands)
+}
+
+// ExistsBatch checks which of several Valkey keys exist using a single pipeline
+func (v *ValkeyClient) ExistsBatch(keys []string) ([]bool, error) {
+	if v.conn() == nil {
+		return nil, fmt.Errorf("Valkey client not connected")
+	}
+
+	ctx, cancel := v.config.OperationContext("batch", int64(len(keys)))
+	defer cancel()
+
+	return existsBatch(ctx, v.conn(), keys)
+}
+
+// DumpKeys serializes several keys with DUMP using a single pipeline
+func (v *ValkeyClient) DumpKeys(keys []string) ([]DumpRecord, error) {
+	if v.conn() == nil {
+		return nil, fmt.Errorf("Valkey client not connected")
+	}
+
+	ctx, cancel := v.config.OperationContext("dump", int64(len(keys)))
+	defer cancel()
+
+	return dumpKeys(ctx, v.conn(), keys)
+}
+
+// RestoreKeys recreates several keys from their DUMP payloads using a single pipeline
+func (v *ValkeyClient) RestoreKeys(records []DumpRecord) ([]error, error) {
+	if v.conn() == nil {
+		return nil, fmt.Errorf("Valkey client not connected")
+	}
+
+	ctx, cancel := v.config.OperationContext("dump", int64(len(records)))
+	defer cancel()
+
+	return restoreKeys(ctx, v.conn(), records)
+}
+
+// RDBVersion returns the RDB version the server serializes values with
+func (v *ValkeyClient) RDBVersion() (int, error) {
+	if v.conn() == nil {
+		return 0, fmt.Errorf("Valkey client not connected")
+	}
+
+	ctx, cancel := v.config.OperationContext("dump", 1)
+	defer cancel()
+
+	return rdbVersion(ctx, v.conn())
+}
+
+// KeySizeAndExpiry returns the number of elements in a collection or the length of a
+// string, and the absolute expiry of the key, in a single round trip
+func (v *ValkeyClient) KeySizeAndExpiry(key, keyType string) (int64, time.Time, bool, error) {
+	if v.conn() == nil {
+		return 0, time.Time{}, false, fmt.Errorf("Valkey client not connected")
+	}
+
+	ctx, cancel := v.config.OperationContext("size", 0)
+	defer cancel()
+
+	return keySizeAndExpiry(ctx, v.conn(), v.conn(), key, keyType)
+}
+
+// ReadChunk reads one chunk of a hash, set, sorted set or list
+func (v *ValkeyClient) ReadChunk(key, keyType string, cursor uint64, count int64) (interface{}, uint64, error) {
+	if v.conn() == nil {
+		return nil, 0, fmt.Errorf("Valkey client not connected")
+	}
+
+	ctx, cancel := v.config.OperationContext(keyType, count)
+	defer cancel()
+
+	chunk, next, err := readChunk(ctx, v.conn(), key, keyType, cursor, count)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read chunk of %s: %w", key, err)
+	}
+
+	return chunk, next, nil
+}
+
+// AppendChunk adds a chunk of elements to a hash, set, sorted set or list
+func (v *ValkeyClient) AppendChunk(key, keyType string, chunk interface{}) error {
+	if v.conn() == nil {
+		return fmt.Errorf("Valkey client not connected")
+	}
+
+	_, chunkSize, err := describeValue(chunk)
+	if err != nil {
 		return err
-	default:
-		return fmt.Errorf("unsupported value type: %T", value)
 	}
+
+	ctx, cancel := v.config.OperationContext(keyType, chunkSize)
+	defer cancel()
+
+	return appendChunk(ctx, v.conn(), key, keyType, chunk)
+}
+
+// RenameKey atomically renames a key, replacing the destination if it exists
+func (v *ValkeyClient) RenameKey(key, newKey string) error {
+	if v.conn() == nil {
+		return fmt.Errorf("Valkey client not connected")
+	}
+
+	ctx, cancel := v.config.OperationContext("rename", 0)
+	defer cancel()
+
+	return v.conn().Rename(ctx, key, newKey).Err()
+}
+
+// DeleteKey removes a key, reclaiming its memory in the background
+func (v *ValkeyClient) DeleteKey(key string) error {
+	if v.conn() == nil {
+		return fmt.Errorf("Valkey client not connected")
+	}
+
+	ctx, cancel := v.config.OperationContext("delete", 0)
+	defer cancel()
+
+	return v.conn().Unlink(ctx, key).Err()
 }
 
 // Exists checks if a key exists in Valkey
 func (v *ValkeyClient) Exists(key string) (bool, error) {
-	if v.client == nil {
+	if v.conn() == nil {
 		return false, fmt.Errorf("Valkey client not connected")
 	}
 
 	ctx, cancel := v.config.OperationContext("exists", 0)
 	defer cancel()
 
-	count, err := v.client.Exists(ctx, key).Result()
+	count, err := v.conn().Exists(ctx, key).Result()
 	if err != nil {
 		return false, fmt.Errorf("failed to check key existence for %s: %w", key, err)
 	}
@@ -297,26 +412,26 @@ func (v *ValkeyClient) Exists(key string) (bool, error) {
 
 // Ping tests the Valkey connection
 func (v *ValkeyClient) Ping() error {
-	if v.client == nil {
+	if v.conn() == nil {
 		return fmt.Errorf("Valkey client not connected")
 	}
 
 	ctx, cancel := v.config.ConnectionContext()
 	defer cancel()
 
-	return v.client.Ping(ctx).Err()
+	return v.conn().Ping(ctx).Err()
 }
 
 // GetTTL returns the time-to-live for a Valkey key
 func (v *ValkeyClient) GetTTL(key string) (time.Duration, error) {
-	if v.client == nil {
+	if v.conn() == nil {
 		return 0, fmt.Errorf("Valkey client not connected")
 	}
 
 	ctx, cancel := v.config.OperationContext("ttl", 0)
 	defer cancel()
 
-	ttl, err := v.client.TTL(ctx, key).Result()
+	ttl, err := v.conn().TTL(ctx, key).Result()
 	if err != nil {
 		return 0, fmt.Errorf("failed to get TTL for %s: %w", key, err)
 	}
@@ -326,12 +441,36 @@ func (v *ValkeyClient) GetTTL(key string) (time.Duration, error) {
 
 // SetTTL sets the time-to-live for a Valkey key
 func (v *ValkeyClient) SetTTL(key string, ttl time.Duration) error {
-	if v.client == nil {
+	if v.conn() == nil {
+		return fmt.Errorf("Valkey client not connected")
+	}
+
+	ctx, cancel := v.config.OperationContext("ttl", 0)
+	defer cancel()
+
+	return v.conn().Expire(ctx, key, ttl).Err()
+}
+
+// GetExpireAt returns the absolute, millisecond-precise expiry time of a Valkey key
+func (v *ValkeyClient) GetExpireAt(key string) (time.Time, bool, error) {
+	if v.conn() == nil {
+		return time.Time{}, false, fmt.Errorf("Valkey client not connected")
+	}
+
+	ctx, cancel := v.config.OperationContext("ttl", 0)
+	defer cancel()
+
+	return readExpireAt(ctx, v.conn(), key)
+}
+
+// SetExpireAt sets the absolute expiry time of a Valkey key
+func (v *ValkeyClient) SetExpireAt(key string, expireAt time.Time) error {
+	if v.conn() == nil {
 		return fmt.Errorf("Valkey client not connected")
 	}
 
 	ctx, cancel := v.config.OperationContext("ttl", 0)
 	defer cancel()
 
-	return v.client.Expire(ctx, key, ttl).Err()
+	return v.conn().PExpireAt(ctx, key, expireAt).Err()
 }
@@ -12,23 +12,58 @@ func BindFlags(cmd *cobra.Command) {
 	// Redis connection flags
 	cmd.Flags().String("redis-host", "localhost", "Redis server hostname or IP address")
 	cmd.Flags().Int("redis-port", 6379, "Redis server port number")
+	cmd.Flags().String("redis-username", "", "Redis ACL username (leave empty for the default user)")
 	cmd.Flags().String("redis-password", "", "Redis authentication password (leave empty if no auth required)")
+	cmd.Flags().String("redis-password-file", "", "Read the Redis password from this file instead of --redis-password")
+	cmd.Flags().String("redis-password-env", "", "Read the Redis password from this environment variable instead of --redis-password")
+	cmd.Flags().String("redis-password-command", "", "Run this shell command and use its output as the Redis password; it is run again for every new connection")
 	cmd.Flags().Int("redis-database", 0, "Redis database number to migrate from (0-15)")
 	cmd.Flags().Duration("redis-connection-timeout", 30*time.Second, "Redis connection timeout")
 	cmd.Flags().Duration("redis-operation-timeout", 10*time.Second, "Redis operation timeout")
 	cmd.Flags().Duration("redis-large-data-timeout", 60*time.Second, "Redis large data operation timeout")
+	cmd.Flags().Bool("redis-cluster", false, "Redis is a cluster; --redis-host and --redis-port name any node to discover the others from")
+	cmd.Flags().Bool("redis-read-from-replicas", false, "Scan and read keys from one replica of every Redis Cluster shard instead of its master")
+	cmd.Flags().StringSlice("redis-nodes", []string{}, "Additional Redis Cluster seed nodes as host:port. Can be specified multiple times.")
+	cmd.Flags().String("redis-sentinel-master", "", "Name of the Redis master to look up through Sentinel")
+	cmd.Flags().StringSlice("redis-sentinels", []string{}, "Redis Sentinel addresses as host:port, used with --redis-sentinel-master. Can be specified multiple times.")
+	cmd.Flags().String("redis-replica", "", "Redis replica as host:port to send scans and value reads to instead of the primary")
+	cmd.Flags().Int64("redis-max-replica-lag", 1048576, "Pause the migration while the Redis replica is more than this many bytes behind its primary (0 = pause on any lag)")
+	cmd.Flags().String("redis-url", "", "Redis endpoint as redis://[[username]:password@]host[:port][/database]; rediss:// enables TLS. Overrides --redis-host, --redis-port, --redis-username, --redis-password and --redis-database")
+	cmd.Flags().String("redis-rdb-file", "", "Read keys from an RDB snapshot file, such as a dump.rdb backup, instead of a running Redis")
+	cmd.Flags().Bool("redis-tls", false, "Connect to Redis over TLS")
+	cmd.Flags().String("redis-tls-ca-file", "", "PEM bundle of certificate authorities trusted to verify the Redis server certificate")
+	cmd.Flags().String("redis-tls-cert-file", "", "PEM client certificate presented to Redis for mutual TLS")
+	cmd.Flags().String("redis-tls-key-file", "", "PEM private key of the Redis client certificate")
+	cmd.Flags().String("redis-tls-server-name", "", "Host name to verify the Redis server certificate against")
+	cmd.Flags().Bool("redis-tls-insecure-skip-verify", false, "Skip verification of the Redis server certificate (insecure, for testing only)")
 
 	// Valkey connection flags
 	cmd.Flags().String("valkey-host", "localhost", "Valkey server hostname or IP address")
 	cmd.Flags().Int("valkey-port", 6380, "Valkey server port number")
+	cmd.Flags().String("valkey-username", "", "Valkey ACL username (leave empty for the default user)")
 	cmd.Flags().String("valkey-password", "", "Valkey authentication password (leave empty if no auth required)")
+	cmd.Flags().String("valkey-password-file", "", "Read the Valkey password from this file instead of --valkey-password")
+	cmd.Flags().String("valkey-password-env", "", "Read the Valkey password from this environment variable instead of --valkey-password")
+	cmd.Flags().String("valkey-password-command", "", "Run this shell command and use its output as the Valkey password; it is run again for every new connection")
 	cmd.Flags().Int("valkey-database", 0, "Valkey database number to migrate to (0-15)")
 	cmd.Flags().Duration("valkey-connection-timeout", 30*time.Second, "Valkey connection timeout")
 	cmd.Flags().Duration("valkey-operation-timeout", 10*time.Second, "Valkey operation timeout")
 	cmd.Flags().Duration("valkey-large-data-timeout", 60*time.Second, "Valkey large data operation timeout")
+	cmd.Flags().Bool("valkey-cluster", false, "Valkey is a cluster; --valkey-host and --valkey-port name any node to discover the others from")
+	cmd.Flags().StringSlice("valkey-nodes", []string{}, "Additional Valkey Cluster seed nodes as host:port. Can be specified multiple times.")
+	cmd.Flags().String("valkey-sentinel-master", "", "Name of the Valkey master to look up through Sentinel")
+	cmd.Flags().StringSlice("valkey-sentinels", []string{}, "Valkey Sentinel addresses as host:port, used with --valkey-sentinel-master. Can be specified multiple times.")
+	cmd.Flags().String("valkey-url", "", "Valkey endpoint as redis://[[username]:password@]host[:port][/database]; rediss:// enables TLS. Overrides --valkey-host, --valkey-port, --valkey-username, --valkey-password and --valkey-database")
+	cmd.Flags().String("valkey-command-file", "", "Write the migration as RESP commands for valkey-cli --pipe to this file (- for stdout) instead of a running Valkey")
+	cmd.Flags().Bool("valkey-tls", false, "Connect to Valkey over TLS")
+	cmd.Flags().String("valkey-tls-ca-file", "", "PEM bundle of certificate authorities trusted to verify the Valkey server certificate")
+	cmd.Flags().String("valkey-tls-cert-file", "", "PEM client certificate presented to Valkey for mutual TLS")
+	cmd.Flags().String("valkey-tls-key-file", "", "PEM private key of the Valkey client certificate")
+	cmd.Flags().String("valkey-tls-server-name", "", "Host name to verify the Valkey server certificate against")
+	cmd.Flags().Bool("valkey-tls-insecure-skip-verify", false, "Skip verification of the Valkey server certificate (insecure, for testing only)")
 
 	// Migration behavior flags
-	cmd.Flags().Int("batch-size", 1000, "Number of keys to process in each batch (higher values use more memory)")
+	cmd.Flags().Int("batch-size", 1000, "Number of keys read and written per pipelined round trip (higher values use more memory)")
 	cmd.Flags().Int("retry-attempts", 3, "Number of retry attempts for failed operations before giving up")
 	cmd.Flags().String("log-level", "info", "Logging level: trace, debug, info, warn, error, fatal, panic")
 
@@ -46,22 +81,71 @@ func BindFlags(cmd *cobra.Command) {
 	cmd.Flags().StringSlice("pattern", []string{}, "Key patterns to migrate (glob-style, e.g., 'user:*', 'session:*'). Can be specified multiple times.")
 	cmd.Flags().StringSlice("collections", []string{}, "Alias for --pattern. Key patterns to migrate (glob-style). Can be specified multiple times.")
 
+	// Database selection flags
+	cmd.Flags().Bool("all-databases", false, "Migrate every non-empty Redis database, found with INFO keyspace, instead of --redis-database")
+	cmd.Flags().String("database-map", "", "With --all-databases, migrate source databases to other target databases, e.g. '0->0,3->1'. Unmapped databases keep their number.")
+
+	// Key rename flags
+	cmd.Flags().StringArray("key-rule", []string{}, "Rename keys on the target: strip-prefix=P, add-prefix=P, replace=REGEX=>REPLACEMENT or hash-tag=REGEX. Can be specified multiple times, rules apply in order.")
+
+	UseFlags(cmd)
+}
+
+// UseFlags binds the configuration keys to the flags BindFlags added to cmd. A key is
+// bound to one command's flag at a time, so commands sharing the flags call it before
+// loading the configuration.
+func UseFlags(cmd *cobra.Command) {
 	// Bind flags to viper
 	viper.BindPFlag("redis.host", cmd.Flags().Lookup("redis-host"))
 	viper.BindPFlag("redis.port", cmd.Flags().Lookup("redis-port"))
+	viper.BindPFlag("redis.username", cmd.Flags().Lookup("redis-username"))
 	viper.BindPFlag("redis.password", cmd.Flags().Lookup("redis-password"))
+	viper.BindPFlag("redis.password_file", cmd.Flags().Lookup("redis-password-file"))
+	viper.BindPFlag("redis.password_env", cmd.Flags().Lookup("redis-password-env"))
+	viper.BindPFlag("redis.password_command", cmd.Flags().Lookup("redis-password-command"))
 	viper.BindPFlag("redis.database", cmd.Flags().Lookup("redis-database"))
 	viper.BindPFlag("redis.connection_timeout", cmd.Flags().Lookup("redis-connection-timeout"))
 	viper.BindPFlag("redis.operation_timeout", cmd.Flags().Lookup("redis-operation-timeout"))
 	viper.BindPFlag("redis.large_data_timeout", cmd.Flags().Lookup("redis-large-data-timeout"))
+	viper.BindPFlag("redis.cluster", cmd.Flags().Lookup("redis-cluster"))
+	viper.BindPFlag("redis.read_from_replicas", cmd.Flags().Lookup("redis-read-from-replicas"))
+	viper.BindPFlag("redis.nodes", cmd.Flags().Lookup("redis-nodes"))
+	viper.BindPFlag("redis.sentinel_master", cmd.Flags().Lookup("redis-sentinel-master"))
+	viper.BindPFlag("redis.sentinels", cmd.Flags().Lookup("redis-sentinels"))
+	viper.BindPFlag("redis.replica", cmd.Flags().Lookup("redis-replica"))
+	viper.BindPFlag("redis.max_replica_lag", cmd.Flags().Lookup("redis-max-replica-lag"))
+	viper.BindPFlag("redis.url", cmd.Flags().Lookup("redis-url"))
+	viper.BindPFlag("redis.rdb_file", cmd.Flags().Lookup("redis-rdb-file"))
+	viper.BindPFlag("redis.tls.enabled", cmd.Flags().Lookup("redis-tls"))
+	viper.BindPFlag("redis.tls.ca_file", cmd.Flags().Lookup("redis-tls-ca-file"))
+	viper.BindPFlag("redis.tls.cert_file", cmd.Flags().Lookup("redis-tls-cert-file"))
+	viper.BindPFlag("redis.tls.key_file", cmd.Flags().Lookup("redis-tls-key-file"))
+	viper.BindPFlag("redis.tls.server_name", cmd.Flags().Lookup("redis-tls-server-name"))
+	viper.BindPFlag("redis.tls.insecure_skip_verify", cmd.Flags().Lookup("redis-tls-insecure-skip-verify"))
 
 	viper.BindPFlag("valkey.host", cmd.Flags().Lookup("valkey-host"))
 	viper.BindPFlag("valkey.port", cmd.Flags().Lookup("valkey-port"))
+	viper.BindPFlag("valkey.username", cmd.Flags().Lookup("valkey-username"))
 	viper.BindPFlag("valkey.password", cmd.Flags().Lookup("valkey-password"))
+	viper.BindPFlag("valkey.password_file", cmd.Flags().Lookup("valkey-password-file"))
+	viper.BindPFlag("valkey.password_env", cmd.Flags().Lookup("valkey-password-env"))
+	viper.BindPFlag("valkey.password_command", cmd.Flags().Lookup("valkey-password-command"))
 	viper.BindPFlag("valkey.database", cmd.Flags().Lookup("valkey-database"))
 	viper.BindPFlag("valkey.connection_timeout", cmd.Flags().Lookup("valkey-connection-timeout"))
 	viper.BindPFlag("valkey.operation_timeout", cmd.Flags().Lookup("valkey-operation-timeout"))
 	viper.BindPFlag("valkey.large_data_timeout", cmd.Flags().Lookup("valkey-large-data-timeout"))
+	viper.BindPFlag("valkey.cluster", cmd.Flags().Lookup("valkey-cluster"))
+	viper.BindPFlag("valkey.nodes", cmd.Flags().Lookup("valkey-nodes"))
+	viper.BindPFlag("valkey.sentinel_master", cmd.Flags().Lookup("valkey-sentinel-master"))
+	viper.BindPFlag("valkey.sentinels", cmd.Flags().Lookup("valkey-sentinels"))
+	viper.BindPFlag("valkey.url", cmd.Flags().Lookup("valkey-url"))
+	viper.BindPFlag("valkey.command_file", cmd.Flags().Lookup("valkey-command-file"))
+	viper.BindPFlag("valkey.tls.enabled", cmd.Flags().Lookup("valkey-tls"))
+	viper.BindPFlag("valkey.tls.ca_file", cmd.Flags().Lookup("valkey-tls-ca-file"))
+	viper.BindPFlag("valkey.tls.cert_file", cmd.Flags().Lookup("valkey-tls-cert-file"))
+	viper.BindPFlag("valkey.tls.key_file", cmd.Flags().Lookup("valkey-tls-key-file"))
+	viper.BindPFlag("valkey.tls.server_name", cmd.Flags().Lookup("valkey-tls-server-name"))
+	viper.BindPFlag("valkey.tls.insecure_skip_verify", cmd.Flags().Lookup("valkey-tls-insecure-skip-verify"))
 
 	viper.BindPFlag("migration.batch_size", cmd.Flags().Lookup("batch-size"))
 	viper.BindPFlag("migration.retry_attempts", cmd.Flags().Lookup("retry-attempts"))
@@ -79,6 +163,11 @@ func BindFlags(cmd *cobra.Command) {
 	viper.BindPFlag("migration.collection_patterns", cmd.Flags().Lookup("pattern"))
 	// Also bind collections flag to the same config key
 	viper.BindPFlag("migration.collection_patterns", cmd.Flags().Lookup("collections"))
+
+	viper.BindPFlag("migration.key_rules", cmd.Flags().Lookup("key-rule"))
+
+	viper.BindPFlag("migration.all_databases", cmd.Flags().Lookup("all-databases"))
+	viper.BindPFlag("migration.database_map", cmd.Flags().Lookup("database-map"))
 }
 
 // LoadConfigWithFlags loads configuration with command-line flag support
@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -9,6 +11,8 @@ import (
 	"time"
 
 	"github.com/spf13/viper"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/transform"
 )
 
 // Config represents the complete configuration for the migration tool
@@ -22,11 +26,86 @@ type Config struct {
 type DatabaseConfig struct {
 	Host              string        `mapstructure:"host"`
 	Port              int           `mapstructure:"port"`
+	Username          string        `mapstructure:"username"`
 	Password          string        `mapstructure:"password"`
 	Database          int           `mapstructure:"database"`
 	ConnectionTimeout time.Duration `mapstructure:"connection_timeout"`
 	OperationTimeout  time.Duration `mapstructure:"operation_timeout"`
 	LargeDataTimeout  time.Duration `mapstructure:"large_data_timeout"`
+
+	// Cluster connects to a cluster, discovering its nodes from Host and Port and
+	// the additional seed nodes in Nodes
+	Cluster bool `mapstructure:"cluster"`
+	// Nodes lists additional cluster seed nodes as host:port, tried when Host and
+	// Port cannot be reached
+	Nodes []string `mapstructure:"nodes"`
+	// ReadFromReplicas scans and reads keys from one replica of every cluster shard
+	// instead of its master
+	ReadFromReplicas bool `mapstructure:"read_from_replicas"`
+
+	// SentinelMaster is the name of the master monitored by the Sentinels in Sentinels.
+	// When set, the current master is looked up through Sentinel instead of connecting
+	// to Host and Port, and failovers are followed.
+	SentinelMaster string `mapstructure:"sentinel_master"`
+	// Sentinels lists the Sentinel addresses as host:port
+	Sentinels []string `mapstructure:"sentinels"`
+
+	// Replica is a replica of the source as host:port. Scans and value reads are sent to
+	// it, while PING, TTL reads and verification keep going to Host and Port.
+	Replica string `mapstructure:"replica"`
+	// MaxReplicaLag is how many bytes of replication stream the replica may be behind
+	// before the migration pauses until it catches up
+	MaxReplicaLag int64 `mapstructure:"max_replica_lag"`
+
+	// URL is a redis://, rediss://, valkey:// or valkeys:// endpoint. When set, it overrides
+	// Host, Port, Username, Password and Database, and the rediss and valkeys schemes
+	// enable TLS.
+	URL string `mapstructure:"url"`
+
+	TLS TLSConfig `mapstructure:"tls"`
+
+	// PasswordFile, PasswordEnv and PasswordCommand read the password from a file, an
+	// environment variable or a command's output instead of Password. The password is
+	// read again for every new connection.
+	PasswordFile    string `mapstructure:"password_file"`
+	PasswordEnv     string `mapstructure:"password_env"`
+	PasswordCommand string `mapstructure:"password_command"`
+
+	// RDBFile is an RDB snapshot file, such as a dump.rdb backup, read instead of
+	// connecting to a server. Only the source can be a file.
+	RDBFile string `mapstructure:"rdb_file"`
+
+	// CommandFile is a file, or - for standard output, that the migration is written to
+	// as RESP commands for valkey-cli --pipe instead of connecting to a server. Only the
+	// target can be a file.
+	CommandFile string `mapstructure:"command_file"`
+}
+
+// TLSConfig holds TLS settings for a database connection
+type TLSConfig struct {
+	// Enabled negotiates TLS on every connection
+	Enabled bool `mapstructure:"enabled"`
+	// CAFile is a PEM bundle of certificate authorities trusted instead of the system pool
+	CAFile string `mapstructure:"ca_file"`
+	// CertFile and KeyFile are the PEM client certificate and key presented for mutual TLS
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+	// ServerName overrides the host name the server certificate is verified against
+	ServerName string `mapstructure:"server_name"`
+	// InsecureSkipVerify disables verification of the server certificate
+	InsecureSkipVerify bool `mapstructure:"insecure_skip_verify"`
+}
+
+// SeedAddrs returns Host and Port followed by the additional seed nodes, as host:port
+func (c *DatabaseConfig) SeedAddrs() []string {
+	primary := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	addrs := []string{primary}
+	for _, node := range c.Nodes {
+		if node != primary {
+			addrs = append(addrs, node)
+		}
+	}
+	return addrs
 }
 
 // MigrationConfig holds migration-specific settings
@@ -36,6 +115,9 @@ type MigrationConfig struct {
 	LogLevel           string        `mapstructure:"log_level"`
 	TimeoutConfig      TimeoutConfig `mapstructure:"timeout_config"`
 	CollectionPatterns []string      `mapstructure:"collection_patterns"`
+	KeyRules           []string      `mapstructure:"key_rules"`
+	AllDatabases       bool          `mapstructure:"all_databases"`
+	DatabaseMap        string        `mapstructure:"database_map"`
 }
 
 // TimeoutConfig holds operation-specific timeout settings
@@ -82,6 +164,10 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("error unmarshaling config: %w", err)
 	}
 
+	if err := applyURLs(&config); err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	}
+
 	// Validate configuration
 	if err := ValidateConfig(&config); err != nil {
 		return nil, fmt.Errorf("configuration validation failed: %w", err)
@@ -95,20 +181,55 @@ func setDefaults() {
 	// Redis defaults
 	viper.SetDefault("redis.host", "localhost")
 	viper.SetDefault("redis.port", 6379)
+	viper.SetDefault("redis.username", "")
 	viper.SetDefault("redis.password", "")
+	viper.SetDefault("redis.password_file", "")
+	viper.SetDefault("redis.password_env", "")
+	viper.SetDefault("redis.password_command", "")
 	viper.SetDefault("redis.database", 0)
 	viper.SetDefault("redis.connection_timeout", "30s")
 	viper.SetDefault("redis.operation_timeout", "10s")
 	viper.SetDefault("redis.large_data_timeout", "60s")
+	viper.SetDefault("redis.cluster", false)
+	viper.SetDefault("redis.read_from_replicas", false)
+	viper.SetDefault("redis.nodes", []string{})
+	viper.SetDefault("redis.sentinel_master", "")
+	viper.SetDefault("redis.sentinels", []string{})
+	viper.SetDefault("redis.replica", "")
+	viper.SetDefault("redis.max_replica_lag", 1048576)
+	viper.SetDefault("redis.url", "")
+	viper.SetDefault("redis.rdb_file", "")
+	viper.SetDefault("redis.tls.enabled", false)
+	viper.SetDefault("redis.tls.ca_file", "")
+	viper.SetDefault("redis.tls.cert_file", "")
+	viper.SetDefault("redis.tls.key_file", "")
+	viper.SetDefault("redis.tls.server_name", "")
+	viper.SetDefault("redis.tls.insecure_skip_verify", false)
 
 	// Valkey defaults
 	viper.SetDefault("valkey.host", "localhost")
 	viper.SetDefault("valkey.port", 6380)
+	viper.SetDefault("valkey.username", "")
 	viper.SetDefault("valkey.password", "")
+	viper.SetDefault("valkey.password_file", "")
+	viper.SetDefault("valkey.password_env", "")
+	viper.SetDefault("valkey.password_command", "")
 	viper.SetDefault("valkey.database", 0)
 	viper.SetDefault("valkey.connection_timeout", "30s")
 	viper.SetDefault("valkey.operation_timeout", "10s")
 	viper.SetDefault("valkey.large_data_timeout", "60s")
+	viper.SetDefault("valkey.cluster", false)
+	viper.SetDefault("valkey.nodes", []string{})
+	viper.SetDefault("valkey.sentinel_master", "")
+	viper.SetDefault("valkey.sentinels", []string{})
+	viper.SetDefault("valkey.url", "")
+	viper.SetDefault("valkey.command_file", "")
+	viper.SetDefault("valkey.tls.enabled", false)
+	viper.SetDefault("valkey.tls.ca_file", "")
+	viper.SetDefault("valkey.tls.cert_file", "")
+	viper.SetDefault("valkey.tls.key_file", "")
+	viper.SetDefault("valkey.tls.server_name", "")
+	viper.SetDefault("valkey.tls.insecure_skip_verify", false)
 
 	// Migration defaults
 	viper.SetDefault("migration.batch_size", 1000)
@@ -132,26 +253,64 @@ func bindEnvVars() {
 	// Redis environment variables
 	viper.BindEnv("redis.host", "RVM_REDIS_HOST")
 	viper.BindEnv("redis.port", "RVM_REDIS_PORT")
+	viper.BindEnv("redis.username", "RVM_REDIS_USERNAME")
 	viper.BindEnv("redis.password", "RVM_REDIS_PASSWORD")
+	viper.BindEnv("redis.password_file", "RVM_REDIS_PASSWORD_FILE")
+	viper.BindEnv("redis.password_env", "RVM_REDIS_PASSWORD_ENV")
+	viper.BindEnv("redis.password_command", "RVM_REDIS_PASSWORD_COMMAND")
 	viper.BindEnv("redis.database", "RVM_REDIS_DATABASE")
 	viper.BindEnv("redis.connection_timeout", "RVM_REDIS_CONNECTION_TIMEOUT")
 	viper.BindEnv("redis.operation_timeout", "RVM_REDIS_OPERATION_TIMEOUT")
 	viper.BindEnv("redis.large_data_timeout", "RVM_REDIS_LARGE_DATA_TIMEOUT")
+	viper.BindEnv("redis.cluster", "RVM_REDIS_CLUSTER")
+	viper.BindEnv("redis.read_from_replicas", "RVM_REDIS_READ_FROM_REPLICAS")
+	viper.BindEnv("redis.nodes", "RVM_REDIS_NODES")
+	viper.BindEnv("redis.sentinel_master", "RVM_REDIS_SENTINEL_MASTER")
+	viper.BindEnv("redis.sentinels", "RVM_REDIS_SENTINELS")
+	viper.BindEnv("redis.replica", "RVM_REDIS_REPLICA")
+	viper.BindEnv("redis.max_replica_lag", "RVM_REDIS_MAX_REPLICA_LAG")
+	viper.BindEnv("redis.url", "RVM_REDIS_URL")
+	viper.BindEnv("redis.rdb_file", "RVM_REDIS_RDB_FILE")
+	viper.BindEnv("redis.tls.enabled", "RVM_REDIS_TLS_ENABLED")
+	viper.BindEnv("redis.tls.ca_file", "RVM_REDIS_TLS_CA_FILE")
+	viper.BindEnv("redis.tls.cert_file", "RVM_REDIS_TLS_CERT_FILE")
+	viper.BindEnv("redis.tls.key_file", "RVM_REDIS_TLS_KEY_FILE")
+	viper.BindEnv("redis.tls.server_name", "RVM_REDIS_TLS_SERVER_NAME")
+	viper.BindEnv("redis.tls.insecure_skip_verify", "RVM_REDIS_TLS_INSECURE_SKIP_VERIFY")
 
 	// Valkey environment variables
 	viper.BindEnv("valkey.host", "RVM_VALKEY_HOST")
 	viper.BindEnv("valkey.port", "RVM_VALKEY_PORT")
+	viper.BindEnv("valkey.username", "RVM_VALKEY_USERNAME")
 	viper.BindEnv("valkey.password", "RVM_VALKEY_PASSWORD")
+	viper.BindEnv("valkey.password_file", "RVM_VALKEY_PASSWORD_FILE")
+	viper.BindEnv("valkey.password_env", "RVM_VALKEY_PASSWORD_ENV")
+	viper.BindEnv("valkey.password_command", "RVM_VALKEY_PASSWORD_COMMAND")
 	viper.BindEnv("valkey.database", "RVM_VALKEY_DATABASE")
 	viper.BindEnv("valkey.connection_timeout", "RVM_VALKEY_CONNECTION_TIMEOUT")
 	viper.BindEnv("valkey.operation_timeout", "RVM_VALKEY_OPERATION_TIMEOUT")
 	viper.BindEnv("valkey.large_data_timeout", "RVM_VALKEY_LARGE_DATA_TIMEOUT")
+	viper.BindEnv("valkey.cluster", "RVM_VALKEY_CLUSTER")
+	viper.BindEnv("valkey.nodes", "RVM_VALKEY_NODES")
+	viper.BindEnv("valkey.sentinel_master", "RVM_VALKEY_SENTINEL_MASTER")
+	viper.BindEnv("valkey.sentinels", "RVM_VALKEY_SENTINELS")
+	viper.BindEnv("valkey.url", "RVM_VALKEY_URL")
+	viper.BindEnv("valkey.command_file", "RVM_VALKEY_COMMAND_FILE")
+	viper.BindEnv("valkey.tls.enabled", "RVM_VALKEY_TLS_ENABLED")
+	viper.BindEnv("valkey.tls.ca_file", "RVM_VALKEY_TLS_CA_FILE")
+	viper.BindEnv("valkey.tls.cert_file", "RVM_VALKEY_TLS_CERT_FILE")
+	viper.BindEnv("valkey.tls.key_file", "RVM_VALKEY_TLS_KEY_FILE")
+	viper.BindEnv("valkey.tls.server_name", "RVM_VALKEY_TLS_SERVER_NAME")
+	viper.BindEnv("valkey.tls.insecure_skip_verify", "RVM_VALKEY_TLS_INSECURE_SKIP_VERIFY")
 
 	// Migration environment variables
 	viper.BindEnv("migration.batch_size", "RVM_MIGRATION_BATCH_SIZE")
 	viper.BindEnv("migration.retry_attempts", "RVM_MIGRATION_RETRY_ATTEMPTS")
 	viper.BindEnv("migration.log_level", "RVM_MIGRATION_LOG_LEVEL")
 	viper.BindEnv("migration.collection_patterns", "RVM_MIGRATION_COLLECTION_PATTERNS")
+	viper.BindEnv("migration.key_rules", "RVM_MIGRATION_KEY_RULES")
+	viper.BindEnv("migration.all_databases", "RVM_MIGRATION_ALL_DATABASES")
+	viper.BindEnv("migration.database_map", "RVM_MIGRATION_DATABASE_MAP")
 
 	// Timeout configuration environment variables
 	viper.BindEnv("migration.timeout_config.connection_timeout", "RVM_TIMEOUT_CONNECTION")
@@ -175,6 +334,22 @@ func ValidateConfig(config *Config) error {
 		return err
 	}
 
+	if config.Valkey.ReadFromReplicas {
+		return fmt.Errorf("Valkey read from replicas is not supported, Valkey is the migration target")
+	}
+
+	if config.Valkey.Replica != "" {
+		return fmt.Errorf("Valkey replica reads are not supported, Valkey is the migration target")
+	}
+
+	if config.Valkey.RDBFile != "" {
+		return fmt.Errorf("Valkey RDB file is not supported, Valkey is the migration target")
+	}
+
+	if config.Redis.CommandFile != "" {
+		return fmt.Errorf("Redis command file is not supported, Redis is the migration source")
+	}
+
 	if err := validateMigrationConfig(&config.Migration); err != nil {
 		return err
 	}
@@ -187,6 +362,14 @@ func ValidateConfig(config *Config) error {
 		return err
 	}
 
+	if _, err := transform.ParseRules(config.Migration.KeyRules); err != nil {
+		return err
+	}
+
+	if err := validateDatabaseMode(config); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -216,6 +399,190 @@ func validateDatabaseConfig(name string, dbConfig *DatabaseConfig) error {
 		return fmt.Errorf("%s large data timeout must be positive, got %v", name, dbConfig.LargeDataTimeout)
 	}
 
+	if dbConfig.Cluster && dbConfig.Database != 0 {
+		return fmt.Errorf("%s cluster only supports database 0, got %d", name, dbConfig.Database)
+	}
+
+	if dbConfig.ReadFromReplicas && !dbConfig.Cluster {
+		return fmt.Errorf("%s read from replicas requires cluster mode", name)
+	}
+
+	if len(dbConfig.Nodes) > 0 && !dbConfig.Cluster {
+		return fmt.Errorf("%s seed nodes require cluster mode", name)
+	}
+
+	if err := validateTLSConfig(name, &dbConfig.TLS); err != nil {
+		return err
+	}
+
+	if err := validatePasswordSource(name, dbConfig); err != nil {
+		return err
+	}
+
+	for _, node := range dbConfig.Nodes {
+		if err := validateAddr(name, "seed node", node); err != nil {
+			return err
+		}
+	}
+
+	if (dbConfig.SentinelMaster == "") != (len(dbConfig.Sentinels) == 0) {
+		return fmt.Errorf("%s sentinel master name and sentinel addresses must be set together", name)
+	}
+
+	if dbConfig.SentinelMaster != "" && dbConfig.Cluster {
+		return fmt.Errorf("%s sentinel and cluster modes cannot be combined", name)
+	}
+
+	for _, sentinel := range dbConfig.Sentinels {
+		if err := validateAddr(name, "sentinel", sentinel); err != nil {
+			return err
+		}
+	}
+
+	if dbConfig.Replica != "" {
+		if dbConfig.Cluster {
+			return fmt.Errorf("%s replica reads cannot be combined with cluster mode, use read from replicas instead", name)
+		}
+		if err := validateAddr(name, "replica", dbConfig.Replica); err != nil {
+			return err
+		}
+	}
+
+	if dbConfig.MaxReplicaLag < 0 {
+		return fmt.Errorf("%s max replica lag cannot be negative, got %d", name, dbConfig.MaxReplicaLag)
+	}
+
+	if dbConfig.RDBFile != "" {
+		if dbConfig.Cluster || dbConfig.SentinelMaster != "" || dbConfig.Replica != "" {
+			return fmt.Errorf("%s RDB file cannot be combined with cluster, sentinel or replica settings", name)
+		}
+		if _, err := os.Stat(dbConfig.RDBFile); err != nil {
+			return fmt.Errorf("%s RDB file: %w", name, err)
+		}
+	}
+
+	if dbConfig.CommandFile != "" && (dbConfig.Cluster || dbConfig.SentinelMaster != "") {
+		return fmt.Errorf("%s command file cannot be combined with cluster or sentinel settings", name)
+	}
+
+	return nil
+}
+
+// validateAddr validates a host:port address
+func validateAddr(name, kind, addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return fmt.Errorf("%s %s %q must be host:port", name, kind, addr)
+	}
+	if portNum, err := strconv.Atoi(port); err != nil || portNum <= 0 || portNum > 65535 {
+		return fmt.Errorf("%s %s %q port must be between 1 and 65535", name, kind, addr)
+	}
+	return nil
+}
+
+// validateTLSConfig validates TLS settings and checks that the files they name exist
+func validateTLSConfig(name string, tlsConfig *TLSConfig) error {
+	if !tlsConfig.Enabled {
+		if tlsConfig.CAFile != "" || tlsConfig.CertFile != "" || tlsConfig.KeyFile != "" ||
+			tlsConfig.ServerName != "" || tlsConfig.InsecureSkipVerify {
+			return fmt.Errorf("%s TLS options require TLS to be enabled", name)
+		}
+		return nil
+	}
+
+	if (tlsConfig.CertFile == "") != (tlsConfig.KeyFile == "") {
+		return fmt.Errorf("%s TLS client certificate and key must be set together", name)
+	}
+
+	for _, file := range []string{tlsConfig.CAFile, tlsConfig.CertFile, tlsConfig.KeyFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			return fmt.Errorf("%s TLS file cannot be read: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// validatePasswordSource checks that the password comes from at most one source
+func validatePasswordSource(name string, dbConfig *DatabaseConfig) error {
+	sources := 0
+	for _, source := range []string{dbConfig.Password, dbConfig.PasswordFile, dbConfig.PasswordEnv, dbConfig.PasswordCommand} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return fmt.Errorf("%s password, password file, password env and password command are mutually exclusive", name)
+	}
+
+	if dbConfig.PasswordFile != "" {
+		if _, err := os.Stat(dbConfig.PasswordFile); err != nil {
+			return fmt.Errorf("%s password file cannot be read: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// applyURLs replaces the connection settings of each database that has a URL with the
+// ones from the URL
+func applyURLs(config *Config) error {
+	if err := applyURL("Redis", &config.Redis); err != nil {
+		return err
+	}
+	return applyURL("Valkey", &config.Valkey)
+}
+
+// applyURL parses a redis://, rediss://, valkey:// or valkeys:// URL into dbConfig
+func applyURL(name string, dbConfig *DatabaseConfig) error {
+	if dbConfig.URL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(dbConfig.URL)
+	if err != nil {
+		return fmt.Errorf("%s URL is invalid: %w", name, err)
+	}
+
+	switch u.Scheme {
+	case "rediss", "valkeys":
+		dbConfig.TLS.Enabled = true
+	case "redis", "valkey":
+	default:
+		return fmt.Errorf("%s URL scheme must be redis, rediss, valkey or valkeys, got %q", name, u.Scheme)
+	}
+
+	if u.Hostname() == "" {
+		return fmt.Errorf("%s URL must include a host", name)
+	}
+	dbConfig.Host = u.Hostname()
+
+	dbConfig.Port = 6379
+	if port := u.Port(); port != "" {
+		if dbConfig.Port, err = strconv.Atoi(port); err != nil {
+			return fmt.Errorf("%s URL port is invalid: %s", name, port)
+		}
+	}
+
+	if u.User != nil {
+		if username := u.User.Username(); username != "" && username != "default" {
+			dbConfig.Username = username
+		}
+		if password, ok := u.User.Password(); ok {
+			dbConfig.Password = password
+		}
+	}
+
+	dbConfig.Database = 0
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		if dbConfig.Database, err = strconv.Atoi(path); err != nil {
+			return fmt.Errorf("%s URL database is invalid: %s", name, path)
+		}
+	}
+
 	return nil
 }
 
@@ -321,6 +688,66 @@ func validateTimeoutConfig(timeoutConfig *TimeoutConfig) error {
 	return nil
 }
 
+// validateDatabaseMode validates the settings of the mode migrating every database
+func validateDatabaseMode(config *Config) error {
+	mapping, err := ParseDatabaseMap(config.Migration.DatabaseMap)
+	if err != nil {
+		return err
+	}
+
+	if !config.Migration.AllDatabases {
+		if len(mapping) > 0 {
+			return fmt.Errorf("database map can only be used when migrating all databases")
+		}
+		return nil
+	}
+
+	if config.Redis.Database != 0 || config.Valkey.Database != 0 {
+		return fmt.Errorf("migrating all databases selects the databases itself, the Redis and Valkey databases must be left at 0")
+	}
+
+	if config.Valkey.Cluster {
+		for source, target := range mapping {
+			if target != 0 {
+				return fmt.Errorf("Valkey cluster only has database 0, database %d cannot be mapped to %d", source, target)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ParseDatabaseMap parses a mapping of source to target databases written as
+// source->target pairs separated by commas, such as 0->0,3->1. Several source databases
+// may be mapped to the same target database. An empty mapping returns an empty map.
+func ParseDatabaseMap(spec string) (map[int]int, error) {
+	mapping := make(map[int]int)
+	if strings.TrimSpace(spec) == "" {
+		return mapping, nil
+	}
+
+	for _, pair := range strings.Split(spec, ",") {
+		sourceText, targetText, ok := strings.Cut(pair, "->")
+		if !ok {
+			return nil, fmt.Errorf("invalid database mapping %q, expected source->target", strings.TrimSpace(pair))
+		}
+		source, sourceErr := strconv.Atoi(strings.TrimSpace(sourceText))
+		target, targetErr := strconv.Atoi(strings.TrimSpace(targetText))
+		if sourceErr != nil || targetErr != nil {
+			return nil, fmt.Errorf("invalid database mapping %q, databases must be numbers", strings.TrimSpace(pair))
+		}
+		if source < 0 || source > 15 || target < 0 || target > 15 {
+			return nil, fmt.Errorf("invalid database mapping %q, databases must be between 0 and 15", strings.TrimSpace(pair))
+		}
+		if _, exists := mapping[source]; exists {
+			return nil, fmt.Errorf("database %d is mapped more than once", source)
+		}
+		mapping[source] = target
+	}
+
+	return mapping, nil
+}
+
 // validateCollectionPatterns validates collection pattern syntax
 func validateCollectionPatterns(patterns []string) error {
 	if len(patterns) == 0 {
@@ -353,26 +780,68 @@ func LoadConfigFromEnv() (*Config, error) {
 		Redis: DatabaseConfig{
 			Host:              getEnvString("RVM_REDIS_HOST", "localhost"),
 			Port:              getEnvInt("RVM_REDIS_PORT", 6379),
+			Username:          getEnvString("RVM_REDIS_USERNAME", ""),
 			Password:          getEnvString("RVM_REDIS_PASSWORD", ""),
+			PasswordFile:      getEnvString("RVM_REDIS_PASSWORD_FILE", ""),
+			PasswordEnv:       getEnvString("RVM_REDIS_PASSWORD_ENV", ""),
+			PasswordCommand:   getEnvString("RVM_REDIS_PASSWORD_COMMAND", ""),
 			Database:          getEnvInt("RVM_REDIS_DATABASE", 0),
 			ConnectionTimeout: getEnvDuration("RVM_REDIS_CONNECTION_TIMEOUT", 30*time.Second),
 			OperationTimeout:  getEnvDuration("RVM_REDIS_OPERATION_TIMEOUT", 10*time.Second),
 			LargeDataTimeout:  getEnvDuration("RVM_REDIS_LARGE_DATA_TIMEOUT", 60*time.Second),
+			Cluster:           getEnvBool("RVM_REDIS_CLUSTER", false),
+			ReadFromReplicas:  getEnvBool("RVM_REDIS_READ_FROM_REPLICAS", false),
+			Nodes:             getEnvStringSlice("RVM_REDIS_NODES", []string{}),
+			SentinelMaster:    getEnvString("RVM_REDIS_SENTINEL_MASTER", ""),
+			Sentinels:         getEnvStringSlice("RVM_REDIS_SENTINELS", []string{}),
+			Replica:           getEnvString("RVM_REDIS_REPLICA", ""),
+			MaxReplicaLag:     getEnvInt64("RVM_REDIS_MAX_REPLICA_LAG", 1048576),
+			URL:               getEnvString("RVM_REDIS_URL", ""),
+			RDBFile:           getEnvString("RVM_REDIS_RDB_FILE", ""),
+			TLS: TLSConfig{
+				Enabled:            getEnvBool("RVM_REDIS_TLS_ENABLED", false),
+				CAFile:             getEnvString("RVM_REDIS_TLS_CA_FILE", ""),
+				CertFile:           getEnvString("RVM_REDIS_TLS_CERT_FILE", ""),
+				KeyFile:            getEnvString("RVM_REDIS_TLS_KEY_FILE", ""),
+				ServerName:         getEnvString("RVM_REDIS_TLS_SERVER_NAME", ""),
+				InsecureSkipVerify: getEnvBool("RVM_REDIS_TLS_INSECURE_SKIP_VERIFY", false),
+			},
 		},
 		Valkey: DatabaseConfig{
 			Host:              getEnvString("RVM_VALKEY_HOST", "localhost"),
 			Port:              getEnvInt("RVM_VALKEY_PORT", 6380),
+			Username:          getEnvString("RVM_VALKEY_USERNAME", ""),
 			Password:          getEnvString("RVM_VALKEY_PASSWORD", ""),
+			PasswordFile:      getEnvString("RVM_VALKEY_PASSWORD_FILE", ""),
+			PasswordEnv:       getEnvString("RVM_VALKEY_PASSWORD_ENV", ""),
+			PasswordCommand:   getEnvString("RVM_VALKEY_PASSWORD_COMMAND", ""),
 			Database:          getEnvInt("RVM_VALKEY_DATABASE", 0),
 			ConnectionTimeout: getEnvDuration("RVM_VALKEY_CONNECTION_TIMEOUT", 30*time.Second),
 			OperationTimeout:  getEnvDuration("RVM_VALKEY_OPERATION_TIMEOUT", 10*time.Second),
 			LargeDataTimeout:  getEnvDuration("RVM_VALKEY_LARGE_DATA_TIMEOUT", 60*time.Second),
+			Cluster:           getEnvBool("RVM_VALKEY_CLUSTER", false),
+			Nodes:             getEnvStringSlice("RVM_VALKEY_NODES", []string{}),
+			SentinelMaster:    getEnvString("RVM_VALKEY_SENTINEL_MASTER", ""),
+			Sentinels:         getEnvStringSlice("RVM_VALKEY_SENTINELS", []string{}),
+			URL:               getEnvString("RVM_VALKEY_URL", ""),
+			CommandFile:       getEnvString("RVM_VALKEY_COMMAND_FILE", ""),
+			TLS: TLSConfig{
+				Enabled:            getEnvBool("RVM_VALKEY_TLS_ENABLED", false),
+				CAFile:             getEnvString("RVM_VALKEY_TLS_CA_FILE", ""),
+				CertFile:           getEnvString("RVM_VALKEY_TLS_CERT_FILE", ""),
+				KeyFile:            getEnvString("RVM_VALKEY_TLS_KEY_FILE", ""),
+				ServerName:         getEnvString("RVM_VALKEY_TLS_SERVER_NAME", ""),
+				InsecureSkipVerify: getEnvBool("RVM_VALKEY_TLS_INSECURE_SKIP_VERIFY", false),
+			},
 		},
 		Migration: MigrationConfig{
 			BatchSize:          getEnvInt("RVM_MIGRATION_BATCH_SIZE", 1000),
 			RetryAttempts:      getEnvInt("RVM_MIGRATION_RETRY_ATTEMPTS", 3),
 			LogLevel:           getEnvString("RVM_MIGRATION_LOG_LEVEL", "info"),
 			CollectionPatterns: getEnvStringSlice("RVM_MIGRATION_COLLECTION_PATTERNS", []string{}),
+			KeyRules:           getEnvStringSlice("RVM_MIGRATION_KEY_RULES", []string{}),
+			AllDatabases:       getEnvBool("RVM_MIGRATION_ALL_DATABASES", false),
+			DatabaseMap:        getEnvString("RVM_MIGRATION_DATABASE_MAP", ""),
 			TimeoutConfig: TimeoutConfig{
 				ConnectionTimeout:   getEnvDuration("RVM_TIMEOUT_CONNECTION", 30*time.Second),
 				DefaultOperation:    getEnvDuration("RVM_TIMEOUT_DEFAULT_OPERATION", 10*time.Second),
@@ -387,6 +856,10 @@ func LoadConfigFromEnv() (*Config, error) {
 		},
 	}
 
+	if err := applyURLs(config); err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	}
+
 	if err := ValidateConfig(config); err != nil {
 		return nil, fmt.Errorf("configuration validation failed: %w", err)
 	}
@@ -432,6 +905,16 @@ func getEnvFloat64(key string, defaultValue float64) float64 {
 	return defaultValue
 }
 
+// getEnvBool gets a boolean environment variable with a default value
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
 // getEnvDuration gets a duration environment variable with a default value
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
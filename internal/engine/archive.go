@@ -0,0 +1,381 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/archive"
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+	"github.com/kinyelo/redis-valkey-migration/internal/processor"
+	"github.com/kinyelo/redis-valkey-migration/internal/scanner"
+	"github.com/kinyelo/redis-valkey-migration/internal/transform"
+	"github.com/kinyelo/redis-valkey-migration/internal/verifier"
+	"github.com/kinyelo/redis-valkey-migration/pkg/logger"
+)
+
+// ArchiveConfig holds the settings of an export to or an import from an archive
+type ArchiveConfig struct {
+	// Path is the archive file
+	Path string
+
+	// ChunkKeys is how many keys an export writes per archive chunk
+	ChunkKeys int
+
+	MaxConcurrency     int
+	CollectionPatterns []string
+
+	// ResumeFile records the chunks an import has completed, so that an interrupted
+	// import continues after them
+	ResumeFile string
+
+	// Verify compares every imported key with the archive
+	Verify bool
+
+	// CopyStreamPending copies the pending entries lists of stream consumer groups on
+	// import. Exports always keep them.
+	CopyStreamPending bool
+
+	// KeyRules rename keys on import, see transform.ParseRules. Exports always keep the
+	// source names.
+	KeyRules []string
+}
+
+// DefaultArchiveConfig returns the default archive settings
+func DefaultArchiveConfig() *ArchiveConfig {
+	return &ArchiveConfig{
+		ChunkKeys:      1000,
+		MaxConcurrency: 10,
+		ResumeFile:     "migration_import.json",
+		Verify:         true,
+	}
+}
+
+// ArchiveStats summarizes an export or import
+type ArchiveStats struct {
+	Chunks int
+	Keys   int64
+
+	// SkippedKeys are keys exported before an interrupted export was resumed, or keys
+	// that expired or were deleted before they could be exported or imported
+	SkippedKeys int64
+	FailedKeys  int64
+	Duration    time.Duration
+}
+
+// importState is the progress of an import, saved after every chunk
+type importState struct {
+	ArchiveID    string    `json:"archive_id"`
+	NextChunk    int       `json:"next_chunk"`
+	ImportedKeys int64     `json:"imported_keys"`
+	SkippedKeys  int64     `json:"skipped_keys"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// ExportArchive exports the keys of source matching the collection patterns to the
+// archive at config.Path, with the type handlers of the data processor. An existing
+// archive that was interrupted is resumed: keys in its chunks are not exported again.
+// The archive is only completed with its manifest once every key has been exported, so
+// an export that failed for some keys can be run again to retry them.
+func ExportArchive(ctx context.Context, source client.DatabaseClient, sourceConfig *client.ClientConfig, log logger.Logger, config *ArchiveConfig) (*ArchiveStats, error) {
+	startTime := time.Now()
+	source = NewRecoverableClient(source, sourceConfig, NewConnectionRecovery(DefaultRetryConfig(), log), log, "Redis")
+	if err := source.Connect(); err != nil {
+		return nil, WrapError(err, "source database connection")
+	}
+	defer source.Disconnect()
+
+	header := archive.Header{
+		Source:   describeSource(sourceConfig),
+		Database: sourceConfig.Database,
+		Patterns: config.CollectionPatterns,
+	}
+	writer, err := archive.OpenWriter(config.Path, header)
+	if errors.Is(err, archive.ErrComplete) {
+		return nil, fmt.Errorf("archive %s is already complete, remove it to export again", config.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer writer.Close()
+
+	// A resumed archive keeps the header it was created with
+	if existing := writer.Header(); existing.Database != header.Database || !slices.Equal(existing.Patterns, header.Patterns) {
+		return nil, fmt.Errorf("archive %s was started from database %d with patterns %v, remove it to start a different export",
+			config.Path, existing.Database, existing.Patterns)
+	}
+	if writer.Chunks() > 0 {
+		log.Infof("Resuming export to %s: %d keys already exported in %d chunks", config.Path, writer.Keys(), writer.Chunks())
+	}
+
+	keys, err := scanner.NewKeyScanner(log).ScanKeysByPatterns(source, config.CollectionPatterns)
+	if err != nil {
+		return nil, WrapError(err, "key discovery")
+	}
+
+	chunkKeys := config.ChunkKeys
+	if chunkKeys < 1 {
+		chunkKeys = DefaultArchiveConfig().ChunkKeys
+	}
+
+	stats := &ArchiveStats{}
+	pending := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if writer.Exported(key) {
+			stats.SkippedKeys++
+			continue
+		}
+		pending = append(pending, key)
+	}
+	// Exporting in key order makes archives of the same data identical, chunk by chunk
+	slices.Sort(pending)
+	log.Infof("Exporting %d keys to %s", len(pending), config.Path)
+
+	dataProcessor := processor.NewDataProcessorWithOptions(log, processor.Options{
+		TimeoutConfig:     sourceConfig.TimeoutConfig,
+		CopyStreamPending: true,
+	})
+	sink := archive.NewSink(sourceConfig.Database)
+
+	for start := 0; start < len(pending) && ctx.Err() == nil; start += chunkKeys {
+		end := min(start+chunkKeys, len(pending))
+		chunk := pending[start:end]
+
+		stats.FailedKeys += runArchiveWorkers(ctx, chunk, config.MaxConcurrency, log, func(key string) error {
+			keyType, err := source.GetKeyType(key)
+			if err != nil {
+				return fmt.Errorf("failed to get key type: %w", err)
+			}
+			return dataProcessor.ProcessKey(key, keyType, source, sink)
+		})
+
+		records := sink.Records()
+		if err := writer.WriteChunk(records); err != nil {
+			return nil, err
+		}
+		stats.SkippedKeys += int64(len(chunk)) - int64(len(records))
+		log.Infof("Exported %d of %d keys", end, len(pending))
+	}
+
+	stats.Chunks = writer.Chunks()
+	stats.Keys = writer.Keys()
+	stats.SkippedKeys -= stats.FailedKeys
+	stats.Duration = time.Since(startTime)
+
+	if err := ctx.Err(); err != nil {
+		return stats, fmt.Errorf("export interrupted, run it again to resume: %w", err)
+	}
+	if stats.FailedKeys > 0 {
+		return stats, fmt.Errorf("%d keys failed to export, run the export again to retry them", stats.FailedKeys)
+	}
+
+	manifest, err := writer.Finish()
+	if err != nil {
+		return stats, err
+	}
+	log.Infof("Export completed: %d keys in %d chunks written to %s (archive %s)", manifest.Keys, len(manifest.Chunks), config.Path, manifest.ID)
+	return stats, nil
+}
+
+// ImportArchive imports the keys of the archive at config.Path into target, with the
+// type handlers of the data processor. Keys keep the absolute expiry they had on the
+// source, so keys that expired since the export are skipped. Progress is saved to
+// config.ResumeFile after every chunk, and an interrupted import of the same archive
+// continues with the first chunk it did not complete.
+func ImportArchive(ctx context.Context, target client.DatabaseClient, targetConfig *client.ClientConfig, log logger.Logger, config *ArchiveConfig) (*ArchiveStats, error) {
+	startTime := time.Now()
+	reader, err := archive.OpenReader(config.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	manifest := reader.Manifest()
+
+	state := &importState{ArchiveID: manifest.ID}
+	if saved, err := loadImportState(config.ResumeFile); err == nil {
+		if saved.ArchiveID == manifest.ID {
+			state = saved
+			log.Infof("Resuming import of %s: %d of %d chunks already imported", config.Path, state.NextChunk, len(manifest.Chunks))
+		} else {
+			log.Warnf("Resume file %s belongs to another archive, starting the import from the beginning", config.ResumeFile)
+		}
+	} else if !os.IsNotExist(err) {
+		log.Warnf("Could not load import state: %v. Starting the import from the beginning.", err)
+	}
+
+	keyRules, err := transform.ParseRules(config.KeyRules)
+	if err != nil {
+		return nil, err
+	}
+
+	target = NewRecoverableClient(target, targetConfig, NewConnectionRecovery(DefaultRetryConfig(), log), log, "Valkey")
+	if err := target.Connect(); err != nil {
+		return nil, WrapError(err, "target database connection")
+	}
+	defer target.Disconnect()
+
+	log.Infof("Importing %d keys exported from %s database %d on %s into database %d",
+		manifest.Keys, manifest.Source, manifest.Database, manifest.CreatedAt.Format(time.RFC3339), targetConfig.Database)
+
+	dataProcessor := processor.NewDataProcessorWithOptions(log, processor.Options{
+		TimeoutConfig:     targetConfig.TimeoutConfig,
+		CopyStreamPending: config.CopyStreamPending,
+		RenameKey:         renameKey(keyRules),
+	})
+	dataVerifier := verifier.NewDataVerifierWithOptions(log, verifier.Options{RenameKey: renameKey(keyRules)})
+
+	stats := &ArchiveStats{Keys: state.ImportedKeys, SkippedKeys: state.SkippedKeys}
+	for i := state.NextChunk; i < len(manifest.Chunks) && ctx.Err() == nil; i++ {
+		records, err := reader.ReadChunk(i)
+		if err != nil {
+			return stats, err
+		}
+
+		source := archive.NewChunkSource(records)
+		keys := make([]string, len(records))
+		for j, record := range records {
+			keys[j] = record.Key
+		}
+
+		var skipped atomic.Int64
+		failed := runArchiveWorkers(ctx, keys, config.MaxConcurrency, log, func(key string) error {
+			keyType, _ := source.GetKeyType(key)
+			if keyType == "none" {
+				log.Debugf("Key %s expired since it was exported, skipping it", key)
+				skipped.Add(1)
+				return nil
+			}
+
+			if err := dataProcessor.ProcessKey(key, keyType, source, target); err != nil {
+				return err
+			}
+			if config.Verify {
+				if result := dataVerifier.VerifyKey(key, source, target); !result.Success {
+					return verificationError(result)
+				}
+			}
+			return nil
+		})
+
+		stats.FailedKeys += failed
+		stats.SkippedKeys += skipped.Load()
+		stats.Keys += int64(len(keys)) - failed - skipped.Load()
+		if failed > 0 {
+			// The chunk is imported again when the import is run again
+			break
+		}
+		if ctx.Err() != nil {
+			// The workers may have stopped before the end of the chunk
+			break
+		}
+
+		stats.Chunks++
+		state.NextChunk = i + 1
+		state.ImportedKeys = stats.Keys
+		state.SkippedKeys = stats.SkippedKeys
+		if err := saveImportState(config.ResumeFile, state); err != nil {
+			return stats, err
+		}
+		log.Infof("Imported chunk %d of %d", i+1, len(manifest.Chunks))
+	}
+	stats.Duration = time.Since(startTime)
+
+	if stats.FailedKeys > 0 {
+		return stats, fmt.Errorf("%d keys failed to import, run the import again to retry their chunk", stats.FailedKeys)
+	}
+	if err := ctx.Err(); err != nil {
+		return stats, fmt.Errorf("import interrupted, run it again to resume: %w", err)
+	}
+
+	if err := os.Remove(config.ResumeFile); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Failed to remove import state file: %v", err)
+	}
+	log.Infof("Import completed: %d keys imported, %d skipped", stats.Keys, stats.SkippedKeys)
+	return stats, nil
+}
+
+// runArchiveWorkers calls fn for every key using up to workers goroutines, logs the keys
+// it fails for and returns how many failed. Keys are not started once ctx is done.
+func runArchiveWorkers(ctx context.Context, keys []string, workers int, log logger.Logger, fn func(key string) error) int64 {
+	if workers < 1 {
+		workers = 1
+	}
+
+	var failed atomic.Int64
+	keyChan := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range keyChan {
+				if err := fn(key); err != nil {
+					log.Errorf("Key %s failed: %v", key, err)
+					failed.Add(1)
+				}
+			}
+		}()
+	}
+
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			break
+		}
+		keyChan <- key
+	}
+	close(keyChan)
+	wg.Wait()
+	return failed.Load()
+}
+
+// describeSource names the database a client config points at
+func describeSource(config *client.ClientConfig) string {
+	if config.RDBFile != "" {
+		return config.RDBFile
+	}
+	return fmt.Sprintf("%s:%d", config.Host, config.Port)
+}
+
+// loadImportState loads the progress of an import
+func loadImportState(filename string) (*importState, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var state importState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// saveImportState saves the progress of an import
+func saveImportState(filename string, state *importState) error {
+	state.UpdatedAt = time.Now()
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal import state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return fmt.Errorf("failed to create import state directory: %w", err)
+	}
+
+	// Write to temporary file first, then rename for atomicity
+	tempFile := filename + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write import state: %w", err)
+	}
+	if err := os.Rename(tempFile, filename); err != nil {
+		return fmt.Errorf("failed to rename import state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/archive"
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+	"github.com/kinyelo/redis-valkey-migration/pkg/logger"
+)
+
+func newArchiveTestConfig(t *testing.T) (*ArchiveConfig, logger.Logger) {
+	t.Helper()
+	dir := t.TempDir()
+	log, err := logger.NewLogger(logger.Config{Level: "info", OutputFile: filepath.Join(dir, "archive.log"), Format: "text"})
+	require.NoError(t, err)
+
+	config := DefaultArchiveConfig()
+	config.Path = filepath.Join(dir, "export.rvma")
+	config.ResumeFile = filepath.Join(dir, "import.json")
+	config.ChunkKeys = 2
+	config.MaxConcurrency = 2
+	return config, log
+}
+
+func TestExportImportArchive(t *testing.T) {
+	config, log := newArchiveTestConfig(t)
+
+	source := newStringTestClient(map[string]string{"a": "1", "b": "2", "c": "3"})
+	source.keys["h"] = map[string]string{"f": "v"}
+	source.keyTypes["h"] = "hash"
+	sourceConfig := &client.ClientConfig{Host: "redis", Port: 6379, Database: 4}
+
+	stats, err := ExportArchive(context.Background(), source, sourceConfig, log, config)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), stats.Keys)
+	assert.Equal(t, 2, stats.Chunks)
+	assert.Zero(t, stats.SkippedKeys)
+
+	target := newStringTestClient(nil)
+	stats, err = ImportArchive(context.Background(), target, &client.ClientConfig{Host: "valkey", Port: 6380}, log, config)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), stats.Keys)
+	assert.Equal(t, map[string]interface{}{"a": "1", "b": "2", "c": "3", "h": map[string]string{"f": "v"}}, target.keys)
+
+	_, err = os.Stat(config.ResumeFile)
+	assert.True(t, os.IsNotExist(err), "the import state is removed once complete")
+
+	// A complete archive is not exported over
+	_, err = ExportArchive(context.Background(), source, sourceConfig, log, config)
+	assert.ErrorContains(t, err, "already complete")
+}
+
+func TestExportArchive_Resume(t *testing.T) {
+	config, log := newArchiveTestConfig(t)
+	source := newStringTestClient(map[string]string{"a": "1", "b": "2", "c": "3"})
+	source.failOnKey = "c"
+	sourceConfig := &client.ClientConfig{Host: "redis", Port: 6379}
+
+	stats, err := ExportArchive(context.Background(), source, sourceConfig, log, config)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 keys failed to export")
+	assert.Equal(t, int64(1), stats.FailedKeys)
+	assert.Equal(t, int64(2), stats.Keys)
+
+	_, err = archive.OpenReader(config.Path)
+	assert.ErrorContains(t, err, "incomplete")
+
+	// A different export is not appended to it
+	config.CollectionPatterns = []string{"a*"}
+	_, err = ExportArchive(context.Background(), source, sourceConfig, log, config)
+	assert.ErrorContains(t, err, "remove it to start a different export")
+	config.CollectionPatterns = nil
+
+	// Running it again only exports the key that failed
+	source.failOnKey = ""
+	stats, err = ExportArchive(context.Background(), source, sourceConfig, log, config)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.Keys)
+	assert.Equal(t, int64(2), stats.SkippedKeys)
+
+	r, err := archive.OpenReader(config.Path)
+	require.NoError(t, err)
+	defer r.Close()
+	assert.Equal(t, int64(3), r.Manifest().Keys)
+}
+
+func TestImportArchive_Resume(t *testing.T) {
+	config, log := newArchiveTestConfig(t)
+	source := newStringTestClient(map[string]string{"a": "1", "b": "2", "c": "3", "d": "4"})
+	_, err := ExportArchive(context.Background(), source, &client.ClientConfig{Host: "redis", Port: 6379}, log, config)
+	require.NoError(t, err)
+
+	// The second chunk holds c and d, and c fails to import
+	target := newStringTestClient(nil)
+	target.failOnKey = "c"
+	stats, err := ImportArchive(context.Background(), target, &client.ClientConfig{}, log, config)
+	require.Error(t, err)
+	assert.Equal(t, int64(1), stats.FailedKeys)
+	assert.Equal(t, 1, stats.Chunks)
+
+	state, err := loadImportState(config.ResumeFile)
+	require.NoError(t, err)
+	assert.Equal(t, 1, state.NextChunk)
+	assert.Equal(t, int64(2), state.ImportedKeys)
+
+	// Running it again starts with the chunk that failed
+	target.failOnKey = ""
+	delete(target.keys, "a")
+	stats, err = ImportArchive(context.Background(), target, &client.ClientConfig{}, log, config)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), stats.Keys)
+	assert.Equal(t, 1, stats.Chunks)
+	assert.NotContains(t, target.keys, "a", "completed chunks are not imported again")
+	assert.Equal(t, "3", target.keys["c"])
+}
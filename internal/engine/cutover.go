@@ -0,0 +1,369 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+)
+
+// cutoverRecentKeys is how many of the most recently changed keys are verified once the
+// last changes have been applied
+const cutoverRecentKeys = 10000
+
+// cutoverMismatchLimit is how many mismatched keys a cutover result lists
+const cutoverMismatchLimit = 100
+
+// cutoverPollInterval is how often the drain checks whether the target has caught up
+const cutoverPollInterval = 10 * time.Millisecond
+
+// cutoverResultPollInterval is how often RequestCutover checks for the result
+const cutoverResultPollInterval = 100 * time.Millisecond
+
+// CutoverResult describes a cutover. It is written as JSON to the cutover result file,
+// so that a deploy pipeline can switch applications to the target once Success is set.
+type CutoverResult struct {
+	Success    bool   `json:"success"`
+	Error      string `json:"error,omitempty"`
+	SyncMethod string `json:"sync_method"`
+
+	// WritesPaused is false if the source could not pause writes, in which case the
+	// target may miss writes made after the drain
+	WritesPaused bool      `json:"writes_paused"`
+	PausedAt     time.Time `json:"paused_at"`
+	// PausedUntil is when the source accepts writes again by itself; applications have
+	// to be switched to the target before then. It is omitted if writes were not paused or
+	// were unpaused because the cutover failed.
+	PausedUntil *time.Time `json:"paused_until,omitempty"`
+	DrainedAt   *time.Time `json:"drained_at,omitempty"`
+	CompletedAt time.Time  `json:"completed_at"`
+
+	// WriteFreezeMS is how long writes are paused on the source, in milliseconds: until
+	// PausedUntil when they stay paused, as after a successful cutover, or until they
+	// were unpaused after a failure. It is zero if writes were not paused.
+	WriteFreezeMS int64 `json:"write_freeze_ms"`
+
+	DrainedChanges int64    `json:"drained_changes"`
+	VerifiedKeys   int      `json:"verified_keys"`
+	MismatchCount  int      `json:"mismatch_count"`
+	MismatchedKeys []string `json:"mismatched_keys,omitempty"`
+}
+
+// RequestCutover asks a running sync to cut over by creating cutoverFile, and waits up
+// to timeout for the sync to write its result to resultFile. A result left by an earlier
+// cutover is removed first.
+func RequestCutover(cutoverFile, resultFile string, timeout time.Duration) (*CutoverResult, error) {
+	if err := os.Remove(resultFile); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove old cutover result: %w", err)
+	}
+	if err := os.WriteFile(cutoverFile, nil, 0644); err != nil {
+		return nil, fmt.Errorf("failed to create cutover file: %w", err)
+	}
+
+	ticker := time.NewTicker(cutoverResultPollInterval)
+	defer ticker.Stop()
+	expired := time.After(timeout)
+	for {
+		data, err := os.ReadFile(resultFile)
+		if err == nil {
+			var result CutoverResult
+			if err := json.Unmarshal(data, &result); err != nil {
+				return nil, fmt.Errorf("failed to parse cutover result: %w", err)
+			}
+			return &result, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read cutover result: %w", err)
+		}
+
+		select {
+		case <-expired:
+			return nil, fmt.Errorf("no cutover result in %s after %v, check that a sync is running with the same cutover files", resultFile, timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// cutoverDrain applies the changes made on the source before writes were paused and
+// returns how many it applied. It fails if they cannot be applied before deadline.
+type cutoverDrain func(deadline time.Time) (int64, error)
+
+// cutover stops a sync: it pauses writes on the source, drains the remaining changes
+// with drain, verifies the recently changed keys and writes the result to the cutover
+// result file. On success writes stay paused until the pause times out, so that no
+// write reaches the source after the target was declared ready; on failure they are
+// unpaused right away. Either way the cutover file is removed.
+func (me *MigrationEngine) cutover(drain cutoverDrain, recent *recentKeys) error {
+	defer func() {
+		if err := me.removeCutoverRequest(); err != nil {
+			me.logger.Warn(err.Error())
+		}
+	}()
+
+	timeout := me.config.CutoverPauseTimeout
+	if timeout <= 0 {
+		timeout = DefaultEngineConfig().CutoverPauseTimeout
+	}
+
+	result := &CutoverResult{SyncMethod: me.config.SyncMethod}
+	if result.SyncMethod == "" {
+		result.SyncMethod = SyncNotifications
+	}
+
+	result.PausedAt = time.Now()
+	deadline := result.PausedAt.Add(timeout)
+	if err := me.sourceClient.PauseWrites(timeout); err != nil {
+		if !errors.Is(err, client.ErrUnsupported) {
+			return me.failCutover(result, fmt.Errorf("failed to pause writes on the source: %w", err))
+		}
+		me.logger.Warn("The source cannot pause writes, writes made during the cutover may be missing on the target")
+	} else {
+		result.WritesPaused = true
+		result.PausedUntil = &deadline
+		me.logger.Infof("Writes paused on the source until %s", deadline.Format(time.RFC3339Nano))
+	}
+
+	drained, err := drain(deadline)
+	result.DrainedChanges = drained
+	if err != nil {
+		return me.failCutover(result, fmt.Errorf("failed to apply the remaining changes: %w", err))
+	}
+	drainedAt := time.Now()
+	result.DrainedAt = &drainedAt
+	me.logger.Infof("Applied the last %d changes in %v", drained, drainedAt.Sub(result.PausedAt))
+
+	// Compare the target against the primary, not a replica that may still be behind
+	me.sourceClient.UseReplica(false)
+
+	keys := recent.list()
+	mismatches := me.verifyRecentKeys(keys)
+	result.VerifiedKeys = len(keys)
+	result.MismatchCount = len(mismatches)
+	if len(mismatches) > cutoverMismatchLimit {
+		mismatches = mismatches[:cutoverMismatchLimit]
+	}
+	result.MismatchedKeys = mismatches
+	if result.MismatchCount > 0 {
+		return me.failCutover(result, fmt.Errorf("%d of %d recently changed keys differ between source and target", result.MismatchCount, len(keys)))
+	}
+	if err := me.ctx.Err(); err != nil {
+		return me.failCutover(result, err)
+	}
+
+	result.CompletedAt = time.Now()
+	if result.WritesPaused && result.CompletedAt.After(deadline) {
+		return me.failCutover(result, fmt.Errorf("writes were unpaused by the source before the cutover completed, increase the cutover pause timeout"))
+	}
+
+	result.Success = true
+	result.WriteFreezeMS = writeFreeze(result)
+	if err := me.writeCutoverResult(result); err != nil {
+		return me.failCutover(result, err)
+	}
+
+	me.logger.Infof("Cutover completed in %v: %d changes drained, %d recently changed keys verified, writes frozen for %dms",
+		result.CompletedAt.Sub(result.PausedAt), result.DrainedChanges, result.VerifiedKeys, result.WriteFreezeMS)
+	return nil
+}
+
+// failCutover unpauses writes on the source and records err in the cutover result
+func (me *MigrationEngine) failCutover(result *CutoverResult, err error) error {
+	if result.WritesPaused {
+		if unpauseErr := me.sourceClient.UnpauseWrites(); unpauseErr != nil {
+			me.logger.Errorf("Failed to unpause writes on the source, they stay paused until %s: %v",
+				result.PausedUntil.Format(time.RFC3339Nano), unpauseErr)
+		} else {
+			result.PausedUntil = nil
+		}
+	}
+
+	result.Success = false
+	result.Error = err.Error()
+	result.CompletedAt = time.Now()
+	result.WriteFreezeMS = writeFreeze(result)
+
+	if writeErr := me.writeCutoverResult(result); writeErr != nil {
+		me.logger.Errorf("%v", writeErr)
+	}
+	return err
+}
+
+// writeCutoverResult writes the result to the cutover result file, if one is configured
+func (me *MigrationEngine) writeCutoverResult(result *CutoverResult) error {
+	if me.config.CutoverResultFile == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cutover result: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(me.config.CutoverResultFile), 0755); err != nil {
+		return fmt.Errorf("failed to create cutover result directory: %w", err)
+	}
+
+	// Write to temporary file first, so that the result is never read half written
+	tempFile := me.config.CutoverResultFile + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cutover result: %w", err)
+	}
+	if err := os.Rename(tempFile, me.config.CutoverResultFile); err != nil {
+		return fmt.Errorf("failed to rename cutover result file: %w", err)
+	}
+	return nil
+}
+
+// removeCutoverResult removes the result of an earlier cutover, so that it is not
+// mistaken for the result of this sync
+func (me *MigrationEngine) removeCutoverResult() error {
+	if me.config.CutoverResultFile == "" {
+		return nil
+	}
+	if err := os.Remove(me.config.CutoverResultFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove old cutover result: %w", err)
+	}
+	return nil
+}
+
+// removeCutoverRequest removes the cutover file, so that it does not cut the next sync
+// over as soon as it starts
+func (me *MigrationEngine) removeCutoverRequest() error {
+	if me.config.CutoverFile == "" {
+		return nil
+	}
+	if err := os.Remove(me.config.CutoverFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove cutover file: %w", err)
+	}
+	return nil
+}
+
+// verifyRecentKeys compares keys between the source and the target using up to
+// MaxConcurrency workers, and returns the keys that differ in sorted order
+func (me *MigrationEngine) verifyRecentKeys(keys []string) []string {
+	workers := me.config.MaxConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	var mu sync.Mutex
+	var mismatches []string
+
+	keyChan := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range keyChan {
+				if err := me.verifyChangedKey(key); err != nil {
+					me.logger.Errorf("Cutover verification of key %s failed: %v", key, err)
+					mu.Lock()
+					mismatches = append(mismatches, key)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, key := range keys {
+		keyChan <- key
+	}
+	close(keyChan)
+	wg.Wait()
+
+	sort.Strings(mismatches)
+	return mismatches
+}
+
+// verifyChangedKey compares a key between the source and the target. A key deleted on
+// the source must not exist on the target.
+func (me *MigrationEngine) verifyChangedKey(key string) error {
+	keyType, err := me.sourceClient.GetKeyType(key)
+	if err != nil {
+		return fmt.Errorf("failed to get key type from source: %w", err)
+	}
+
+	if keyType == "none" {
+		exists, err := me.targetClient.Exists(me.targetKey(key))
+		if err != nil {
+			return fmt.Errorf("failed to check key existence in target: %w", err)
+		}
+		if exists {
+			return errors.New("key was deleted on the source but exists on the target")
+		}
+		return nil
+	}
+
+	result := me.verifier.VerifyKey(key, me.sourceClient, me.targetClient)
+	if !result.Success {
+		return verificationError(result)
+	}
+	return nil
+}
+
+// recentKeys remembers the most recently changed keys, up to a limit. A key that changes
+// again keeps its place, so a few hot keys cannot push the others out.
+type recentKeys struct {
+	mu    sync.Mutex
+	limit int
+	ring  []string
+	next  int
+	keys  map[string]struct{}
+}
+
+// newRecentKeys creates a set that remembers up to limit keys
+func newRecentKeys(limit int) *recentKeys {
+	return &recentKeys{
+		limit: limit,
+		keys:  make(map[string]struct{}),
+	}
+}
+
+// add remembers a key, forgetting the oldest one when the set is full
+func (r *recentKeys) add(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.keys[key]; exists {
+		return
+	}
+	if len(r.ring) < r.limit {
+		r.ring = append(r.ring, key)
+	} else {
+		delete(r.keys, r.ring[r.next])
+		r.ring[r.next] = key
+		r.next = (r.next + 1) % r.limit
+	}
+	r.keys[key] = struct{}{}
+}
+
+// list returns the remembered keys
+func (r *recentKeys) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]string, len(r.ring))
+	copy(keys, r.ring)
+	return keys
+}
+
+// writeFreeze returns how long writes are paused on the source by a cutover that has
+// completed, in milliseconds: until the pause times out if they stay paused, or until
+// the cutover completed if they were unpaused
+func writeFreeze(result *CutoverResult) int64 {
+	switch {
+	case !result.WritesPaused:
+		return 0
+	case result.PausedUntil != nil:
+		return result.PausedUntil.Sub(result.PausedAt).Milliseconds()
+	default:
+		return result.CompletedAt.Sub(result.PausedAt).Milliseconds()
+	}
+}
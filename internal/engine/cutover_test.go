@@ -0,0 +1,254 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+	"github.com/kinyelo/redis-valkey-migration/pkg/logger"
+)
+
+// PausingTestClient adds write pauses to NotifyingTestClient
+type PausingTestClient struct {
+	*NotifyingTestClient
+	pauseMu  sync.Mutex
+	paused   []time.Duration
+	unpaused int
+	// unpauseErr fails UnpauseWrites
+	unpauseErr error
+	offset     int64
+}
+
+func (m *PausingTestClient) PauseWrites(timeout time.Duration) error {
+	m.pauseMu.Lock()
+	defer m.pauseMu.Unlock()
+	m.paused = append(m.paused, timeout)
+	return nil
+}
+
+func (m *PausingTestClient) UnpauseWrites() error {
+	m.pauseMu.Lock()
+	defer m.pauseMu.Unlock()
+	m.unpaused++
+	return m.unpauseErr
+}
+
+func (m *PausingTestClient) ReplicationOffset() (int64, error) {
+	return m.offset, nil
+}
+
+// newStringTestClient creates a connected client holding string keys
+func newStringTestClient(values map[string]string) *IntegrationTestClient {
+	c := &IntegrationTestClient{
+		keys:      make(map[string]interface{}),
+		keyTypes:  make(map[string]string),
+		connected: true,
+	}
+	for key, value := range values {
+		c.keys[key] = value
+		c.keyTypes[key] = "string"
+	}
+	return c
+}
+
+func newCutoverTestEngine(t *testing.T, source, target client.DatabaseClient) (*MigrationEngine, *EngineConfig) {
+	t.Helper()
+	dir := t.TempDir()
+	log, err := logger.NewLogger(logger.Config{Level: "info", OutputFile: filepath.Join(dir, "cutover.log"), Format: "text"})
+	require.NoError(t, err)
+
+	engineConfig := DefaultEngineConfig()
+	engineConfig.ResumeFile = filepath.Join(dir, "resume.json")
+	engineConfig.CutoverResultFile = filepath.Join(dir, "cutover.json")
+	engineConfig.DeadLetterFile = filepath.Join(dir, "failed.ndjson")
+	engineConfig.CutoverPauseTimeout = 5 * time.Second
+
+	engine, err := NewMigrationEngine(
+		source,
+		&client.ClientConfig{Host: "localhost", Port: 6379},
+		target,
+		&client.ClientConfig{Host: "localhost", Port: 6380},
+		log,
+		engineConfig,
+	)
+	require.NoError(t, err)
+	return engine, engineConfig
+}
+
+func readCutoverResult(t *testing.T, path string) CutoverResult {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var result CutoverResult
+	require.NoError(t, json.Unmarshal(data, &result))
+	return result
+}
+
+func TestMigrationEngine_Cutover(t *testing.T) {
+	newSource := func() *PausingTestClient {
+		return &PausingTestClient{NotifyingTestClient: &NotifyingTestClient{
+			IntegrationTestClient: newStringTestClient(map[string]string{"a": "1", "b": "2"}),
+		}}
+	}
+	recent := newRecentKeys(10)
+	recent.add("a")
+	recent.add("b")
+	recent.add("deleted")
+
+	t.Run("success", func(t *testing.T) {
+		source := newSource()
+		target := newStringTestClient(map[string]string{"a": "1", "b": "2"})
+		engine, engineConfig := newCutoverTestEngine(t, source, target)
+
+		var drainDeadline time.Time
+		err := engine.cutover(func(deadline time.Time) (int64, error) {
+			drainDeadline = deadline
+			time.Sleep(20 * time.Millisecond)
+			return 4, nil
+		}, recent)
+		require.NoError(t, err)
+
+		assert.Equal(t, []time.Duration{5 * time.Second}, source.paused)
+		assert.Zero(t, source.unpaused, "writes stay paused after a successful cutover")
+
+		result := readCutoverResult(t, engineConfig.CutoverResultFile)
+		assert.True(t, result.Success)
+		assert.Empty(t, result.Error)
+		assert.Equal(t, SyncNotifications, result.SyncMethod)
+		assert.True(t, result.WritesPaused)
+		require.NotNil(t, result.PausedUntil)
+		assert.True(t, result.PausedUntil.Equal(drainDeadline))
+		assert.Equal(t, result.PausedAt.Add(5*time.Second), *result.PausedUntil)
+		assert.GreaterOrEqual(t, result.CompletedAt.Sub(result.PausedAt), 20*time.Millisecond)
+		assert.Equal(t, int64(5000), result.WriteFreezeMS, "writes stay frozen until the pause times out")
+		assert.Equal(t, int64(4), result.DrainedChanges)
+		assert.Equal(t, 3, result.VerifiedKeys)
+		assert.Zero(t, result.MismatchCount)
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		source := newSource()
+		target := newStringTestClient(map[string]string{"a": "1", "b": "old", "deleted": "x"})
+		engine, engineConfig := newCutoverTestEngine(t, source, target)
+
+		err := engine.cutover(func(deadline time.Time) (int64, error) { return 0, nil }, recent)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "2 of 3 recently changed keys differ")
+		assert.Equal(t, 1, source.unpaused)
+
+		result := readCutoverResult(t, engineConfig.CutoverResultFile)
+		assert.False(t, result.Success)
+		assert.Equal(t, err.Error(), result.Error)
+		assert.Nil(t, result.PausedUntil, "writes were unpaused")
+		assert.Equal(t, result.CompletedAt.Sub(result.PausedAt).Milliseconds(), result.WriteFreezeMS)
+		assert.Equal(t, 2, result.MismatchCount)
+		assert.Equal(t, []string{"b", "deleted"}, result.MismatchedKeys)
+	})
+
+	t.Run("drain fails", func(t *testing.T) {
+		source := newSource()
+		engine, engineConfig := newCutoverTestEngine(t, source, newStringTestClient(nil))
+
+		err := engine.cutover(func(deadline time.Time) (int64, error) {
+			return 1, errors.New("3 changes still pending when the write pause ended")
+		}, recent)
+		require.Error(t, err)
+		assert.Equal(t, 1, source.unpaused)
+
+		result := readCutoverResult(t, engineConfig.CutoverResultFile)
+		assert.False(t, result.Success)
+		assert.Contains(t, result.Error, "failed to apply the remaining changes")
+		assert.Nil(t, result.DrainedAt)
+		assert.Equal(t, int64(1), result.DrainedChanges)
+		assert.Zero(t, result.VerifiedKeys)
+	})
+
+	t.Run("unpause fails", func(t *testing.T) {
+		source := newSource()
+		source.unpauseErr = errors.New("NOPERM this user has no permissions to run the 'client|unpause' command")
+		engine, engineConfig := newCutoverTestEngine(t, source, newStringTestClient(nil))
+
+		err := engine.cutover(func(deadline time.Time) (int64, error) {
+			return 0, errors.New("3 changes still pending when the write pause ended")
+		}, recent)
+		require.Error(t, err)
+
+		result := readCutoverResult(t, engineConfig.CutoverResultFile)
+		assert.False(t, result.Success)
+		require.NotNil(t, result.PausedUntil, "writes stay paused")
+		assert.Equal(t, int64(5000), result.WriteFreezeMS)
+	})
+
+	t.Run("source cannot pause", func(t *testing.T) {
+		source := newSource().NotifyingTestClient
+		target := newStringTestClient(map[string]string{"a": "1", "b": "2"})
+		engine, engineConfig := newCutoverTestEngine(t, source, target)
+
+		require.NoError(t, engine.cutover(func(deadline time.Time) (int64, error) { return 0, nil }, recent))
+
+		result := readCutoverResult(t, engineConfig.CutoverResultFile)
+		assert.True(t, result.Success)
+		assert.False(t, result.WritesPaused)
+		assert.Nil(t, result.PausedUntil)
+		assert.Zero(t, result.WriteFreezeMS)
+	})
+}
+
+func TestRequestCutover(t *testing.T) {
+	dir := t.TempDir()
+	cutoverFile := filepath.Join(dir, "cutover")
+	resultFile := filepath.Join(dir, "cutover.json")
+
+	t.Run("result", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(resultFile, []byte(`{"success": false}`), 0644))
+
+		// A sync writes its result once the cutover file appears
+		go func() {
+			for {
+				if _, err := os.Stat(cutoverFile); err == nil {
+					os.WriteFile(resultFile, []byte(`{"success": true, "write_freeze_ms": 120}`), 0644)
+					return
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+		}()
+
+		result, err := RequestCutover(cutoverFile, resultFile, 2*time.Second)
+		require.NoError(t, err)
+		assert.True(t, result.Success, "the old result was removed")
+		assert.Equal(t, int64(120), result.WriteFreezeMS)
+	})
+
+	t.Run("no sync running", func(t *testing.T) {
+		os.Remove(resultFile)
+		_, err := RequestCutover(cutoverFile, resultFile, 50*time.Millisecond)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no cutover result")
+	})
+}
+
+func TestRecentKeys(t *testing.T) {
+	recent := newRecentKeys(3)
+	recent.add("a")
+	recent.add("b")
+	recent.add("a")
+	recent.add("c")
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, recent.list())
+
+	// The oldest key is forgotten first
+	recent.add("d")
+	recent.add("e")
+	assert.ElementsMatch(t, []string{"c", "d", "e"}, recent.list())
+
+	// A forgotten key can be remembered again
+	recent.add("a")
+	assert.ElementsMatch(t, []string{"d", "e", "a"}, recent.list())
+}
@@ -0,0 +1,181 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/monitor"
+	"github.com/kinyelo/redis-valkey-migration/pkg/logger"
+)
+
+// DatabaseMapping pairs a source database with the target database its keys are
+// migrated to
+type DatabaseMapping struct {
+	Source int `json:"source"`
+	Target int `json:"target"`
+
+	// Keys is the number of keys the source database held when it was discovered
+	Keys int64 `json:"keys"`
+}
+
+// DatabaseResult is the outcome of migrating one database of a multi-database migration
+type DatabaseResult struct {
+	DatabaseMapping
+	Stats monitor.MigrationStats `json:"stats"`
+
+	// Resumed reports that the database was completed by an earlier run, whose
+	// statistics are reported
+	Resumed bool `json:"-"`
+}
+
+// MigrateDatabaseFunc migrates one database to its target database, keeping the
+// progress of its keys in resumeFile
+type MigrateDatabaseFunc func(mapping DatabaseMapping, resumeFile string) (monitor.MigrationStats, error)
+
+// databasesState records the databases a multi-database migration has completed, so
+// that a migration started again continues with the first database it did not complete
+type databasesState struct {
+	Fingerprint *ResumeFingerprint        `json:"fingerprint,omitempty"`
+	Completed   map[string]DatabaseResult `json:"completed_databases"`
+	UpdatedAt   time.Time                 `json:"updated_at"`
+}
+
+// PlanDatabases returns the databases to migrate: every database of keyspace holding
+// keys, in order, with the target database mapping gives it. Databases mapping does not
+// mention keep their number.
+func PlanDatabases(keyspace map[int]int64, mapping map[int]int) []DatabaseMapping {
+	plan := make([]DatabaseMapping, 0, len(keyspace))
+	for db, keys := range keyspace {
+		if keys <= 0 {
+			continue
+		}
+		target, mapped := mapping[db]
+		if !mapped {
+			target = db
+		}
+		plan = append(plan, DatabaseMapping{Source: db, Target: target, Keys: keys})
+	}
+	sort.Slice(plan, func(i, j int) bool { return plan[i].Source < plan[j].Source })
+	return plan
+}
+
+// DatabaseResumeFile returns the resume file of one source database of a multi-database
+// migration, named after resumeFile: migration_resume.json becomes
+// migration_resume.db3.json for database 3
+func DatabaseResumeFile(resumeFile string, db int) string {
+	ext := filepath.Ext(resumeFile)
+	return strings.TrimSuffix(resumeFile, ext) + ".db" + strconv.Itoa(db) + ext
+}
+
+// MigrateDatabases migrates the databases of plan one after the other with migrate.
+// Each database keeps the progress of its keys in a resume file of its own, see
+// DatabaseResumeFile, and the databases completed so far are recorded in resumeFile, so
+// that a migration started again skips them and resumes the database it stopped in. A
+// completed database is migrated again if it is now mapped to another target database.
+// resumeFile is removed once every database is complete.
+//
+// Databases are only skipped if resumeFile was saved with fingerprint, see
+// NewDatabasesFingerprint, and MigrateDatabases fails otherwise unless force is set.
+//
+// Migration stops at the first database that fails or when ctx is done. The results of
+// the databases completed by this or an earlier run are returned either way.
+func MigrateDatabases(ctx context.Context, plan []DatabaseMapping, resumeFile string, fingerprint ResumeFingerprint, force bool, log logger.Logger, migrate MigrateDatabaseFunc) ([]DatabaseResult, error) {
+	state := &databasesState{Completed: make(map[string]DatabaseResult)}
+	if saved, err := loadDatabasesState(resumeFile); err == nil {
+		if saved.Completed != nil {
+			differences, err := checkResumeFingerprint(saved.Fingerprint, fingerprint, force, resumeFile)
+			if err != nil {
+				return nil, err
+			}
+			for _, difference := range differences {
+				log.Warnf("Resuming %s despite a different migration (--force-resume): %s", resumeFile, difference)
+			}
+			state = saved
+		}
+	} else if !os.IsNotExist(err) {
+		log.Warnf("Could not load database progress: %v. Migrating every database.", err)
+	}
+	state.Fingerprint = &fingerprint
+
+	results := make([]DatabaseResult, 0, len(plan))
+	for i, mapping := range plan {
+		key := strconv.Itoa(mapping.Source)
+		if done, ok := state.Completed[key]; ok && done.Target == mapping.Target {
+			log.Infof("Database %d was migrated to database %d by a previous run, skipping it", mapping.Source, mapping.Target)
+			done.Resumed = true
+			results = append(results, done)
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return results, fmt.Errorf("migration interrupted before database %d, run it again to resume: %w", mapping.Source, err)
+		}
+
+		log.Infof("Migrating database %d to database %d (%d of %d, %d keys)", mapping.Source, mapping.Target, i+1, len(plan), mapping.Keys)
+		stats, err := migrate(mapping, DatabaseResumeFile(resumeFile, mapping.Source))
+		if err != nil {
+			return results, fmt.Errorf("database %d: %w", mapping.Source, err)
+		}
+		if err := ctx.Err(); err != nil {
+			// The database may have been cut short
+			return results, fmt.Errorf("migration interrupted in database %d, run it again to resume: %w", mapping.Source, err)
+		}
+
+		result := DatabaseResult{DatabaseMapping: mapping, Stats: stats}
+		results = append(results, result)
+		state.Completed[key] = result
+		if err := saveDatabasesState(resumeFile, state); err != nil {
+			return results, err
+		}
+		log.Infof("Database %d migrated to database %d: %d keys processed, %d failed", mapping.Source, mapping.Target, stats.ProcessedKeys, stats.FailedKeys)
+	}
+
+	if err := os.Remove(resumeFile); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Failed to remove database progress file: %v", err)
+	}
+	return results, nil
+}
+
+// loadDatabasesState loads the databases completed by an earlier run
+func loadDatabasesState(filename string) (*databasesState, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var state databasesState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// saveDatabasesState saves the databases completed so far
+func saveDatabasesState(filename string, state *databasesState) error {
+	state.UpdatedAt = time.Now()
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal database progress: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return fmt.Errorf("failed to create database progress directory: %w", err)
+	}
+
+	// Write to temporary file first, then rename for atomicity
+	tempFile := filename + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write database progress: %w", err)
+	}
+	if err := os.Rename(tempFile, filename); err != nil {
+		return fmt.Errorf("failed to rename database progress file: %w", err)
+	}
+	return nil
+}
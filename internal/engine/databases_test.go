@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/monitor"
+	"github.com/kinyelo/redis-valkey-migration/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDatabasesTestLogger(t *testing.T) (logger.Logger, string) {
+	t.Helper()
+	dir := t.TempDir()
+	log, err := logger.NewLogger(logger.Config{Level: "info", OutputFile: filepath.Join(dir, "databases.log"), Format: "text"})
+	require.NoError(t, err)
+	return log, filepath.Join(dir, "migration_resume.json")
+}
+
+// databasesFingerprint is the fingerprint the multi-database migrations of the tests
+// are run with
+var databasesFingerprint = ResumeFingerprint{Source: "redis:6379", Target: "valkey:6379", DatabaseMap: []string{"3->1"}, Version: "test"}
+
+func TestPlanDatabases(t *testing.T) {
+	plan := PlanDatabases(map[int]int64{9: 4, 0: 120, 3: 7, 5: 0}, map[int]int{3: 1})
+	assert.Equal(t, []DatabaseMapping{
+		{Source: 0, Target: 0, Keys: 120},
+		{Source: 3, Target: 1, Keys: 7},
+		{Source: 9, Target: 9, Keys: 4},
+	}, plan)
+
+	assert.Empty(t, PlanDatabases(nil, map[int]int{0: 1}))
+}
+
+func TestDatabaseResumeFile(t *testing.T) {
+	assert.Equal(t, "migration_resume.db3.json", DatabaseResumeFile("migration_resume.json", 3))
+	assert.Equal(t, filepath.Join("state", "resume.db0"), DatabaseResumeFile(filepath.Join("state", "resume"), 0))
+}
+
+func TestMigrateDatabases(t *testing.T) {
+	log, resumeFile := newDatabasesTestLogger(t)
+	plan := []DatabaseMapping{{Source: 0, Target: 0, Keys: 2}, {Source: 3, Target: 1, Keys: 1}}
+
+	var migrated []string
+	results, err := MigrateDatabases(context.Background(), plan, resumeFile, databasesFingerprint, false, log,
+		func(mapping DatabaseMapping, dbResumeFile string) (monitor.MigrationStats, error) {
+			migrated = append(migrated, filepath.Base(dbResumeFile))
+			keys := int(mapping.Keys)
+			return monitor.MigrationStats{TotalKeys: keys, ProcessedKeys: keys, SuccessfulKeys: keys}, nil
+		})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"migration_resume.db0.json", "migration_resume.db3.json"}, migrated)
+	require.Len(t, results, 2)
+	assert.Equal(t, 1, results[1].Target)
+	assert.Equal(t, 1, results[1].Stats.SuccessfulKeys)
+	assert.NoFileExists(t, resumeFile, "progress is removed once every database is migrated")
+}
+
+func TestMigrateDatabases_Resume(t *testing.T) {
+	log, resumeFile := newDatabasesTestLogger(t)
+	plan := []DatabaseMapping{{Source: 0, Target: 0, Keys: 2}, {Source: 3, Target: 1, Keys: 1}, {Source: 5, Target: 5, Keys: 1}}
+
+	failure := errors.New("target unavailable")
+	var migrated []int
+	migrate := func(mapping DatabaseMapping, _ string) (monitor.MigrationStats, error) {
+		migrated = append(migrated, mapping.Source)
+		if mapping.Source == 3 && failure != nil {
+			return monitor.MigrationStats{}, failure
+		}
+		return monitor.MigrationStats{ProcessedKeys: int(mapping.Keys)}, nil
+	}
+
+	results, err := MigrateDatabases(context.Background(), plan, resumeFile, databasesFingerprint, false, log, migrate)
+	assert.ErrorIs(t, err, failure)
+	assert.ErrorContains(t, err, "database 3")
+	assert.Len(t, results, 1)
+	assert.Equal(t, []int{0, 3}, migrated, "migration stops at the failed database")
+	assert.FileExists(t, resumeFile)
+
+	failure = nil
+	migrated = nil
+	results, err = MigrateDatabases(context.Background(), plan, resumeFile, databasesFingerprint, false, log, migrate)
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 5}, migrated, "completed databases are skipped")
+	require.Len(t, results, 3)
+	assert.True(t, results[0].Resumed)
+	assert.Equal(t, 2, results[0].Stats.ProcessedKeys)
+	assert.False(t, results[1].Resumed)
+}
+
+func TestMigrateDatabases_TargetChanged(t *testing.T) {
+	log, resumeFile := newDatabasesTestLogger(t)
+	saved := databasesFingerprint
+	saved.DatabaseMap = nil
+	require.NoError(t, saveDatabasesState(resumeFile, &databasesState{Fingerprint: &saved, Completed: map[string]DatabaseResult{
+		"0": {DatabaseMapping: DatabaseMapping{Source: 0, Target: 0}},
+		"3": {DatabaseMapping: DatabaseMapping{Source: 3, Target: 3}},
+	}}))
+
+	var migrated []int
+	plan := []DatabaseMapping{{Source: 0, Target: 0}, {Source: 3, Target: 1}}
+	_, err := MigrateDatabases(context.Background(), plan, resumeFile, databasesFingerprint, true, log,
+		func(mapping DatabaseMapping, _ string) (monitor.MigrationStats, error) {
+			migrated = append(migrated, mapping.Source)
+			return monitor.MigrationStats{}, nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, []int{3}, migrated, "a database mapped to another target is migrated again")
+}
+
+func TestMigrateDatabases_Fingerprint(t *testing.T) {
+	plan := []DatabaseMapping{{Source: 0, Target: 0}, {Source: 3, Target: 1}}
+	completed := map[string]DatabaseResult{"0": {DatabaseMapping: DatabaseMapping{Source: 0, Target: 0}}}
+	var migrated []int
+	migrate := func(mapping DatabaseMapping, _ string) (monitor.MigrationStats, error) {
+		migrated = append(migrated, mapping.Source)
+		return monitor.MigrationStats{}, nil
+	}
+
+	other := databasesFingerprint
+	other.Source = "other-redis:6379"
+	for name, saved := range map[string]*ResumeFingerprint{"different source": &other, "older version": nil} {
+		t.Run(name, func(t *testing.T) {
+			log, resumeFile := newDatabasesTestLogger(t)
+			require.NoError(t, saveDatabasesState(resumeFile, &databasesState{Fingerprint: saved, Completed: completed}))
+
+			migrated = nil
+			_, err := MigrateDatabases(context.Background(), plan, resumeFile, databasesFingerprint, false, log, migrate)
+			var migrationErr *MigrationError
+			require.ErrorAs(t, err, &migrationErr)
+			assert.Equal(t, ConfigurationError, migrationErr.Type)
+			assert.ErrorContains(t, err, "--force-resume")
+			assert.Empty(t, migrated, "no database is skipped or migrated")
+			assert.FileExists(t, resumeFile)
+
+			results, err := MigrateDatabases(context.Background(), plan, resumeFile, databasesFingerprint, true, log, migrate)
+			require.NoError(t, err)
+			assert.Equal(t, []int{3}, migrated, "--force-resume skips the completed databases")
+			assert.True(t, results[0].Resumed)
+		})
+	}
+}
+
+func TestMigrateDatabases_Interrupted(t *testing.T) {
+	log, resumeFile := newDatabasesTestLogger(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var migrated []int
+	plan := []DatabaseMapping{{Source: 0, Target: 0}, {Source: 1, Target: 1}}
+	results, err := MigrateDatabases(ctx, plan, resumeFile, databasesFingerprint, false, log,
+		func(mapping DatabaseMapping, _ string) (monitor.MigrationStats, error) {
+			migrated = append(migrated, mapping.Source)
+			cancel()
+			return monitor.MigrationStats{}, nil
+		})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, results, "an interrupted database is not complete")
+	assert.Equal(t, []int{0}, migrated)
+
+	_, statErr := os.Stat(resumeFile)
+	assert.True(t, os.IsNotExist(statErr))
+}
@@ -0,0 +1,334 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// DeadLetter is a key that failed to migrate, as recorded in the dead-letter file
+type DeadLetter struct {
+	Key            string `json:"key"`
+	Database       int    `json:"database"`
+	TargetKey      string `json:"target_key,omitempty"` // Set when key rules rename the key
+	TargetDatabase int    `json:"target_database"`
+
+	// Type, Operation and Retryable describe the MigrationError the key failed with
+	Type      string    `json:"type"`
+	Operation string    `json:"operation"`
+	Error     string    `json:"error"`
+	Cause     string    `json:"cause,omitempty"`
+	Retryable bool      `json:"retryable"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+// newDeadLetter describes the failure of a key
+func newDeadLetter(key string, err error) DeadLetter {
+	migErr := WrapError(err, "migration")
+	letter := DeadLetter{
+		Key:       key,
+		Type:      migErr.Type.String(),
+		Operation: migErr.Operation,
+		Error:     err.Error(),
+		Retryable: migErr.Retryable,
+		FailedAt:  time.Now(),
+	}
+	if migErr.Cause != nil {
+		letter.Cause = migErr.Cause.Error()
+	}
+	return letter
+}
+
+// deadLetterFile appends the keys that fail to migrate to an NDJSON file, one line per
+// failure. The file is only created once a key fails. A nil deadLetterFile records
+// nothing.
+type deadLetterFile struct {
+	mu             sync.Mutex
+	path           string
+	database       int
+	targetDatabase int
+	file           *os.File
+}
+
+// newDeadLetterFile creates a dead-letter file for the keys of a source database
+// migrated to a target database, nil if path is empty. Keys of other databases already
+// in the file are left alone.
+func newDeadLetterFile(path string, database, targetDatabase int) *deadLetterFile {
+	if path == "" {
+		return nil
+	}
+	return &deadLetterFile{path: path, database: database, targetDatabase: targetDatabase}
+}
+
+// Add records a failed key
+func (d *deadLetterFile) Add(letter DeadLetter) error {
+	if d == nil {
+		return nil
+	}
+	letter.Database = d.database
+	letter.TargetDatabase = d.targetDatabase
+
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file == nil {
+		file, err := os.OpenFile(d.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open dead-letter file: %w", err)
+		}
+		d.file = file
+	}
+	if _, err := d.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write dead-letter file: %w", err)
+	}
+	return nil
+}
+
+// Compact rewrites the file so that it lists each key of the databases once, with its
+// latest failure, and only if it is in failed. Keys of other databases are kept. The
+// file is removed once nothing is left in it. It returns the number of keys of the
+// database left in the file.
+func (d *deadLetterFile) Compact(failed map[string]bool) (int, error) {
+	if d == nil {
+		return 0, nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file != nil {
+		if err := d.file.Close(); err != nil {
+			return 0, fmt.Errorf("failed to close dead-letter file: %w", err)
+		}
+		d.file = nil
+	}
+
+	letters, err := ReadDeadLetterFile(d.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	type letterKey struct {
+		database, targetDatabase int
+		key                      string
+	}
+	kept := make([]DeadLetter, 0, len(letters))
+	index := make(map[letterKey]int)
+	remaining := 0
+	for _, letter := range letters {
+		ours := d.owns(letter)
+		if ours && !failed[letter.Key] {
+			continue
+		}
+		id := letterKey{letter.Database, letter.TargetDatabase, letter.Key}
+		if i, seen := index[id]; seen {
+			kept[i] = letter
+			continue
+		}
+		index[id] = len(kept)
+		kept = append(kept, letter)
+		if ours {
+			remaining++
+		}
+	}
+
+	if len(kept) == 0 {
+		if err := os.Remove(d.path); err != nil && !os.IsNotExist(err) {
+			return 0, fmt.Errorf("failed to remove dead-letter file: %w", err)
+		}
+		return 0, nil
+	}
+	return remaining, writeDeadLetterFile(d.path, kept)
+}
+
+// owns reports whether a failed key was migrated from and to the databases of the file
+func (d *deadLetterFile) owns(letter DeadLetter) bool {
+	return letter.Database == d.database && letter.TargetDatabase == d.targetDatabase
+}
+
+// Close closes the file
+func (d *deadLetterFile) Close() error {
+	if d == nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	d.file = nil
+	return err
+}
+
+// ReadDeadLetterFile reads the failed keys recorded in a dead-letter file, in the order
+// they failed
+func ReadDeadLetterFile(path string) ([]DeadLetter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var letters []DeadLetter
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var letter DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			return nil, fmt.Errorf("failed to parse line %d of dead-letter file %s: %w", line, path, err)
+		}
+		letters = append(letters, letter)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dead-letter file %s: %w", path, err)
+	}
+	return letters, nil
+}
+
+// writeDeadLetterFile replaces a dead-letter file with letters, through a temporary
+// file so that it is never left half written
+func writeDeadLetterFile(path string, letters []DeadLetter) error {
+	tempFile := path + ".tmp"
+	file, err := os.Create(tempFile)
+	if err != nil {
+		return fmt.Errorf("failed to write dead-letter file: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, letter := range letters {
+		if err := encoder.Encode(letter); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to write dead-letter file: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write dead-letter file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write dead-letter file: %w", err)
+	}
+
+	if err := os.Rename(tempFile, path); err != nil {
+		return fmt.Errorf("failed to rename dead-letter file: %w", err)
+	}
+	return nil
+}
+
+// RetryFailed migrates the keys listed in a dead-letter file again, those migrated from
+// and to the databases of the engine, and verifies the keys that now succeed if
+// configured. The dead-letter file is left listing the keys that failed again, and
+// RetryFailed fails if there are any.
+func (me *MigrationEngine) RetryFailed(letters []DeadLetter) error {
+	me.logger.Info("Migrating the keys that failed to migrate again")
+
+	defer me.gracefulShutdown()
+	me.shutdownManager.StartSignalHandler()
+
+	var keys []string
+	seen := make(map[string]bool)
+	others := 0
+	for _, letter := range letters {
+		if letter.Database != me.fingerprint.SourceDatabase || letter.TargetDatabase != me.fingerprint.TargetDatabase {
+			others++
+			continue
+		}
+		if !seen[letter.Key] {
+			seen[letter.Key] = true
+			keys = append(keys, letter.Key)
+		}
+	}
+	if others > 0 {
+		me.logger.Warnf("Not retrying %d failed keys of other databases, retry them with their --redis-database and --valkey-database", others)
+	}
+	if len(keys) == 0 {
+		me.logger.Info("No failed keys to retry")
+		return nil
+	}
+
+	if err := me.connectDatabases(); err != nil {
+		return me.failureHandler.HandleCriticalFailure("database connection", err)
+	}
+	if err := me.replicaGate.Wait(me.ctx); err != nil {
+		return me.failureHandler.HandleCriticalFailure("replica lag check", err)
+	}
+
+	me.monitor.Initialize(0)
+	me.logger.Infof("Retrying %d failed keys", len(keys))
+
+	// Keys deleted from the source since they failed have nothing left to migrate
+	var existing []string
+	stream := newKeyStream(me.ctx, func(ctx context.Context, send func(keyPage) bool) error {
+		var err error
+		if existing, err = me.existingKeys(keys); err != nil {
+			return WrapError(err, "failed key lookup")
+		}
+		if gone := len(keys) - len(existing); gone > 0 {
+			me.logger.Infof("%d failed keys no longer exist on the source", gone)
+		}
+		send(keyPage{keys: existing})
+		return nil
+	})
+
+	go me.startProgressReporting()
+
+	migrationErr := me.performMigration(stream)
+	me.compactDeadLetters()
+	if err := stream.Err(); err != nil {
+		return me.failureHandler.HandleCriticalFailure("failed key lookup", err)
+	}
+	if migrationErr != nil && IsCritical(migrationErr) {
+		return me.failureHandler.HandleCriticalFailure("migration", migrationErr)
+	}
+
+	failed := me.resumeState.GetFailedKeys()
+	if me.config.VerifyAfterMigration {
+		failedAgain := make(map[string]bool, len(failed))
+		for _, key := range failed {
+			failedAgain[key] = true
+		}
+		migrated := make([]string, 0, len(existing))
+		for _, key := range existing {
+			if !failedAgain[key] {
+				migrated = append(migrated, key)
+			}
+		}
+
+		verify := newKeyStream(me.ctx, func(ctx context.Context, send func(keyPage) bool) error {
+			send(keyPage{keys: migrated})
+			return nil
+		})
+		if err := me.verifyMigration(verify); err != nil {
+			me.logger.Errorf("Migration verification failed: %v", err)
+			return err
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d keys failed again, they are listed in %s", len(failed), len(keys), me.config.DeadLetterFile)
+	}
+	me.logger.Infof("All %d failed keys were migrated", len(keys))
+	return nil
+}
@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDeadLetter(t *testing.T) {
+	cause := NewMigrationError(DataError, "conflict check", ErrTargetKeyExists.Error()).WithCause(ErrTargetKeyExists)
+	letter := newDeadLetter("user:1", cause.WithKey("user:1"))
+	assert.Equal(t, "user:1", letter.Key)
+	assert.Equal(t, "DataError", letter.Type)
+	assert.Equal(t, "conflict check", letter.Operation)
+	assert.Equal(t, ErrTargetKeyExists.Error(), letter.Cause)
+	assert.Contains(t, letter.Error, "user:1")
+	assert.False(t, letter.FailedAt.IsZero())
+
+	plain := newDeadLetter("user:2", os.ErrDeadlineExceeded)
+	assert.Equal(t, "NetworkError", plain.Type)
+	assert.Equal(t, "migration", plain.Operation)
+	assert.True(t, plain.Retryable)
+}
+
+func TestDeadLetterFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failed.ndjson")
+	other := newDeadLetterFile(path, 1, 0)
+	require.NoError(t, other.Add(DeadLetter{Key: "b", Error: "other database"}))
+	require.NoError(t, other.Close())
+
+	file := newDeadLetterFile(path, 0, 0)
+	require.NoError(t, file.Add(DeadLetter{Key: "a", Error: "first"}))
+	require.NoError(t, file.Add(DeadLetter{Key: "b", Error: "second"}))
+	require.NoError(t, file.Add(DeadLetter{Key: "a", Error: "third"}))
+
+	letters, err := ReadDeadLetterFile(path)
+	require.NoError(t, err)
+	require.Len(t, letters, 4, "every failure is appended")
+	assert.Equal(t, 1, letters[0].Database)
+
+	remaining, err := file.Compact(map[string]bool{"a": true})
+	require.NoError(t, err)
+	assert.Equal(t, 1, remaining)
+	letters, err = ReadDeadLetterFile(path)
+	require.NoError(t, err)
+	require.Len(t, letters, 2)
+	assert.Equal(t, DeadLetter{Key: "b", Database: 1, Error: "other database"}, letters[0], "keys of other databases are kept")
+	assert.Equal(t, "third", letters[1].Error, "the latest failure of a key is kept")
+
+	// Keys that fail again are appended after compaction
+	require.NoError(t, file.Add(DeadLetter{Key: "c", Error: "fourth"}))
+	letters, err = ReadDeadLetterFile(path)
+	require.NoError(t, err)
+	assert.Len(t, letters, 3)
+
+	remaining, err = other.Compact(nil)
+	require.NoError(t, err)
+	assert.Zero(t, remaining)
+	remaining, err = file.Compact(nil)
+	require.NoError(t, err)
+	assert.Zero(t, remaining)
+	assert.NoFileExists(t, path, "an empty dead-letter file is removed")
+
+	var disabled *deadLetterFile
+	assert.NoError(t, disabled.Add(DeadLetter{Key: "a"}))
+	_, err = disabled.Compact(nil)
+	assert.NoError(t, err)
+}
+
+func TestReadDeadLetterFile_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failed.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte("{\"key\":\"a\"}\n\nnot json\n"), 0644))
+
+	_, err := ReadDeadLetterFile(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 3")
+}
+
+func TestMigrationEngineRecordsDeadLetters(t *testing.T) {
+	source := newPagingTestClient(10)
+	target := newStringTestClient(map[string]string{"k4": "existing"})
+
+	engine, engineConfig := newCutoverTestEngine(t, source, target)
+	engineConfig.VerifyAfterMigration = false
+	engineConfig.OnConflict = ConflictFail
+	require.Error(t, engine.Migrate())
+
+	letters, err := ReadDeadLetterFile(engineConfig.DeadLetterFile)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, "k4", letters[0].Key)
+	assert.Equal(t, "DataError", letters[0].Type)
+	assert.Equal(t, "conflict check", letters[0].Operation)
+	assert.Equal(t, ErrTargetKeyExists.Error(), letters[0].Cause)
+}
+
+func TestMigrationEngineRetryFailed(t *testing.T) {
+	source := newPagingTestClient(5)
+	target := newStringTestClient(map[string]string{"k3": "existing"})
+
+	engine, engineConfig := newCutoverTestEngine(t, source, target)
+	engineConfig.ResumeFile = ""
+	engineConfig.OnConflict = ConflictFail
+	file := newDeadLetterFile(engineConfig.DeadLetterFile, 0, 0)
+	for _, key := range []string{"k1", "k3", "gone", "k1"} {
+		require.NoError(t, file.Add(DeadLetter{Key: key}))
+	}
+	require.NoError(t, newDeadLetterFile(engineConfig.DeadLetterFile, 2, 0).Add(DeadLetter{Key: "k2"}))
+	require.NoError(t, file.Close())
+
+	letters, err := ReadDeadLetterFile(engineConfig.DeadLetterFile)
+	require.NoError(t, err)
+	err = engine.RetryFailed(letters)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), ErrTargetKeyExists.Error())
+
+	// Only the keys of the dead-letter file are migrated
+	assert.Equal(t, "v1", target.keys["k1"])
+	assert.NotContains(t, target.keys, "k2")
+	assert.Empty(t, source.cursors, "the source is not scanned")
+
+	letters, err = ReadDeadLetterFile(engineConfig.DeadLetterFile)
+	require.NoError(t, err)
+	require.Len(t, letters, 2)
+	assert.Equal(t, "k3", letters[0].Key)
+	assert.Equal(t, "conflict check", letters[0].Operation)
+	assert.Equal(t, "k2", letters[1].Key, "keys of other databases are left in the file")
+	assert.NoFileExists(t, filepath.Join(filepath.Dir(engineConfig.DeadLetterFile), "resume.json"))
+}
+
+func TestMigrationEngineRetryFailed_AllMigrated(t *testing.T) {
+	source := newPagingTestClient(5)
+	target := newStringTestClient(nil)
+
+	engine, engineConfig := newCutoverTestEngine(t, source, target)
+	engineConfig.ResumeFile = ""
+	file := newDeadLetterFile(engineConfig.DeadLetterFile, 0, 0)
+	require.NoError(t, file.Add(DeadLetter{Key: "k2"}))
+	require.NoError(t, file.Add(DeadLetter{Key: "k4"}))
+	require.NoError(t, file.Close())
+
+	letters, err := ReadDeadLetterFile(engineConfig.DeadLetterFile)
+	require.NoError(t, err)
+	require.NoError(t, engine.RetryFailed(letters))
+
+	assert.Equal(t, map[string]interface{}{"k2": "v2", "k4": "v4"}, target.keys)
+	assert.Equal(t, 2, engine.GetStats().SuccessfulKeys)
+	assert.NoFileExists(t, engineConfig.DeadLetterFile)
+}
@@ -0,0 +1,422 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"sync"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+)
+
+// minScanPageSize is the smallest COUNT asked for per SCAN page. Pages are otherwise
+// about as large as a batch of keys.
+const minScanPageSize = 100
+
+// keyRenameCheckLimit is the most target key names held in memory while checking the
+// key rules. Larger keyspaces are checked in several passes over a share of the names.
+var keyRenameCheckLimit = 1000000
+
+// keyNameSeed seeds the hash that splits key names into shares
+var keyNameSeed = maphash.MakeSeed()
+
+// errKeyRenameCheckFull stops a pass of the key rule check that holds too many names
+var errKeyRenameCheckFull = errors.New("too many key names to check at once")
+
+// keyPage is a page of discovered keys
+type keyPage struct {
+	keys []string
+
+	// node and cursor are the scan position after the page, see ScanCheckpoint
+	node   string
+	cursor uint64
+
+	// checkpoint is set for the pages of the scan, which move the scan checkpoint past
+	// them once their keys are migrated. Keys retried from an earlier run are not part
+	// of the scan.
+	checkpoint bool
+}
+
+// keyStream delivers the keys to migrate a page at a time while they are being discovered
+type keyStream struct {
+	pages  chan keyPage
+	cancel context.CancelFunc
+	err    error // Set before pages is closed
+}
+
+// newKeyStream runs discover in a goroutine, which hands pages to send until it returns.
+// send reports false once the stream has been stopped.
+func newKeyStream(ctx context.Context, discover func(ctx context.Context, send func(keyPage) bool) error) *keyStream {
+	ctx, cancel := context.WithCancel(ctx)
+	stream := &keyStream{
+		pages:  make(chan keyPage, 1),
+		cancel: cancel,
+	}
+
+	go func() {
+		defer close(stream.pages)
+		err := discover(ctx, func(page keyPage) bool {
+			select {
+			case stream.pages <- page:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+		// A stopped stream did not fail
+		if err != nil && ctx.Err() == nil {
+			stream.err = err
+		}
+	}()
+
+	return stream
+}
+
+// Stop stops discovery and waits for it to end
+func (s *keyStream) Stop() {
+	s.cancel()
+	for range s.pages {
+	}
+}
+
+// Err returns the error discovery failed with. It must only be called once every page
+// has been received, or after Stop.
+func (s *keyStream) Err() error {
+	return s.err
+}
+
+// discoverKeys starts discovering the keys to migrate, first sending retryKeys that
+// still exist on the source, then the keys matching the collection patterns. When the
+// source can scan a page at a time, keys are sent as SCAN pages arrive, continuing from
+// checkpoint if it is not nil; otherwise every key is listed first and sent as a single
+// page.
+func (me *MigrationEngine) discoverKeys(ctx context.Context, checkpoint *ScanCheckpoint, retryKeys []string) *keyStream {
+	return newKeyStream(ctx, func(ctx context.Context, send func(keyPage) bool) error {
+		sendPage := func(page keyPage) error {
+			if !send(page) {
+				return ctx.Err()
+			}
+			return nil
+		}
+
+		if len(retryKeys) > 0 {
+			keys, err := me.existingKeys(retryKeys)
+			if err != nil {
+				return WrapError(err, "failed key lookup")
+			}
+			me.logger.Infof("Migrating %d keys that failed in the previous run again", len(keys))
+			if err := sendPage(keyPage{keys: keys}); err != nil {
+				return err
+			}
+		}
+
+		if len(me.config.CollectionPatterns) > 0 {
+			me.logger.Infof("Using collection patterns: %v", me.config.CollectionPatterns)
+		}
+
+		if !client.Supports[client.ScanClient](me.sourceClient) {
+			keys, err := me.listKeys()
+			if err != nil {
+				return err
+			}
+			return sendPage(keyPage{keys: keys, node: client.StandaloneNode, checkpoint: true})
+		}
+
+		discovered, err := me.scanKeys(checkpoint, sendPage)
+		if err != nil {
+			return err
+		}
+		me.logger.Infof("Discovered %d keys to migrate", discovered)
+		return nil
+	})
+}
+
+// listKeys lists every key to migrate at once, for sources that cannot scan a page at a time
+func (me *MigrationEngine) listKeys() ([]string, error) {
+	if len(me.config.CollectionPatterns) > 0 {
+		keys, err := me.scanner.ScanKeysByPatterns(me.sourceClient, me.config.CollectionPatterns)
+		if err != nil {
+			return nil, WrapError(err, "filtered key discovery")
+		}
+		me.logger.Infof("Discovered %d keys matching patterns", len(keys))
+		return keys, nil
+	}
+
+	me.logger.Info("No collection patterns specified, scanning all keys")
+	keys, err := me.scanner.ScanAllKeys(me.sourceClient)
+	if err != nil {
+		return nil, WrapError(err, "key discovery")
+	}
+	me.logger.Infof("Discovered %d keys to migrate", len(keys))
+	return keys, nil
+}
+
+// scanKeys scans every node of the source a page at a time, continuing from checkpoint,
+// and sends the keys of every page that match the collection patterns. It returns how
+// many keys it sent.
+func (me *MigrationEngine) scanKeys(checkpoint *ScanCheckpoint, sendPage func(keyPage) error) (int, error) {
+	nodes, err := me.sourceClient.ScanNodes()
+	if err != nil {
+		return 0, WrapError(err, "key discovery")
+	}
+
+	count := int64(max(me.config.BatchSize, minScanPageSize))
+	discovered := 0
+	for _, node := range nodes {
+		var cursor uint64
+		if checkpoint != nil {
+			if saved, ok := checkpoint.Cursors[node]; ok {
+				if saved == 0 {
+					me.logger.Debugf("Keys of node %q were all migrated by a previous run", node)
+					continue
+				}
+				cursor = saved
+			}
+		}
+
+		for {
+			keys, next, err := me.sourceClient.ScanPage(node, cursor, "*", count)
+			if err != nil {
+				return discovered, WrapError(err, "key discovery")
+			}
+
+			if len(me.config.CollectionPatterns) > 0 {
+				matched := keys[:0]
+				for _, key := range keys {
+					if me.scanner.MatchesPatterns(key, me.config.CollectionPatterns) {
+						matched = append(matched, key)
+					}
+				}
+				keys = matched
+			}
+			discovered += len(keys)
+
+			if err := sendPage(keyPage{keys: keys, node: node, cursor: next, checkpoint: true}); err != nil {
+				return discovered, err
+			}
+			if next == 0 {
+				break
+			}
+			cursor = next
+		}
+	}
+
+	return discovered, nil
+}
+
+// existingKeys returns the keys that still exist on the source
+func (me *MigrationEngine) existingKeys(keys []string) ([]string, error) {
+	existing := make([]string, 0, len(keys))
+	for _, key := range keys {
+		exists, err := me.sourceClient.Exists(key)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			me.logger.Debugf("Failed key %s no longer exists on the source", key)
+			me.resumeState.ForgetFailed(key)
+			continue
+		}
+		existing = append(existing, key)
+	}
+	return existing, nil
+}
+
+// checkKeyRenames fails if the key rules rename two keys to the same name, as one would
+// silently overwrite the other on the target. It reads every key to migrate before any
+// is written, holding at most keyRenameCheckLimit names at a time: when a pass holds
+// more, the names are split into twice as many shares, each checked in a pass of its own.
+func (me *MigrationEngine) checkKeyRenames() error {
+	if len(me.keyRules) == 0 {
+		return nil
+	}
+
+	me.logger.Info("Checking that the key rules give every key a name of its own...")
+	eachPage := func(check func(keys []string) error) error {
+		_, err := me.scanKeys(nil, func(page keyPage) error {
+			if err := me.ctx.Err(); err != nil {
+				return err
+			}
+			return check(page.keys)
+		})
+		return err
+	}
+	if !client.Supports[client.ScanClient](me.sourceClient) {
+		keys, err := me.listKeys()
+		if err != nil {
+			return err
+		}
+		eachPage = func(check func(keys []string) error) error {
+			return check(keys)
+		}
+	}
+
+	bits := 0
+	for share := 0; share < 1<<bits; {
+		renamed := make(map[string]string)
+		err := eachPage(func(keys []string) error {
+			for _, key := range keys {
+				targetKey := me.targetKey(key)
+				if nameShare(targetKey, bits) != share {
+					continue
+				}
+				if other, exists := renamed[targetKey]; exists && other != key {
+					return NewMigrationError(ConfigurationError, "key rules",
+						fmt.Sprintf("keys %s and %s are both renamed to %s", other, key, targetKey))
+				}
+				renamed[targetKey] = key
+				if len(renamed) > keyRenameCheckLimit {
+					return errKeyRenameCheckFull
+				}
+			}
+			return nil
+		})
+		if errors.Is(err, errKeyRenameCheckFull) {
+			bits++
+			share *= 2
+			me.logger.Debugf("Checking the key rules in %d passes", 1<<bits)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		share++
+	}
+	return nil
+}
+
+// nameShare returns which of 2^bits shares of the key names name belongs to
+func nameShare(name string, bits int) int {
+	return int(maphash.String(keyNameSeed, name) >> (64 - bits))
+}
+
+// keyBatch is a batch of keys handed to a migration worker
+type keyBatch struct {
+	keys []string
+
+	// pages holds the scan page of every key, nil for keys outside the scan
+	pages []*pendingPage
+}
+
+// pendingPage is a page of the scan whose keys are being migrated
+type pendingPage struct {
+	node   string
+	cursor uint64
+	keys   []string // Keys discovered in the page
+
+	remaining int  // Keys of the page not migrated yet
+	sealed    bool // Set once every key of the page has been handed to a worker
+}
+
+// scanWindow tracks the pages of the scan whose keys are being migrated, and moves the
+// scan checkpoint of a node past its pages once they, and every page of the node before
+// them, are complete. Keys whose page is incomplete are migrated again on resume unless
+// the resume state lists them as processed; the resume state forgets the keys of the
+// pages the checkpoint moved past, which are not scanned again.
+type scanWindow struct {
+	mu         sync.Mutex
+	state      *ResumeState
+	checkpoint ScanCheckpoint
+	pages      map[string][]*pendingPage // Incomplete pages of every node, in scan order
+	inFlight   map[string]int            // Keys handed to workers and not migrated yet
+}
+
+// newScanWindow creates a window continuing from the checkpoint saved in state, if any
+func newScanWindow(state *ResumeState) *scanWindow {
+	checkpoint := ScanCheckpoint{Cursors: make(map[string]uint64)}
+	if saved := state.GetScan(); saved != nil && saved.Cursors != nil {
+		checkpoint = *saved
+	}
+
+	return &scanWindow{
+		state:      state,
+		checkpoint: checkpoint,
+		pages:      make(map[string][]*pendingPage),
+		inFlight:   make(map[string]int),
+	}
+}
+
+// open starts tracking a page, returning nil for pages outside the scan
+func (w *scanWindow) open(page keyPage) *pendingPage {
+	if !page.checkpoint {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	pending := &pendingPage{node: page.node, cursor: page.cursor, keys: page.keys}
+	w.pages[page.node] = append(w.pages[page.node], pending)
+	return pending
+}
+
+// add records that a key of page is handed to a worker. It reports false for keys that
+// are already being migrated, which a scan may return twice.
+func (w *scanWindow) add(page *pendingPage, key string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.inFlight[key] > 0 {
+		return false
+	}
+	w.inFlight[key]++
+	if page != nil {
+		page.remaining++
+	}
+	return true
+}
+
+// seal records that every key of page has been handed to a worker
+func (w *scanWindow) seal(page *pendingPage) {
+	if page == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	page.sealed = true
+	w.advance(page.node)
+}
+
+// done records that the keys of a batch have been migrated, or have failed
+func (w *scanWindow) done(batch keyBatch) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	nodes := make(map[string]bool)
+	for i, key := range batch.keys {
+		if w.inFlight[key]--; w.inFlight[key] <= 0 {
+			delete(w.inFlight, key)
+		}
+		if page := batch.pages[i]; page != nil {
+			page.remaining--
+			nodes[page.node] = true
+		}
+	}
+
+	for node := range nodes {
+		w.advance(node)
+	}
+}
+
+// advance moves the checkpoint of node past its complete pages and saves it in the
+// resume state. The caller must hold w.mu.
+func (w *scanWindow) advance(node string) {
+	pages := w.pages[node]
+	moved := false
+	var keys []string
+	for len(pages) > 0 && pages[0].sealed && pages[0].remaining == 0 {
+		w.checkpoint.Cursors[node] = pages[0].cursor
+		w.checkpoint.Keys += len(pages[0].keys)
+		keys = append(keys, pages[0].keys...)
+		pages = pages[1:]
+		moved = true
+	}
+	w.pages[node] = pages
+
+	if moved {
+		w.state.AdvanceScan(w.checkpoint, keys)
+	}
+}
@@ -0,0 +1,292 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+	"github.com/kinyelo/redis-valkey-migration/internal/transform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKeyStream returns a stream sending keys as a single page outside the scan
+func testKeyStream(keys []string) *keyStream {
+	return newKeyStream(context.Background(), func(ctx context.Context, send func(keyPage) bool) error {
+		send(keyPage{keys: keys})
+		return nil
+	})
+}
+
+// pagingTestClient is a test client that scans its keys in sorted order, pageSize keys
+// per page, with the index of the next key as the cursor
+type pagingTestClient struct {
+	*IntegrationTestClient
+	pageSize int
+
+	scanMu  sync.Mutex
+	cursors []uint64 // Cursors ScanPage was called with
+}
+
+func (c *pagingTestClient) ScanNodes() ([]string, error) {
+	return []string{client.StandaloneNode}, nil
+}
+
+func (c *pagingTestClient) ScanPage(node string, cursor uint64, pattern string, count int64) ([]string, uint64, error) {
+	keys, err := c.GetAllKeys()
+	if err != nil {
+		return nil, 0, err
+	}
+	sort.Strings(keys)
+
+	c.scanMu.Lock()
+	c.cursors = append(c.cursors, cursor)
+	c.scanMu.Unlock()
+
+	end := min(int(cursor)+c.pageSize, len(keys))
+	if end == len(keys) {
+		return keys[cursor:end], 0, nil
+	}
+	return keys[cursor:end], uint64(end), nil
+}
+
+func newPagingTestClient(count int) *pagingTestClient {
+	values := make(map[string]string, count)
+	for i := 0; i < count; i++ {
+		values[fmt.Sprintf("k%d", i)] = fmt.Sprintf("v%d", i)
+	}
+	return &pagingTestClient{IntegrationTestClient: newStringTestClient(values), pageSize: 3}
+}
+
+func TestMigrationEngineStreamsKeys(t *testing.T) {
+	source := newPagingTestClient(10)
+	target := newStringTestClient(nil)
+
+	engine, engineConfig := newCutoverTestEngine(t, source, target)
+	engineConfig.CollectionPatterns = []string{"k*"}
+	require.NoError(t, engine.Migrate())
+
+	assert.Len(t, target.keys, 10)
+	stats := engine.GetStats()
+	assert.Equal(t, 10, stats.TotalKeys)
+	assert.Equal(t, 10, stats.SuccessfulKeys)
+	assert.Zero(t, stats.FailedKeys, "keys are verified against a second scan")
+	assert.Equal(t, []uint64{0, 3, 6, 9, 0, 3, 6, 9}, source.cursors, "the source is scanned for migration and for verification")
+	assert.NoFileExists(t, engineConfig.ResumeFile)
+}
+
+func TestMigrationEngineResumesScan(t *testing.T) {
+	source := newPagingTestClient(10)
+	target := newStringTestClient(nil)
+
+	engine, engineConfig := newCutoverTestEngine(t, source, target)
+	engineConfig.VerifyAfterMigration = false
+
+	// The previous run migrated the first two pages except k1, which failed, and k6 of
+	// the page after them
+	state := NewResumeState()
+	state.MarkProcessed("k6")
+	state.MarkFailed("k1")
+	state.MarkFailed("gone")
+	state.AdvanceScan(ScanCheckpoint{Cursors: map[string]uint64{client.StandaloneNode: 6}, Keys: 6}, nil)
+	data, err := json.Marshal(state)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(engineConfig.ResumeFile, data, 0644))
+	engine.resumeState, err = loadResumeState(engineConfig.ResumeFile)
+	require.NoError(t, err)
+
+	require.NoError(t, engine.Migrate())
+
+	migrated := make([]string, 0, len(target.keys))
+	for key := range target.keys {
+		migrated = append(migrated, key)
+	}
+	assert.ElementsMatch(t, []string{"k1", "k7", "k8", "k9"}, migrated)
+	assert.Equal(t, []uint64{6, 9}, source.cursors, "discovery continues from the checkpoint")
+	assert.Equal(t, 5, engine.GetStats().TotalKeys, "k1 and the keys from the checkpoint on")
+	assert.NoFileExists(t, engineConfig.ResumeFile)
+}
+
+func TestMigrationEngineSavesScanCheckpoint(t *testing.T) {
+	source := newPagingTestClient(10)
+	target := newStringTestClient(map[string]string{"k4": "existing"})
+
+	engine, engineConfig := newCutoverTestEngine(t, source, target)
+	engineConfig.VerifyAfterMigration = false
+	engineConfig.OnConflict = ConflictFail
+	err := engine.Migrate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), ErrTargetKeyExists.Error())
+
+	// Keys that fail are kept for the next run, past the checkpoint
+	assert.Equal(t, []string{"k4"}, engine.resumeState.GetFailedKeys())
+	scan := engine.resumeState.GetScan()
+	require.NotNil(t, scan)
+	assert.Equal(t, map[string]uint64{client.StandaloneNode: 0}, scan.Cursors, "every node was scanned to the end")
+	assert.Equal(t, 10, scan.Keys)
+
+	// The checkpoint covers every migrated key, none has to be listed
+	assert.Empty(t, engine.resumeState.ProcessedKeys)
+	assert.Equal(t, 9, engine.resumeState.GetProcessedCount())
+}
+
+func TestMigrationEngineChecksKeyRenamesInPasses(t *testing.T) {
+	limit := keyRenameCheckLimit
+	keyRenameCheckLimit = 4
+	defer func() { keyRenameCheckLimit = limit }()
+
+	t.Run("distinct names", func(t *testing.T) {
+		source := newPagingTestClient(20)
+		target := newStringTestClient(nil)
+
+		engine, engineConfig := newCutoverTestEngine(t, source, target)
+		engineConfig.VerifyAfterMigration = false
+		rules, err := transform.ParseRules([]string{"add-prefix=t:"})
+		require.NoError(t, err)
+		engine.keyRules = rules
+
+		require.NoError(t, engine.Migrate())
+		assert.Len(t, target.keys, 20)
+
+		scans := 0
+		for _, cursor := range source.cursors {
+			if cursor == 0 {
+				scans++
+			}
+		}
+		assert.Greater(t, scans, 2, "the names are checked in several passes before the migration scan")
+	})
+
+	t.Run("collision", func(t *testing.T) {
+		source := newPagingTestClient(20)
+		source.keys["x:k17"], source.keyTypes["x:k17"] = "other", "string"
+		target := newStringTestClient(nil)
+
+		engine, _ := newCutoverTestEngine(t, source, target)
+		rules, err := transform.ParseRules([]string{"strip-prefix=x:"})
+		require.NoError(t, err)
+		engine.keyRules = rules
+
+		err = engine.Migrate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "are both renamed to k17")
+		assert.Empty(t, target.keys, "nothing is written before the key rules are checked")
+	})
+}
+
+// repeatingScanClient returns repeat again in its last page, once the keys before it are
+// checkpointed, as SCAN does when the keyspace is resized between pages
+type repeatingScanClient struct {
+	*pagingTestClient
+	repeat string
+	state  func() *ResumeState
+}
+
+func (c *repeatingScanClient) ScanPage(node string, cursor uint64, pattern string, count int64) ([]string, uint64, error) {
+	keys, next, err := c.pagingTestClient.ScanPage(node, cursor, pattern, count)
+	if err != nil || next != 0 || cursor == 0 {
+		return keys, next, err
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if scan := c.state().GetScan(); scan != nil && scan.Cursors[node] == cursor {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return append(keys, c.repeat), next, nil
+}
+
+func TestMigrationEngineMigratesRepeatedKeyOnce(t *testing.T) {
+	source := &repeatingScanClient{pagingTestClient: newPagingTestClient(10), repeat: "k1"}
+	target := newStringTestClient(nil)
+
+	engine, engineConfig := newCutoverTestEngine(t, source, target)
+	engineConfig.BatchSize = 1
+	engineConfig.VerifyAfterMigration = false
+	engineConfig.OnConflict = ConflictFail
+	source.state = func() *ResumeState { return engine.resumeState }
+
+	require.NoError(t, engine.Migrate(), "k1 must not conflict with its own copy")
+	assert.Len(t, target.keys, 10)
+	stats := engine.GetStats()
+	assert.Equal(t, 10, stats.SuccessfulKeys)
+	assert.Zero(t, stats.ConflictKeys)
+	assert.Zero(t, stats.FailedKeys)
+}
+
+func TestLoadResumeState_ProcessedKeysOnly(t *testing.T) {
+	// Files saved before keys were dropped from processed_keys have no processed count
+	path := filepath.Join(t.TempDir(), "resume.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"processed_keys":{"a":true,"b":true},"total_keys":5}`), 0644))
+
+	state, err := loadResumeState(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, state.GetProcessedCount())
+	assert.True(t, state.IsProcessed("a"))
+	assert.Nil(t, state.GetScan())
+}
+
+func TestScanWindow(t *testing.T) {
+	state := NewResumeState()
+	window := newScanWindow(state)
+
+	first := window.open(keyPage{keys: []string{"a", "b"}, node: "n1", cursor: 5, checkpoint: true})
+	require.True(t, window.add(first, "a"))
+	require.True(t, window.add(first, "b"))
+	window.seal(first)
+	second := window.open(keyPage{keys: []string{"c", "a"}, node: "n1", cursor: 9, checkpoint: true})
+	require.True(t, window.add(second, "c"))
+	assert.False(t, window.add(second, "a"), "a key returned twice is migrated once")
+	window.seal(second)
+	other := window.open(keyPage{node: "n2", cursor: 0, checkpoint: true})
+	window.seal(other)
+
+	assert.Equal(t, map[string]uint64{"n2": 0}, state.GetScan().Cursors, "empty pages are complete at once")
+
+	state.MarkProcessed("c")
+	window.done(keyBatch{keys: []string{"c"}, pages: []*pendingPage{second}})
+	assert.Equal(t, map[string]uint64{"n2": 0}, state.GetScan().Cursors, "a page is not complete before the pages ahead of it")
+	assert.True(t, state.IsProcessed("c"), "keys of incomplete pages stay listed")
+
+	state.MarkProcessed("a")
+	state.MarkProcessed("b")
+	window.done(keyBatch{keys: []string{"a", "b"}, pages: []*pendingPage{first, first}})
+	assert.Equal(t, &ScanCheckpoint{Cursors: map[string]uint64{"n1": 9, "n2": 0}, Keys: 4}, state.GetScan())
+	assert.Empty(t, state.ProcessedKeys, "keys behind the checkpoint are dropped")
+	assert.Equal(t, 3, state.GetProcessedCount())
+
+	assert.Nil(t, window.open(keyPage{keys: []string{"retry"}}), "keys outside the scan are not tracked")
+}
+
+func TestMigrationEngineDiscoveryError(t *testing.T) {
+	source := &failingScanClient{pagingTestClient: newPagingTestClient(4)}
+	target := newStringTestClient(nil)
+
+	engine, engineConfig := newCutoverTestEngine(t, source, target)
+	engineConfig.ResumeFile = filepath.Join(t.TempDir(), "resume.json")
+	err := engine.Migrate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "key discovery")
+	assert.Len(t, target.keys, 3, "keys of the pages scanned before the error are migrated")
+}
+
+// failingScanClient fails to scan anything after its first page
+type failingScanClient struct {
+	*pagingTestClient
+}
+
+func (c *failingScanClient) ScanPage(node string, cursor uint64, pattern string, count int64) ([]string, uint64, error) {
+	if cursor > 0 {
+		return nil, 0, fmt.Errorf("permission denied")
+	}
+	return c.pagingTestClient.ScanPage(node, cursor, pattern, count)
+}
@@ -3,6 +3,7 @@ package engine
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,6 +14,7 @@ import (
 	"github.com/kinyelo/redis-valkey-migration/internal/monitor"
 	"github.com/kinyelo/redis-valkey-migration/internal/processor"
 	"github.com/kinyelo/redis-valkey-migration/internal/scanner"
+	"github.com/kinyelo/redis-valkey-migration/internal/transform"
 	"github.com/kinyelo/redis-valkey-migration/internal/verifier"
 	"github.com/kinyelo/redis-valkey-migration/pkg/logger"
 )
@@ -20,7 +22,9 @@ import (
 // MigrationEngine orchestrates the entire migration process with error handling and recovery
 type MigrationEngine struct {
 	sourceClient     *RecoverableClient
+	sourceConfig     *client.ClientConfig
 	targetClient     *RecoverableClient
+	targetConfig     *client.ClientConfig
 	processor        processor.DataProcessor
 	monitor          *monitor.ProgressMonitor
 	verifier         verifier.DataVerifier
@@ -33,6 +37,11 @@ type MigrationEngine struct {
 	recoveryManager  *RecoveryManager
 	resumeState      *ResumeState
 	config           *EngineConfig
+	keyRules         transform.Rules   // Renames source keys on the target
+	fingerprint      ResumeFingerprint // Identifies the migration in the resume state
+	deadLetters      *deadLetterFile   // Records the keys that fail, nil when disabled
+	resumeDone       bool              // Set once the resume file is removed, so it is not saved again
+	replicaGate      *ReplicaLagGate
 	mu               sync.RWMutex
 	ctx              context.Context
 	shutdownComplete chan struct{}
@@ -47,8 +56,38 @@ type EngineConfig struct {
 	MaxConcurrency       int           `json:"max_concurrency"`
 	ProgressInterval     time.Duration `json:"progress_interval"`
 	CollectionPatterns   []string      `json:"collection_patterns"`
+	CopyStreamPending    bool          `json:"copy_stream_pending"`
+	TransferMode         string        `json:"transfer_mode"`
+	OnConflict           string        `json:"on_conflict"`
+	MaxReplicaLag        int64         `json:"max_replica_lag"`
+	CutoverFile          string        `json:"cutover_file"`
+	SyncInterval         time.Duration `json:"sync_interval"`
+	SyncMethod           string        `json:"sync_method"`
+	CutoverPauseTimeout  time.Duration `json:"cutover_pause_timeout"`
+	CutoverResultFile    string        `json:"cutover_result_file"`
+	KeyRules             []string      `json:"key_rules"`
+	ForceResume          bool          `json:"force_resume"`
+	DeadLetterFile       string        `json:"dead_letter_file"`
+
+	// Retry overrides how failed operations are retried, DefaultRetryConfig when nil
+	Retry *RetryConfig `json:"-"`
 }
 
+// Conflict policies for keys that already exist on the target
+const (
+	// ConflictOverwrite replaces the existing key with the source value
+	ConflictOverwrite = "overwrite"
+	// ConflictSkip leaves the existing key untouched
+	ConflictSkip = "skip"
+	// ConflictFail leaves the existing key untouched and counts the key as failed
+	ConflictFail = "fail"
+	// ConflictMerge merges the source value into the existing key
+	ConflictMerge = "merge"
+)
+
+// ErrTargetKeyExists is the cause of key failures under the fail conflict policy
+var ErrTargetKeyExists = errors.New("key already exists on target")
+
 // DefaultEngineConfig returns default engine configuration
 func DefaultEngineConfig() *EngineConfig {
 	return &EngineConfig{
@@ -59,6 +98,15 @@ func DefaultEngineConfig() *EngineConfig {
 		MaxConcurrency:       10,
 		ProgressInterval:     5 * time.Second,
 		CollectionPatterns:   []string{}, // Empty means migrate all keys
+		TransferMode:         processor.TransferModeNative,
+		OnConflict:           ConflictOverwrite,
+		MaxReplicaLag:        1048576,
+		CutoverFile:          "migration.cutover",
+		SyncInterval:         time.Second,
+		SyncMethod:           SyncNotifications,
+		CutoverPauseTimeout:  30 * time.Second,
+		CutoverResultFile:    "migration_cutover.json",
+		DeadLetterFile:       "migration_failed.ndjson",
 	}
 }
 
@@ -77,6 +125,9 @@ func NewMigrationEngine(
 
 	// Create recovery handler
 	retryConfig := DefaultRetryConfig()
+	if config.Retry != nil {
+		retryConfig = *config.Retry
+	}
 	recovery := NewConnectionRecovery(retryConfig, logger)
 
 	// Wrap clients with recovery capabilities
@@ -98,22 +149,44 @@ func NewMigrationEngine(
 	// Use shutdown manager's context
 	ctx := shutdownManager.Context()
 
+	keyRules, err := transform.ParseRules(config.KeyRules)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create components
 	progressMonitor := monitor.NewProgressMonitor(logger)
-	dataProcessor := processor.NewDataProcessor(logger)
-	dataVerifier := verifier.NewDataVerifier(logger)
+	dataProcessor, err := newDataProcessor(config, keyRules, sourceClient, sourceConfig, targetClient, logger)
+	if err != nil {
+		return nil, err
+	}
+	dataVerifier := verifier.NewDataVerifierWithOptions(logger, verifier.Options{RenameKey: renameKey(keyRules)})
 	keyScanner := scanner.NewKeyScanner(logger)
 
-	// Load or create resume state
-	resumeState, err := loadResumeState(config.ResumeFile)
-	if err != nil {
+	// Load or create resume state, refusing to resume a different migration
+	fingerprint := newResumeFingerprint(sourceConfig, targetConfig, config)
+	var resumeState *ResumeState
+	if config.ResumeFile == "" {
+		resumeState = NewResumeState()
+	} else if resumeState, err = loadResumeState(config.ResumeFile); err != nil {
 		logger.Warnf("Could not load resume state: %v. Starting fresh migration.", err)
 		resumeState = NewResumeState()
+	} else {
+		differences, err := checkResumeFingerprint(resumeState.Fingerprint, fingerprint, config.ForceResume, config.ResumeFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, difference := range differences {
+			logger.Warnf("Resuming %s despite a different migration (--force-resume): %s", config.ResumeFile, difference)
+		}
 	}
+	resumeState.Fingerprint = &fingerprint
 
 	engine := &MigrationEngine{
 		sourceClient:     recoverableSource,
+		sourceConfig:     sourceConfig,
 		targetClient:     recoverableTarget,
+		targetConfig:     targetConfig,
 		processor:        dataProcessor,
 		monitor:          progressMonitor,
 		verifier:         dataVerifier,
@@ -126,6 +199,10 @@ func NewMigrationEngine(
 		recoveryManager:  recoveryManager,
 		resumeState:      resumeState,
 		config:           config,
+		keyRules:         keyRules,
+		fingerprint:      fingerprint,
+		deadLetters:      newDeadLetterFile(config.DeadLetterFile, fingerprint.SourceDatabase, fingerprint.TargetDatabase),
+		replicaGate:      NewReplicaLagGate(recoverableSource, config.MaxReplicaLag, replicaLagInterval, logger),
 		ctx:              ctx,
 		shutdownComplete: make(chan struct{}),
 	}
@@ -136,6 +213,73 @@ func NewMigrationEngine(
 	return engine, nil
 }
 
+// renameKey returns the function that renames keys with rules, nil if there are none
+func renameKey(rules transform.Rules) func(key string) string {
+	if len(rules) == 0 {
+		return nil
+	}
+	return rules.Apply
+}
+
+// targetKey returns the name of a source key on the target
+func (me *MigrationEngine) targetKey(key string) string {
+	return me.keyRules.Apply(key)
+}
+
+// newDataProcessor creates the processor for the configured transfer mode
+func newDataProcessor(
+	config *EngineConfig,
+	keyRules transform.Rules,
+	sourceClient client.DatabaseClient,
+	sourceConfig *client.ClientConfig,
+	targetClient client.DatabaseClient,
+	logger logger.Logger,
+) (processor.DataProcessor, error) {
+	options := processor.Options{
+		CopyStreamPending: config.CopyStreamPending,
+		RenameKey:         renameKey(keyRules),
+	}
+	if sourceConfig != nil {
+		// The source's large data threshold decides which collections are transferred in chunks
+		options.TimeoutConfig = sourceConfig.TimeoutConfig
+	}
+	dumpSupported := client.Supports[client.DumpRestorer](sourceClient) && client.Supports[client.DumpRestorer](targetClient)
+
+	switch config.OnConflict {
+	case ConflictOverwrite, ConflictSkip, ConflictFail, "":
+	case ConflictMerge:
+		// RESTORE can only replace keys, so merging needs the type-specific transfer
+		if config.TransferMode == processor.TransferModeDump {
+			return nil, fmt.Errorf("conflict policy %q cannot be used with transfer mode %q", config.OnConflict, config.TransferMode)
+		}
+		options.MergeExisting = true
+		dumpSupported = false
+	default:
+		return nil, fmt.Errorf("invalid conflict policy %q, must be one of: %s, %s, %s, %s",
+			config.OnConflict, ConflictOverwrite, ConflictSkip, ConflictFail, ConflictMerge)
+	}
+
+	switch config.TransferMode {
+	case processor.TransferModeNative, "":
+		return processor.NewDataProcessorWithOptions(logger, options), nil
+	case processor.TransferModeDump:
+		if !dumpSupported {
+			return nil, fmt.Errorf("transfer mode %q requires DUMP/RESTORE support on both clients", config.TransferMode)
+		}
+		return processor.NewDumpRestoreProcessor(logger, options), nil
+	case processor.TransferModeAuto:
+		if dumpSupported {
+			logger.Info("Transfer mode auto: copying keys with DUMP/RESTORE")
+			return processor.NewDumpRestoreProcessor(logger, options), nil
+		}
+		logger.Info("Transfer mode auto: DUMP/RESTORE not supported by both clients, using type-specific transfer")
+		return processor.NewDataProcessorWithOptions(logger, options), nil
+	default:
+		return nil, fmt.Errorf("invalid transfer mode %q, must be one of: %s, %s, %s",
+			config.TransferMode, processor.TransferModeDump, processor.TransferModeNative, processor.TransferModeAuto)
+	}
+}
+
 // Migrate performs the complete migration with error handling and recovery
 func (me *MigrationEngine) Migrate() error {
 	me.logger.Info("Starting Redis to Valkey migration with error handling and recovery")
@@ -151,30 +295,46 @@ func (me *MigrationEngine) Migrate() error {
 		return me.failureHandler.HandleCriticalFailure("database connection", err)
 	}
 
-	// Discover keys to migrate
-	keys, err := me.discoverKeys()
-	if err != nil {
-		return me.failureHandler.HandleCriticalFailure("key discovery", err)
+	// Do not start reading from a replica that is already far behind
+	if err := me.replicaGate.Wait(me.ctx); err != nil {
+		return me.failureHandler.HandleCriticalFailure("replica lag check", err)
+	}
+
+	if err := me.checkKeyRenames(); err != nil {
+		return me.failureHandler.HandleCriticalFailure("key rules", err)
 	}
 
-	// Initialize progress monitoring
-	me.monitor.Initialize(len(keys))
-	me.resumeState.TotalKeys = len(keys)
+	// Keys are migrated while they are being discovered, continuing after the keys an
+	// interrupted run already migrated
+	checkpoint := me.resumeState.GetScan()
+	me.monitor.Initialize(0)
+	me.resumeState.TotalKeys = 0
+	if checkpoint != nil {
+		me.logger.Infof("Resuming key discovery after the %d keys discovered by the previous run", checkpoint.Keys)
+		me.resumeState.TotalKeys = checkpoint.Keys
+	}
+	me.logger.Info("Discovering keys to migrate...")
+	keys := me.discoverKeys(me.ctx, checkpoint, me.resumeState.GetFailedKeys())
 
 	// Start progress reporting
 	go me.startProgressReporting()
 
 	// Perform migration with error handling
-	if err := me.performMigration(keys); err != nil {
-		if IsCritical(err) {
-			return me.failureHandler.HandleCriticalFailure("migration", err)
+	migrationErr := me.performMigration(keys)
+	me.compactDeadLetters()
+	if err := keys.Err(); err != nil {
+		return me.failureHandler.HandleCriticalFailure("key discovery", err)
+	}
+	if migrationErr != nil {
+		if IsCritical(migrationErr) {
+			return me.failureHandler.HandleCriticalFailure("migration", migrationErr)
 		}
-		me.logger.Errorf("Migration completed with errors: %v", err)
+		me.logger.Errorf("Migration completed with errors: %v", migrationErr)
 	}
 
 	// Verify migration if configured
 	if me.config.VerifyAfterMigration {
-		if err := me.verifyMigration(keys); err != nil {
+		if err := me.verifyMigration(me.discoverKeys(me.ctx, nil, nil)); err != nil {
 			me.logger.Errorf("Migration verification failed: %v", err)
 			return err
 		}
@@ -214,89 +374,314 @@ func (me *MigrationEngine) connectDatabases() error {
 	return nil
 }
 
-// discoverKeys discovers keys to migrate based on collection patterns
-func (me *MigrationEngine) discoverKeys() ([]string, error) {
-	me.logger.Info("Discovering keys to migrate...")
+// performMigration migrates the keys of a stream with a bounded pool of workers while
+// they are being discovered, and stops the stream once it returns
+func (me *MigrationEngine) performMigration(keys *keyStream) error {
+	defer keys.Stop()
 
-	var keys []string
-	var err error
+	workers := me.config.MaxConcurrency
+	if workers < 1 {
+		workers = 1
+	}
 
-	if len(me.config.CollectionPatterns) > 0 {
-		me.logger.Infof("Using collection patterns: %v", me.config.CollectionPatterns)
-		keys, err = me.scanner.ScanKeysByPatterns(me.sourceClient, me.config.CollectionPatterns)
-		if err != nil {
-			return nil, WrapError(err, "filtered key discovery")
-		}
-		me.logger.Infof("Discovered %d keys matching patterns", len(keys))
+	// Keys are handed to workers in batches so that pipelining clients can
+	// transfer a whole batch per round trip
+	batchSize := 1
+	if me.batchingEnabled() {
+		batchSize = me.config.BatchSize
+		me.logger.Infof("Starting key migration with %d concurrent workers and pipelined batches of %d keys...", workers, batchSize)
 	} else {
-		me.logger.Info("No collection patterns specified, scanning all keys")
-		keys, err = me.scanner.ScanAllKeys(me.sourceClient)
-		if err != nil {
-			return nil, WrapError(err, "key discovery")
+		me.logger.Infof("Starting key migration with %d concurrent workers...", workers)
+	}
+
+	errorAggregator := NewErrorAggregator()
+	window := newScanWindow(me.resumeState)
+
+	// Workers stop picking up new keys once the migration has to stop, either
+	// because of a shutdown request or because a worker hit a fatal error
+	ctx, cancel := context.WithCancel(me.ctx)
+	defer cancel()
+
+	var stopErr error
+	var stopOnce sync.Once
+	stop := func(err error) {
+		stopOnce.Do(func() {
+			stopErr = err
+			cancel()
+		})
+	}
+
+	batchChan := make(chan keyBatch, workers)
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batchChan {
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := me.replicaGate.Wait(ctx); err != nil {
+					continue
+				}
+				me.migrateWorkerBatch(ctx, batch.keys, errorAggregator, stop)
+
+				// Keys of an interrupted batch may not all have been migrated
+				if ctx.Err() == nil {
+					window.done(batch)
+				}
+			}
+		}()
+	}
+
+	batch := keyBatch{keys: make([]string, 0, batchSize)}
+dispatch:
+	for page := range keys.pages {
+		me.monitor.AddKeys(len(page.keys))
+		me.resumeState.AddTotalKeys(len(page.keys))
+
+		pending := window.open(page)
+		for _, key := range page.keys {
+			// Skip if already processed (resume functionality)
+			if me.resumeState.IsProcessed(key) {
+				me.logger.Debugf("Skipping already processed key: %s", key)
+				continue
+			}
+			if !window.add(pending, key) {
+				continue
+			}
+
+			batch.keys = append(batch.keys, key)
+			batch.pages = append(batch.pages, pending)
+			if len(batch.keys) < batchSize {
+				continue
+			}
+
+			select {
+			case batchChan <- batch:
+				batch = keyBatch{keys: make([]string, 0, batchSize)}
+			case <-ctx.Done():
+				batch = keyBatch{}
+				break dispatch
+			}
+		}
+		window.seal(pending)
+	}
+
+	if len(batch.keys) > 0 {
+		select {
+		case batchChan <- batch:
+		case <-ctx.Done():
 		}
-		me.logger.Infof("Discovered %d keys to migrate", len(keys))
 	}
+	close(batchChan)
+	wg.Wait()
 
-	return keys, nil
+	if stopErr != nil {
+		return stopErr
+	}
+
+	// Check for cancellation
+	if me.ctx.Err() != nil {
+		me.logger.Info("Migration cancelled")
+		return me.ctx.Err()
+	}
+
+	// Return aggregated errors if any
+	if errorAggregator.HasErrors() {
+		return errorAggregator
+	}
+
+	return nil
 }
 
-// performMigration performs the actual migration with error handling
-func (me *MigrationEngine) performMigration(keys []string) error {
-	me.logger.Info("Starting key migration...")
+// batchingEnabled reports whether keys can be migrated in pipelined batches
+func (me *MigrationEngine) batchingEnabled() bool {
+	if me.config.BatchSize <= 1 {
+		return false
+	}
+
+	if _, ok := me.processor.(processor.BatchProcessor); !ok {
+		return false
+	}
 
-	errorAggregator := NewErrorAggregator()
+	return client.Supports[client.BatchClient](me.sourceClient) && client.Supports[client.BatchClient](me.targetClient)
+}
+
+// migrateWorkerBatch migrates a batch of keys on behalf of a worker, falling back
+// to one key at a time when the batch cannot be pipelined
+func (me *MigrationEngine) migrateWorkerBatch(ctx context.Context, keys []string, errorAggregator *ErrorAggregator, stop func(error)) {
+	keys = me.resolveConflicts(keys, errorAggregator, stop)
+	if len(keys) == 0 {
+		return
+	}
+
+	if batchProcessor, ok := me.processor.(processor.BatchProcessor); ok && len(keys) > 1 {
+		errs, err := batchProcessor.ProcessBatch(keys, me.sourceClient, me.targetClient)
+		if err == nil {
+			for i, key := range keys {
+				var keyErr error
+				if errs[i] != nil {
+					keyErr = WrapError(errs[i], "key processing").WithKey(key)
+				}
+				me.recordKeyResult(key, keyErr, errorAggregator, stop)
+			}
+			return
+		}
+
+		me.logger.Warnf("Batch of %d keys could not be pipelined, migrating keys individually: %v", len(keys), err)
+	}
 
 	for _, key := range keys {
-		// Check for cancellation
-		select {
-		case <-me.ctx.Done():
-			me.logger.Info("Migration cancelled")
-			return me.ctx.Err()
-		default:
+		if ctx.Err() != nil {
+			return
 		}
+		me.recordKeyResult(key, me.migrateKey(key), errorAggregator, stop)
+	}
+}
+
+// resolveConflicts checks which keys already exist on the target and applies the conflict
+// policy to them. It returns the keys that should still be written. Existing keys are
+// replaced under the overwrite policy, so nothing is checked then.
+func (me *MigrationEngine) resolveConflicts(keys []string, errorAggregator *ErrorAggregator, stop func(error)) []string {
+	if me.config.OnConflict == ConflictOverwrite || me.config.OnConflict == "" {
+		return keys
+	}
+
+	exists, err := me.targetExists(keys)
+	if err != nil {
+		// Without knowing which keys exist the policy cannot be applied, so none are written
+		for _, key := range keys {
+			me.recordKeyResult(key, WrapError(err, "conflict check").WithKey(key), errorAggregator, stop)
+		}
+		return nil
+	}
 
-		// Skip if already processed (resume functionality)
-		if me.resumeState.IsProcessed(key) {
-			me.logger.Debugf("Skipping already processed key: %s", key)
+	writes := make([]string, 0, len(keys))
+	for i, key := range keys {
+		if !exists[i] {
+			writes = append(writes, key)
 			continue
 		}
 
-		// Migrate individual key with error handling
-		if err := me.migrateKey(key); err != nil {
-			errorAggregator.Add(err)
-			me.monitor.IncrementFailed()
+		me.monitor.IncrementConflicts()
+		if me.config.OnConflict != ConflictFail {
+			// Left out of verification, also after a resume
+			me.resumeState.MarkConflict(key)
+		}
 
-			// Check if error is critical
-			if IsCritical(err) {
-				return err
-			}
+		switch me.config.OnConflict {
+		case ConflictSkip:
+			me.logger.Debugf("Key '%s' already exists on target. Skipping migration.", key)
+			me.markProcessed(key)
+			me.monitor.IncrementSkipped()
+		case ConflictFail:
+			err := NewMigrationError(DataError, "conflict check", ErrTargetKeyExists.Error()).
+				WithKey(key).
+				WithCause(ErrTargetKeyExists)
+			me.recordKeyResult(key, err, errorAggregator, stop)
+		default:
+			writes = append(writes, key)
+		}
+	}
 
-			// Continue or stop based on configuration
-			if !me.config.ContinueOnError {
-				return errorAggregator
-			}
+	return writes
+}
 
-			me.logger.Warnf("Continuing migration despite error for key %s: %v", key, err)
-		} else {
-			// Mark key as processed for resume functionality only on successful migration
-			me.resumeState.MarkProcessed(key)
-			me.monitor.IncrementProcessed()
+// targetExists reports which keys exist on the target, in a single round trip if possible
+func (me *MigrationEngine) targetExists(keys []string) ([]bool, error) {
+	if len(me.keyRules) > 0 {
+		targetKeys := make([]string, len(keys))
+		for i, key := range keys {
+			targetKeys[i] = me.targetKey(key)
 		}
+		keys = targetKeys
+	}
 
-		// Save resume state periodically
-		if me.resumeState.GetProcessedCount()%100 == 0 {
-			if err := me.saveResumeState(); err != nil {
-				me.logger.Warnf("Failed to save resume state: %v", err)
-			}
+	if len(keys) > 1 && client.Supports[client.BatchClient](me.targetClient) {
+		return me.targetClient.ExistsBatch(keys)
+	}
+
+	exists := make([]bool, len(keys))
+	for i, key := range keys {
+		found, err := me.targetClient.Exists(key)
+		if err != nil {
+			return nil, err
 		}
+		exists[i] = found
 	}
+	return exists, nil
+}
 
-	// Return aggregated errors if any
-	if errorAggregator.HasErrors() {
-		return errorAggregator
+// recordKeyResult records the outcome of migrating a single key
+func (me *MigrationEngine) recordKeyResult(key string, err error, errorAggregator *ErrorAggregator, stop func(error)) {
+	if err != nil {
+		errorAggregator.Add(err)
+		me.monitor.IncrementFailed()
+		me.resumeState.MarkFailed(key)
+		me.recordDeadLetter(key, err)
+
+		// Check if error is critical
+		if IsCritical(err) {
+			stop(err)
+			return
+		}
+
+		// Continue or stop based on configuration
+		if !me.config.ContinueOnError {
+			stop(errorAggregator)
+			return
+		}
+
+		me.logger.Warnf("Continuing migration despite error for key %s: %v", key, err)
+		return
 	}
 
-	return nil
+	// Mark key as processed for resume functionality only on successful migration
+	me.markProcessed(key)
+	me.monitor.IncrementProcessed()
+}
+
+// recordDeadLetter records a failed key in the dead-letter file
+func (me *MigrationEngine) recordDeadLetter(key string, err error) {
+	letter := newDeadLetter(key, err)
+	if targetKey := me.targetKey(key); targetKey != key {
+		letter.TargetKey = targetKey
+	}
+	if err := me.deadLetters.Add(letter); err != nil {
+		me.logger.Warnf("Failed to record failed key %s: %v", key, err)
+	}
+}
+
+// compactDeadLetters rewrites the dead-letter file to list the keys that are still
+// failing once the workers have stopped
+func (me *MigrationEngine) compactDeadLetters() {
+	failed := make(map[string]bool)
+	for _, key := range me.resumeState.GetFailedKeys() {
+		failed[key] = true
+	}
+
+	remaining, err := me.deadLetters.Compact(failed)
+	if err != nil {
+		me.logger.Warnf("Failed to compact dead-letter file: %v", err)
+		return
+	}
+	if remaining > 0 {
+		me.logger.Warnf("%d failed keys are listed in %s, migrate them again with retry-failed", remaining, me.config.DeadLetterFile)
+	}
+}
+
+// markProcessed marks a key as processed in the resume state, saving it periodically
+func (me *MigrationEngine) markProcessed(key string) {
+	processed := me.resumeState.MarkProcessed(key)
+
+	// Save resume state periodically
+	if processed%100 == 0 {
+		if err := me.saveResumeState(); err != nil {
+			me.logger.Warnf("Failed to save resume state: %v", err)
+		}
+	}
 }
 
 // migrateKey migrates a single key with error handling
@@ -317,27 +702,39 @@ func (me *MigrationEngine) migrateKey(key string) error {
 	return nil
 }
 
-// verifyMigration verifies the migration results
-func (me *MigrationEngine) verifyMigration(keys []string) error {
+// verifyMigration verifies the migration results of the keys of a stream, which
+// discovers them again so that they do not have to be kept in memory
+func (me *MigrationEngine) verifyMigration(keys *keyStream) error {
 	me.logger.Info("Verifying migration results...")
 
+	// Compare the target against the primary, not a replica that may still be behind
+	me.sourceClient.UseReplica(false)
+
 	errorAggregator := NewErrorAggregator()
 
-	for _, key := range keys {
-		result := me.verifier.VerifyKey(key, me.sourceClient, me.targetClient)
-		if !result.Success {
-			var errorMsg string
-			if result.ErrorMsg != "" {
-				errorMsg = result.ErrorMsg
-			} else if len(result.Mismatches) > 0 {
-				errorMsg = fmt.Sprintf("data mismatches found: %v", result.Mismatches)
-			} else {
-				errorMsg = "verification failed for unknown reason"
+	// Keys that were skipped or merged into existing keys are not expected to match the source
+	skipVerify := me.config.OnConflict == ConflictSkip || me.config.OnConflict == ConflictMerge
+	conflicts := me.resumeState.GetConflictCount()
+	if skipVerify && conflicts > 0 {
+		me.logger.Infof("Not verifying %d keys that already existed on target (conflict policy: %s)", conflicts, me.config.OnConflict)
+	}
+
+	defer keys.Stop()
+	for page := range keys.pages {
+		for _, key := range page.keys {
+			if skipVerify && me.resumeState.IsConflict(key) {
+				continue
+			}
+
+			result := me.verifier.VerifyKey(key, me.sourceClient, me.targetClient)
+			if !result.Success {
+				errorAggregator.Add(WrapError(verificationError(result), "verification").WithKey(key))
 			}
-			err := fmt.Errorf("verification failed: %s", errorMsg)
-			errorAggregator.Add(WrapError(err, "verification").WithKey(key))
 		}
 	}
+	if err := keys.Err(); err != nil {
+		return WrapError(err, "verification")
+	}
 
 	if errorAggregator.HasErrors() {
 		return errorAggregator
@@ -347,6 +744,19 @@ func (me *MigrationEngine) verifyMigration(keys []string) error {
 	return nil
 }
 
+// verificationError describes why a key failed verification
+func verificationError(result verifier.VerificationResult) error {
+	var errorMsg string
+	if result.ErrorMsg != "" {
+		errorMsg = result.ErrorMsg
+	} else if len(result.Mismatches) > 0 {
+		errorMsg = fmt.Sprintf("data mismatches found: %v", result.Mismatches)
+	} else {
+		errorMsg = "verification failed for unknown reason"
+	}
+	return fmt.Errorf("verification failed: %s", errorMsg)
+}
+
 // startProgressReporting starts periodic progress reporting
 func (me *MigrationEngine) startProgressReporting() {
 	ticker := time.NewTicker(me.config.ProgressInterval)
@@ -368,6 +778,11 @@ func (me *MigrationEngine) saveResumeState() error {
 	me.mu.Lock()
 	defer me.mu.Unlock()
 
+	// A completed migration has nothing left to resume
+	if me.resumeDone || me.config.ResumeFile == "" {
+		return nil
+	}
+
 	data, err := json.Marshal(me.resumeState)
 	if err != nil {
 		return fmt.Errorf("failed to marshal resume state: %w", err)
@@ -392,6 +807,14 @@ func (me *MigrationEngine) saveResumeState() error {
 	return nil
 }
 
+// newResumeState creates an empty resume state for the migration
+func (me *MigrationEngine) newResumeState() *ResumeState {
+	state := NewResumeState()
+	fingerprint := me.fingerprint
+	state.Fingerprint = &fingerprint
+	return state
+}
+
 // loadResumeState loads resume state from disk
 func loadResumeState(filename string) (*ResumeState, error) {
 	data, err := os.ReadFile(filename)
@@ -403,12 +826,27 @@ func loadResumeState(filename string) (*ResumeState, error) {
 	if err := json.Unmarshal(data, &state); err != nil {
 		return nil, err
 	}
+	if state.ProcessedKeys == nil {
+		state.ProcessedKeys = make(map[string]bool)
+	}
+	state.indexCheckpointed()
+	// Files saved before keys were dropped from ProcessedKeys do not count them separately
+	if state.Processed < len(state.ProcessedKeys) {
+		state.Processed = len(state.ProcessedKeys)
+	}
 
 	return &state, nil
 }
 
 // cleanupResumeState removes the resume state file after successful completion
 func (me *MigrationEngine) cleanupResumeState() {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+
+	me.resumeDone = true
+	if me.config.ResumeFile == "" {
+		return
+	}
 	if err := os.Remove(me.config.ResumeFile); err != nil && !os.IsNotExist(err) {
 		me.logger.Warnf("Failed to remove resume state file: %v", err)
 	}
@@ -425,6 +863,10 @@ func (me *MigrationEngine) cleanup() error {
 		errors = append(errors, fmt.Errorf("failed to save resume state: %w", err))
 	}
 
+	if err := me.deadLetters.Close(); err != nil {
+		errors = append(errors, fmt.Errorf("failed to close dead-letter file: %w", err))
+	}
+
 	// Disconnect from databases
 	if err := me.sourceClient.Disconnect(); err != nil {
 		errors = append(errors, fmt.Errorf("failed to disconnect from source: %w", err))
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 )
 
 // ErrorType represents different categories of errors
@@ -259,7 +260,9 @@ func IsCritical(err error) bool {
 }
 
 // ErrorAggregator collects and manages multiple errors
+// It is safe for concurrent use by multiple migration workers
 type ErrorAggregator struct {
+	mu     sync.RWMutex
 	errors []error
 }
 
@@ -273,27 +276,40 @@ func NewErrorAggregator() *ErrorAggregator {
 // Add adds an error to the aggregator
 func (ea *ErrorAggregator) Add(err error) {
 	if err != nil {
+		ea.mu.Lock()
 		ea.errors = append(ea.errors, err)
+		ea.mu.Unlock()
 	}
 }
 
 // HasErrors returns true if there are any errors
 func (ea *ErrorAggregator) HasErrors() bool {
+	ea.mu.RLock()
+	defer ea.mu.RUnlock()
 	return len(ea.errors) > 0
 }
 
 // Count returns the number of errors
 func (ea *ErrorAggregator) Count() int {
+	ea.mu.RLock()
+	defer ea.mu.RUnlock()
 	return len(ea.errors)
 }
 
 // Errors returns all collected errors
 func (ea *ErrorAggregator) Errors() []error {
-	return ea.errors
+	ea.mu.RLock()
+	defer ea.mu.RUnlock()
+	errs := make([]error, len(ea.errors))
+	copy(errs, ea.errors)
+	return errs
 }
 
 // Error returns a combined error message
 func (ea *ErrorAggregator) Error() string {
+	ea.mu.RLock()
+	defer ea.mu.RUnlock()
+
 	if len(ea.errors) == 0 {
 		return ""
 	}
@@ -312,6 +328,9 @@ func (ea *ErrorAggregator) Error() string {
 
 // HasCriticalErrors checks if any collected errors are critical
 func (ea *ErrorAggregator) HasCriticalErrors() bool {
+	ea.mu.RLock()
+	defer ea.mu.RUnlock()
+
 	for _, err := range ea.errors {
 		if IsCritical(err) {
 			return true
@@ -322,6 +341,9 @@ func (ea *ErrorAggregator) HasCriticalErrors() bool {
 
 // GetCriticalErrors returns only the critical errors
 func (ea *ErrorAggregator) GetCriticalErrors() []error {
+	ea.mu.RLock()
+	defer ea.mu.RUnlock()
+
 	var critical []error
 	for _, err := range ea.errors {
 		if IsCritical(err) {
@@ -333,5 +355,7 @@ func (ea *ErrorAggregator) GetCriticalErrors() []error {
 
 // Clear removes all collected errors
 func (ea *ErrorAggregator) Clear() {
+	ea.mu.Lock()
+	defer ea.mu.Unlock()
 	ea.errors = ea.errors[:0]
 }
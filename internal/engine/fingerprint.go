@@ -0,0 +1,184 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+	"github.com/kinyelo/redis-valkey-migration/internal/version"
+)
+
+// ResumeFingerprint identifies the migration a resume state was saved by, so that a
+// resume file is not used to resume a different migration, whose keys it would skip
+type ResumeFingerprint struct {
+	Source         string   `json:"source"`
+	SourceDatabase int      `json:"source_database"`
+	Target         string   `json:"target"`
+	TargetDatabase int      `json:"target_database"`
+	DatabaseMap    []string `json:"database_map,omitempty"`
+	Patterns       []string `json:"patterns,omitempty"`
+	KeyRules       []string `json:"key_rules,omitempty"`
+	Version        string   `json:"version"`
+}
+
+// newResumeFingerprint returns the fingerprint of a migration. Patterns are sorted, as
+// their order does not change the keys migrated; the order of key rules does.
+func newResumeFingerprint(sourceConfig, targetConfig *client.ClientConfig, config *EngineConfig) ResumeFingerprint {
+	fingerprint := ResumeFingerprint{
+		Patterns: slices.Clone(config.CollectionPatterns),
+		KeyRules: slices.Clone(config.KeyRules),
+		Version:  version.Version,
+	}
+	sort.Strings(fingerprint.Patterns)
+
+	if sourceConfig != nil {
+		fingerprint.Source = describeEndpoint(sourceConfig)
+		fingerprint.SourceDatabase = sourceConfig.Database
+	}
+	if targetConfig != nil {
+		fingerprint.Target = describeEndpoint(targetConfig)
+		fingerprint.TargetDatabase = targetConfig.Database
+	}
+	return fingerprint
+}
+
+// NewDatabasesFingerprint returns the fingerprint of a multi-database migration, which
+// names no database of its own but the database map, written as source->target in order
+// of the source database
+func NewDatabasesFingerprint(sourceConfig, targetConfig *client.ClientConfig, databaseMap map[int]int, config *EngineConfig) ResumeFingerprint {
+	fingerprint := newResumeFingerprint(sourceConfig, targetConfig, config)
+	fingerprint.SourceDatabase, fingerprint.TargetDatabase = 0, 0
+
+	sources := make([]int, 0, len(databaseMap))
+	for source := range databaseMap {
+		sources = append(sources, source)
+	}
+	sort.Ints(sources)
+	for _, source := range sources {
+		fingerprint.DatabaseMap = append(fingerprint.DatabaseMap, fmt.Sprintf("%d->%d", source, databaseMap[source]))
+	}
+	return fingerprint
+}
+
+// describeEndpoint names the server or file a client config points at
+func describeEndpoint(config *client.ClientConfig) string {
+	switch {
+	case config.CommandFile != "":
+		return config.CommandFile
+	case config.SentinelMaster != "":
+		return "sentinel:" + config.SentinelMaster
+	default:
+		return describeSource(config)
+	}
+}
+
+// Differences lists how a fingerprint differs from the one a resume state was saved with
+func (f ResumeFingerprint) Differences(saved ResumeFingerprint) []string {
+	var differences []string
+	compare := func(name, current, saved string) {
+		if current != saved {
+			differences = append(differences, fmt.Sprintf("%s is %q, the resume file has %q", name, current, saved))
+		}
+	}
+	// Lists are compared element by element, as the elements may contain commas
+	compareLists := func(name string, current, saved []string) {
+		if !slices.Equal(current, saved) {
+			differences = append(differences, fmt.Sprintf("%s is %q, the resume file has %q",
+				name, strings.Join(current, ","), strings.Join(saved, ",")))
+		}
+	}
+
+	compare("source", f.Source, saved.Source)
+	compare("source database", fmt.Sprint(f.SourceDatabase), fmt.Sprint(saved.SourceDatabase))
+	compare("target", f.Target, saved.Target)
+	compare("target database", fmt.Sprint(f.TargetDatabase), fmt.Sprint(saved.TargetDatabase))
+	compareLists("database map", f.DatabaseMap, saved.DatabaseMap)
+	compareLists("patterns", f.Patterns, saved.Patterns)
+	compareLists("key rules", f.KeyRules, saved.KeyRules)
+	compare("version", f.Version, saved.Version)
+	return differences
+}
+
+// checkResumeFingerprint fails unless a loaded resume file was saved, with the saved
+// fingerprint, by the same migration, or force is set
+func checkResumeFingerprint(saved *ResumeFingerprint, fingerprint ResumeFingerprint, force bool, resumeFile string) ([]string, error) {
+	var differences []string
+	if saved == nil {
+		differences = []string{"the resume file has no fingerprint, it was saved by an older version"}
+	} else {
+		differences = fingerprint.Differences(*saved)
+	}
+	if len(differences) == 0 || force {
+		return differences, nil
+	}
+
+	return nil, NewMigrationError(ConfigurationError, "resume",
+		fmt.Sprintf("resume file %s was saved by a different migration: %s; remove it to start over, or use --force-resume to resume it anyway",
+			resumeFile, strings.Join(differences, "; ")))
+}
+
+// ResumeSummary describes the contents of a resume file
+type ResumeSummary struct {
+	Fingerprint *ResumeFingerprint `json:"fingerprint,omitempty"`
+	StartTime   *time.Time         `json:"start_time,omitempty"`
+	UpdatedAt   *time.Time         `json:"updated_at,omitempty"`
+	LastKey     string             `json:"last_key,omitempty"`
+	TotalKeys   int                `json:"total_keys"`
+	Processed   int                `json:"processed_keys"`
+
+	// PendingKeys is the number of migrated keys listed because the scan checkpoint has
+	// not moved past them yet
+	PendingKeys int                         `json:"pending_keys"`
+	FailedKeys  []string                    `json:"failed_keys,omitempty"`
+	Scan        *ScanCheckpoint             `json:"scan,omitempty"`
+	Replication *client.ReplicationPosition `json:"replication,omitempty"`
+
+	// Databases are the databases completed by a multi-database migration, whose resume
+	// file records nothing else
+	Databases []DatabaseResult `json:"completed_databases,omitempty"`
+}
+
+// InspectResumeFile reads a resume file saved by a migration, or by a multi-database
+// migration, and describes what it contains
+func InspectResumeFile(filename string) (*ResumeSummary, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var databases databasesState
+	if err := json.Unmarshal(data, &databases); err != nil {
+		return nil, fmt.Errorf("failed to parse resume file %s: %w", filename, err)
+	}
+	if databases.Completed != nil {
+		summary := &ResumeSummary{Fingerprint: databases.Fingerprint, UpdatedAt: &databases.UpdatedAt}
+		for _, result := range databases.Completed {
+			summary.Databases = append(summary.Databases, result)
+		}
+		sort.Slice(summary.Databases, func(i, j int) bool {
+			return summary.Databases[i].Source < summary.Databases[j].Source
+		})
+		return summary, nil
+	}
+
+	state, err := loadResumeState(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse resume file %s: %w", filename, err)
+	}
+	return &ResumeSummary{
+		Fingerprint: state.Fingerprint,
+		StartTime:   &state.StartTime,
+		LastKey:     state.LastKey,
+		TotalKeys:   state.TotalKeys,
+		Processed:   state.GetProcessedCount(),
+		PendingKeys: len(state.ProcessedKeys),
+		FailedKeys:  state.GetFailedKeys(),
+		Scan:        state.GetScan(),
+		Replication: state.GetReplication(),
+	}, nil
+}
@@ -0,0 +1,201 @@
+package engine
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+	"github.com/kinyelo/redis-valkey-migration/internal/monitor"
+	"github.com/kinyelo/redis-valkey-migration/internal/version"
+	"github.com/kinyelo/redis-valkey-migration/pkg/logger"
+)
+
+// newFingerprintTestEngine creates an engine migrating from sourceHost, resuming from
+// the resume file in dir
+func newFingerprintTestEngine(t *testing.T, dir, sourceHost string, force bool) (*MigrationEngine, error) {
+	t.Helper()
+	log, err := logger.NewLogger(logger.Config{Level: "error", OutputFile: filepath.Join(dir, "migration.log"), Format: "text"})
+	require.NoError(t, err)
+
+	engineConfig := DefaultEngineConfig()
+	engineConfig.ResumeFile = filepath.Join(dir, "resume.json")
+	engineConfig.CollectionPatterns = []string{"user:*", "order:*"}
+	engineConfig.ForceResume = force
+
+	return NewMigrationEngine(
+		newStringTestClient(nil),
+		&client.ClientConfig{Host: sourceHost, Port: 6379, Database: 2},
+		newStringTestClient(nil),
+		&client.ClientConfig{Host: "valkey", Port: 6380},
+		log,
+		engineConfig,
+	)
+}
+
+func writeResumeState(t *testing.T, path string, state *ResumeState) {
+	t.Helper()
+	data, err := json.Marshal(state)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0644))
+}
+
+func TestNewResumeFingerprint(t *testing.T) {
+	engineConfig := DefaultEngineConfig()
+	engineConfig.CollectionPatterns = []string{"user:*", "order:*"}
+	engineConfig.KeyRules = []string{"strip-prefix=app:", "add-prefix=t:"}
+
+	fingerprint := newResumeFingerprint(
+		&client.ClientConfig{Host: "redis", Port: 6379, Database: 2},
+		&client.ClientConfig{Host: "valkey", Port: 6380, Database: 1},
+		engineConfig,
+	)
+	assert.Equal(t, ResumeFingerprint{
+		Source:         "redis:6379",
+		SourceDatabase: 2,
+		Target:         "valkey:6380",
+		TargetDatabase: 1,
+		Patterns:       []string{"order:*", "user:*"},
+		KeyRules:       []string{"strip-prefix=app:", "add-prefix=t:"},
+		Version:        version.Version,
+	}, fingerprint)
+	assert.Equal(t, []string{"user:*", "order:*"}, engineConfig.CollectionPatterns, "the config is not reordered")
+
+	fileFingerprint := newResumeFingerprint(&client.ClientConfig{RDBFile: "dump.rdb"}, &client.ClientConfig{CommandFile: "out.resp"}, engineConfig)
+	assert.Equal(t, "dump.rdb", fileFingerprint.Source)
+	assert.Equal(t, "out.resp", fileFingerprint.Target)
+
+	assert.Empty(t, fingerprint.Differences(fingerprint))
+	other := fingerprint
+	other.SourceDatabase = 3
+	other.KeyRules = []string{"add-prefix=t:", "strip-prefix=app:"}
+	assert.Equal(t, []string{
+		`source database is "2", the resume file has "3"`,
+		`key rules is "strip-prefix=app:,add-prefix=t:", the resume file has "add-prefix=t:,strip-prefix=app:"`,
+	}, fingerprint.Differences(other))
+
+	// Patterns may contain commas, so their lists differ even when joined alike
+	joined := fingerprint
+	joined.Patterns = []string{"order:*,user:*"}
+	assert.Equal(t, []string{
+		`patterns is "order:*,user:*", the resume file has "order:*,user:*"`,
+	}, fingerprint.Differences(joined))
+}
+
+func TestNewDatabasesFingerprint(t *testing.T) {
+	engineConfig := DefaultEngineConfig()
+	engineConfig.CollectionPatterns = []string{"user:*"}
+
+	fingerprint := NewDatabasesFingerprint(
+		&client.ClientConfig{Host: "redis", Port: 6379, Database: 2},
+		&client.ClientConfig{Host: "valkey", Port: 6380},
+		map[int]int{10: 0, 3: 1, 4: 1},
+		engineConfig,
+	)
+	assert.Equal(t, ResumeFingerprint{
+		Source:      "redis:6379",
+		Target:      "valkey:6380",
+		DatabaseMap: []string{"3->1", "4->1", "10->0"},
+		Patterns:    []string{"user:*"},
+		Version:     version.Version,
+	}, fingerprint)
+
+	other := fingerprint
+	other.DatabaseMap = []string{"3->1"}
+	assert.Equal(t, []string{
+		`database map is "3->1,4->1,10->0", the resume file has "3->1"`,
+	}, fingerprint.Differences(other))
+}
+
+func TestNewMigrationEngine_ResumeFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	engine, err := newFingerprintTestEngine(t, dir, "redis", false)
+	require.NoError(t, err)
+	engine.resumeState.MarkProcessed("user:1")
+	require.NoError(t, engine.saveResumeState())
+
+	// The same migration resumes
+	engine, err = newFingerprintTestEngine(t, dir, "redis", false)
+	require.NoError(t, err)
+	assert.True(t, engine.resumeState.IsProcessed("user:1"))
+
+	// A different source is refused
+	_, err = newFingerprintTestEngine(t, dir, "other-redis", false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `source is "other-redis:6379", the resume file has "redis:6379"`)
+	assert.Contains(t, err.Error(), "--force-resume")
+
+	// unless forced, which takes over the resume file
+	engine, err = newFingerprintTestEngine(t, dir, "other-redis", true)
+	require.NoError(t, err)
+	assert.True(t, engine.resumeState.IsProcessed("user:1"))
+	assert.Equal(t, "other-redis:6379", engine.resumeState.Fingerprint.Source)
+}
+
+func TestNewMigrationEngine_ResumeWithoutFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	state := NewResumeState()
+	state.MarkProcessed("user:1")
+	writeResumeState(t, filepath.Join(dir, "resume.json"), state)
+
+	_, err := newFingerprintTestEngine(t, dir, "redis", false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no fingerprint")
+
+	engine, err := newFingerprintTestEngine(t, dir, "redis", true)
+	require.NoError(t, err)
+	assert.True(t, engine.resumeState.IsProcessed("user:1"))
+	assert.NotNil(t, engine.resumeState.Fingerprint)
+}
+
+func TestInspectResumeFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "resume.json")
+
+	fingerprint := ResumeFingerprint{Source: "redis:6379", Target: "valkey:6380", Version: "1.0.0"}
+	state := NewResumeState()
+	state.Fingerprint = &fingerprint
+	state.TotalKeys = 12
+	state.MarkProcessed("a")
+	state.MarkProcessed("b")
+	state.MarkFailed("c")
+	state.AdvanceScan(ScanCheckpoint{Cursors: map[string]uint64{client.StandaloneNode: 42}, Keys: 10}, []string{"a"})
+	writeResumeState(t, path, state)
+
+	summary, err := InspectResumeFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, &fingerprint, summary.Fingerprint)
+	assert.Equal(t, 12, summary.TotalKeys)
+	assert.Equal(t, 2, summary.Processed)
+	assert.Equal(t, 1, summary.PendingKeys)
+	assert.Equal(t, []string{"c"}, summary.FailedKeys)
+	assert.Equal(t, uint64(42), summary.Scan.Cursors[client.StandaloneNode])
+	assert.Empty(t, summary.Databases)
+
+	_, err = InspectResumeFile(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestInspectResumeFile_Databases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resume.json")
+	state := &databasesState{Completed: map[string]DatabaseResult{
+		"3": {DatabaseMapping: DatabaseMapping{Source: 3, Target: 1, Keys: 5}, Stats: monitor.MigrationStats{ProcessedKeys: 5}},
+		"0": {DatabaseMapping: DatabaseMapping{Source: 0, Target: 0, Keys: 2}},
+	}, UpdatedAt: time.Now()}
+	data, err := json.Marshal(state)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0644))
+
+	summary, err := InspectResumeFile(path)
+	require.NoError(t, err)
+	require.Len(t, summary.Databases, 2)
+	assert.Equal(t, 0, summary.Databases[0].Source)
+	assert.Equal(t, 1, summary.Databases[1].Target)
+	assert.Equal(t, 5, summary.Databases[1].Stats.ProcessedKeys)
+	assert.Nil(t, summary.Fingerprint)
+}
@@ -1,12 +1,16 @@
 package engine
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/kinyelo/redis-valkey-migration/internal/client"
+	"github.com/kinyelo/redis-valkey-migration/internal/processor"
 	"github.com/kinyelo/redis-valkey-migration/pkg/logger"
 
 	"github.com/redis/go-redis/v9"
@@ -240,8 +244,441 @@ func TestMigrationEngineProgressReporting(t *testing.T) {
 	os.Remove("test_progress_resume.json")
 }
 
+// TestMigrationEngineConcurrentWorkers tests that the worker pool migrates every key exactly once
+func TestMigrationEngineConcurrentWorkers(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	logConfig := logger.Config{
+		Level:      "warn",
+		OutputFile: "test_concurrent_migration.log",
+		Format:     "text",
+	}
+	log, err := logger.NewLogger(logConfig)
+	require.NoError(t, err)
+
+	sourceClient := &IntegrationTestClient{
+		keys:      make(map[string]interface{}),
+		keyTypes:  make(map[string]string),
+		connected: false,
+	}
+
+	for i := 0; i < 500; i++ {
+		key := fmt.Sprintf("concurrent:key:%d", i)
+		sourceClient.keys[key] = fmt.Sprintf("value%d", i)
+		sourceClient.keyTypes[key] = "string"
+	}
+
+	targetClient := &IntegrationTestClient{
+		keys:      make(map[string]interface{}),
+		keyTypes:  make(map[string]string),
+		connected: false,
+	}
+
+	engineConfig := &EngineConfig{
+		BatchSize:            50,
+		ResumeFile:           "test_concurrent_resume.json",
+		VerifyAfterMigration: true,
+		ContinueOnError:      true,
+		MaxConcurrency:       16,
+		ProgressInterval:     1 * time.Second,
+	}
+
+	engine, err := NewMigrationEngine(
+		sourceClient,
+		&client.ClientConfig{Host: "localhost", Port: 6379, Database: 0},
+		targetClient,
+		&client.ClientConfig{Host: "localhost", Port: 6380, Database: 0},
+		log,
+		engineConfig,
+	)
+	require.NoError(t, err)
+
+	err = engine.Migrate()
+	require.NoError(t, err)
+
+	assert.Equal(t, 500, len(targetClient.keys))
+
+	stats := engine.GetStats()
+	assert.Equal(t, 500, stats.ProcessedKeys)
+	assert.Equal(t, 500, stats.SuccessfulKeys)
+	assert.Equal(t, 0, stats.FailedKeys)
+
+	os.Remove("test_concurrent_migration.log")
+	os.Remove("test_concurrent_resume.json")
+}
+
+// TestPerformMigrationStopsOnCancellation tests that workers stop picking up keys after shutdown
+func TestPerformMigrationStopsOnCancellation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	logConfig := logger.Config{
+		Level:      "warn",
+		OutputFile: "test_cancel_migration.log",
+		Format:     "text",
+	}
+	log, err := logger.NewLogger(logConfig)
+	require.NoError(t, err)
+
+	sourceClient := &IntegrationTestClient{
+		keys:      make(map[string]interface{}),
+		keyTypes:  make(map[string]string),
+		connected: true,
+	}
+
+	keys := make([]string, 0, 100)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("cancel:key:%d", i)
+		sourceClient.keys[key] = "value"
+		sourceClient.keyTypes[key] = "string"
+		keys = append(keys, key)
+	}
+
+	targetClient := &IntegrationTestClient{
+		keys:      make(map[string]interface{}),
+		keyTypes:  make(map[string]string),
+		connected: true,
+	}
+
+	engine, err := NewMigrationEngine(
+		sourceClient,
+		&client.ClientConfig{Host: "localhost", Port: 6379, Database: 0},
+		targetClient,
+		&client.ClientConfig{Host: "localhost", Port: 6380, Database: 0},
+		log,
+		&EngineConfig{
+			ResumeFile:      "test_cancel_resume.json",
+			ContinueOnError: true,
+			MaxConcurrency:  4,
+		},
+	)
+	require.NoError(t, err)
+
+	// Simulate a shutdown request before any key is dispatched
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	engine.ctx = ctx
+
+	err = engine.performMigration(testKeyStream(keys))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, len(targetClient.keys))
+
+	os.Remove("test_cancel_migration.log")
+	os.Remove("test_cancel_resume.json")
+}
+
+// TestMigrationEngineBatchedTransfer tests that pipelining clients receive keys in batches of BatchSize
+func TestMigrationEngineBatchedTransfer(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	logConfig := logger.Config{
+		Level:      "warn",
+		OutputFile: "test_batch_migration.log",
+		Format:     "text",
+	}
+	log, err := logger.NewLogger(logConfig)
+	require.NoError(t, err)
+
+	sourceClient := &BatchIntegrationTestClient{IntegrationTestClient: &IntegrationTestClient{
+		keys:     make(map[string]interface{}),
+		keyTypes: make(map[string]string),
+	}}
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("batch:key:%d", i)
+		sourceClient.keys[key] = fmt.Sprintf("value%d", i)
+		sourceClient.keyTypes[key] = "string"
+	}
+
+	targetClient := &BatchIntegrationTestClient{IntegrationTestClient: &IntegrationTestClient{
+		keys:     make(map[string]interface{}),
+		keyTypes: make(map[string]string),
+	}}
+
+	engineConfig := &EngineConfig{
+		BatchSize:            25,
+		ResumeFile:           "test_batch_resume.json",
+		VerifyAfterMigration: true,
+		ContinueOnError:      true,
+		MaxConcurrency:       3,
+		ProgressInterval:     1 * time.Second,
+	}
+
+	engine, err := NewMigrationEngine(
+		sourceClient,
+		&client.ClientConfig{Host: "localhost", Port: 6379, Database: 0},
+		targetClient,
+		&client.ClientConfig{Host: "localhost", Port: 6380, Database: 0},
+		log,
+		engineConfig,
+	)
+	require.NoError(t, err)
+
+	err = engine.Migrate()
+	require.NoError(t, err)
+
+	assert.Equal(t, 100, len(targetClient.keys))
+	assert.Equal(t, 4, sourceClient.BatchCalls())
+	assert.Equal(t, 4, targetClient.BatchCalls())
+
+	stats := engine.GetStats()
+	assert.Equal(t, 100, stats.SuccessfulKeys)
+	assert.Equal(t, 0, stats.FailedKeys)
+
+	os.Remove("test_batch_migration.log")
+	os.Remove("test_batch_resume.json")
+}
+
+// TestMigrationEngineCommandFileTarget tests a migration written to a RESP command file
+func TestMigrationEngineCommandFileTarget(t *testing.T) {
+	dir := t.TempDir()
+	commandFile := filepath.Join(dir, "migration.resp")
+	source := newStringTestClient(map[string]string{"a": "1", "b": "2"})
+	target := client.NewRESPFileClient(&client.ClientConfig{CommandFile: commandFile})
+
+	engine, engineConfig := newCutoverTestEngine(t, source, target)
+	engineConfig.VerifyAfterMigration = false
+	require.NoError(t, engine.Migrate())
+
+	stats := engine.GetStats()
+	assert.Equal(t, 2, stats.SuccessfulKeys)
+	assert.Equal(t, 0, stats.ConflictKeys)
+
+	data, err := os.ReadFile(commandFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "*3\r\n$3\r\nset\r\n$1\r\na\r\n$1\r\n1\r\n")
+	assert.Contains(t, string(data), "*3\r\n$3\r\nset\r\n$1\r\nb\r\n$1\r\n2\r\n")
+}
+
+func TestMigrationEngineKeyRules(t *testing.T) {
+	newEngine := func(t *testing.T, source, target client.DatabaseClient, rules ...string) (*MigrationEngine, error) {
+		dir := t.TempDir()
+		log, err := logger.NewLogger(logger.Config{Level: "info", OutputFile: filepath.Join(dir, "migration.log"), Format: "text"})
+		require.NoError(t, err)
+
+		engineConfig := DefaultEngineConfig()
+		engineConfig.ResumeFile = filepath.Join(dir, "resume.json")
+		engineConfig.KeyRules = rules
+		engineConfig.OnConflict = ConflictSkip
+		return NewMigrationEngine(source, &client.ClientConfig{}, target, &client.ClientConfig{}, log, engineConfig)
+	}
+
+	t.Run("renamed and verified", func(t *testing.T) {
+		source := newStringTestClient(map[string]string{"app1:user:1": "a", "app1:user:2": "b", "other": "c"})
+		target := newStringTestClient(map[string]string{"tenant1:user:{2}": "existing"})
+
+		engine, err := newEngine(t, source, target, "strip-prefix=app1:", "add-prefix=tenant1:", `hash-tag=^tenant1:user:(\d+)`)
+		require.NoError(t, err)
+		require.NoError(t, engine.Migrate())
+
+		assert.Equal(t, map[string]interface{}{
+			"tenant1:user:{1}": "a",
+			"tenant1:user:{2}": "existing",
+			"tenant1:other":    "c",
+		}, target.keys, "user:2 already exists under its new name and is skipped")
+
+		stats := engine.GetStats()
+		assert.Equal(t, 2, stats.SuccessfulKeys)
+		assert.Equal(t, 1, stats.ConflictKeys)
+		assert.Zero(t, stats.FailedKeys, "the source keys verify against their renamed copies")
+	})
+
+	t.Run("collision", func(t *testing.T) {
+		source := newStringTestClient(map[string]string{"app1:k": "a", "app2:k": "b"})
+		target := newStringTestClient(nil)
+
+		engine, err := newEngine(t, source, target, `replace=^app\d:=>`)
+		require.NoError(t, err)
+		err = engine.Migrate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "are both renamed to k")
+		assert.Empty(t, target.keys)
+	})
+
+	t.Run("invalid rule", func(t *testing.T) {
+		_, err := newEngine(t, newStringTestClient(nil), newStringTestClient(nil), "prefix=a")
+		assert.ErrorContains(t, err, "unknown kind")
+	})
+}
+
+// TestNewDataProcessorTransferModes tests processor selection for each transfer mode
+func TestNewDataProcessorTransferModes(t *testing.T) {
+	log, err := logger.NewLogger(logger.Config{Level: "error", Format: "text"})
+	require.NoError(t, err)
+
+	plain := &IntegrationTestClient{keys: make(map[string]interface{}), keyTypes: make(map[string]string)}
+	dumping := &DumpIntegrationTestClient{IntegrationTestClient: plain}
+
+	testCases := []struct {
+		name        string
+		mode        string
+		source      client.DatabaseClient
+		target      client.DatabaseClient
+		expectDump  bool
+		expectError bool
+	}{
+		{"default is native", "", dumping, dumping, false, false},
+		{"native", processor.TransferModeNative, dumping, dumping, false, false},
+		{"dump", processor.TransferModeDump, dumping, dumping, true, false},
+		{"dump without support", processor.TransferModeDump, plain, dumping, false, true},
+		{"auto with support", processor.TransferModeAuto, dumping, dumping, true, false},
+		{"auto without support", processor.TransferModeAuto, dumping, plain, false, false},
+		{"invalid", "rdb", dumping, dumping, false, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := DefaultEngineConfig()
+			config.TransferMode = tc.mode
+
+			dataProcessor, err := newDataProcessor(config, nil, tc.source, nil, tc.target, log)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			native := processor.NewDataProcessor(log)
+			assert.Equal(t, tc.expectDump, fmt.Sprintf("%T", dataProcessor) != fmt.Sprintf("%T", native))
+		})
+	}
+}
+
+func TestNewDataProcessorConflictPolicies(t *testing.T) {
+	log, err := logger.NewLogger(logger.Config{Level: "error", Format: "text"})
+	require.NoError(t, err)
+
+	dumping := &DumpIntegrationTestClient{IntegrationTestClient: &IntegrationTestClient{
+		keys:     make(map[string]interface{}),
+		keyTypes: make(map[string]string),
+	}}
+
+	testCases := []struct {
+		name        string
+		policy      string
+		mode        string
+		expectDump  bool
+		expectError bool
+	}{
+		{"skip with dump", ConflictSkip, processor.TransferModeDump, true, false},
+		{"fail with dump", ConflictFail, processor.TransferModeDump, true, false},
+		{"merge with dump", ConflictMerge, processor.TransferModeDump, false, true},
+		{"merge with auto uses native", ConflictMerge, processor.TransferModeAuto, false, false},
+		{"invalid", "ignore", processor.TransferModeNative, false, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := DefaultEngineConfig()
+			config.OnConflict = tc.policy
+			config.TransferMode = tc.mode
+
+			dataProcessor, err := newDataProcessor(config, nil, dumping, nil, dumping, log)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			native := processor.NewDataProcessor(log)
+			assert.Equal(t, tc.expectDump, fmt.Sprintf("%T", dataProcessor) != fmt.Sprintf("%T", native))
+		})
+	}
+}
+
+func TestMigrationEngineConflictPolicies(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	testCases := []struct {
+		name              string
+		policy            string
+		verify            bool
+		expectExisting    string
+		expectSuccessful  int
+		expectSkipped     int
+		expectFailed      int
+		expectConflicts   int
+		expectMigrateFail bool
+	}{
+		// Existing keys are replaced without checking for them
+		{"overwrite", ConflictOverwrite, true, "value0", 10, 0, 0, 0, false},
+		{"skip", ConflictSkip, true, "old", 8, 2, 0, 2, false},
+		{"fail", ConflictFail, false, "old", 8, 0, 2, 2, true},
+		{"merge replaces strings", ConflictMerge, true, "value0", 10, 0, 0, 2, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			log, err := logger.NewLogger(logger.Config{Level: "error", Format: "text"})
+			require.NoError(t, err)
+
+			sourceClient := &IntegrationTestClient{keys: make(map[string]interface{}), keyTypes: make(map[string]string)}
+			for i := 0; i < 10; i++ {
+				key := fmt.Sprintf("conflict:key:%d", i)
+				sourceClient.keys[key] = fmt.Sprintf("value%d", i)
+				sourceClient.keyTypes[key] = "string"
+			}
+
+			// Two keys already exist on the target from an earlier run
+			targetClient := &IntegrationTestClient{keys: make(map[string]interface{}), keyTypes: make(map[string]string)}
+			for i := 0; i < 2; i++ {
+				key := fmt.Sprintf("conflict:key:%d", i)
+				targetClient.keys[key] = "old"
+				targetClient.keyTypes[key] = "string"
+			}
+
+			resumeFile := fmt.Sprintf("test_conflict_%s_resume.json", tc.policy)
+			defer os.Remove(resumeFile)
+
+			engine, err := NewMigrationEngine(
+				sourceClient,
+				&client.ClientConfig{Host: "localhost", Port: 6379, Database: 0},
+				targetClient,
+				&client.ClientConfig{Host: "localhost", Port: 6380, Database: 0},
+				log,
+				&EngineConfig{
+					BatchSize:            1,
+					ResumeFile:           resumeFile,
+					VerifyAfterMigration: tc.verify,
+					ContinueOnError:      true,
+					MaxConcurrency:       2,
+					ProgressInterval:     time.Second,
+					OnConflict:           tc.policy,
+				},
+			)
+			require.NoError(t, err)
+
+			err = engine.Migrate()
+			if tc.expectMigrateFail {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), ErrTargetKeyExists.Error())
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.expectExisting, targetClient.keys["conflict:key:0"])
+			assert.Equal(t, "value5", targetClient.keys["conflict:key:5"])
+
+			stats := engine.GetStats()
+			assert.Equal(t, tc.expectSuccessful, stats.SuccessfulKeys)
+			assert.Equal(t, tc.expectSkipped, stats.SkippedKeys)
+			assert.Equal(t, tc.expectFailed, stats.FailedKeys)
+			assert.Equal(t, tc.expectConflicts, stats.ConflictKeys)
+			assert.Equal(t, 10, stats.ProcessedKeys)
+		})
+	}
+}
+
 // IntegrationTestClient implements DatabaseClient for integration testing
 type IntegrationTestClient struct {
+	mu        sync.Mutex
 	keys      map[string]interface{}
 	keyTypes  map[string]string
 	connected bool
@@ -249,16 +686,25 @@ type IntegrationTestClient struct {
 }
 
 func (m *IntegrationTestClient) Connect() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.connected = true
 	return nil
 }
 
 func (m *IntegrationTestClient) Disconnect() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.connected = false
 	return nil
 }
 
 func (m *IntegrationTestClient) GetAllKeys() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if !m.connected {
 		return nil, fmt.Errorf("not connected")
 	}
@@ -271,15 +717,14 @@ func (m *IntegrationTestClient) GetAllKeys() ([]string, error) {
 }
 
 func (m *IntegrationTestClient) GetKeysByPattern(pattern string) ([]string, error) {
-	if !m.connected {
-		return nil, fmt.Errorf("not connected")
-	}
-
 	// Simple pattern matching for testing - just return all keys for now
 	return m.GetAllKeys()
 }
 
 func (m *IntegrationTestClient) GetKeyType(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if !m.connected {
 		return "", fmt.Errorf("not connected")
 	}
@@ -292,6 +737,9 @@ func (m *IntegrationTestClient) GetKeyType(key string) (string, error) {
 }
 
 func (m *IntegrationTestClient) GetValue(key string) (interface{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if !m.connected {
 		return nil, fmt.Errorf("not connected")
 	}
@@ -308,6 +756,9 @@ func (m *IntegrationTestClient) GetValue(key string) (interface{}, error) {
 }
 
 func (m *IntegrationTestClient) SetValue(key string, value interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if !m.connected {
 		return fmt.Errorf("not connected")
 	}
@@ -316,6 +767,11 @@ func (m *IntegrationTestClient) SetValue(key string, value interface{}) error {
 		return fmt.Errorf("simulated error for key: %s", key)
 	}
 
+	// Only strings are used in merge tests, and those are replaced
+	if merge, ok := value.(client.MergeValue); ok {
+		value = merge.Value
+	}
+
 	m.keys[key] = value
 
 	// Determine type based on value
@@ -343,6 +799,9 @@ func (m *IntegrationTestClient) SetValue(key string, value interface{}) error {
 }
 
 func (m *IntegrationTestClient) Exists(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if !m.connected {
 		return false, fmt.Errorf("not connected")
 	}
@@ -352,6 +811,9 @@ func (m *IntegrationTestClient) Exists(key string) (bool, error) {
 }
 
 func (m *IntegrationTestClient) Ping() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if !m.connected {
 		return fmt.Errorf("not connected")
 	}
@@ -359,6 +821,9 @@ func (m *IntegrationTestClient) Ping() error {
 }
 
 func (m *IntegrationTestClient) GetTTL(key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if !m.connected {
 		return 0, fmt.Errorf("not connected")
 	}
@@ -366,6 +831,9 @@ func (m *IntegrationTestClient) GetTTL(key string) (time.Duration, error) {
 }
 
 func (m *IntegrationTestClient) SetTTL(key string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if !m.connected {
 		return fmt.Errorf("not connected")
 	}
@@ -374,6 +842,78 @@ func (m *IntegrationTestClient) SetTTL(key string, ttl time.Duration) error {
 
 // HasKey checks if a key exists (helper for tests)
 func (m *IntegrationTestClient) HasKey(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	_, exists := m.keys[key]
 	return exists
 }
+
+// BatchIntegrationTestClient adds pipelined batch operations to IntegrationTestClient
+type BatchIntegrationTestClient struct {
+	*IntegrationTestClient
+	batchCalls int
+}
+
+func (m *BatchIntegrationTestClient) GetBatch(keys []string) ([]client.KeyRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.batchCalls++
+	records := make([]client.KeyRecord, len(keys))
+	for i, key := range keys {
+		records[i] = client.KeyRecord{Key: key, Type: m.keyTypes[key], Value: m.keys[key]}
+		if records[i].Type == "" {
+			records[i].Type = "none"
+		}
+	}
+	return records, nil
+}
+
+func (m *BatchIntegrationTestClient) SetBatch(records []client.KeyRecord) ([]error, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.batchCalls++
+	errs := make([]error, len(records))
+	for i, record := range records {
+		if record.Key == m.failOnKey {
+			errs[i] = fmt.Errorf("simulated error for key: %s", record.Key)
+			continue
+		}
+		m.keys[record.Key] = record.Value
+		m.keyTypes[record.Key] = record.Type
+	}
+	return errs, nil
+}
+
+func (m *BatchIntegrationTestClient) ExistsBatch(keys []string) ([]bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	exists := make([]bool, len(keys))
+	for i, key := range keys {
+		_, exists[i] = m.keys[key]
+	}
+	return exists, nil
+}
+
+// BatchCalls returns the number of batch operations performed (helper for tests)
+func (m *BatchIntegrationTestClient) BatchCalls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.batchCalls
+}
+
+// DumpIntegrationTestClient adds DUMP/RESTORE support to IntegrationTestClient
+type DumpIntegrationTestClient struct {
+	*IntegrationTestClient
+}
+
+func (m *DumpIntegrationTestClient) DumpKeys(keys []string) ([]client.DumpRecord, error) {
+	return nil, client.ErrUnsupported
+}
+
+func (m *DumpIntegrationTestClient) RestoreKeys(records []client.DumpRecord) ([]error, error) {
+	return nil, client.ErrUnsupported
+}
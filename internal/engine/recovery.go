@@ -1,10 +1,14 @@
 package engine
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"math"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/kinyelo/redis-valkey-migration/internal/client"
@@ -35,6 +39,12 @@ func DefaultRetryConfig() RetryConfig {
 			"i/o timeout",
 			"broken pipe",
 			"connection lost",
+			// Seen while a Sentinel failover is in progress
+			"readonly",
+			"master is down",
+			"loading",
+			// Commands still running on a connection replaced by a reconnect
+			"client is closed",
 		},
 	}
 }
@@ -60,6 +70,11 @@ type RecoverableClient struct {
 	recovery *ConnectionRecovery
 	logger   logger.Logger
 	name     string // "Redis" or "Valkey" for logging
+
+	// reconnectMu guards generation, which counts the reconnects so that workers
+	// failing on the same connection reconnect only once
+	reconnectMu sync.Mutex
+	generation  int
 }
 
 // NewRecoverableClient creates a new recoverable database client
@@ -73,6 +88,11 @@ func NewRecoverableClient(client client.DatabaseClient, config *client.ClientCon
 	}
 }
 
+// Unwrap returns the client wrapped with recovery capabilities
+func (rc *RecoverableClient) Unwrap() client.DatabaseClient {
+	return rc.client
+}
+
 // WithRetry executes an operation with retry logic
 func (cr *ConnectionRecovery) WithRetry(operation string, fn func() error) error {
 	var lastErr error
@@ -152,6 +172,58 @@ func (cr *ConnectionRecovery) logError(operation, key, errorMsg, stackTrace stri
 	cr.logger.LogError(operation, key, errorMsg, stackTrace, retryAttempt)
 }
 
+// withRetry executes an operation with retry logic. When an attempt on a client
+// connected through Sentinel fails because the master changed, it reconnects before the
+// next attempt, so that retries go to the new master rather than to the address the old
+// connection points at. Other errors are retried on the same connection, whose pool
+// replaces broken connections by itself.
+func (rc *RecoverableClient) withRetry(operation string, fn func() error) error {
+	reconnector, canReconnect := rc.client.(client.Reconnector)
+	canReconnect = canReconnect && rc.config.SentinelMaster != ""
+
+	return rc.recovery.WithRetry(operation, func() error {
+		generation := rc.currentGeneration()
+		err := fn()
+		if err != nil && canReconnect && isFailoverError(err) {
+			rc.reconnect(reconnector, generation)
+		}
+		return err
+	})
+}
+
+// isFailoverError reports whether err shows that the connection no longer points at the
+// master: a former master now replicating, or a replica that lost its master
+func isFailoverError(err error) bool {
+	errStr := strings.ToLower(err.Error())
+	return strings.Contains(errStr, "readonly") || strings.Contains(errStr, "master is down")
+}
+
+// currentGeneration returns the number of reconnects so far
+func (rc *RecoverableClient) currentGeneration() int {
+	rc.reconnectMu.Lock()
+	defer rc.reconnectMu.Unlock()
+	return rc.generation
+}
+
+// reconnect reconnects the client unless another worker already did since generation
+func (rc *RecoverableClient) reconnect(reconnector client.Reconnector, generation int) {
+	rc.reconnectMu.Lock()
+	defer rc.reconnectMu.Unlock()
+
+	if rc.generation != generation {
+		return
+	}
+
+	start := time.Now()
+	err := reconnector.Reconnect()
+	rc.logger.LogConnection("reconnect", rc.config.Host, rc.config.Port, rc.config.Database, err == nil, time.Since(start))
+	if err != nil {
+		rc.logger.Warnf("Failed to reconnect to %s: %v", rc.name, err)
+		return
+	}
+	rc.generation++
+}
+
 // RecoverableClient methods with automatic recovery
 
 // Connect establishes connection with retry logic
@@ -182,7 +254,7 @@ func (rc *RecoverableClient) Disconnect() error {
 
 // Ping tests connection with retry logic
 func (rc *RecoverableClient) Ping() error {
-	return rc.recovery.WithRetry(fmt.Sprintf("%s ping", rc.name), func() error {
+	return rc.withRetry(fmt.Sprintf("%s ping", rc.name), func() error {
 		return rc.client.Ping()
 	})
 }
@@ -190,7 +262,7 @@ func (rc *RecoverableClient) Ping() error {
 // GetAllKeys retrieves all keys with retry logic
 func (rc *RecoverableClient) GetAllKeys() ([]string, error) {
 	var result []string
-	err := rc.recovery.WithRetry(fmt.Sprintf("%s get all keys", rc.name), func() error {
+	err := rc.withRetry(fmt.Sprintf("%s get all keys", rc.name), func() error {
 		keys, err := rc.client.GetAllKeys()
 		if err != nil {
 			return err
@@ -204,7 +276,7 @@ func (rc *RecoverableClient) GetAllKeys() ([]string, error) {
 // GetKeysByPattern retrieves keys matching a pattern with retry logic
 func (rc *RecoverableClient) GetKeysByPattern(pattern string) ([]string, error) {
 	var result []string
-	err := rc.recovery.WithRetry(fmt.Sprintf("%s get keys by pattern", rc.name), func() error {
+	err := rc.withRetry(fmt.Sprintf("%s get keys by pattern", rc.name), func() error {
 		keys, err := rc.client.GetKeysByPattern(pattern)
 		if err != nil {
 			return err
@@ -215,10 +287,50 @@ func (rc *RecoverableClient) GetKeysByPattern(pattern string) ([]string, error)
 	return result, err
 }
 
+// ScanNodes lists the nodes to scan with retry logic if the underlying client can scan a page at a time
+func (rc *RecoverableClient) ScanNodes() ([]string, error) {
+	scanClient, ok := rc.client.(client.ScanClient)
+	if !ok {
+		return nil, client.ErrUnsupported
+	}
+
+	var result []string
+	err := rc.withRetry(fmt.Sprintf("%s list scan nodes", rc.name), func() error {
+		nodes, err := scanClient.ScanNodes()
+		if err != nil {
+			return err
+		}
+		result = nodes
+		return nil
+	})
+	return result, err
+}
+
+// ScanPage reads a page of keys with retry logic if the underlying client can scan a page at a time.
+// A page is read again from the same cursor when retried.
+func (rc *RecoverableClient) ScanPage(node string, cursor uint64, pattern string, count int64) ([]string, uint64, error) {
+	scanClient, ok := rc.client.(client.ScanClient)
+	if !ok {
+		return nil, 0, client.ErrUnsupported
+	}
+
+	var result []string
+	var next uint64
+	err := rc.withRetry(fmt.Sprintf("%s scan page", rc.name), func() error {
+		keys, nextCursor, err := scanClient.ScanPage(node, cursor, pattern, count)
+		if err != nil {
+			return err
+		}
+		result, next = keys, nextCursor
+		return nil
+	})
+	return result, next, err
+}
+
 // GetKeyType gets key type with retry logic
 func (rc *RecoverableClient) GetKeyType(key string) (string, error) {
 	var result string
-	err := rc.recovery.WithRetry(fmt.Sprintf("%s get key type", rc.name), func() error {
+	err := rc.withRetry(fmt.Sprintf("%s get key type", rc.name), func() error {
 		keyType, err := rc.client.GetKeyType(key)
 		if err != nil {
 			return err
@@ -232,7 +344,7 @@ func (rc *RecoverableClient) GetKeyType(key string) (string, error) {
 // GetValue retrieves value with retry logic
 func (rc *RecoverableClient) GetValue(key string) (interface{}, error) {
 	var result interface{}
-	err := rc.recovery.WithRetry(fmt.Sprintf("%s get value", rc.name), func() error {
+	err := rc.withRetry(fmt.Sprintf("%s get value", rc.name), func() error {
 		value, err := rc.client.GetValue(key)
 		if err != nil {
 			return err
@@ -245,7 +357,7 @@ func (rc *RecoverableClient) GetValue(key string) (interface{}, error) {
 
 // SetValue stores value with retry logic
 func (rc *RecoverableClient) SetValue(key string, value interface{}) error {
-	return rc.recovery.WithRetry(fmt.Sprintf("%s set value", rc.name), func() error {
+	return rc.withRetry(fmt.Sprintf("%s set value", rc.name), func() error {
 		return rc.client.SetValue(key, value)
 	})
 }
@@ -253,7 +365,7 @@ func (rc *RecoverableClient) SetValue(key string, value interface{}) error {
 // Exists checks key existence with retry logic
 func (rc *RecoverableClient) Exists(key string) (bool, error) {
 	var result bool
-	err := rc.recovery.WithRetry(fmt.Sprintf("%s exists check", rc.name), func() error {
+	err := rc.withRetry(fmt.Sprintf("%s exists check", rc.name), func() error {
 		exists, err := rc.client.Exists(key)
 		if err != nil {
 			return err
@@ -267,7 +379,7 @@ func (rc *RecoverableClient) Exists(key string) (bool, error) {
 // GetTTL gets TTL with retry logic
 func (rc *RecoverableClient) GetTTL(key string) (time.Duration, error) {
 	var result time.Duration
-	err := rc.recovery.WithRetry(fmt.Sprintf("%s get TTL", rc.name), func() error {
+	err := rc.withRetry(fmt.Sprintf("%s get TTL", rc.name), func() error {
 		ttl, err := rc.client.GetTTL(key)
 		if err != nil {
 			return err
@@ -280,17 +392,426 @@ func (rc *RecoverableClient) GetTTL(key string) (time.Duration, error) {
 
 // SetTTL sets TTL with retry logic
 func (rc *RecoverableClient) SetTTL(key string, ttl time.Duration) error {
-	return rc.recovery.WithRetry(fmt.Sprintf("%s set TTL", rc.name), func() error {
+	return rc.withRetry(fmt.Sprintf("%s set TTL", rc.name), func() error {
 		return rc.client.SetTTL(key, ttl)
 	})
 }
 
+// GetBatch reads a batch of keys with retry logic if the underlying client supports pipelining
+func (rc *RecoverableClient) GetBatch(keys []string) ([]client.KeyRecord, error) {
+	batchClient, ok := rc.client.(client.BatchClient)
+	if !ok {
+		return nil, client.ErrUnsupported
+	}
+
+	var result []client.KeyRecord
+	err := rc.withRetry(fmt.Sprintf("%s get batch", rc.name), func() error {
+		records, err := batchClient.GetBatch(keys)
+		if err != nil {
+			return err
+		}
+		result = records
+		return nil
+	})
+	return result, err
+}
+
+// SetBatch writes a batch of keys with retry logic if the underlying client supports pipelining
+func (rc *RecoverableClient) SetBatch(records []client.KeyRecord) ([]error, error) {
+	batchClient, ok := rc.client.(client.BatchClient)
+	if !ok {
+		return nil, client.ErrUnsupported
+	}
+
+	var result []error
+	err := rc.withRetry(fmt.Sprintf("%s set batch", rc.name), func() error {
+		errs, err := batchClient.SetBatch(records)
+		if err != nil {
+			return err
+		}
+		result = errs
+		return nil
+	})
+	return result, err
+}
+
+// ExistsBatch checks a batch of keys with retry logic if the underlying client supports pipelining
+func (rc *RecoverableClient) ExistsBatch(keys []string) ([]bool, error) {
+	batchClient, ok := rc.client.(client.BatchClient)
+	if !ok {
+		return nil, client.ErrUnsupported
+	}
+
+	var result []bool
+	err := rc.withRetry(fmt.Sprintf("%s exists batch", rc.name), func() error {
+		exists, err := batchClient.ExistsBatch(keys)
+		if err != nil {
+			return err
+		}
+		result = exists
+		return nil
+	})
+	return result, err
+}
+
+// DumpKeys serializes a batch of keys with retry logic if the underlying client supports DUMP
+func (rc *RecoverableClient) DumpKeys(keys []string) ([]client.DumpRecord, error) {
+	dumpRestorer, ok := rc.client.(client.DumpRestorer)
+	if !ok {
+		return nil, client.ErrUnsupported
+	}
+
+	var result []client.DumpRecord
+	err := rc.withRetry(fmt.Sprintf("%s dump keys", rc.name), func() error {
+		records, err := dumpRestorer.DumpKeys(keys)
+		if err != nil {
+			return err
+		}
+		result = records
+		return nil
+	})
+	return result, err
+}
+
+// RestoreKeys restores a batch of keys with retry logic if the underlying client supports RESTORE
+func (rc *RecoverableClient) RestoreKeys(records []client.DumpRecord) ([]error, error) {
+	dumpRestorer, ok := rc.client.(client.DumpRestorer)
+	if !ok {
+		return nil, client.ErrUnsupported
+	}
+
+	var result []error
+	err := rc.withRetry(fmt.Sprintf("%s restore keys", rc.name), func() error {
+		errs, err := dumpRestorer.RestoreKeys(records)
+		if err != nil {
+			return err
+		}
+		result = errs
+		return nil
+	})
+	return result, err
+}
+
+// RDBVersion probes the RDB version of the server with retry logic if the underlying client supports it
+func (rc *RecoverableClient) RDBVersion() (int, error) {
+	prober, ok := rc.client.(client.RDBVersionProber)
+	if !ok {
+		return 0, client.ErrUnsupported
+	}
+
+	var result int
+	err := rc.withRetry(fmt.Sprintf("%s RDB version probe", rc.name), func() error {
+		version, err := prober.RDBVersion()
+		if err != nil {
+			return err
+		}
+		result = version
+		return nil
+	})
+	return result, err
+}
+
+// KeySizeAndExpiry gets the size and expiry of a key with retry logic if the underlying client supports chunked transfer
+func (rc *RecoverableClient) KeySizeAndExpiry(key, keyType string) (int64, time.Time, bool, error) {
+	chunkedClient, ok := rc.client.(client.ChunkedClient)
+	if !ok {
+		return 0, time.Time{}, false, client.ErrUnsupported
+	}
+
+	var size int64
+	var expireAt time.Time
+	var exists bool
+	err := rc.withRetry(fmt.Sprintf("%s get key size", rc.name), func() error {
+		var err error
+		size, expireAt, exists, err = chunkedClient.KeySizeAndExpiry(key, keyType)
+		return err
+	})
+	return size, expireAt, exists, err
+}
+
+// ReadChunk reads a chunk of a collection with retry logic if the underlying client supports chunked transfer
+func (rc *RecoverableClient) ReadChunk(key, keyType string, cursor uint64, count int64) (interface{}, uint64, error) {
+	chunkedClient, ok := rc.client.(client.ChunkedClient)
+	if !ok {
+		return nil, 0, client.ErrUnsupported
+	}
+
+	var result interface{}
+	var next uint64
+	err := rc.withRetry(fmt.Sprintf("%s read chunk", rc.name), func() error {
+		chunk, nextCursor, err := chunkedClient.ReadChunk(key, keyType, cursor, count)
+		if err != nil {
+			return err
+		}
+		result, next = chunk, nextCursor
+		return nil
+	})
+	return result, next, err
+}
+
+// AppendChunk adds a chunk to a collection if the underlying client supports chunked transfer.
+// Lists are not retried, because a retried RPUSH that had already been applied would duplicate elements.
+func (rc *RecoverableClient) AppendChunk(key, keyType string, chunk interface{}) error {
+	chunkedClient, ok := rc.client.(client.ChunkedClient)
+	if !ok {
+		return client.ErrUnsupported
+	}
+
+	if keyType == "list" {
+		return chunkedClient.AppendChunk(key, keyType, chunk)
+	}
+
+	return rc.withRetry(fmt.Sprintf("%s append chunk", rc.name), func() error {
+		return chunkedClient.AppendChunk(key, keyType, chunk)
+	})
+}
+
+// RenameKey renames a key with retry logic if the underlying client supports chunked transfer
+func (rc *RecoverableClient) RenameKey(key, newKey string) error {
+	chunkedClient, ok := rc.client.(client.ChunkedClient)
+	if !ok {
+		return client.ErrUnsupported
+	}
+
+	return rc.withRetry(fmt.Sprintf("%s rename key", rc.name), func() error {
+		return chunkedClient.RenameKey(key, newKey)
+	})
+}
+
+// DeleteKey removes a key with retry logic if the underlying client supports chunked transfer
+func (rc *RecoverableClient) DeleteKey(key string) error {
+	chunkedClient, ok := rc.client.(client.ChunkedClient)
+	if !ok {
+		return client.ErrUnsupported
+	}
+
+	return rc.withRetry(fmt.Sprintf("%s delete key", rc.name), func() error {
+		return chunkedClient.DeleteKey(key)
+	})
+}
+
+// GetExpireAt gets the absolute expiry time of a key with retry logic if the underlying client supports it
+func (rc *RecoverableClient) GetExpireAt(key string) (time.Time, bool, error) {
+	expiryClient, ok := rc.client.(client.ExpiryClient)
+	if !ok {
+		return time.Time{}, false, client.ErrUnsupported
+	}
+
+	var expireAt time.Time
+	var exists bool
+	err := rc.withRetry(fmt.Sprintf("%s get expiry", rc.name), func() error {
+		at, found, err := expiryClient.GetExpireAt(key)
+		if err != nil {
+			return err
+		}
+		expireAt, exists = at, found
+		return nil
+	})
+	return expireAt, exists, err
+}
+
+// SetValueWithExpiry sets a value and its expiry with retry logic if the underlying client supports it
+func (rc *RecoverableClient) SetValueWithExpiry(key string, value interface{}, expireAt time.Time) error {
+	expiryClient, ok := rc.client.(client.ExpiryClient)
+	if !ok {
+		return client.ErrUnsupported
+	}
+
+	return rc.withRetry(fmt.Sprintf("%s set value with expiry", rc.name), func() error {
+		return expiryClient.SetValueWithExpiry(key, value, expireAt)
+	})
+}
+
+// SetExpireAt sets the absolute expiry time of a key with retry logic if the underlying client supports it
+func (rc *RecoverableClient) SetExpireAt(key string, expireAt time.Time) error {
+	expiryClient, ok := rc.client.(client.ExpiryClient)
+	if !ok {
+		return client.ErrUnsupported
+	}
+
+	return rc.withRetry(fmt.Sprintf("%s set expiry", rc.name), func() error {
+		return expiryClient.SetExpireAt(key, expireAt)
+	})
+}
+
+// ReplicationLag returns the replication lag of the source replica with retry logic if the
+// underlying client supports it
+func (rc *RecoverableClient) ReplicationLag() (int64, error) {
+	replicaClient, ok := rc.client.(client.ReplicaClient)
+	if !ok {
+		return 0, client.ErrUnsupported
+	}
+
+	var lag int64
+	err := rc.withRetry(fmt.Sprintf("%s replication lag", rc.name), func() error {
+		l, err := replicaClient.ReplicationLag()
+		if err != nil {
+			return err
+		}
+		lag = l
+		return nil
+	})
+	return lag, err
+}
+
+// UseReplica switches the heavy reads of the underlying client to its replica or back
+func (rc *RecoverableClient) UseReplica(enabled bool) {
+	if replicaClient, ok := rc.client.(client.ReplicaClient); ok {
+		replicaClient.UseReplica(enabled)
+	}
+}
+
+// EnableKeyNotifications enables keyevent notifications with retry logic if the underlying
+// client supports them
+func (rc *RecoverableClient) EnableKeyNotifications() error {
+	notificationClient, ok := rc.client.(client.NotificationClient)
+	if !ok {
+		return client.ErrUnsupported
+	}
+
+	return rc.withRetry(fmt.Sprintf("%s enable key notifications", rc.name), func() error {
+		return notificationClient.EnableKeyNotifications()
+	})
+}
+
+// SubscribeKeyEvents subscribes to keyevent notifications if the underlying client supports
+// them. The subscription restores itself, so it is not retried here.
+func (rc *RecoverableClient) SubscribeKeyEvents(ctx context.Context) (<-chan client.KeyEvent, error) {
+	notificationClient, ok := rc.client.(client.NotificationClient)
+	if !ok {
+		return nil, client.ErrUnsupported
+	}
+
+	return notificationClient.SubscribeKeyEvents(ctx)
+}
+
+// RunCommands runs a pipeline of commands if the underlying client supports it. It is
+// not retried, as a retry after a partial run would apply the commands that had
+// succeeded twice.
+func (rc *RecoverableClient) RunCommands(commands [][]interface{}) ([]error, error) {
+	commandClient, ok := rc.client.(client.CommandClient)
+	if !ok {
+		return nil, client.ErrUnsupported
+	}
+
+	return commandClient.RunCommands(commands)
+}
+
+// RunTransaction runs commands in a transaction if the underlying client supports it. It
+// is not retried, as the transaction may have run before the error.
+func (rc *RecoverableClient) RunTransaction(commands [][]interface{}) ([]error, error) {
+	commandClient, ok := rc.client.(client.CommandClient)
+	if !ok {
+		return nil, client.ErrUnsupported
+	}
+
+	return commandClient.RunTransaction(commands)
+}
+
+// PauseWrites blocks writes with retry logic if the underlying client supports it
+func (rc *RecoverableClient) PauseWrites(timeout time.Duration) error {
+	cutoverClient, ok := rc.client.(client.CutoverClient)
+	if !ok {
+		return client.ErrUnsupported
+	}
+
+	return rc.withRetry(fmt.Sprintf("%s pause writes", rc.name), func() error {
+		return cutoverClient.PauseWrites(timeout)
+	})
+}
+
+// UnpauseWrites lets writes through again with retry logic if the underlying client
+// supports it
+func (rc *RecoverableClient) UnpauseWrites() error {
+	cutoverClient, ok := rc.client.(client.CutoverClient)
+	if !ok {
+		return client.ErrUnsupported
+	}
+
+	return rc.withRetry(fmt.Sprintf("%s unpause writes", rc.name), func() error {
+		return cutoverClient.UnpauseWrites()
+	})
+}
+
+// ReplicationOffset returns the replication offset with retry logic if the underlying
+// client supports it
+func (rc *RecoverableClient) ReplicationOffset() (int64, error) {
+	cutoverClient, ok := rc.client.(client.CutoverClient)
+	if !ok {
+		return 0, client.ErrUnsupported
+	}
+
+	var offset int64
+	err := rc.withRetry(fmt.Sprintf("%s replication offset", rc.name), func() error {
+		o, err := cutoverClient.ReplicationOffset()
+		if err != nil {
+			return err
+		}
+		offset = o
+		return nil
+	})
+	return offset, err
+}
+
+// resumeCheckpointedKeys is how many of the migrated keys the scan checkpoint moved past
+// are remembered. SCAN returns a key again in a later page when the keyspace is resized
+// in between, which is usually soon after.
+const resumeCheckpointedKeys = 10000
+
 // ResumeState tracks migration state for resume functionality
+// It is safe for concurrent use by multiple migration workers
 type ResumeState struct {
+	mu sync.RWMutex
+
+	// ProcessedKeys are the keys migrated that the scan checkpoint has not moved past yet.
+	// Keys are dropped once the checkpoint moves past their page, so the state stays
+	// about as large as the pages being migrated rather than the whole keyspace.
 	ProcessedKeys map[string]bool `json:"processed_keys"`
 	StartTime     time.Time       `json:"start_time"`
 	LastKey       string          `json:"last_key"`
 	TotalKeys     int             `json:"total_keys"`
+
+	// Processed is the number of keys migrated, including the keys no longer listed in
+	// ProcessedKeys
+	Processed int `json:"processed"`
+
+	// Fingerprint identifies the migration that saved the state, nil in files saved
+	// before fingerprints were recorded
+	Fingerprint *ResumeFingerprint `json:"fingerprint,omitempty"`
+
+	// Replication is the position of the source replication stream applied so far in
+	// replication sync mode
+	Replication *client.ReplicationPosition `json:"replication,omitempty"`
+
+	// Scan is the position of key discovery that every key found before it has been
+	// migrated or has failed, nil until the first page of keys is complete
+	Scan *ScanCheckpoint `json:"scan,omitempty"`
+
+	// FailedKeys are the keys that failed to migrate. They are migrated again on resume,
+	// as discovery continues after keys that failed before the scan checkpoint.
+	FailedKeys map[string]bool `json:"failed_keys,omitempty"`
+
+	// ConflictKeys are the keys that already existed on the target and were skipped or
+	// merged into, which verification leaves out
+	ConflictKeys map[string]bool `json:"conflict_keys,omitempty"`
+
+	// CheckpointedKeys are the last resumeCheckpointedKeys migrated keys the scan
+	// checkpoint moved past, which are not migrated again if the scan returns them again.
+	// checkpointed indexes them and checkpointedNext is the oldest once the list is full.
+	CheckpointedKeys []string `json:"checkpointed_keys,omitempty"`
+	checkpointed     map[string]bool
+	checkpointedNext int
+}
+
+// ScanCheckpoint is a position of key discovery
+type ScanCheckpoint struct {
+	// Cursors is the SCAN cursor discovery continues from on each node, by the node
+	// names of client.ScanClient, 0 for nodes scanned to the end. Nodes not listed are
+	// scanned from the start.
+	Cursors map[string]uint64 `json:"cursors"`
+
+	// Keys is the number of keys discovered before the cursors
+	Keys int `json:"keys"`
 }
 
 // NewResumeState creates a new resume state
@@ -303,18 +824,177 @@ func NewResumeState() *ResumeState {
 
 // IsProcessed checks if a key has already been processed
 func (rs *ResumeState) IsProcessed(key string) bool {
-	return rs.ProcessedKeys[key]
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.ProcessedKeys[key] || rs.checkpointed[key]
 }
 
-// MarkProcessed marks a key as processed
-func (rs *ResumeState) MarkProcessed(key string) {
-	rs.ProcessedKeys[key] = true
+// MarkProcessed marks a key as processed and returns the new processed count
+func (rs *ResumeState) MarkProcessed(key string) int {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if !rs.ProcessedKeys[key] {
+		rs.ProcessedKeys[key] = true
+		rs.Processed++
+	}
 	rs.LastKey = key
+	delete(rs.FailedKeys, key)
+	return rs.Processed
+}
+
+// MarkFailed records that a key failed to migrate
+func (rs *ResumeState) MarkFailed(key string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.FailedKeys == nil {
+		rs.FailedKeys = make(map[string]bool)
+	}
+	rs.FailedKeys[key] = true
+}
+
+// ForgetFailed drops a failed key that no longer needs to be migrated
+func (rs *ResumeState) ForgetFailed(key string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	delete(rs.FailedKeys, key)
+}
+
+// GetFailedKeys returns the keys that failed to migrate, in order
+func (rs *ResumeState) GetFailedKeys() []string {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	keys := make([]string, 0, len(rs.FailedKeys))
+	for key := range rs.FailedKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// MarkConflict records that a key already existed on the target
+func (rs *ResumeState) MarkConflict(key string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.ConflictKeys == nil {
+		rs.ConflictKeys = make(map[string]bool)
+	}
+	rs.ConflictKeys[key] = true
+}
+
+// IsConflict checks if a key already existed on the target
+func (rs *ResumeState) IsConflict(key string) bool {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.ConflictKeys[key]
+}
+
+// GetConflictCount returns the number of keys that already existed on the target
+func (rs *ResumeState) GetConflictCount() int {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return len(rs.ConflictKeys)
+}
+
+// AdvanceScan records a copy of the position of key discovery, and drops the keys of
+// the pages it moved past from the processed keys, remembering the last of them among
+// the checkpointed keys. Both change at once, so a saved state never lists fewer keys as
+// processed than its checkpoint needs.
+func (rs *ResumeState) AdvanceScan(checkpoint ScanCheckpoint, keys []string) {
+	cursors := make(map[string]uint64, len(checkpoint.Cursors))
+	for node, cursor := range checkpoint.Cursors {
+		cursors[node] = cursor
+	}
+	checkpoint.Cursors = cursors
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.Scan = &checkpoint
+	for _, key := range keys {
+		if rs.ProcessedKeys[key] {
+			delete(rs.ProcessedKeys, key)
+			rs.addCheckpointed(key)
+		}
+	}
+}
+
+// addCheckpointed remembers a key the scan checkpoint moved past, forgetting the oldest
+// one when resumeCheckpointedKeys are remembered. The caller must hold rs.mu.
+func (rs *ResumeState) addCheckpointed(key string) {
+	if rs.checkpointed == nil {
+		rs.indexCheckpointed()
+	}
+	if rs.checkpointed[key] {
+		return
+	}
+
+	if len(rs.CheckpointedKeys) < resumeCheckpointedKeys {
+		rs.CheckpointedKeys = append(rs.CheckpointedKeys, key)
+	} else {
+		delete(rs.checkpointed, rs.CheckpointedKeys[rs.checkpointedNext])
+		rs.CheckpointedKeys[rs.checkpointedNext] = key
+		rs.checkpointedNext = (rs.checkpointedNext + 1) % len(rs.CheckpointedKeys)
+	}
+	rs.checkpointed[key] = true
+}
+
+// indexCheckpointed indexes the checkpointed keys of a loaded state
+func (rs *ResumeState) indexCheckpointed() {
+	rs.checkpointed = make(map[string]bool, len(rs.CheckpointedKeys))
+	for _, key := range rs.CheckpointedKeys {
+		rs.checkpointed[key] = true
+	}
+}
+
+// AddTotalKeys adds newly discovered keys to the total key count
+func (rs *ResumeState) AddTotalKeys(count int) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.TotalKeys += count
+}
+
+// GetScan returns a copy of the recorded position of key discovery, or nil if there is none
+func (rs *ResumeState) GetScan() *ScanCheckpoint {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	if rs.Scan == nil {
+		return nil
+	}
+	checkpoint := ScanCheckpoint{Cursors: make(map[string]uint64, len(rs.Scan.Cursors)), Keys: rs.Scan.Keys}
+	for node, cursor := range rs.Scan.Cursors {
+		checkpoint.Cursors[node] = cursor
+	}
+	return &checkpoint
 }
 
 // GetProcessedCount returns the number of processed keys
 func (rs *ResumeState) GetProcessedCount() int {
-	return len(rs.ProcessedKeys)
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.Processed
+}
+
+// SetReplication records the position of the replication stream applied so far
+func (rs *ResumeState) SetReplication(position client.ReplicationPosition) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.Replication = &position
+}
+
+// GetReplication returns the recorded replication position, or nil if there is none
+func (rs *ResumeState) GetReplication() *client.ReplicationPosition {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.Replication
+}
+
+// MarshalJSON serializes the resume state while holding its lock, so workers
+// can keep marking keys as processed while the state is being saved
+func (rs *ResumeState) MarshalJSON() ([]byte, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	type resumeState ResumeState
+	return json.Marshal((*resumeState)(rs))
 }
 
 // CriticalErrorHandler handles critical errors that require graceful shutdown
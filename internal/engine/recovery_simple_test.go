@@ -1,12 +1,19 @@
 package engine
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
 )
 
 // Simple test to verify basic recovery functionality
@@ -96,3 +103,146 @@ func TestResumeState(t *testing.T) {
 		}
 	}
 }
+
+func TestResumeState_ConflictKeysAreSaved(t *testing.T) {
+	resumeState := NewResumeState()
+	resumeState.MarkConflict("existing")
+	resumeState.MarkConflict("existing")
+	assert.Equal(t, 1, resumeState.GetConflictCount())
+
+	data, err := json.Marshal(resumeState)
+	assert.NoError(t, err)
+
+	var loaded ResumeState
+	assert.NoError(t, json.Unmarshal(data, &loaded))
+	assert.True(t, loaded.IsConflict("existing"), "conflicts must still be left out of verification after a resume")
+	assert.False(t, loaded.IsConflict("other"))
+}
+
+func TestResumeState_RemembersCheckpointedKeys(t *testing.T) {
+	resumeState := NewResumeState()
+	resumeState.MarkProcessed("a")
+	resumeState.MarkProcessed("b")
+	resumeState.MarkFailed("c")
+	resumeState.AdvanceScan(ScanCheckpoint{Cursors: map[string]uint64{"n1": 5}, Keys: 3}, []string{"a", "b", "c"})
+
+	assert.Empty(t, resumeState.ProcessedKeys)
+	assert.True(t, resumeState.IsProcessed("a"), "a key the scan returns again must not be migrated twice")
+	assert.False(t, resumeState.IsProcessed("c"), "failed keys are migrated again")
+
+	// The checkpointed keys are saved, but only the most recent ones
+	path := filepath.Join(t.TempDir(), "resume.json")
+	data, err := json.Marshal(resumeState)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	loaded, err := loadResumeState(path)
+	require.NoError(t, err)
+	assert.True(t, loaded.IsProcessed("b"))
+
+	keys := make([]string, resumeCheckpointedKeys)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("k%d", i)
+		loaded.MarkProcessed(keys[i])
+	}
+	loaded.AdvanceScan(ScanCheckpoint{Cursors: map[string]uint64{"n1": 0}, Keys: 3 + len(keys)}, keys)
+	assert.False(t, loaded.IsProcessed("a"))
+	assert.True(t, loaded.IsProcessed(keys[len(keys)-1]))
+	assert.Len(t, loaded.CheckpointedKeys, resumeCheckpointedKeys)
+	assert.Equal(t, 2+len(keys), loaded.GetProcessedCount())
+}
+
+// reconnectingClient is a MockDatabaseClient that counts reconnects
+type reconnectingClient struct {
+	*MockDatabaseClient
+	reconnects int
+}
+
+func (c *reconnectingClient) Reconnect() error {
+	c.reconnects++
+	return nil
+}
+
+func TestRecoverableClient_ReconnectsBeforeRetry(t *testing.T) {
+	mockLogger := &MockLogger{}
+	mockLogger.On("Infof", mock.AnythingOfType("string"), mock.Anything).Return()
+	mockLogger.On("LogError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+	mockLogger.On("LogConnection", "reconnect", "localhost", 6379, 0, true, mock.Anything).Return()
+
+	inner := &reconnectingClient{MockDatabaseClient: &MockDatabaseClient{}}
+	inner.On("GetKeyType", "key").Return("", errors.New("READONLY You can't write against a read only replica.")).Once()
+	inner.On("GetKeyType", "key").Return("string", nil).Once()
+
+	recovery := NewConnectionRecovery(RetryConfig{
+		MaxAttempts:     3,
+		InitialDelay:    time.Millisecond,
+		MaxDelay:        time.Millisecond,
+		BackoffFactor:   1.0,
+		RetryableErrors: DefaultRetryConfig().RetryableErrors,
+	}, mockLogger)
+	clientConfig := client.NewClientConfig("localhost", 6379, "", 0)
+	clientConfig.SentinelMaster = "mymaster"
+	rc := NewRecoverableClient(inner, clientConfig, recovery, mockLogger, "Redis")
+
+	keyType, err := rc.GetKeyType("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "string", keyType)
+	assert.Equal(t, 1, inner.reconnects)
+	inner.AssertExpectations(t)
+}
+
+func TestRecoverableClient_ReconnectsOnlyAfterFailover(t *testing.T) {
+	mockLogger := &MockLogger{}
+	mockLogger.On("Infof", mock.AnythingOfType("string"), mock.Anything).Return()
+	mockLogger.On("LogError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+
+	recovery := NewConnectionRecovery(RetryConfig{
+		MaxAttempts:     3,
+		InitialDelay:    time.Millisecond,
+		MaxDelay:        time.Millisecond,
+		BackoffFactor:   1.0,
+		RetryableErrors: DefaultRetryConfig().RetryableErrors,
+	}, mockLogger)
+
+	testCases := []struct {
+		name           string
+		sentinelMaster string
+		err            error
+	}{
+		{"timeout through Sentinel", "mymaster", errors.New("i/o timeout")},
+		{"read-only without Sentinel", "", errors.New("READONLY You can't write against a read only replica.")},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			inner := &reconnectingClient{MockDatabaseClient: &MockDatabaseClient{}}
+			inner.On("GetKeyType", "key").Return("", tc.err).Once()
+			inner.On("GetKeyType", "key").Return("string", nil).Once()
+
+			clientConfig := client.NewClientConfig("localhost", 6379, "", 0)
+			clientConfig.SentinelMaster = tc.sentinelMaster
+			rc := NewRecoverableClient(inner, clientConfig, recovery, mockLogger, "Redis")
+
+			_, err := rc.GetKeyType("key")
+			assert.NoError(t, err)
+			assert.Zero(t, inner.reconnects, "the error must be retried on the same connection")
+		})
+	}
+}
+
+func TestRecoverableClient_ReconnectsOncePerFailedConnection(t *testing.T) {
+	mockLogger := &MockLogger{}
+	mockLogger.On("LogConnection", "reconnect", "localhost", 6379, 0, true, mock.Anything).Return()
+
+	inner := &reconnectingClient{MockDatabaseClient: &MockDatabaseClient{}}
+	recovery := NewConnectionRecovery(DefaultRetryConfig(), mockLogger)
+	rc := NewRecoverableClient(inner, client.NewClientConfig("localhost", 6379, "", 0), recovery, mockLogger, "Redis")
+
+	// Workers that failed on the same connection reconnect it only once
+	generation := rc.currentGeneration()
+	rc.reconnect(inner, generation)
+	rc.reconnect(inner, generation)
+	assert.Equal(t, 1, inner.reconnects)
+
+	rc.reconnect(inner, rc.currentGeneration())
+	assert.Equal(t, 2, inner.reconnects)
+}
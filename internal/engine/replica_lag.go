@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+	"github.com/kinyelo/redis-valkey-migration/pkg/logger"
+)
+
+// replicaLagInterval is how often the replication lag of the source replica is checked
+const replicaLagInterval = time.Second
+
+// replicaLagMaxFailures is how many lag checks in a row may fail before the gate gives up
+// on the replica and switches the reads to the primary
+const replicaLagMaxFailures = 30
+
+// ReplicaLagGate pauses the migration while the source replica is too far behind its
+// primary, so that keys are not copied from a stale replica. It does nothing when the
+// source has no replica configured.
+type ReplicaLagGate struct {
+	client   client.ReplicaClient
+	maxLag   int64
+	interval time.Duration
+	logger   logger.Logger
+
+	// mu serializes checks, so that every worker waits while the replica catches up
+	mu        sync.Mutex
+	lastCheck time.Time
+	disabled  bool
+}
+
+// NewReplicaLagGate creates a gate that pauses while the replica is more than maxLag bytes behind
+func NewReplicaLagGate(replicaClient client.ReplicaClient, maxLag int64, interval time.Duration, logger logger.Logger) *ReplicaLagGate {
+	return &ReplicaLagGate{
+		client:   replicaClient,
+		maxLag:   maxLag,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Wait returns once the replica is at most maxLag bytes behind its primary, or with the
+// context's error if it is cancelled first. The lag is checked at most once per interval;
+// calls in between return immediately. If the lag cannot be checked replicaLagMaxFailures
+// times in a row, the reads are switched to the primary and the gate stops checking.
+func (g *ReplicaLagGate) Wait(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.disabled || time.Since(g.lastCheck) < g.interval {
+		return nil
+	}
+
+	paused := false
+	failures := 0
+	for {
+		lag, err := g.client.ReplicationLag()
+		g.lastCheck = time.Now()
+
+		if errors.Is(err, client.ErrUnsupported) {
+			g.disabled = true
+			return nil
+		}
+
+		if err != nil {
+			failures++
+			if failures >= replicaLagMaxFailures {
+				g.logger.Warnf("Could not check source replica lag %d times in a row, reading from the primary instead: %v", failures, err)
+				g.client.UseReplica(false)
+				g.disabled = true
+				return nil
+			}
+		} else {
+			failures = 0
+		}
+
+		if err == nil && lag <= g.maxLag {
+			if paused {
+				g.logger.Infof("Source replica caught up (lag %d bytes), resuming migration", lag)
+			}
+			return nil
+		}
+
+		if !paused {
+			if err != nil {
+				g.logger.Warnf("Could not check source replica lag, pausing migration: %v", err)
+			} else {
+				g.logger.Warnf("Source replica is %d bytes behind its primary (max %d), pausing migration", lag, g.maxLag)
+			}
+			paused = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(g.interval):
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+)
+
+// laggingReplica reports the lags in order, repeating the last one
+type laggingReplica struct {
+	mu     sync.Mutex
+	lags   []int64
+	errs   []error
+	checks int
+
+	primary bool // Set once the reads are switched to the primary
+}
+
+func (r *laggingReplica) ReplicationLag() (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.checks
+	if i >= len(r.lags) {
+		i = len(r.lags) - 1
+	}
+	r.checks++
+	var err error
+	if i < len(r.errs) {
+		err = r.errs[i]
+	}
+	return r.lags[i], err
+}
+
+func (r *laggingReplica) UseReplica(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.primary = !enabled
+}
+
+func newGateLogger() *MockLogger {
+	mockLogger := &MockLogger{}
+	mockLogger.On("Infof", mock.Anything, mock.Anything).Return()
+	mockLogger.On("Warnf", mock.Anything, mock.Anything).Return()
+	return mockLogger
+}
+
+func TestReplicaLagGate_PausesUntilCaughtUp(t *testing.T) {
+	replica := &laggingReplica{lags: []int64{5000, 3000, 100}}
+	mockLogger := newGateLogger()
+	gate := NewReplicaLagGate(replica, 1000, time.Millisecond, mockLogger)
+
+	assert.NoError(t, gate.Wait(context.Background()))
+	assert.Equal(t, 3, replica.checks)
+	mockLogger.AssertNumberOfCalls(t, "Warnf", 1)
+	mockLogger.AssertNumberOfCalls(t, "Infof", 1)
+}
+
+func TestReplicaLagGate_PausesWhileLagUnknown(t *testing.T) {
+	replica := &laggingReplica{
+		lags: []int64{0, 0},
+		errs: []error{errors.New("replica link to its primary is down"), nil},
+	}
+	gate := NewReplicaLagGate(replica, 1000, time.Millisecond, newGateLogger())
+
+	assert.NoError(t, gate.Wait(context.Background()))
+	assert.Equal(t, 2, replica.checks)
+}
+
+func TestReplicaLagGate_ChecksOncePerInterval(t *testing.T) {
+	replica := &laggingReplica{lags: []int64{0}}
+	gate := NewReplicaLagGate(replica, 1000, time.Hour, newGateLogger())
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, gate.Wait(context.Background()))
+	}
+	assert.Equal(t, 1, replica.checks)
+}
+
+func TestReplicaLagGate_DisabledWithoutReplica(t *testing.T) {
+	replica := &laggingReplica{lags: []int64{0}, errs: []error{client.ErrUnsupported}}
+	gate := NewReplicaLagGate(replica, 1000, time.Millisecond, newGateLogger())
+
+	assert.NoError(t, gate.Wait(context.Background()))
+	time.Sleep(2 * time.Millisecond)
+	assert.NoError(t, gate.Wait(context.Background()))
+	assert.Equal(t, 1, replica.checks)
+}
+
+func TestReplicaLagGate_StopsOnCancel(t *testing.T) {
+	replica := &laggingReplica{lags: []int64{5000}}
+	gate := NewReplicaLagGate(replica, 1000, time.Millisecond, newGateLogger())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, gate.Wait(ctx), context.DeadlineExceeded)
+}
+
+func TestReplicaLagGate_SwitchesToPrimaryWhenLagStaysUnknown(t *testing.T) {
+	replica := &laggingReplica{lags: []int64{0}, errs: make([]error, replicaLagMaxFailures+1)}
+	for i := range replica.errs {
+		replica.errs[i] = errors.New("replica link to its primary is down")
+	}
+	mockLogger := newGateLogger()
+	gate := NewReplicaLagGate(replica, 1000, time.Millisecond, mockLogger)
+
+	assert.NoError(t, gate.Wait(context.Background()))
+	assert.Equal(t, replicaLagMaxFailures, replica.checks)
+	assert.True(t, replica.primary, "reads must fall back to the primary")
+	mockLogger.AssertNumberOfCalls(t, "Warnf", 2)
+
+	// The gate no longer checks the replica
+	time.Sleep(2 * time.Millisecond)
+	assert.NoError(t, gate.Wait(context.Background()))
+	assert.Equal(t, replicaLagMaxFailures, replica.checks)
+}
@@ -0,0 +1,497 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+)
+
+// replicationMarkerKey is the key on the target holding the replication position its
+// data corresponds to. It is written in the same transaction as the commands, so a
+// restarted sync continues exactly where the target stopped.
+const replicationMarkerKey = "redis-valkey-migration:replication"
+
+// replicationSkippedCommands are propagated to replicas but do not change keys. The
+// commands of a transaction are replayed one by one.
+var replicationSkippedCommands = map[string]bool{
+	"MULTI":    true,
+	"EXEC":     true,
+	"PUBLISH":  true,
+	"SPUBLISH": true,
+}
+
+// commandKeys returns the keys a replicated write command changes. Most commands take
+// their key as the first argument; the exceptions the source propagates are listed.
+func commandKeys(args []string) []string {
+	if len(args) < 2 {
+		return nil
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "FLUSHDB", "FLUSHALL", "SWAPDB", "SCRIPT", "FUNCTION":
+		return nil
+	case "DEL", "UNLINK":
+		return args[1:]
+	case "MSET", "MSETNX":
+		keys := make([]string, 0, len(args)/2)
+		for i := 1; i < len(args); i += 2 {
+			keys = append(keys, args[i])
+		}
+		return keys
+	case "RENAME", "RENAMENX", "COPY", "SMOVE", "LMOVE", "RPOPLPUSH":
+		if len(args) < 3 {
+			return args[1:2]
+		}
+		return args[1:3]
+	case "BITOP", "XGROUP":
+		// The key follows the operation or subcommand
+		if len(args) < 3 {
+			return nil
+		}
+		return args[2:3]
+	}
+	return args[1:2]
+}
+
+// syncReplication keeps the target in step with the source by acting as a replica of
+// it: the snapshot the source sends is written to the target, followed by every write
+// command the source propagates. The commands are applied in transactions that also
+// store the replication position in a marker key on the target, so a restarted sync
+// continues with a partial resynchronization from the position the target reached,
+// and no command is applied twice.
+func (me *MigrationEngine) syncReplication() error {
+	if !client.Supports[client.CommandClient](me.targetClient) || !client.Supports[client.BatchClient](me.targetClient) {
+		return me.failureHandler.HandleCriticalFailure("sync setup",
+			NewMigrationError(ConfigurationError, "sync setup", "target cannot replay replicated commands"))
+	}
+
+	if me.sourceConfig == nil {
+		return me.failureHandler.HandleCriticalFailure("sync setup",
+			NewMigrationError(ConfigurationError, "sync setup", "source connection settings are missing"))
+	}
+
+	var position client.ReplicationPosition
+	if saved := me.resumeState.GetReplication(); saved != nil {
+		applied, err := me.appliedReplication()
+		if err != nil {
+			return me.failureHandler.HandleCriticalFailure("sync setup", err)
+		}
+		if applied != nil {
+			position = *applied
+			me.logger.Infof("Resuming replication %s from offset %d", position.ReplID, position.Offset)
+		} else {
+			me.logger.Warnf("The target holds no replication position, starting from a snapshot of the source")
+		}
+	} else if processed := me.resumeState.GetProcessedCount(); processed > 0 {
+		me.logger.Warnf("Replication sync starts from a snapshot of the source, ignoring the %d keys processed by the previous run", processed)
+		me.resumeState = me.newResumeState()
+	}
+
+	applier := newReplicationApplier(me, me.sourceConfig.Database, position.ReplID != "")
+	replication := client.NewReplication(me.sourceConfig, position)
+
+	ctx, cancel := context.WithCancel(me.ctx)
+	defer cancel()
+	var runErr error
+	stopped := make(chan struct{})
+	go func() {
+		runErr = me.runReplication(ctx, replication, applier)
+		close(stopped)
+	}()
+
+	interval := me.config.SyncInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for !me.cutoverRequested() {
+		select {
+		case <-stopped:
+			if me.ctx.Err() != nil {
+				me.logger.Info("Sync cancelled")
+				return me.ctx.Err()
+			}
+			return me.failureHandler.HandleCriticalFailure("replication", runErr)
+		case <-ticker.C:
+		}
+	}
+
+	me.logger.Info("Cutover requested, pausing writes and applying the remaining commands")
+	cutoverErr := me.cutover(func(deadline time.Time) (int64, error) {
+		return me.drainReplication(replication, applier, stopped, interval, deadline)
+	}, applier.recent)
+
+	cancel()
+	<-stopped
+	if me.ctx.Err() != nil {
+		me.logger.Info("Sync cancelled")
+		return me.ctx.Err()
+	}
+
+	loaded, applied, failed := applier.stats()
+	me.logger.Infof("Sync stopped at offset %d after loading %d snapshot keys and applying %d commands (%d failed)",
+		replication.Position().Offset, loaded, applied, failed)
+
+	if cutoverErr != nil {
+		return me.failureHandler.HandleCriticalFailure("cutover", cutoverErr)
+	}
+
+	if me.config.VerifyAfterMigration {
+		me.logger.Info("Not verifying every key in sync mode, the recently changed keys were verified at cutover")
+	}
+
+	if _, err := me.targetClient.RunCommands([][]interface{}{{"DEL", replicationMarkerKey}}); err != nil {
+		me.logger.Warnf("Failed to delete %s from the target: %v", replicationMarkerKey, err)
+	}
+	me.cleanupResumeState()
+
+	me.logger.Info("Sync completed, applications can be switched to the target")
+	return nil
+}
+
+// appliedReplication reads the replication position stored with the data on the target.
+// It returns nil if the target holds none.
+func (me *MigrationEngine) appliedReplication() (*client.ReplicationPosition, error) {
+	exists, err := me.targetClient.Exists(replicationMarkerKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the replication position of the target: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	value, err := me.targetClient.GetValue(replicationMarkerKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the replication position of the target: %w", err)
+	}
+	data, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s on the target is not a string", replicationMarkerKey)
+	}
+
+	var position client.ReplicationPosition
+	if err := json.Unmarshal([]byte(data), &position); err != nil {
+		return nil, fmt.Errorf("failed to parse %s on the target: %w", replicationMarkerKey, err)
+	}
+	return &position, nil
+}
+
+// drainReplication waits until the target has applied the replication stream up to the
+// offset the source reached when writes were paused
+func (me *MigrationEngine) drainReplication(replication *client.Replication, applier *replicationApplier, stopped <-chan struct{}, interval time.Duration, deadline time.Time) (int64, error) {
+	_, appliedBefore, _ := applier.stats()
+	drained := func() int64 {
+		_, applied, _ := applier.stats()
+		return applied - appliedBefore
+	}
+
+	target, err := me.sourceClient.ReplicationOffset()
+	if err != nil {
+		if !errors.Is(err, client.ErrUnsupported) {
+			return 0, fmt.Errorf("failed to read the replication offset of the source: %w", err)
+		}
+
+		// Commands for the last writes may still be on their way
+		me.logger.Warnf("The source does not report its replication offset, waiting %v for the last commands", interval)
+		select {
+		case <-me.ctx.Done():
+			return 0, me.ctx.Err()
+		case <-stopped:
+			return drained(), errors.New("replication stopped")
+		case <-time.After(interval):
+		}
+		return drained(), nil
+	}
+
+	ticker := time.NewTicker(cutoverPollInterval)
+	defer ticker.Stop()
+	for {
+		offset := replication.Position().Offset
+		if offset >= target {
+			return drained(), nil
+		}
+		if time.Now().After(deadline) {
+			return drained(), fmt.Errorf("replication at offset %d of %d when the write pause ended", offset, target)
+		}
+
+		select {
+		case <-me.ctx.Done():
+			return drained(), me.ctx.Err()
+		case <-stopped:
+			return drained(), fmt.Errorf("replication stopped at offset %d of %d", offset, target)
+		case <-ticker.C:
+		}
+	}
+}
+
+// runReplication runs the replication until ctx is cancelled. After a failure it
+// reconnects and continues from the last applied position, giving up once it failed
+// MaxAttempts times in a row without applying anything. It gives up at once when the
+// target may have applied commands past that position.
+func (me *MigrationEngine) runReplication(ctx context.Context, replication *client.Replication, applier *replicationApplier) error {
+	attempt := 0
+	for {
+		before := replication.Position()
+		applier.discard()
+
+		err := replication.Run(ctx, applier)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		var migErr *MigrationError
+		if errors.As(err, &migErr) && migErr.Type == CriticalError {
+			return err
+		}
+
+		if replication.Position() != before {
+			attempt = 0
+		}
+		attempt++
+		if attempt > me.recovery.config.MaxAttempts {
+			return err
+		}
+
+		delay := me.recovery.calculateDelay(attempt)
+		me.logger.Warnf("Replication interrupted: %v. Continuing from offset %d in %v", err, replication.Position().Offset, delay)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+// replicationApplier writes what the source sends over replication to the target.
+// Snapshot keys are written in batches when the replication flushes or a batch is full.
+// The commands of the stream are replayed in a transaction when the replication flushes.
+type replicationApplier struct {
+	me       *MigrationEngine
+	sourceDB int
+	resumed  bool
+
+	records  []client.KeyRecord
+	commands [][]interface{}
+
+	loaded  atomic.Int64
+	applied atomic.Int64
+	failed  atomic.Int64
+
+	// recent holds the keys of the last commands, which are verified at cutover
+	recent *recentKeys
+
+	lastReport time.Time
+}
+
+// newReplicationApplier creates an applier for the keys of sourceDB. resumed is set when
+// the replication continues from an earlier position.
+func newReplicationApplier(me *MigrationEngine, sourceDB int, resumed bool) *replicationApplier {
+	return &replicationApplier{
+		me:         me,
+		sourceDB:   sourceDB,
+		resumed:    resumed,
+		recent:     newRecentKeys(cutoverRecentKeys),
+		lastReport: time.Now(),
+	}
+}
+
+// FullResync is called when the source sends a snapshot
+func (a *replicationApplier) FullResync(position client.ReplicationPosition) error {
+	if a.resumed {
+		a.me.logger.Warnf("The source could not continue the replication and sends a new snapshot; keys deleted on the source since the last run may remain on the target")
+	}
+	a.me.logger.Infof("Loading snapshot of replication %s at offset %d", position.ReplID, position.Offset)
+	a.discard()
+
+	// The target no longer matches the position it holds
+	if _, err := a.me.targetClient.RunCommands([][]interface{}{{"DEL", replicationMarkerKey}}); err != nil {
+		return fmt.Errorf("failed to delete %s from the target: %w", replicationMarkerKey, err)
+	}
+	a.resumed = true
+	return nil
+}
+
+// SnapshotEntry queues a key of the snapshot
+func (a *replicationApplier) SnapshotEntry(entry *client.RDBEntry) error {
+	if entry.DB != a.sourceDB {
+		return nil
+	}
+	if !entry.ExpireAt.IsZero() && !entry.ExpireAt.After(time.Now()) {
+		return nil
+	}
+	if entry.Value == nil {
+		a.me.logger.Warnf("Skipping %s: %s values cannot be migrated", entry.Key, entry.Type)
+		a.failed.Add(1)
+		return nil
+	}
+
+	a.records = append(a.records, client.KeyRecord{
+		Key:      entry.Key,
+		Type:     entry.Type,
+		Value:    entry.Value,
+		ExpireAt: entry.ExpireAt,
+	})
+	if len(a.records) >= a.batchSize() {
+		return a.writeRecords()
+	}
+	return nil
+}
+
+// Command queues a command of the stream
+func (a *replicationApplier) Command(db int, args []string) error {
+	name := strings.ToUpper(args[0])
+	if replicationSkippedCommands[name] {
+		return nil
+	}
+
+	// FLUSHALL also empties the migrated database, but must not empty the other
+	// databases of the target
+	if name == "FLUSHALL" {
+		args = append([]string{"FLUSHDB"}, args[1:]...)
+	} else if db != a.sourceDB {
+		return nil
+	}
+
+	command := make([]interface{}, len(args))
+	for i, arg := range args {
+		command[i] = arg
+	}
+	a.commands = append(a.commands, command)
+	for _, key := range commandKeys(args) {
+		a.recent.add(key)
+	}
+	return nil
+}
+
+// Flush writes the queued keys, then applies the queued commands together with the
+// position and saves it in the resume file
+func (a *replicationApplier) Flush(position client.ReplicationPosition) error {
+	if err := a.writeRecords(); err != nil {
+		return err
+	}
+	if err := a.runCommands(position); err != nil {
+		return err
+	}
+
+	a.me.resumeState.SetReplication(position)
+	if err := a.me.saveResumeState(); err != nil {
+		return err
+	}
+
+	if time.Since(a.lastReport) >= a.me.config.ProgressInterval {
+		a.lastReport = time.Now()
+		loaded, applied, failed := a.stats()
+		a.me.logger.Infof("Replication at offset %d: %d snapshot keys loaded, %d commands applied, %d failed",
+			position.Offset, loaded, applied, failed)
+	}
+	return nil
+}
+
+// discard drops the queued keys and commands, which the source sends again after a reconnect
+func (a *replicationApplier) discard() {
+	a.records = nil
+	a.commands = nil
+}
+
+// writeRecords writes the queued snapshot keys to the target
+func (a *replicationApplier) writeRecords() error {
+	if len(a.records) == 0 {
+		return nil
+	}
+
+	errs, err := a.me.targetClient.SetBatch(a.records)
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot keys: %w", err)
+	}
+	for i, err := range errs {
+		if err != nil {
+			a.me.logger.Warnf("Failed to write snapshot key %s: %v", a.records[i].Key, err)
+			a.failed.Add(1)
+			continue
+		}
+		a.loaded.Add(1)
+	}
+
+	a.records = nil
+	return nil
+}
+
+// runCommands replays the queued commands on the target in a transaction that stores
+// position in the marker key. Commands the target refuses to queue are dropped, as the
+// transaction would not run otherwise. Any other failure is critical: the transaction
+// may have run, so the commands cannot be replayed safely.
+func (a *replicationApplier) runCommands(position client.ReplicationPosition) error {
+	marker, err := json.Marshal(position)
+	if err != nil {
+		return fmt.Errorf("failed to encode replication position: %w", err)
+	}
+
+	for {
+		transaction := make([][]interface{}, 0, len(a.commands)+1)
+		transaction = append(transaction, a.commands...)
+		transaction = append(transaction, []interface{}{"SET", replicationMarkerKey, string(marker)})
+		errs, err := a.me.targetClient.RunTransaction(transaction)
+		if errors.Is(err, client.ErrTransactionDiscarded) && a.dropRefused(errs) {
+			continue
+		}
+		if err == nil && errs[len(a.commands)] != nil {
+			err = errs[len(a.commands)]
+		}
+		if err != nil {
+			return NewMigrationError(CriticalError, "replication apply",
+				fmt.Sprintf("failed to apply replicated commands up to offset %d", position.Offset)).WithCause(err)
+		}
+
+		for i, err := range errs[:len(a.commands)] {
+			if err != nil {
+				a.me.logger.Warnf("Replicated command %v failed on the target: %v", a.commands[i][0], err)
+				a.failed.Add(1)
+				continue
+			}
+			a.applied.Add(1)
+		}
+
+		a.commands = nil
+		return nil
+	}
+}
+
+// dropRefused drops the queued commands the target refused in a discarded transaction.
+// It returns false if it dropped none.
+func (a *replicationApplier) dropRefused(errs []error) bool {
+	kept := make([][]interface{}, 0, len(a.commands))
+	for i, command := range a.commands {
+		if errs[i] != nil {
+			a.me.logger.Warnf("Replicated command %v failed on the target: %v", command[0], errs[i])
+			a.failed.Add(1)
+			continue
+		}
+		kept = append(kept, command)
+	}
+
+	dropped := len(kept) < len(a.commands)
+	a.commands = kept
+	return dropped
+}
+
+// batchSize returns how many snapshot keys are queued before they are written
+func (a *replicationApplier) batchSize() int {
+	if a.me.config.BatchSize < 1 {
+		return 1
+	}
+	return a.me.config.BatchSize
+}
+
+// stats returns how many snapshot keys were loaded, how many commands were applied and
+// how many keys or commands failed
+func (a *replicationApplier) stats() (loaded, applied, failed int64) {
+	return a.loaded.Load(), a.applied.Load(), a.failed.Load()
+}
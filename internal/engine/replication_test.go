@@ -0,0 +1,324 @@
+package engine
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+	"github.com/kinyelo/redis-valkey-migration/pkg/logger"
+)
+
+// ReplayingTestClient records the snapshot keys and commands written to it, and keeps
+// the replication marker
+type ReplayingTestClient struct {
+	*IntegrationTestClient
+	records        []client.KeyRecord
+	commands       [][]interface{}
+	marker         string
+	transactionErr error
+}
+
+func (m *ReplayingTestClient) Exists(key string) (bool, error) {
+	return key == replicationMarkerKey && m.marker != "", nil
+}
+
+func (m *ReplayingTestClient) GetValue(key string) (interface{}, error) {
+	return m.marker, nil
+}
+
+func (m *ReplayingTestClient) GetBatch(keys []string) ([]client.KeyRecord, error) {
+	return nil, client.ErrUnsupported
+}
+
+func (m *ReplayingTestClient) SetBatch(records []client.KeyRecord) ([]error, error) {
+	m.records = append(m.records, records...)
+	return make([]error, len(records)), nil
+}
+
+func (m *ReplayingTestClient) ExistsBatch(keys []string) ([]bool, error) {
+	return make([]bool, len(keys)), nil
+}
+
+// RunCommands fails commands named FAIL
+func (m *ReplayingTestClient) RunCommands(commands [][]interface{}) ([]error, error) {
+	errs := make([]error, len(commands))
+	for i, command := range commands {
+		if command[0] == "FAIL" {
+			errs[i] = errors.New("ERR unknown command")
+			continue
+		}
+		m.run(command)
+	}
+	return errs, nil
+}
+
+// RunTransaction discards the transaction if it holds commands named FAIL
+func (m *ReplayingTestClient) RunTransaction(commands [][]interface{}) ([]error, error) {
+	if m.transactionErr != nil {
+		return nil, m.transactionErr
+	}
+
+	errs := make([]error, len(commands))
+	discarded := false
+	for i, command := range commands {
+		if command[0] == "FAIL" {
+			errs[i] = errors.New("ERR unknown command")
+			discarded = true
+		}
+	}
+	if discarded {
+		return errs, client.ErrTransactionDiscarded
+	}
+
+	for _, command := range commands {
+		m.run(command)
+	}
+	return errs, nil
+}
+
+// run records a command, or applies it to the marker
+func (m *ReplayingTestClient) run(command []interface{}) {
+	switch {
+	case command[0] == "SET" && command[1] == replicationMarkerKey:
+		m.marker = command[2].(string)
+	case command[0] == "DEL" && command[1] == replicationMarkerKey:
+		m.marker = ""
+	default:
+		m.commands = append(m.commands, command)
+	}
+}
+
+func newReplicationTestEngine(t *testing.T, target client.DatabaseClient, engineConfig *EngineConfig) *MigrationEngine {
+	t.Helper()
+	dir := t.TempDir()
+	log, err := logger.NewLogger(logger.Config{Level: "info", OutputFile: filepath.Join(dir, "sync.log"), Format: "text"})
+	require.NoError(t, err)
+
+	engineConfig.ResumeFile = filepath.Join(dir, "resume.json")
+	engine, err := NewMigrationEngine(
+		&IntegrationTestClient{},
+		&client.ClientConfig{Host: "localhost", Port: 6379},
+		target,
+		&client.ClientConfig{Host: "localhost", Port: 6380},
+		log,
+		engineConfig,
+	)
+	require.NoError(t, err)
+	return engine
+}
+
+func TestReplicationApplier(t *testing.T) {
+	target := &ReplayingTestClient{IntegrationTestClient: &IntegrationTestClient{}}
+	engineConfig := DefaultEngineConfig()
+	engineConfig.BatchSize = 100
+	engine := newReplicationTestEngine(t, target, engineConfig)
+
+	applier := newReplicationApplier(engine, 0, false)
+	position := client.ReplicationPosition{ReplID: "abc", Offset: 100}
+	require.NoError(t, applier.FullResync(position))
+
+	expireAt := time.Now().Add(time.Hour)
+	require.NoError(t, applier.SnapshotEntry(&client.RDBEntry{Key: "kept", Type: "string", Value: "v", ExpireAt: expireAt}))
+	require.NoError(t, applier.SnapshotEntry(&client.RDBEntry{DB: 1, Key: "other-db", Type: "string", Value: "v"}))
+	require.NoError(t, applier.SnapshotEntry(&client.RDBEntry{Key: "expired", Type: "string", Value: "v", ExpireAt: time.Now().Add(-time.Second)}))
+	require.NoError(t, applier.SnapshotEntry(&client.RDBEntry{Key: "bloom", Type: "module"}))
+	require.NoError(t, applier.Flush(position))
+
+	require.NoError(t, applier.Command(0, []string{"MULTI"}))
+	require.NoError(t, applier.Command(0, []string{"set", "a", "1"}))
+	require.NoError(t, applier.Command(0, []string{"EXEC"}))
+	require.NoError(t, applier.Command(1, []string{"SET", "b", "1"}))
+	require.NoError(t, applier.Command(1, []string{"FLUSHALL", "ASYNC"}))
+	require.NoError(t, applier.Command(0, []string{"FAIL"}))
+
+	// Nothing is written before the flush
+	assert.Empty(t, target.commands)
+
+	position.Offset = 200
+	require.NoError(t, applier.Flush(position))
+
+	assert.Equal(t, []client.KeyRecord{{Key: "kept", Type: "string", Value: "v", ExpireAt: expireAt}}, target.records)
+	assert.Equal(t, [][]interface{}{{"set", "a", "1"}, {"FLUSHDB", "ASYNC"}}, target.commands)
+
+	loaded, applied, failed := applier.stats()
+	assert.Equal(t, int64(1), loaded)
+	assert.Equal(t, int64(2), applied)
+	assert.Equal(t, int64(2), failed, "the module key and the failed command")
+
+	saved, err := loadResumeState(engineConfig.ResumeFile)
+	require.NoError(t, err)
+	assert.Equal(t, &position, saved.GetReplication())
+
+	// The target holds the position its data corresponds to
+	marker, err := engine.appliedReplication()
+	require.NoError(t, err)
+	assert.Equal(t, &position, marker)
+
+	// A new snapshot invalidates it
+	require.NoError(t, applier.FullResync(client.ReplicationPosition{ReplID: "def", Offset: 10}))
+	marker, err = engine.appliedReplication()
+	require.NoError(t, err)
+	assert.Nil(t, marker)
+}
+
+func TestReplicationApplier_WritesFullBatches(t *testing.T) {
+	target := &ReplayingTestClient{IntegrationTestClient: &IntegrationTestClient{}}
+	engineConfig := DefaultEngineConfig()
+	engineConfig.BatchSize = 2
+	engine := newReplicationTestEngine(t, target, engineConfig)
+
+	applier := newReplicationApplier(engine, 0, false)
+	require.NoError(t, applier.SnapshotEntry(&client.RDBEntry{Key: "a", Type: "string", Value: "1"}))
+	assert.Empty(t, target.records)
+	require.NoError(t, applier.SnapshotEntry(&client.RDBEntry{Key: "b", Type: "string", Value: "1"}))
+	assert.Len(t, target.records, 2)
+
+	// Commands wait for the flush, so that they are applied together with the position
+	require.NoError(t, applier.Command(0, []string{"SET", "a", "2"}))
+	require.NoError(t, applier.Command(0, []string{"SET", "b", "2"}))
+	assert.Empty(t, target.commands)
+
+	// Commands queued when the connection failed are sent again by the source
+	require.NoError(t, applier.Command(0, []string{"SET", "c", "1"}))
+	applier.discard()
+	require.NoError(t, applier.Flush(client.ReplicationPosition{ReplID: "abc", Offset: 10}))
+	assert.Empty(t, target.commands)
+}
+
+func TestReplicationApplier_FailedTransactionIsCritical(t *testing.T) {
+	target := &ReplayingTestClient{IntegrationTestClient: &IntegrationTestClient{}, transactionErr: errors.New("i/o timeout")}
+	engine := newReplicationTestEngine(t, target, DefaultEngineConfig())
+
+	applier := newReplicationApplier(engine, 0, false)
+	require.NoError(t, applier.Command(0, []string{"INCR", "counter"}))
+	err := applier.Flush(client.ReplicationPosition{ReplID: "abc", Offset: 10})
+	require.Error(t, err)
+	assert.True(t, IsCritical(err), "the transaction may have run, so it must not be replayed")
+
+	_, applied, _ := applier.stats()
+	assert.Zero(t, applied)
+	assert.Nil(t, engine.resumeState.GetReplication(), "the position must not be saved")
+}
+
+func TestMigrationEngine_SyncReplicationSetup(t *testing.T) {
+	t.Run("collection patterns", func(t *testing.T) {
+		engineConfig := DefaultEngineConfig()
+		engineConfig.SyncMethod = SyncReplication
+		engineConfig.CollectionPatterns = []string{"user:*"}
+		engine := newReplicationTestEngine(t, &ReplayingTestClient{IntegrationTestClient: &IntegrationTestClient{}}, engineConfig)
+
+		err := engine.Sync()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "does not support collection patterns")
+	})
+
+	t.Run("unknown method", func(t *testing.T) {
+		engineConfig := DefaultEngineConfig()
+		engineConfig.SyncMethod = "polling"
+		engine := newReplicationTestEngine(t, &ReplayingTestClient{IntegrationTestClient: &IntegrationTestClient{}}, engineConfig)
+
+		err := engine.Sync()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown sync method "polling"`)
+	})
+
+	t.Run("cluster target", func(t *testing.T) {
+		engineConfig := DefaultEngineConfig()
+		engineConfig.SyncMethod = SyncReplication
+		engineConfig.ResumeFile = filepath.Join(t.TempDir(), "resume.json")
+		log, err := logger.NewLogger(logger.Config{Level: "info", OutputFile: filepath.Join(t.TempDir(), "sync.log"), Format: "text"})
+		require.NoError(t, err)
+
+		engine, err := NewMigrationEngine(
+			&IntegrationTestClient{},
+			&client.ClientConfig{Host: "localhost", Port: 6379},
+			&ReplayingTestClient{IntegrationTestClient: &IntegrationTestClient{}},
+			&client.ClientConfig{Host: "localhost", Port: 7000, Cluster: true},
+			log,
+			engineConfig,
+		)
+		require.NoError(t, err)
+
+		err = engine.Sync()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "does not support a cluster target")
+	})
+
+	t.Run("target cannot replay commands", func(t *testing.T) {
+		engineConfig := DefaultEngineConfig()
+		engineConfig.SyncMethod = SyncReplication
+		engine := newReplicationTestEngine(t, &IntegrationTestClient{}, engineConfig)
+
+		err := engine.Sync()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "target cannot replay replicated commands")
+	})
+}
+
+func TestCommandKeys(t *testing.T) {
+	testCases := []struct {
+		args []string
+		keys []string
+	}{
+		{[]string{"SET", "a", "1"}, []string{"a"}},
+		{[]string{"del", "a", "b"}, []string{"a", "b"}},
+		{[]string{"MSET", "a", "1", "b", "2"}, []string{"a", "b"}},
+		{[]string{"RENAME", "a", "b"}, []string{"a", "b"}},
+		{[]string{"LMOVE", "a", "b", "LEFT", "RIGHT"}, []string{"a", "b"}},
+		{[]string{"BITOP", "AND", "dest", "a", "b"}, []string{"dest"}},
+		{[]string{"XGROUP", "CREATE", "stream", "group", "$"}, []string{"stream"}},
+		{[]string{"FLUSHDB"}, nil},
+		{[]string{"FLUSHALL", "ASYNC"}, nil},
+		{[]string{"FUNCTION", "LOAD", "code"}, nil},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.keys, commandKeys(tc.args), "%v", tc.args)
+	}
+}
+
+func TestMigrationEngine_DrainReplication(t *testing.T) {
+	newEngine := func(t *testing.T, offset int64) *MigrationEngine {
+		source := &PausingTestClient{NotifyingTestClient: &NotifyingTestClient{IntegrationTestClient: newStringTestClient(nil)}, offset: offset}
+		engine, _ := newCutoverTestEngine(t, source, &ReplayingTestClient{IntegrationTestClient: newStringTestClient(nil)})
+		return engine
+	}
+	position := client.ReplicationPosition{ReplID: "abc", Offset: 500}
+
+	t.Run("caught up", func(t *testing.T) {
+		engine := newEngine(t, 500)
+		replication := client.NewReplication(engine.sourceConfig, position)
+		applier := newReplicationApplier(engine, 0, true)
+
+		drained, err := engine.drainReplication(replication, applier, make(chan struct{}), time.Millisecond, time.Now().Add(time.Second))
+		require.NoError(t, err)
+		assert.Zero(t, drained)
+	})
+
+	t.Run("behind when the pause ends", func(t *testing.T) {
+		engine := newEngine(t, 800)
+		replication := client.NewReplication(engine.sourceConfig, position)
+		applier := newReplicationApplier(engine, 0, true)
+
+		_, err := engine.drainReplication(replication, applier, make(chan struct{}), time.Millisecond, time.Now().Add(50*time.Millisecond))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "replication at offset 500 of 800")
+	})
+
+	t.Run("replication stopped", func(t *testing.T) {
+		engine := newEngine(t, 800)
+		replication := client.NewReplication(engine.sourceConfig, position)
+		applier := newReplicationApplier(engine, 0, true)
+		stopped := make(chan struct{})
+		close(stopped)
+
+		_, err := engine.drainReplication(replication, applier, stopped, time.Millisecond, time.Now().Add(time.Second))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "replication stopped at offset 500 of 800")
+	})
+}
@@ -0,0 +1,488 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+	"github.com/kinyelo/redis-valkey-migration/pkg/logger"
+)
+
+// Sync methods, which decide how changes made on the source after the copy are followed
+const (
+	// SyncNotifications copies the keys reported by keyspace notifications again
+	SyncNotifications = "notifications"
+	// SyncReplication replays the replication stream of the source
+	SyncReplication = "psync"
+)
+
+// syncMaxKeyAttempts is how many sync rounds a changed key is retried in before it is
+// given up until it changes again
+const syncMaxKeyAttempts = 3
+
+// Sync copies every key like Migrate and then keeps the target in step with the source.
+// With the notifications method, keyspace notifications are subscribed to before the
+// bulk copy starts, so that keys changed during and after it are copied again and
+// deleted keys are deleted on the target. With the psync method, the tool acts as a
+// replica of the source instead. Sync runs until the cutover file is created or the
+// migration is shut down. Cutover pauses writes on the source, applies the remaining
+// changes, verifies the recently changed keys and writes the outcome to the cutover
+// result file.
+func (me *MigrationEngine) Sync() error {
+	me.logger.Info("Starting Redis to Valkey live sync")
+
+	if me.config.OnConflict != ConflictOverwrite {
+		return fmt.Errorf("sync mode requires the %s conflict policy, got %s", ConflictOverwrite, me.config.OnConflict)
+	}
+
+	switch me.config.SyncMethod {
+	case "", SyncNotifications:
+	case SyncReplication:
+		// The replication stream carries every key of the source
+		if len(me.config.CollectionPatterns) > 0 {
+			return fmt.Errorf("the %s sync method does not support collection patterns", SyncReplication)
+		}
+		// Commands are replayed with the key names they were written with
+		if len(me.keyRules) > 0 {
+			return fmt.Errorf("the %s sync method does not support key rules", SyncReplication)
+		}
+		// Commands without a key, such as FLUSHALL, would only run on one of the masters
+		if me.targetConfig != nil && me.targetConfig.Cluster {
+			return fmt.Errorf("the %s sync method does not support a cluster target", SyncReplication)
+		}
+	default:
+		return fmt.Errorf("unknown sync method %q, expected %s or %s", me.config.SyncMethod, SyncNotifications, SyncReplication)
+	}
+
+	if err := me.removeCutoverResult(); err != nil {
+		return err
+	}
+	if err := me.removeCutoverRequest(); err != nil {
+		return err
+	}
+
+	// Setup graceful shutdown handling
+	defer me.gracefulShutdown()
+
+	// Start signal handler for graceful shutdown
+	me.shutdownManager.StartSignalHandler()
+
+	if err := me.connectDatabases(); err != nil {
+		return me.failureHandler.HandleCriticalFailure("database connection", err)
+	}
+
+	if me.config.SyncMethod == SyncReplication {
+		return me.syncReplication()
+	}
+
+	if !client.Supports[client.ChunkedClient](me.targetClient) {
+		return me.failureHandler.HandleCriticalFailure("sync setup",
+			NewMigrationError(ConfigurationError, "sync setup", "target cannot delete keys"))
+	}
+
+	if err := me.replicaGate.Wait(me.ctx); err != nil {
+		return me.failureHandler.HandleCriticalFailure("replica lag check", err)
+	}
+
+	// Changes made before the subscription are picked up by the bulk copy, and changes
+	// made after it are reported, so nothing falls in between
+	tracker, err := me.subscribeChanges()
+	if err != nil {
+		return me.failureHandler.HandleCriticalFailure("keyspace notification subscription", err)
+	}
+
+	// Keys copied by an earlier run may have changed while nothing was listening
+	if processed := me.resumeState.GetProcessedCount(); processed > 0 {
+		me.logger.Warnf("Sync mode does not resume, copying the %d keys processed by the previous run again", processed)
+		me.resumeState = me.newResumeState()
+	}
+
+	if err := me.checkKeyRenames(); err != nil {
+		return me.failureHandler.HandleCriticalFailure("key rules", err)
+	}
+
+	me.monitor.Initialize(0)
+	me.logger.Info("Discovering keys to migrate...")
+	keys := me.discoverKeys(me.ctx, nil, nil)
+
+	go me.startProgressReporting()
+
+	migrationErr := me.performMigration(keys)
+	me.compactDeadLetters()
+	if err := keys.Err(); err != nil {
+		return me.failureHandler.HandleCriticalFailure("key discovery", err)
+	}
+	if migrationErr != nil {
+		if IsCritical(migrationErr) {
+			return me.failureHandler.HandleCriticalFailure("migration", migrationErr)
+		}
+		me.logger.Errorf("Bulk copy completed with errors: %v", migrationErr)
+	}
+
+	me.logger.Infof("Bulk copy completed, applying changes from the source until %s is created", me.config.CutoverFile)
+	if err := me.followChanges(tracker); err != nil {
+		if me.ctx.Err() != nil {
+			me.logger.Info("Sync cancelled")
+			return err
+		}
+		return me.failureHandler.HandleCriticalFailure("sync", err)
+	}
+
+	if me.config.VerifyAfterMigration {
+		me.logger.Info("Not verifying every key in sync mode, the recently changed keys were verified at cutover")
+	}
+
+	me.cleanupResumeState()
+
+	me.logger.Info("Sync completed, applications can be switched to the target")
+	return nil
+}
+
+// subscribeChanges enables keyspace notifications on the source and starts tracking the
+// keys they report
+func (me *MigrationEngine) subscribeChanges() (*changeTracker, error) {
+	if err := me.sourceClient.EnableKeyNotifications(); err != nil {
+		if !errors.Is(err, client.ErrNotificationConfigUnavailable) {
+			return nil, err
+		}
+		me.logger.Warnf("Could not check that keyspace notifications are enabled on the source, make sure notify-keyspace-events includes E and A: %v", err)
+	}
+
+	events, err := me.sourceClient.SubscribeKeyEvents(me.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tracker := newChangeTracker(me.config.CollectionPatterns)
+	go tracker.consume(events, me.logger)
+
+	me.logger.Info("Subscribed to keyspace notifications of the source")
+	return tracker, nil
+}
+
+// followChanges applies the tracked changes every sync interval until cutover is requested
+func (me *MigrationEngine) followChanges(tracker *changeTracker) error {
+	interval := me.config.SyncInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := tracker.interruption(); err != nil {
+			return err
+		}
+		if err := me.applyChanges(tracker); err != nil {
+			return err
+		}
+
+		if me.cutoverRequested() {
+			me.logger.Info("Cutover requested, pausing writes and applying the remaining changes")
+			err := me.cutover(func(deadline time.Time) (int64, error) {
+				return me.drainChanges(tracker, interval, deadline)
+			}, tracker.recent)
+
+			applied, deleted, failed := tracker.stats()
+			me.logger.Infof("Sync stopped after applying %d changes (%d keys deleted, %d keys failed)", applied, deleted, failed)
+			if err != nil {
+				return fmt.Errorf("cutover failed: %w", err)
+			}
+			return nil
+		}
+
+		select {
+		case <-me.ctx.Done():
+			return me.ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// drainChanges applies the tracked changes until none are left. Writes on the source
+// are paused by then, so only changes made before the pause remain.
+func (me *MigrationEngine) drainChanges(tracker *changeTracker, interval time.Duration, deadline time.Time) (int64, error) {
+	appliedBefore, _, failedBefore := tracker.stats()
+	drained := func() int64 {
+		applied, _, _ := tracker.stats()
+		return int64(applied - appliedBefore)
+	}
+
+	// Notifications for the last writes may still be on their way
+	select {
+	case <-me.ctx.Done():
+		return 0, me.ctx.Err()
+	case <-time.After(interval):
+	}
+
+	for {
+		if err := tracker.interruption(); err != nil {
+			return drained(), err
+		}
+		if err := me.applyChanges(tracker); err != nil {
+			return drained(), err
+		}
+		pending := tracker.pending()
+		if pending == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			return drained(), fmt.Errorf("%d changes still pending when the write pause ended", pending)
+		}
+	}
+
+	if _, _, failed := tracker.stats(); failed > failedBefore {
+		return drained(), fmt.Errorf("%d changed keys could not be synced", failed-failedBefore)
+	}
+	return drained(), nil
+}
+
+// cutoverRequested reports whether the cutover file exists
+func (me *MigrationEngine) cutoverRequested() bool {
+	if me.config.CutoverFile == "" {
+		return false
+	}
+	_, err := os.Stat(me.config.CutoverFile)
+	return err == nil
+}
+
+// applyChanges copies the keys changed since the last call to the target, using up to
+// MaxConcurrency workers
+func (me *MigrationEngine) applyChanges(tracker *changeTracker) error {
+	keys := tracker.drain()
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if err := me.replicaGate.Wait(me.ctx); err != nil {
+		return err
+	}
+
+	workers := me.config.MaxConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	var stopErr error
+	var stopOnce sync.Once
+	stop := func(err error) {
+		stopOnce.Do(func() { stopErr = err })
+	}
+
+	keyChan := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range keyChan {
+				deleted, err := me.syncKey(key)
+				if err == nil {
+					tracker.applied(key, deleted)
+					continue
+				}
+
+				if IsCritical(err) || !me.config.ContinueOnError {
+					stop(err)
+					continue
+				}
+				if tracker.retry(key) {
+					me.logger.Warnf("Failed to sync key %s, retrying: %v", key, err)
+				} else {
+					me.logger.Errorf("Failed to sync key %s after %d attempts, giving up until it changes again: %v", key, syncMaxKeyAttempts, err)
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, key := range keys {
+		select {
+		case keyChan <- key:
+		case <-me.ctx.Done():
+			break feed
+		}
+	}
+	close(keyChan)
+	wg.Wait()
+
+	if stopErr != nil {
+		return stopErr
+	}
+	if err := me.ctx.Err(); err != nil {
+		return err
+	}
+
+	me.logger.Debugf("Applied %d changed keys, %d changes pending", len(keys), tracker.pending())
+	return nil
+}
+
+// syncKey copies a changed key to the target, or deletes it there if it no longer exists
+// on the source. It reports whether the key was deleted.
+func (me *MigrationEngine) syncKey(key string) (bool, error) {
+	keyType, err := me.sourceClient.GetKeyType(key)
+	if err != nil {
+		return false, WrapError(err, "get key type").WithKey(key)
+	}
+
+	if keyType != "none" {
+		err := me.processor.ProcessKey(key, keyType, me.sourceClient, me.targetClient)
+		if err == nil {
+			return false, nil
+		}
+
+		// The key may have been deleted while it was being copied
+		if current, typeErr := me.sourceClient.GetKeyType(key); typeErr != nil || current != "none" {
+			return false, WrapError(err, "key processing").WithKey(key)
+		}
+	}
+
+	if err := me.targetClient.DeleteKey(me.targetKey(key)); err != nil {
+		return false, WrapError(err, "delete key").WithKey(key)
+	}
+	return true, nil
+}
+
+// changeTracker collects the keys reported changed by keyspace notifications. A key is
+// kept once however often it changes, so a burst of writes to it costs a single copy.
+type changeTracker struct {
+	mu       sync.Mutex
+	keys     map[string]struct{}
+	attempts map[string]int
+	patterns []string
+
+	// recent holds the last keys applied, which are verified at cutover
+	recent *recentKeys
+
+	// interrupted is set once the subscription was interrupted, after which changes may
+	// have been missed and the sync cannot cut over
+	interrupted error
+
+	appliedCount int
+	deletedCount int
+	failedCount  int
+}
+
+// newChangeTracker creates a tracker for the keys matching patterns, or every key if empty
+func newChangeTracker(patterns []string) *changeTracker {
+	return &changeTracker{
+		keys:     make(map[string]struct{}),
+		attempts: make(map[string]int),
+		patterns: patterns,
+		recent:   newRecentKeys(cutoverRecentKeys),
+	}
+}
+
+// consume tracks the keys of events until the subscription is closed. An interruption of
+// the subscription is recorded, as the keys changed or deleted while it was down are unknown.
+func (t *changeTracker) consume(events <-chan client.KeyEvent, logger logger.Logger) {
+	for event := range events {
+		if event.Err != nil {
+			logger.Warnf("Changes on the source may have been missed, the sync cannot cut over: %v", event.Err)
+			t.mu.Lock()
+			if t.interrupted == nil {
+				t.interrupted = event.Err
+			}
+			t.mu.Unlock()
+			continue
+		}
+		t.add(event.Key)
+	}
+}
+
+// interruption returns an error if the subscription was interrupted, nil otherwise
+func (t *changeTracker) interruption() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.interrupted == nil {
+		return nil
+	}
+	return fmt.Errorf("changes made on the source may be missing on the target, start the sync again: %w", t.interrupted)
+}
+
+// add tracks a changed key if it matches the patterns
+func (t *changeTracker) add(key string) {
+	if !t.matches(key) {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.keys[key] = struct{}{}
+	// A new change gives a key that was given up on a fresh set of attempts
+	delete(t.attempts, key)
+}
+
+// matches reports whether a key matches one of the patterns
+func (t *changeTracker) matches(key string) bool {
+	if len(t.patterns) == 0 {
+		return true
+	}
+	for _, pattern := range t.patterns {
+		if matched, _ := filepath.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// drain returns the tracked keys and stops tracking them
+func (t *changeTracker) drain() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	keys := make([]string, 0, len(t.keys))
+	for key := range t.keys {
+		keys = append(keys, key)
+	}
+	t.keys = make(map[string]struct{})
+	return keys
+}
+
+// pending returns the number of tracked keys
+func (t *changeTracker) pending() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.keys)
+}
+
+// applied records a key that was copied or deleted
+func (t *changeTracker) applied(key string, deleted bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.attempts, key)
+	t.recent.add(key)
+	t.appliedCount++
+	if deleted {
+		t.deletedCount++
+	}
+}
+
+// retry tracks a key that failed to sync again, unless it has used up its attempts.
+// It reports whether the key will be retried.
+func (t *changeTracker) retry(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.attempts[key]++
+	if t.attempts[key] >= syncMaxKeyAttempts {
+		delete(t.attempts, key)
+		t.failedCount++
+		return false
+	}
+	t.keys[key] = struct{}{}
+	return true
+}
+
+// stats returns how many changes were applied, how many of them were deletions and how
+// many keys were given up on
+func (t *changeTracker) stats() (applied, deleted, failed int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.appliedCount, t.deletedCount, t.failedCount
+}
@@ -0,0 +1,308 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+	"github.com/kinyelo/redis-valkey-migration/pkg/logger"
+)
+
+func TestChangeTracker(t *testing.T) {
+	tracker := newChangeTracker([]string{"user:*"})
+
+	tracker.add("user:1")
+	tracker.add("user:1")
+	tracker.add("user:2")
+	tracker.add("session:1")
+	assert.Equal(t, 2, tracker.pending(), "changes are deduplicated and filtered by pattern")
+
+	keys := tracker.drain()
+	sort.Strings(keys)
+	assert.Equal(t, []string{"user:1", "user:2"}, keys)
+	assert.Equal(t, 0, tracker.pending())
+
+	// A failing key is retried until it has used up its attempts
+	for attempt := 1; attempt < syncMaxKeyAttempts; attempt++ {
+		assert.True(t, tracker.retry("user:1"))
+		assert.Equal(t, []string{"user:1"}, tracker.drain())
+	}
+	assert.False(t, tracker.retry("user:1"))
+	assert.Equal(t, 0, tracker.pending())
+
+	// A new change gives it a fresh set of attempts
+	tracker.add("user:1")
+	tracker.drain()
+	assert.True(t, tracker.retry("user:1"))
+
+	tracker.applied("user:2", false)
+	tracker.applied("user:3", true)
+	applied, deleted, failed := tracker.stats()
+	assert.Equal(t, 2, applied)
+	assert.Equal(t, 1, deleted)
+	assert.Equal(t, 1, failed)
+}
+
+func TestChangeTracker_ConsumeRecordsInterruptions(t *testing.T) {
+	log := newGateLogger()
+	tracker := newChangeTracker(nil)
+
+	events := make(chan client.KeyEvent, 3)
+	events <- client.KeyEvent{Event: "set", Key: "a"}
+	events <- client.KeyEvent{Err: errors.New("keyspace notification subscription interrupted")}
+	events <- client.KeyEvent{Event: "del", Key: "b"}
+	close(events)
+
+	assert.NoError(t, tracker.interruption())
+	tracker.consume(events, log)
+	assert.Equal(t, 2, tracker.pending())
+	log.AssertNumberOfCalls(t, "Warnf", 1)
+	assert.ErrorContains(t, tracker.interruption(), "subscription interrupted")
+}
+
+// NotifyingTestClient adds keyspace notifications to IntegrationTestClient
+type NotifyingTestClient struct {
+	*IntegrationTestClient
+	events chan client.KeyEvent
+}
+
+func (m *NotifyingTestClient) GetKeyType(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if keyType, exists := m.keyTypes[key]; exists {
+		return keyType, nil
+	}
+	return "none", nil
+}
+
+func (m *NotifyingTestClient) EnableKeyNotifications() error {
+	return nil
+}
+
+func (m *NotifyingTestClient) SubscribeKeyEvents(ctx context.Context) (<-chan client.KeyEvent, error) {
+	out := make(chan client.KeyEvent)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case event := <-m.events:
+				out <- event
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// write changes a string key and publishes its notification
+func (m *NotifyingTestClient) write(key, value string) {
+	m.mu.Lock()
+	m.keys[key] = value
+	m.keyTypes[key] = "string"
+	m.mu.Unlock()
+	m.events <- client.KeyEvent{Event: "set", Key: key}
+}
+
+// delete removes a key and publishes its notification
+func (m *NotifyingTestClient) delete(key string) {
+	m.mu.Lock()
+	delete(m.keys, key)
+	delete(m.keyTypes, key)
+	m.mu.Unlock()
+	m.events <- client.KeyEvent{Event: "del", Key: key}
+}
+
+// DeletingTestClient adds the chunked transfer operations, including DeleteKey, to IntegrationTestClient
+type DeletingTestClient struct {
+	*IntegrationTestClient
+}
+
+func (m *DeletingTestClient) KeySizeAndExpiry(key, keyType string) (int64, time.Time, bool, error) {
+	return 0, time.Time{}, false, client.ErrUnsupported
+}
+
+func (m *DeletingTestClient) ReadChunk(key, keyType string, cursor uint64, count int64) (interface{}, uint64, error) {
+	return nil, 0, client.ErrUnsupported
+}
+
+func (m *DeletingTestClient) AppendChunk(key, keyType string, chunk interface{}) error {
+	return client.ErrUnsupported
+}
+
+func (m *DeletingTestClient) RenameKey(key, newKey string) error {
+	return client.ErrUnsupported
+}
+
+func (m *DeletingTestClient) DeleteKey(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.keys, key)
+	delete(m.keyTypes, key)
+	return nil
+}
+
+// value returns the value of a key (helper for tests)
+func (m *DeletingTestClient) value(key string) interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.keys[key]
+}
+
+// newSyncTestEngine creates an engine syncing two string keys from a source publishing
+// keyspace notifications
+func newSyncTestEngine(t *testing.T) (*MigrationEngine, *EngineConfig, *NotifyingTestClient, *DeletingTestClient) {
+	dir := t.TempDir()
+	log, err := logger.NewLogger(logger.Config{Level: "info", OutputFile: filepath.Join(dir, "sync.log"), Format: "text"})
+	require.NoError(t, err)
+
+	source := &NotifyingTestClient{
+		IntegrationTestClient: &IntegrationTestClient{
+			keys:     map[string]interface{}{"user:1": "alice", "user:2": "bob"},
+			keyTypes: map[string]string{"user:1": "string", "user:2": "string"},
+		},
+		events: make(chan client.KeyEvent, 10),
+	}
+	target := &DeletingTestClient{
+		IntegrationTestClient: &IntegrationTestClient{
+			keys:     make(map[string]interface{}),
+			keyTypes: make(map[string]string),
+		},
+	}
+
+	engineConfig := DefaultEngineConfig()
+	engineConfig.BatchSize = 1
+	engineConfig.ResumeFile = filepath.Join(dir, "resume.json")
+	engineConfig.CutoverFile = filepath.Join(dir, "cutover")
+	engineConfig.CutoverResultFile = filepath.Join(dir, "cutover.json")
+	engineConfig.SyncInterval = 10 * time.Millisecond
+	engineConfig.ProgressInterval = time.Second
+
+	engine, err := NewMigrationEngine(
+		source,
+		&client.ClientConfig{Host: "localhost", Port: 6379},
+		target,
+		&client.ClientConfig{Host: "localhost", Port: 6380},
+		log,
+		engineConfig,
+	)
+	require.NoError(t, err)
+	return engine, engineConfig, source, target
+}
+
+func TestMigrationEngine_Sync(t *testing.T) {
+	engine, engineConfig, source, target := newSyncTestEngine(t)
+
+	done := make(chan error, 1)
+	go func() { done <- engine.Sync() }()
+
+	// Bulk copy
+	require.Eventually(t, func() bool { return target.value("user:1") == "alice" && target.value("user:2") == "bob" },
+		2*time.Second, 5*time.Millisecond)
+
+	// Changes after the bulk copy
+	source.write("user:1", "alice-v2")
+	source.write("user:3", "carol")
+	source.delete("user:2")
+
+	require.Eventually(t, func() bool {
+		return target.value("user:1") == "alice-v2" && target.value("user:3") == "carol" && !target.HasKey("user:2")
+	}, 2*time.Second, 5*time.Millisecond)
+
+	require.NoError(t, os.WriteFile(engineConfig.CutoverFile, nil, 0644))
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("sync did not stop after cutover was requested")
+	}
+
+	// The changed keys are verified at cutover
+	result := readCutoverResult(t, engineConfig.CutoverResultFile)
+	assert.True(t, result.Success, result.Error)
+	assert.Equal(t, 3, result.VerifiedKeys)
+	assert.NoFileExists(t, engineConfig.CutoverFile, "the cutover file must not stop the next sync")
+}
+
+func TestMigrationEngine_SyncRemovesLeftoverCutoverFile(t *testing.T) {
+	engine, engineConfig, _, target := newSyncTestEngine(t)
+	require.NoError(t, os.WriteFile(engineConfig.CutoverFile, nil, 0644))
+
+	done := make(chan error, 1)
+	go func() { done <- engine.Sync() }()
+
+	require.Eventually(t, func() bool { return target.value("user:1") == "alice" && target.value("user:2") == "bob" },
+		2*time.Second, 5*time.Millisecond)
+	assert.NoFileExists(t, engineConfig.CutoverFile)
+
+	// The sync keeps running until the cutover file is created again
+	select {
+	case err := <-done:
+		t.Fatalf("sync stopped before cutover was requested: %v", err)
+	case <-time.After(5 * engineConfig.SyncInterval):
+	}
+
+	require.NoError(t, os.WriteFile(engineConfig.CutoverFile, nil, 0644))
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("sync did not stop after cutover was requested")
+	}
+}
+
+func TestMigrationEngine_SyncStopsWhenNotificationsAreInterrupted(t *testing.T) {
+	engine, _, source, target := newSyncTestEngine(t)
+
+	done := make(chan error, 1)
+	go func() { done <- engine.Sync() }()
+
+	require.Eventually(t, func() bool { return target.value("user:1") == "alice" && target.value("user:2") == "bob" },
+		2*time.Second, 5*time.Millisecond)
+
+	// A key deleted while the subscription was down is never reported
+	source.events <- client.KeyEvent{Err: errors.New("keyspace notification subscription interrupted: EOF")}
+
+	select {
+	case err := <-done:
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "start the sync again")
+	case <-time.After(2 * time.Second):
+		t.Fatal("sync did not stop after its notifications were interrupted")
+	}
+}
+
+func TestMigrationEngine_SyncRequiresOverwrite(t *testing.T) {
+	engineConfig := DefaultEngineConfig()
+	engineConfig.OnConflict = ConflictSkip
+	engineConfig.ResumeFile = filepath.Join(t.TempDir(), "resume.json")
+
+	mockLogger := newGateLogger()
+	mockLogger.On("Info", mock.Anything).Return()
+
+	engine, err := NewMigrationEngine(
+		&IntegrationTestClient{},
+		&client.ClientConfig{},
+		&IntegrationTestClient{},
+		&client.ClientConfig{},
+		mockLogger,
+		engineConfig,
+	)
+	require.NoError(t, err)
+
+	err = engine.Sync()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sync mode requires the overwrite conflict policy")
+}
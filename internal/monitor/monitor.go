@@ -43,6 +43,8 @@ type MigrationStats struct {
 	ProcessedKeys    int
 	SuccessfulKeys   int
 	FailedKeys       int
+	SkippedKeys      int // Keys left alone because they already existed on the target
+	ConflictKeys     int // Keys that already existed on the target, whatever the conflict policy did with them
 	BytesTransferred int64
 	Duration         time.Duration
 	Throughput       float64
@@ -98,6 +100,15 @@ func (pm *ProgressMonitor) Initialize(totalKeys int) {
 	pm.lastReported = time.Now()
 }
 
+// AddKeys adds keys found while the migration is running to the total key count
+func (pm *ProgressMonitor) AddKeys(count int) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pm.TotalKeys += count
+	pm.Statistics.TotalKeys += count
+}
+
 // IncrementProcessed increments the processed key count
 func (pm *ProgressMonitor) IncrementProcessed() {
 	pm.mu.Lock()
@@ -126,6 +137,25 @@ func (pm *ProgressMonitor) IncrementFailed() {
 	pm.Statistics.FailedKeys++
 }
 
+// IncrementSkipped increments the processed key count for a key that was not migrated
+// because it already existed on the target
+func (pm *ProgressMonitor) IncrementSkipped() {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pm.ProcessedKeys++
+	pm.Statistics.ProcessedKeys++
+	pm.Statistics.SkippedKeys++
+}
+
+// IncrementConflicts increments the count of keys that already existed on the target
+func (pm *ProgressMonitor) IncrementConflicts() {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pm.Statistics.ConflictKeys++
+}
+
 // GetStats returns current migration statistics
 func (pm *ProgressMonitor) GetStats() MigrationStats {
 	pm.mu.RLock()
@@ -319,6 +349,8 @@ func (pm *ProgressMonitor) PrintSummary() {
 	fmt.Printf("Total Keys: %d\n", stats.TotalKeys)
 	fmt.Printf("Successful: %d\n", stats.SuccessfulKeys)
 	fmt.Printf("Failed: %d\n", stats.FailedKeys)
+	fmt.Printf("Skipped: %d\n", stats.SkippedKeys)
+	fmt.Printf("Conflicts: %d\n", stats.ConflictKeys)
 	fmt.Printf("Success Rate: %.2f%%\n", pm.calculateSuccessRate(stats))
 	fmt.Printf("Duration: %v\n", stats.Duration.Truncate(time.Millisecond))
 	fmt.Printf("Average Rate: %.2f keys/second\n", pm.calculateRate(stats))
@@ -93,6 +93,38 @@ func TestProgressMonitor_RecordFailure(t *testing.T) {
 	assert.False(t, errors[0].Timestamp.IsZero())
 }
 
+func TestProgressMonitor_IncrementSkippedAndConflicts(t *testing.T) {
+	monitor := createTestMonitor()
+	monitor.Initialize(3)
+
+	monitor.IncrementConflicts()
+	monitor.IncrementSkipped()
+	monitor.IncrementConflicts()
+	monitor.IncrementProcessed()
+	monitor.IncrementProcessed()
+
+	stats := monitor.GetStats()
+	assert.Equal(t, 3, stats.ProcessedKeys)
+	assert.Equal(t, 2, stats.SuccessfulKeys)
+	assert.Equal(t, 1, stats.SkippedKeys)
+	assert.Equal(t, 2, stats.ConflictKeys)
+	assert.Equal(t, 0, stats.FailedKeys)
+}
+
+func TestProgressMonitor_AddKeys(t *testing.T) {
+	monitor := createTestMonitor()
+	monitor.Initialize(0)
+
+	monitor.AddKeys(100)
+	monitor.IncrementProcessed()
+	monitor.AddKeys(50)
+
+	processed, total, _, _ := monitor.GetProgress()
+	assert.Equal(t, 1, processed)
+	assert.Equal(t, 150, total)
+	assert.Equal(t, 150, monitor.GetStats().TotalKeys)
+}
+
 func TestProgressMonitor_GetProgress(t *testing.T) {
 	monitor := createTestMonitor()
 	totalKeys := 100
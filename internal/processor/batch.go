@@ -0,0 +1,163 @@
+package processor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+)
+
+// BatchProcessor is implemented by processors that can migrate several keys per round trip
+type BatchProcessor interface {
+	// ProcessBatch migrates keys using pipelined reads and writes and returns one error
+	// per key (nil on success). The returned error is only set when the batch could not
+	// be processed at all, in which case the caller should fall back to ProcessKey.
+	ProcessBatch(keys []string, source, target client.DatabaseClient) ([]error, error)
+}
+
+// ProcessBatch migrates keys using pipelined reads and writes
+func (p *migrationProcessor) ProcessBatch(keys []string, source, target client.DatabaseClient) ([]error, error) {
+	if !client.Supports[client.BatchClient](source) || !client.Supports[client.BatchClient](target) {
+		return nil, client.ErrUnsupported
+	}
+
+	startTime := time.Now()
+
+	records, err := source.(client.BatchClient).GetBatch(keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch of %d keys: %w", len(keys), err)
+	}
+
+	errs := make([]error, len(keys))
+	sizes := make([]int64, len(keys))
+	writes := make([]client.KeyRecord, 0, len(records))
+	writeIndexes := make([]int, 0, len(records))
+
+	for i, record := range records {
+		if record.Err != nil {
+			p.logger.LogKeyTransfer(record.Key, record.Type, 0, false, time.Since(startTime), record.Err.Error())
+			errs[i] = fmt.Errorf("failed to get value for key %s: %w", record.Key, record.Err)
+			continue
+		}
+
+		if record.Type == "none" {
+			// Key doesn't exist (expired, deleted, or temporary)
+			p.logger.Warnf("Key '%s' no longer exists (type: none). Skipping migration.", record.Key)
+			continue
+		}
+
+		if (keyExpiry{at: record.ExpireAt}).expired() {
+			p.logExpiredDuringTransfer(record.Key)
+			continue
+		}
+
+		if record.Oversized {
+			// Too large to read in one piece, so it is transferred on its own in chunks
+			errs[i] = p.processOversized(record, source, target)
+			continue
+		}
+
+		value, size, err := p.batchWriteValue(record)
+		if err != nil {
+			p.logger.LogKeyTransfer(record.Key, record.Type, 0, false, time.Since(startTime), err.Error())
+			errs[i] = err
+			continue
+		}
+
+		// Log large data detection
+		p.logLargeDataDetection(record.Key, record.Type, size)
+
+		record.Key = p.targetKey(record.Key)
+		record.Value = p.valueForWrite(value)
+		sizes[i] = size
+		writes = append(writes, record)
+		writeIndexes = append(writeIndexes, i)
+	}
+
+	if len(writes) == 0 {
+		return errs, nil
+	}
+
+	writeErrs, err := target.(client.BatchClient).SetBatch(writes)
+	if err != nil {
+		// The whole pipeline failed, so every key in it failed
+		writeErrs = make([]error, len(writes))
+		for j := range writeErrs {
+			writeErrs[j] = err
+		}
+	}
+
+	duration := time.Since(startTime)
+	for j, i := range writeIndexes {
+		record := records[i]
+		if writeErrs[j] != nil {
+			p.logger.LogKeyTransfer(record.Key, record.Type, sizes[i], false, duration, writeErrs[j].Error())
+			errs[i] = fmt.Errorf("failed to set %s value for key %s: %w", record.Type, record.Key, writeErrs[j])
+			continue
+		}
+		p.logger.LogKeyTransfer(record.Key, record.Type, sizes[i], true, duration, "")
+	}
+
+	return errs, nil
+}
+
+// batchWriteValue converts a value read by GetBatch into the form expected by SetValue
+// and returns its size using the same measure as the per-type handlers
+func (p *migrationProcessor) batchWriteValue(record client.KeyRecord) (interface{}, int64, error) {
+	switch record.Type {
+	case "string":
+		if v, ok := record.Value.(string); ok {
+			return v, int64(len(v)), nil
+		}
+	case "hash":
+		if v, ok := record.Value.(map[string]string); ok {
+			return v, int64(len(v)), nil
+		}
+	case "list":
+		if v, ok := record.Value.([]string); ok {
+			return v, int64(len(v)), nil
+		}
+	case "set":
+		if v, ok := record.Value.([]string); ok {
+			members := make([]interface{}, len(v))
+			for i, member := range v {
+				members[i] = member
+			}
+			return members, int64(len(v)), nil
+		}
+	case "zset":
+		if v, ok := record.Value.([]redis.Z); ok {
+			return v, int64(len(v)), nil
+		}
+	case "stream":
+		if v, ok := record.Value.(*client.StreamValue); ok {
+			return p.streamForWrite(v), int64(len(v.Entries)), nil
+		}
+	default:
+		return nil, 0, fmt.Errorf("unsupported key type: %s", record.Type)
+	}
+
+	return nil, 0, fmt.Errorf("unexpected %s value for key %s: %T", record.Type, record.Key, record.Value)
+}
+
+// valueForWrite wraps a value so that it is merged into the existing target key when
+// merging was requested
+func (p *migrationProcessor) valueForWrite(value interface{}) interface{} {
+	if p.mergeExisting {
+		return client.MergeValue{Value: value}
+	}
+	return value
+}
+
+// processOversized transfers a collection the batch read found too large to read in one
+// piece, in chunks if both clients support it
+func (p *migrationProcessor) processOversized(record client.KeyRecord, source, target client.DatabaseClient) error {
+	if client.Supports[client.ChunkedClient](source) && client.Supports[client.ChunkedClient](target) {
+		if done, err := p.transferLargeKey(record.Key, record.Type, record.Size, source, target); done {
+			return err
+		}
+	}
+	return p.ProcessKey(record.Key, record.Type, source, target)
+}
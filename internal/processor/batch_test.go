@@ -0,0 +1,239 @@
+package processor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+)
+
+// mockBatchClient extends MockDatabaseClient with pipelined batch operations
+type mockBatchClient struct {
+	*MockDatabaseClient
+	types      map[string]string
+	readErrors map[string]error
+	expireAts  map[string]time.Time
+	writeErr   error
+	getCalls   int
+	setCalls   int
+}
+
+func newMockBatchClient() *mockBatchClient {
+	return &mockBatchClient{
+		MockDatabaseClient: NewMockDatabaseClient(),
+		types:              make(map[string]string),
+		readErrors:         make(map[string]error),
+		expireAts:          make(map[string]time.Time),
+	}
+}
+
+func (m *mockBatchClient) GetBatch(keys []string) ([]client.KeyRecord, error) {
+	m.getCalls++
+	records := make([]client.KeyRecord, len(keys))
+	for i, key := range keys {
+		records[i].Key = key
+		if err, exists := m.readErrors[key]; exists {
+			records[i].Err = err
+			continue
+		}
+		keyType, exists := m.types[key]
+		if !exists {
+			records[i].Type = "none"
+			continue
+		}
+		records[i].Type = keyType
+		records[i].Value = m.data[key]
+		records[i].ExpireAt = m.expireAts[key]
+	}
+	return records, nil
+}
+
+func (m *mockBatchClient) SetBatch(records []client.KeyRecord) ([]error, error) {
+	m.setCalls++
+	if m.writeErr != nil {
+		return nil, m.writeErr
+	}
+	errs := make([]error, len(records))
+	for _, record := range records {
+		m.data[record.Key] = record.Value
+		if !record.ExpireAt.IsZero() {
+			m.expireAts[record.Key] = record.ExpireAt
+		}
+	}
+	return errs, nil
+}
+
+func (m *mockBatchClient) ExistsBatch(keys []string) ([]bool, error) {
+	exists := make([]bool, len(keys))
+	for i, key := range keys {
+		_, exists[i] = m.data[key]
+	}
+	return exists, nil
+}
+
+func newBatchTestLogger() *MockLogger {
+	mockLogger := &MockLogger{}
+	mockLogger.On("LogKeyTransfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+	mockLogger.On("Warnf", mock.Anything, mock.Anything).Return()
+	mockLogger.On("Infof", mock.Anything, mock.Anything).Return()
+	return mockLogger
+}
+
+func TestProcessBatch_AllTypes(t *testing.T) {
+	source := newMockBatchClient()
+	target := newMockBatchClient()
+	mockLogger := newBatchTestLogger()
+
+	source.data["s"], source.types["s"] = "hello", "string"
+	expireAt := time.Now().Add(30 * time.Second)
+	source.expireAts["s"] = expireAt
+	source.data["h"], source.types["h"] = map[string]string{"f": "v"}, "hash"
+	source.data["l"], source.types["l"] = []string{"a", "b"}, "list"
+	source.data["st"], source.types["st"] = []string{"x", "y"}, "set"
+	source.data["z"], source.types["z"] = []redis.Z{{Score: 1, Member: "m"}}, "zset"
+
+	processor := NewDataProcessor(mockLogger).(BatchProcessor)
+	keys := []string{"s", "h", "l", "st", "z"}
+
+	errs, err := processor.ProcessBatch(keys, source, target)
+	require.NoError(t, err)
+	require.Len(t, errs, len(keys))
+	for _, keyErr := range errs {
+		assert.NoError(t, keyErr)
+	}
+
+	assert.Equal(t, 1, source.getCalls)
+	assert.Equal(t, 1, target.setCalls)
+	assert.Equal(t, "hello", target.data["s"])
+	assert.Equal(t, expireAt, target.expireAts["s"])
+	assert.Equal(t, map[string]string{"f": "v"}, target.data["h"])
+	assert.Equal(t, []string{"a", "b"}, target.data["l"])
+	assert.Equal(t, []interface{}{"x", "y"}, target.data["st"])
+	assert.Equal(t, []redis.Z{{Score: 1, Member: "m"}}, target.data["z"])
+
+	mockLogger.AssertNumberOfCalls(t, "LogKeyTransfer", len(keys))
+}
+
+func TestProcessBatch_RenamesKeys(t *testing.T) {
+	source := newMockBatchClient()
+	target := newMockBatchClient()
+	mockLogger := newBatchTestLogger()
+
+	source.data["app1:s"], source.types["app1:s"] = "hello", "string"
+	source.data["app1:h"], source.types["app1:h"] = map[string]string{"f": "v"}, "hash"
+
+	processor := NewDataProcessorWithOptions(mockLogger, Options{
+		RenameKey: func(key string) string { return "tenant1:" + key },
+	}).(BatchProcessor)
+
+	errs, err := processor.ProcessBatch([]string{"app1:s", "app1:h"}, source, target)
+	require.NoError(t, err)
+	assert.Equal(t, []error{nil, nil}, errs)
+
+	assert.Equal(t, map[string]interface{}{
+		"tenant1:app1:s": "hello",
+		"tenant1:app1:h": map[string]string{"f": "v"},
+	}, target.data)
+
+	// Transfers are logged under the source key
+	mockLogger.AssertCalled(t, "LogKeyTransfer", "app1:s", "string", mock.Anything, true, mock.Anything, "")
+}
+
+func TestProcessBatch_PerKeyFailures(t *testing.T) {
+	source := newMockBatchClient()
+	target := newMockBatchClient()
+	mockLogger := newBatchTestLogger()
+
+	source.data["good"], source.types["good"] = "value", "string"
+	source.readErrors["broken"] = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	source.data["mismatch"], source.types["mismatch"] = 42, "string"
+
+	processor := NewDataProcessor(mockLogger).(BatchProcessor)
+	errs, err := processor.ProcessBatch([]string{"good", "broken", "gone", "mismatch"}, source, target)
+	require.NoError(t, err)
+
+	assert.NoError(t, errs[0])
+	assert.Error(t, errs[1])
+	assert.NoError(t, errs[2], "keys that disappeared are skipped, not failed")
+	assert.Error(t, errs[3])
+
+	assert.Equal(t, "value", target.data["good"])
+	_, exists := target.data["gone"]
+	assert.False(t, exists)
+
+	mockLogger.AssertCalled(t, "LogKeyTransfer", "broken", mock.Anything, int64(0), false, mock.Anything, mock.Anything)
+	mockLogger.AssertCalled(t, "LogKeyTransfer", "good", "string", int64(5), true, mock.Anything, "")
+}
+
+func TestProcessBatch_SkipsKeysExpiredDuringTransfer(t *testing.T) {
+	source := newMockBatchClient()
+	target := newMockBatchClient()
+	mockLogger := newBatchTestLogger()
+
+	source.data["gone"], source.types["gone"] = "stale", "string"
+	source.expireAts["gone"] = time.Now().Add(-time.Millisecond)
+	source.data["live"], source.types["live"] = "fresh", "string"
+
+	processor := NewDataProcessor(mockLogger).(BatchProcessor)
+	errs, err := processor.ProcessBatch([]string{"gone", "live"}, source, target)
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+
+	_, written := target.data["gone"]
+	assert.False(t, written)
+	assert.Equal(t, "fresh", target.data["live"])
+	mockLogger.AssertCalled(t, "Warnf", "Key '%s' expired or was deleted during migration. Skipping migration.", []interface{}{"gone"})
+}
+
+func TestProcessBatch_MergeExistingWrapsValues(t *testing.T) {
+	source := newMockBatchClient()
+	target := newMockBatchClient()
+	mockLogger := newBatchTestLogger()
+
+	source.data["h"], source.types["h"] = map[string]string{"f": "v"}, "hash"
+	source.data["s"], source.types["s"] = "hello", "string"
+
+	processor := NewDataProcessorWithOptions(mockLogger, Options{MergeExisting: true}).(BatchProcessor)
+	errs, err := processor.ProcessBatch([]string{"h", "s"}, source, target)
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+
+	assert.Equal(t, client.MergeValue{Value: map[string]string{"f": "v"}}, target.data["h"])
+	assert.Equal(t, client.MergeValue{Value: "hello"}, target.data["s"])
+}
+
+func TestProcessBatch_WriteFailureFailsEveryKey(t *testing.T) {
+	source := newMockBatchClient()
+	target := newMockBatchClient()
+	target.writeErr = errors.New("i/o timeout")
+	mockLogger := newBatchTestLogger()
+
+	source.data["a"], source.types["a"] = "1", "string"
+	source.data["b"], source.types["b"] = "2", "string"
+
+	processor := NewDataProcessor(mockLogger).(BatchProcessor)
+	errs, err := processor.ProcessBatch([]string{"a", "b"}, source, target)
+	require.NoError(t, err)
+	assert.Error(t, errs[0])
+	assert.Error(t, errs[1])
+	mockLogger.AssertNumberOfCalls(t, "LogKeyTransfer", 2)
+}
+
+func TestProcessBatch_UnsupportedClients(t *testing.T) {
+	mockLogger := newBatchTestLogger()
+	processor := NewDataProcessor(mockLogger).(BatchProcessor)
+
+	_, err := processor.ProcessBatch([]string{"a"}, NewMockDatabaseClient(), newMockBatchClient())
+	assert.ErrorIs(t, err, client.ErrUnsupported)
+
+	_, err = processor.ProcessBatch([]string{"a"}, newMockBatchClient(), NewMockDatabaseClient())
+	assert.ErrorIs(t, err, client.ErrUnsupported)
+}
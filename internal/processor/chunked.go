@@ -0,0 +1,175 @@
+package processor
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+)
+
+// chunkSize is the number of elements requested per chunk when transferring large collections
+const chunkSize = 1000
+
+// tempKeySuffix marks the temporary keys large collections are assembled in on the target
+const tempKeySuffix = ":redis-valkey-migration-tmp:"
+
+// migrateLargeKey transfers a hash, set, sorted set or list larger than the large data
+// threshold a chunk at a time, reading its size together with its expiry. It returns
+// true if it has migrated the key, or found that it no longer exists. Otherwise the
+// caller migrates the key in one piece, with the expiry returned if it was read.
+func (p *migrationProcessor) migrateLargeKey(key, keyType string, source, target client.DatabaseClient) (bool, *keyExpiry, error) {
+	if p.timeoutConfig == nil {
+		return false, nil, nil
+	}
+
+	if !client.Supports[client.ChunkedClient](source) || !client.Supports[client.ChunkedClient](target) {
+		return false, nil, nil
+	}
+
+	size, expireAt, exists, err := source.(client.ChunkedClient).KeySizeAndExpiry(key, keyType)
+	if err != nil {
+		// Migrate in one piece if the size is unknown
+		return false, nil, nil
+	}
+	if !exists {
+		p.logExpiredDuringTransfer(key)
+		return true, nil, nil
+	}
+	if size <= p.timeoutConfig.LargeDataThreshold {
+		return false, &keyExpiry{at: expireAt, ttl: -1}, nil
+	}
+
+	done, err := p.transferLargeKey(key, keyType, size, source, target)
+	return done, nil, err
+}
+
+// transferLargeKey transfers a collection of the given size a chunk at a time. The chunks
+// are written to a temporary key that is renamed over the destination once complete, so
+// readers never see a partially migrated key. It returns false without doing anything if
+// no temporary key can be built, in which case the caller migrates it in one piece.
+func (p *migrationProcessor) transferLargeKey(key, keyType string, size int64, source, target client.DatabaseClient) (bool, error) {
+	sourceChunks := source.(client.ChunkedClient)
+	targetChunks := target.(client.ChunkedClient)
+
+	startTime := time.Now()
+	if p.mergeExisting {
+		// Merging adds the chunks straight to the existing key
+		p.logger.Infof("Large data detected for key %s (type: %s, size: %d elements). Merging in chunks of %d elements",
+			key, keyType, size, chunkSize)
+		return true, p.mergeLargeKey(key, keyType, size, startTime, source, target)
+	}
+
+	destKey := p.targetKey(key)
+	tempKey, ok := client.TempKey(destKey, tempKeySuffix+strconv.FormatInt(time.Now().UnixNano(), 36))
+	if !ok {
+		p.logger.Warnf("Cannot build a temporary key in the same hash slot as %s. Migrating it in one piece.", destKey)
+		return false, nil
+	}
+
+	p.logger.Infof("Large data detected for key %s (type: %s, size: %d elements). Transferring in chunks of %d elements",
+		key, keyType, size, chunkSize)
+
+	if err := p.transferChunks(key, tempKey, keyType, sourceChunks, targetChunks); err != nil {
+		// Don't leave a partial copy behind on the target
+		if delErr := targetChunks.DeleteKey(tempKey); delErr != nil {
+			p.logger.Warnf("Failed to delete temporary key %s: %v", tempKey, delErr)
+		}
+
+		duration := time.Since(startTime)
+		p.logger.LogKeyTransfer(key, keyType, size, false, duration, err.Error())
+		return true, fmt.Errorf("failed to transfer %s value for key %s in chunks: %w", keyType, key, err)
+	}
+
+	// Set the expiry on the temporary key so that the rename applies the value and its expiry at once
+	expiry, exists, err := p.readExpiry(key, source)
+	if err != nil {
+		if delErr := targetChunks.DeleteKey(tempKey); delErr != nil {
+			p.logger.Warnf("Failed to delete temporary key %s: %v", tempKey, delErr)
+		}
+
+		duration := time.Since(startTime)
+		p.logger.LogKeyTransfer(key, keyType, size, false, duration, err.Error())
+		return true, fmt.Errorf("failed to get expiry for key %s: %w", key, err)
+	}
+	if !exists || expiry.expired() {
+		if delErr := targetChunks.DeleteKey(tempKey); delErr != nil {
+			p.logger.Warnf("Failed to delete temporary key %s: %v", tempKey, delErr)
+		}
+		p.logExpiredDuringTransfer(key)
+		return true, nil
+	}
+	if err := p.applyExpiry(tempKey, expiry, target); err != nil {
+		// Renaming would leave the destination without its expiry
+		if delErr := targetChunks.DeleteKey(tempKey); delErr != nil {
+			p.logger.Warnf("Failed to delete temporary key %s: %v", tempKey, delErr)
+		}
+
+		duration := time.Since(startTime)
+		p.logger.LogKeyTransfer(key, keyType, size, false, duration, err.Error())
+		return true, fmt.Errorf("failed to set expiry for key %s: %w", key, err)
+	}
+
+	if err := targetChunks.RenameKey(tempKey, destKey); err != nil {
+		if delErr := targetChunks.DeleteKey(tempKey); delErr != nil {
+			p.logger.Warnf("Failed to delete temporary key %s: %v", tempKey, delErr)
+		}
+
+		duration := time.Since(startTime)
+		p.logger.LogKeyTransfer(key, keyType, size, false, duration, err.Error())
+		return true, fmt.Errorf("failed to rename temporary key %s to %s: %w", tempKey, destKey, err)
+	}
+
+	duration := time.Since(startTime)
+	p.logger.LogKeyTransfer(key, keyType, size, true, duration, "")
+	return true, nil
+}
+
+// mergeLargeKey adds a large collection to the existing key on target a chunk at a time
+func (p *migrationProcessor) mergeLargeKey(key, keyType string, size int64, startTime time.Time, source, target client.DatabaseClient) error {
+	destKey := p.targetKey(key)
+	if err := p.transferChunks(key, destKey, keyType, source.(client.ChunkedClient), target.(client.ChunkedClient)); err != nil {
+		duration := time.Since(startTime)
+		p.logger.LogKeyTransfer(key, keyType, size, false, duration, err.Error())
+		return fmt.Errorf("failed to merge %s value for key %s in chunks: %w", keyType, key, err)
+	}
+
+	expiry, exists, err := p.readExpiry(key, source)
+	if err != nil {
+		duration := time.Since(startTime)
+		p.logger.LogKeyTransfer(key, keyType, size, false, duration, err.Error())
+		return fmt.Errorf("failed to get expiry for key %s: %w", key, err)
+	}
+	if !exists || expiry.expired() {
+		// The merged elements are left in place, as the key may have held other data
+		p.logExpiredDuringTransfer(key)
+		return nil
+	}
+	if err := p.applyExpiry(destKey, expiry, target); err != nil {
+		p.logger.Warnf("Failed to set TTL for key %s: %v", destKey, err)
+	}
+
+	duration := time.Since(startTime)
+	p.logger.LogKeyTransfer(key, keyType, size, true, duration, "")
+	return nil
+}
+
+// transferChunks copies a collection from source to destKey on target
+func (p *migrationProcessor) transferChunks(key, destKey, keyType string, source, target client.ChunkedClient) error {
+	var cursor uint64
+	for {
+		chunk, next, err := source.ReadChunk(key, keyType, cursor, chunkSize)
+		if err != nil {
+			return err
+		}
+
+		if err := target.AppendChunk(destKey, keyType, chunk); err != nil {
+			return err
+		}
+
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
@@ -0,0 +1,353 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+	"github.com/kinyelo/redis-valkey-migration/internal/config"
+)
+
+// mockChunkedClient extends mockBatchClient with chunked reads and writes
+type mockChunkedClient struct {
+	*mockBatchClient
+	getValueCalls int
+	sizeCalls     int
+	ttlCalls      int
+	readCalls     int
+	appendErr     error
+	ttlErr        error
+	deleted       []string
+}
+
+func newMockChunkedClient() *mockChunkedClient {
+	return &mockChunkedClient{mockBatchClient: newMockBatchClient()}
+}
+
+func (m *mockChunkedClient) GetValue(key string) (interface{}, error) {
+	m.getValueCalls++
+	return m.MockDatabaseClient.GetValue(key)
+}
+
+func (m *mockChunkedClient) KeySizeAndExpiry(key, keyType string) (int64, time.Time, bool, error) {
+	m.sizeCalls++
+	var size int64
+	switch v := m.data[key].(type) {
+	case []redis.Z:
+		size = int64(len(v))
+	case []string:
+		size = int64(len(v))
+	case map[string]string:
+		size = int64(len(v))
+	case nil:
+		return 0, time.Time{}, false, nil
+	}
+
+	var expireAt time.Time
+	if ttl, exists := m.ttls[key]; exists && ttl > 0 {
+		expireAt = time.Now().Add(ttl)
+	}
+	return size, expireAt, true, nil
+}
+
+func (m *mockChunkedClient) GetTTL(key string) (time.Duration, error) {
+	m.ttlCalls++
+	return m.MockDatabaseClient.GetTTL(key)
+}
+
+func (m *mockChunkedClient) SetTTL(key string, ttl time.Duration) error {
+	if m.ttlErr != nil {
+		return m.ttlErr
+	}
+	return m.MockDatabaseClient.SetTTL(key, ttl)
+}
+
+// ReadChunk uses the element index as cursor for every type
+func (m *mockChunkedClient) ReadChunk(key, keyType string, cursor uint64, count int64) (interface{}, uint64, error) {
+	m.readCalls++
+	start := int(cursor)
+	end := start + int(count)
+
+	var chunk interface{}
+	var total int
+	switch v := m.data[key].(type) {
+	case []redis.Z:
+		total = len(v)
+		chunk = v[start:min(end, total)]
+	case []string:
+		total = len(v)
+		chunk = v[start:min(end, total)]
+	case map[string]string:
+		fields := make([]string, 0, len(v))
+		for field := range v {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		total = len(fields)
+		part := make(map[string]string)
+		for _, field := range fields[start:min(end, total)] {
+			part[field] = v[field]
+		}
+		chunk = part
+	default:
+		return nil, 0, fmt.Errorf("unexpected value %T", v)
+	}
+
+	if end >= total {
+		return chunk, 0, nil
+	}
+	return chunk, uint64(end), nil
+}
+
+func (m *mockChunkedClient) AppendChunk(key, keyType string, chunk interface{}) error {
+	if m.appendErr != nil {
+		return m.appendErr
+	}
+	switch v := chunk.(type) {
+	case []redis.Z:
+		existing, _ := m.data[key].([]redis.Z)
+		m.data[key] = append(existing, v...)
+	case []string:
+		existing, _ := m.data[key].([]string)
+		m.data[key] = append(existing, v...)
+	case map[string]string:
+		existing, ok := m.data[key].(map[string]string)
+		if !ok {
+			existing = make(map[string]string)
+		}
+		for field, value := range v {
+			existing[field] = value
+		}
+		m.data[key] = existing
+	}
+	return nil
+}
+
+func (m *mockChunkedClient) RenameKey(key, newKey string) error {
+	m.data[newKey] = m.data[key]
+	delete(m.data, key)
+	delete(m.ttls, newKey)
+	if ttl, exists := m.ttls[key]; exists {
+		m.ttls[newKey] = ttl
+		delete(m.ttls, key)
+	}
+	return nil
+}
+
+func (m *mockChunkedClient) DeleteKey(key string) error {
+	m.deleted = append(m.deleted, key)
+	delete(m.data, key)
+	return nil
+}
+
+func newChunkedTestProcessor(mockLogger *MockLogger) DataProcessor {
+	return NewDataProcessorWithOptions(mockLogger, Options{
+		TimeoutConfig: &config.TimeoutConfig{LargeDataThreshold: 1500, LargeDataMultiplier: 2.0},
+	})
+}
+
+func TestProcessSortedSet_LargeKeyIsTransferredInChunks(t *testing.T) {
+	source := newMockChunkedClient()
+	target := newMockChunkedClient()
+	mockLogger := newBatchTestLogger()
+
+	members := make([]redis.Z, 3500)
+	for i := range members {
+		members[i] = redis.Z{Score: float64(i), Member: fmt.Sprintf("member%d", i)}
+	}
+	source.data["leaderboard"] = members
+	source.ttls["leaderboard"] = time.Hour
+
+	processor := newChunkedTestProcessor(mockLogger)
+	require.NoError(t, processor.ProcessSortedSet("leaderboard", source, target))
+
+	assert.Equal(t, members, target.data["leaderboard"])
+	assert.Equal(t, time.Hour, target.ttls["leaderboard"])
+	assert.Len(t, target.data, 1, "the temporary key must be renamed away")
+	assert.Equal(t, 0, source.getValueCalls, "large keys must not be read in one piece")
+	assert.Equal(t, 4, source.readCalls)
+
+	mockLogger.AssertCalled(t, "LogKeyTransfer", "leaderboard", "zset", int64(3500), true, mock.Anything, "")
+}
+
+func TestProcessHash_SmallKeyIsTransferredInOnePiece(t *testing.T) {
+	source := newMockChunkedClient()
+	target := newMockChunkedClient()
+	mockLogger := newBatchTestLogger()
+
+	source.data["small"] = map[string]string{"a": "1", "b": "2"}
+
+	processor := newChunkedTestProcessor(mockLogger)
+	require.NoError(t, processor.ProcessHash("small", source, target))
+
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, target.data["small"])
+	assert.Equal(t, 1, source.getValueCalls)
+	assert.Equal(t, 0, source.readCalls)
+	assert.Equal(t, 0, source.ttlCalls, "the expiry is read together with the size")
+}
+
+func TestProcessSet_SmallKeyKeepsExpiryReadWithSize(t *testing.T) {
+	source := newMockChunkedClient()
+	target := newMockChunkedClient()
+	mockLogger := newBatchTestLogger()
+
+	source.data["tags"] = []string{"a", "b"}
+	source.ttls["tags"] = time.Hour
+
+	processor := newChunkedTestProcessor(mockLogger)
+	require.NoError(t, processor.ProcessSet("tags", source, target))
+
+	assert.Equal(t, 1, source.sizeCalls)
+	assert.Equal(t, 0, source.ttlCalls)
+	assert.InDelta(t, time.Hour, target.ttls["tags"], float64(time.Second))
+}
+
+func TestProcessList_ChunkFailureRemovesTemporaryKey(t *testing.T) {
+	source := newMockChunkedClient()
+	target := newMockChunkedClient()
+	target.appendErr = errors.New("OOM command not allowed when used memory > 'maxmemory'")
+	mockLogger := newBatchTestLogger()
+
+	list := make([]string, 2000)
+	for i := range list {
+		list[i] = fmt.Sprintf("item%d", i)
+	}
+	source.data["queue"] = list
+	target.data["queue"] = []string{"old"}
+
+	processor := newChunkedTestProcessor(mockLogger)
+	err := processor.ProcessList("queue", source, target)
+	require.Error(t, err)
+
+	assert.Equal(t, []string{"old"}, target.data["queue"], "the destination must keep its previous value")
+	require.Len(t, target.deleted, 1)
+	assert.True(t, strings.HasPrefix(target.deleted[0], "{queue}"+tempKeySuffix))
+}
+
+func TestProcessList_ExpiryFailureRemovesTemporaryKey(t *testing.T) {
+	source := newMockChunkedClient()
+	target := newMockChunkedClient()
+	target.ttlErr = errors.New("READONLY You can't write against a read only replica")
+	mockLogger := newBatchTestLogger()
+
+	list := make([]string, 2000)
+	for i := range list {
+		list[i] = fmt.Sprintf("item%d", i)
+	}
+	source.data["queue"] = list
+	source.ttls["queue"] = time.Hour
+	target.data["queue"] = []string{"old"}
+
+	processor := newChunkedTestProcessor(mockLogger)
+	err := processor.ProcessList("queue", source, target)
+	require.ErrorIs(t, err, target.ttlErr)
+
+	assert.Equal(t, map[string]interface{}{"queue": []string{"old"}}, target.data,
+		"the destination must keep its previous value rather than lose its expiry")
+	assert.NotContains(t, target.ttls, "queue")
+	require.Len(t, target.deleted, 1)
+	assert.True(t, strings.HasPrefix(target.deleted[0], "{queue}"+tempKeySuffix))
+	mockLogger.AssertCalled(t, "LogKeyTransfer", "queue", "list", int64(2000), false, mock.Anything, target.ttlErr.Error())
+}
+
+func TestProcessHash_LargeKeyIsMergedInPlace(t *testing.T) {
+	source := newMockChunkedClient()
+	target := newMockChunkedClient()
+	mockLogger := newBatchTestLogger()
+
+	fields := make(map[string]string)
+	for i := 0; i < 2000; i++ {
+		fields[fmt.Sprintf("field%d", i)] = "value"
+	}
+	source.data["profile"] = fields
+	target.data["profile"] = map[string]string{"existing": "kept"}
+
+	processor := NewDataProcessorWithOptions(mockLogger, Options{
+		TimeoutConfig: &config.TimeoutConfig{LargeDataThreshold: 1500, LargeDataMultiplier: 2.0},
+		MergeExisting: true,
+	})
+	require.NoError(t, processor.ProcessHash("profile", source, target))
+
+	merged := target.data["profile"].(map[string]string)
+	assert.Len(t, merged, 2001)
+	assert.Equal(t, "kept", merged["existing"])
+	assert.Len(t, target.data, 1, "merging must not use a temporary key")
+	assert.Empty(t, target.deleted)
+}
+
+func TestProcessList_LargeKeyIsRenamed(t *testing.T) {
+	source := newMockChunkedClient()
+	target := newMockChunkedClient()
+	mockLogger := newBatchTestLogger()
+
+	elements := make([]string, 2000)
+	for i := range elements {
+		elements[i] = fmt.Sprintf("element%d", i)
+	}
+	source.data["queue"] = elements
+
+	processor := NewDataProcessorWithOptions(mockLogger, Options{
+		TimeoutConfig: &config.TimeoutConfig{LargeDataThreshold: 1500, LargeDataMultiplier: 2.0},
+		RenameKey:     func(key string) string { return "{app1}:" + key },
+	})
+	require.NoError(t, processor.ProcessList("queue", source, target))
+
+	assert.Equal(t, map[string]interface{}{"{app1}:queue": elements}, target.data,
+		"the temporary key is built from and renamed to the target key")
+}
+
+func TestProcessBatch_OversizedKeysAreTransferredInChunks(t *testing.T) {
+	source := newMockChunkedClient()
+	target := newMockChunkedClient()
+	mockLogger := newBatchTestLogger()
+
+	fields := make(map[string]string)
+	for i := 0; i < 2000; i++ {
+		fields[fmt.Sprintf("field%d", i)] = "value"
+	}
+	source.data["big"], source.types["big"] = fields, "hash"
+	source.data["small"], source.types["small"] = "value", "string"
+
+	// Simulate the client leaving the large hash unread
+	batchSource := &oversizedBatchClient{mockChunkedClient: source, oversized: map[string]bool{"big": true}}
+
+	processor := newChunkedTestProcessor(mockLogger).(BatchProcessor)
+	errs, err := processor.ProcessBatch([]string{"big", "small"}, batchSource, target)
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+
+	assert.Equal(t, fields, target.data["big"])
+	assert.Equal(t, "value", target.data["small"])
+	assert.Equal(t, 0, source.getValueCalls)
+	assert.Equal(t, 2, source.readCalls)
+	assert.Equal(t, 0, source.sizeCalls, "the size is taken from the batch read")
+	mockLogger.AssertCalled(t, "LogKeyTransfer", "big", "hash", int64(2000), true, mock.Anything, "")
+}
+
+// oversizedBatchClient marks some keys as Oversized in GetBatch
+type oversizedBatchClient struct {
+	*mockChunkedClient
+	oversized map[string]bool
+}
+
+func (m *oversizedBatchClient) GetBatch(keys []string) ([]client.KeyRecord, error) {
+	records, err := m.mockChunkedClient.GetBatch(keys)
+	for i := range records {
+		if m.oversized[records[i].Key] {
+			records[i].Size = int64(len(records[i].Value.(map[string]string)))
+			records[i].Value = nil
+			records[i].Oversized = true
+		}
+	}
+	return records, err
+}
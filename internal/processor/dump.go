@@ -0,0 +1,229 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+	"github.com/kinyelo/redis-valkey-migration/pkg/logger"
+)
+
+// Transfer modes select how key values are copied to the target
+const (
+	// TransferModeNative reads and writes values with type-specific commands
+	TransferModeNative = "native"
+
+	// TransferModeDump copies the serialized value with DUMP and RESTORE
+	TransferModeDump = "dump"
+
+	// TransferModeAuto uses DUMP and RESTORE when both clients support it
+	TransferModeAuto = "auto"
+)
+
+// dumpRestoreProcessor implements DataProcessor by copying keys with DUMP and RESTORE.
+// It covers every data type the source can serialize, module types included, and keeps
+// the exact encoding, expiry and LRU/LFU information of each key. If the source
+// serializes with a newer RDB version than the target, or the target rejects a payload,
+// it switches to the type-specific processor for the rest of the migration.
+type dumpRestoreProcessor struct {
+	logger         logger.Logger
+	native         *migrationProcessor
+	incompatible   atomic.Bool
+	versionChecked sync.Once
+}
+
+// NewDumpRestoreProcessor creates a DataProcessor that copies keys with DUMP and RESTORE,
+// falling back to type-specific transfer when the target cannot load the source's payloads
+func NewDumpRestoreProcessor(logger logger.Logger, options Options) DataProcessor {
+	return &dumpRestoreProcessor{
+		logger: logger,
+		native: NewDataProcessorWithOptions(logger, options).(*migrationProcessor),
+	}
+}
+
+// ProcessKey migrates a key with DUMP and RESTORE regardless of its type
+func (p *dumpRestoreProcessor) ProcessKey(key, keyType string, source, target client.DatabaseClient) error {
+	if !p.dumpEnabled(source, target) {
+		return p.native.ProcessKey(key, keyType, source, target)
+	}
+
+	errs, err := p.ProcessBatch([]string{key}, source, target)
+	if err != nil {
+		return err
+	}
+	return errs[0]
+}
+
+// ProcessString handles string value migration
+func (p *dumpRestoreProcessor) ProcessString(key string, source, target client.DatabaseClient) error {
+	return p.ProcessKey(key, "string", source, target)
+}
+
+// ProcessHash handles hash field migration
+func (p *dumpRestoreProcessor) ProcessHash(key string, source, target client.DatabaseClient) error {
+	return p.ProcessKey(key, "hash", source, target)
+}
+
+// ProcessList handles list element migration with order preservation
+func (p *dumpRestoreProcessor) ProcessList(key string, source, target client.DatabaseClient) error {
+	return p.ProcessKey(key, "list", source, target)
+}
+
+// ProcessSet handles set member migration
+func (p *dumpRestoreProcessor) ProcessSet(key string, source, target client.DatabaseClient) error {
+	return p.ProcessKey(key, "set", source, target)
+}
+
+// ProcessSortedSet handles sorted set migration with scores
+func (p *dumpRestoreProcessor) ProcessSortedSet(key string, source, target client.DatabaseClient) error {
+	return p.ProcessKey(key, "zset", source, target)
+}
+
+// ProcessStream handles stream migration with entry IDs and consumer groups
+func (p *dumpRestoreProcessor) ProcessStream(key string, source, target client.DatabaseClient) error {
+	return p.ProcessKey(key, "stream", source, target)
+}
+
+// ProcessBatch migrates keys with one pipelined DUMP round trip and one pipelined RESTORE round trip
+func (p *dumpRestoreProcessor) ProcessBatch(keys []string, source, target client.DatabaseClient) ([]error, error) {
+	if !p.dumpEnabled(source, target) {
+		return p.native.ProcessBatch(keys, source, target)
+	}
+
+	startTime := time.Now()
+
+	records, err := source.(client.DumpRestorer).DumpKeys(keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump batch of %d keys: %w", len(keys), err)
+	}
+	if p.checkRDBVersions(records, target) {
+		return p.processNative(keys, records, source, target)
+	}
+
+	errs := make([]error, len(keys))
+	restores := make([]client.DumpRecord, 0, len(records))
+	restoreIndexes := make([]int, 0, len(records))
+
+	for i, record := range records {
+		if record.Err != nil {
+			p.logger.LogKeyTransfer(record.Key, record.Type, 0, false, time.Since(startTime), record.Err.Error())
+			errs[i] = fmt.Errorf("failed to dump key %s: %w", record.Key, record.Err)
+			continue
+		}
+
+		if record.Type == "none" {
+			// Key doesn't exist (expired, deleted, or temporary)
+			p.logger.Warnf("Key '%s' no longer exists (type: none). Skipping migration.", record.Key)
+			continue
+		}
+
+		record.Key = p.native.targetKey(record.Key)
+		restores = append(restores, record)
+		restoreIndexes = append(restoreIndexes, i)
+	}
+
+	if len(restores) == 0 {
+		return errs, nil
+	}
+
+	restoreErrs, err := target.(client.DumpRestorer).RestoreKeys(restores)
+	if err != nil {
+		// The whole pipeline failed, so every key in it failed
+		restoreErrs = make([]error, len(restores))
+		for j := range restoreErrs {
+			restoreErrs[j] = err
+		}
+	}
+
+	duration := time.Since(startTime)
+	for j, i := range restoreIndexes {
+		record := records[i]
+		size := int64(len(record.Payload))
+
+		// The target's error message is the last resort, for targets whose RDB version
+		// could not be probed
+		if errors.Is(restoreErrs[j], client.ErrDumpIncompatible) {
+			if p.incompatible.CompareAndSwap(false, true) {
+				p.logger.Warnf("Target cannot load DUMP payloads from the source (%v). Falling back to type-specific transfer for the remaining keys.", restoreErrs[j])
+			}
+			errs[i] = p.native.ProcessKey(record.Key, record.Type, source, target)
+			continue
+		}
+
+		if restoreErrs[j] != nil {
+			p.logger.LogKeyTransfer(record.Key, record.Type, size, false, duration, restoreErrs[j].Error())
+			errs[i] = fmt.Errorf("failed to restore %s value for key %s: %w", record.Type, record.Key, restoreErrs[j])
+			continue
+		}
+
+		p.logger.LogKeyTransfer(record.Key, record.Type, size, true, duration, "")
+	}
+
+	return errs, nil
+}
+
+// processNative migrates dumped keys with the type-specific processor instead, one by one
+// if the clients cannot transfer batches
+func (p *dumpRestoreProcessor) processNative(keys []string, records []client.DumpRecord, source, target client.DatabaseClient) ([]error, error) {
+	if errs, err := p.native.ProcessBatch(keys, source, target); !errors.Is(err, client.ErrUnsupported) {
+		return errs, err
+	}
+
+	errs := make([]error, len(records))
+	for i, record := range records {
+		switch {
+		case record.Err != nil:
+			errs[i] = fmt.Errorf("failed to dump key %s: %w", record.Key, record.Err)
+		case record.Type != "none":
+			errs[i] = p.native.ProcessKey(record.Key, record.Type, source, target)
+		}
+	}
+	return errs, nil
+}
+
+// dumpEnabled reports whether keys should still be copied with DUMP and RESTORE
+func (p *dumpRestoreProcessor) dumpEnabled(source, target client.DatabaseClient) bool {
+	if p.incompatible.Load() {
+		return false
+	}
+	return client.Supports[client.DumpRestorer](source) && client.Supports[client.DumpRestorer](target)
+}
+
+// checkRDBVersions compares, once, the RDB version of the dumped payloads with the version
+// the target serializes with, which is the newest it can load. It reports whether the
+// target is too old for the source, in which case type-specific transfer is used from
+// then on.
+func (p *dumpRestoreProcessor) checkRDBVersions(records []client.DumpRecord, target client.DatabaseClient) bool {
+	sourceVersion := 0
+	for i := range records {
+		if sourceVersion = records[i].RDBVersion(); sourceVersion > 0 {
+			break
+		}
+	}
+	if sourceVersion == 0 {
+		// Nothing was dumped, check the next batch
+		return false
+	}
+
+	p.versionChecked.Do(func() {
+		prober, ok := target.(client.RDBVersionProber)
+		if !ok {
+			return
+		}
+		targetVersion, err := prober.RDBVersion()
+		if errors.Is(err, client.ErrUnsupported) {
+			return
+		}
+		if err != nil {
+			p.logger.Warnf("Could not read the RDB version of the target, payloads it cannot load fall back to type-specific transfer: %v", err)
+			return
+		}
+		if targetVersion > 0 && sourceVersion > targetVersion && p.incompatible.CompareAndSwap(false, true) {
+			p.logger.Warnf("Source serializes keys with RDB version %d but the target only loads up to RDB version %d. Using type-specific transfer for the migration.", sourceVersion, targetVersion)
+		}
+	})
+	return p.incompatible.Load()
+}
@@ -0,0 +1,183 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+)
+
+// mockDumpClient extends MockDatabaseClient with DUMP and RESTORE
+type mockDumpClient struct {
+	*MockDatabaseClient
+	types        map[string]string
+	payloads     map[string]string
+	restored     map[string]client.DumpRecord
+	incompatible bool
+	restoreCalls int
+}
+
+func newMockDumpClient() *mockDumpClient {
+	return &mockDumpClient{
+		MockDatabaseClient: NewMockDatabaseClient(),
+		types:              make(map[string]string),
+		payloads:           make(map[string]string),
+		restored:           make(map[string]client.DumpRecord),
+	}
+}
+
+func (m *mockDumpClient) DumpKeys(keys []string) ([]client.DumpRecord, error) {
+	records := make([]client.DumpRecord, len(keys))
+	for i, key := range keys {
+		records[i] = client.DumpRecord{Key: key, Type: "none", IdleTime: -1, Freq: -1}
+		if keyType, exists := m.types[key]; exists {
+			records[i].Type = keyType
+			records[i].Payload = m.payloads[key]
+			records[i].IdleTime = 30 * time.Second
+		}
+	}
+	return records, nil
+}
+
+func (m *mockDumpClient) RestoreKeys(records []client.DumpRecord) ([]error, error) {
+	m.restoreCalls++
+	errs := make([]error, len(records))
+	for i, record := range records {
+		if m.incompatible {
+			errs[i] = fmt.Errorf("%w: %s", client.ErrDumpIncompatible, record.Key)
+			continue
+		}
+		m.restored[record.Key] = record
+	}
+	return errs, nil
+}
+
+func TestDumpRestoreProcessor_CopiesEveryType(t *testing.T) {
+	source := newMockDumpClient()
+	target := newMockDumpClient()
+	mockLogger := newBatchTestLogger()
+
+	source.types["module"], source.payloads["module"] = "ReJSON-RL", "json-payload"
+	source.types["s"], source.payloads["s"] = "string", "string-payload"
+
+	processor := NewDumpRestoreProcessor(mockLogger, Options{})
+
+	err := processor.ProcessKey("module", "ReJSON-RL", source, target)
+	require.NoError(t, err)
+
+	errs, err := processor.(BatchProcessor).ProcessBatch([]string{"s", "gone"}, source, target)
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1], "keys that disappeared are skipped, not failed")
+
+	assert.Equal(t, "json-payload", target.restored["module"].Payload)
+	assert.Equal(t, 30*time.Second, target.restored["s"].IdleTime)
+	assert.NotContains(t, target.restored, "gone")
+	assert.Empty(t, target.data, "values must not be copied with type-specific commands")
+
+	mockLogger.AssertCalled(t, "LogKeyTransfer", "module", "ReJSON-RL", int64(len("json-payload")), true, mock.Anything, "")
+}
+
+func TestDumpRestoreProcessor_RenamesKeys(t *testing.T) {
+	source := newMockDumpClient()
+	target := newMockDumpClient()
+	mockLogger := newBatchTestLogger()
+
+	source.types["s"], source.payloads["s"] = "string", "string-payload"
+
+	processor := NewDumpRestoreProcessor(mockLogger, Options{
+		RenameKey: func(key string) string { return "tenant1:" + key },
+	})
+	require.NoError(t, processor.ProcessKey("s", "string", source, target))
+
+	assert.Equal(t, "string-payload", target.restored["tenant1:s"].Payload)
+	assert.NotContains(t, target.restored, "s")
+	mockLogger.AssertCalled(t, "LogKeyTransfer", "s", "string", mock.Anything, true, mock.Anything, "")
+}
+
+func TestDumpRestoreProcessor_FallsBackOnIncompatiblePayload(t *testing.T) {
+	source := newMockDumpClient()
+	target := newMockDumpClient()
+	target.incompatible = true
+	mockLogger := newBatchTestLogger()
+
+	source.types["a"], source.payloads["a"], source.data["a"] = "string", "payload-a", "value-a"
+	source.types["b"], source.payloads["b"], source.data["b"] = "string", "payload-b", "value-b"
+
+	processor := NewDumpRestoreProcessor(mockLogger, Options{})
+
+	require.NoError(t, processor.ProcessKey("a", "string", source, target))
+	require.NoError(t, processor.ProcessKey("b", "string", source, target))
+
+	assert.Equal(t, "value-a", target.data["a"])
+	assert.Equal(t, "value-b", target.data["b"])
+	assert.Equal(t, 1, target.restoreCalls, "DUMP/RESTORE must not be retried after a version mismatch")
+
+	warnings := 0
+	for _, call := range mockLogger.Calls {
+		if call.Method == "Warnf" {
+			warnings++
+		}
+	}
+	assert.Equal(t, 1, warnings, "the fallback should only be logged once")
+}
+
+// mockProbedDumpClient is a mockDumpClient that reports the RDB version of its server
+type mockProbedDumpClient struct {
+	*mockDumpClient
+	rdbVersion int
+}
+
+func (m *mockProbedDumpClient) RDBVersion() (int, error) {
+	return m.rdbVersion, nil
+}
+
+// dumpPayload returns a DUMP payload serialized with an RDB version
+func dumpPayload(value string, rdbVersion int) string {
+	return value + string([]byte{byte(rdbVersion), 0}) + "\x00\x00\x00\x00\x00\x00\x00\x00"
+}
+
+func TestDumpRestoreProcessor_ComparesRDBVersions(t *testing.T) {
+	source := newMockDumpClient()
+	mockLogger := newBatchTestLogger()
+
+	source.types["a"], source.payloads["a"], source.data["a"] = "string", dumpPayload("a", 12), "value-a"
+	source.types["b"], source.payloads["b"], source.data["b"] = "string", dumpPayload("b", 12), "value-b"
+
+	// A target that loads the source's RDB version restores the payloads
+	target := &mockProbedDumpClient{mockDumpClient: newMockDumpClient(), rdbVersion: 12}
+	processor := NewDumpRestoreProcessor(mockLogger, Options{})
+	require.NoError(t, processor.ProcessKey("a", "string", source, target))
+	assert.Contains(t, target.restored, "a")
+
+	// An older target is detected before anything is restored
+	target = &mockProbedDumpClient{mockDumpClient: newMockDumpClient(), rdbVersion: 11}
+	processor = NewDumpRestoreProcessor(mockLogger, Options{})
+	require.NoError(t, processor.ProcessKey("a", "string", source, target))
+	require.NoError(t, processor.ProcessKey("b", "string", source, target))
+
+	assert.Equal(t, 0, target.restoreCalls)
+	assert.Equal(t, "value-a", target.data["a"])
+	assert.Equal(t, "value-b", target.data["b"])
+	mockLogger.AssertCalled(t, "Warnf", mock.MatchedBy(func(format string) bool {
+		return strings.Contains(format, "RDB version %d")
+	}), []interface{}{12, 11})
+}
+
+func TestDumpRestoreProcessor_UnsupportedClientsUseNativeTransfer(t *testing.T) {
+	source := NewMockDatabaseClient()
+	target := NewMockDatabaseClient()
+	mockLogger := newBatchTestLogger()
+
+	source.data["key"] = "value"
+
+	processor := NewDumpRestoreProcessor(mockLogger, Options{})
+	require.NoError(t, processor.ProcessString("key", source, target))
+	assert.Equal(t, "value", target.data["key"])
+}
@@ -0,0 +1,111 @@
+package processor
+
+import (
+	"time"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
+)
+
+// keyExpiry is the expiry of a source key as read at the start of its transfer
+type keyExpiry struct {
+	// at is the absolute expiry time, zero if the key has no expiry or the source
+	// cannot report absolute expiry times
+	at time.Time
+
+	// ttl is the relative TTL reported by GetTTL, used when the source cannot
+	// report absolute expiry times; -1 if the key has no expiry
+	ttl time.Duration
+}
+
+// expired reports whether the key has expired since its expiry was read
+func (e keyExpiry) expired() bool {
+	return !e.at.IsZero() && !e.at.After(time.Now())
+}
+
+// absolute returns the expiry as an absolute time, zero if the key has no expiry
+func (e keyExpiry) absolute() time.Time {
+	if !e.at.IsZero() {
+		return e.at
+	}
+	if e.ttl > 0 {
+		return time.Now().Add(e.ttl)
+	}
+	return time.Time{}
+}
+
+// relative returns the remaining time to live, or a non-positive value if the key has no expiry
+func (e keyExpiry) relative() time.Duration {
+	if !e.at.IsZero() {
+		return time.Until(e.at)
+	}
+	return e.ttl
+}
+
+// readExpiry reads the expiry of a key from source, as an absolute time when the source
+// supports it. It returns false if the key no longer exists.
+func (p *migrationProcessor) readExpiry(key string, source client.DatabaseClient) (keyExpiry, bool, error) {
+	if client.Supports[client.ExpiryClient](source) {
+		expireAt, exists, err := source.(client.ExpiryClient).GetExpireAt(key)
+		if err != nil {
+			return keyExpiry{}, false, err
+		}
+		return keyExpiry{at: expireAt, ttl: -1}, exists, nil
+	}
+
+	ttl, err := source.GetTTL(key)
+	if err != nil {
+		return keyExpiry{}, false, err
+	}
+	if ttl == -2 {
+		// Key does not exist
+		return keyExpiry{}, false, nil
+	}
+	return keyExpiry{ttl: ttl}, true, nil
+}
+
+// collectionExpiry returns the expiry of a collection whose value was just read, reusing
+// the expiry read together with its size if there is one. A collection is never empty,
+// so an empty value shows that the key was deleted in between.
+func (p *migrationProcessor) collectionExpiry(key string, expiry *keyExpiry, size int64, source client.DatabaseClient) (keyExpiry, bool, error) {
+	if expiry == nil {
+		return p.readExpiry(key, source)
+	}
+	return *expiry, size > 0, nil
+}
+
+// writeValue stores the value of a source key on target together with its expiry. When
+// the target supports it, both are written in a single transaction with PEXPIREAT.
+func (p *migrationProcessor) writeValue(key string, value interface{}, expiry keyExpiry, target client.DatabaseClient) error {
+	value = p.valueForWrite(value)
+	key = p.targetKey(key)
+
+	if client.Supports[client.ExpiryClient](target) {
+		return target.(client.ExpiryClient).SetValueWithExpiry(key, value, expiry.absolute())
+	}
+
+	if err := target.SetValue(key, value); err != nil {
+		return err
+	}
+
+	if err := p.applyExpiry(key, expiry, target); err != nil {
+		p.logger.Warnf("Failed to set TTL for key %s: %v", key, err)
+	}
+	return nil
+}
+
+// applyExpiry sets the expiry of a key that already exists on target
+func (p *migrationProcessor) applyExpiry(key string, expiry keyExpiry, target client.DatabaseClient) error {
+	if client.Supports[client.ExpiryClient](target) {
+		if expireAt := expiry.absolute(); !expireAt.IsZero() {
+			return target.(client.ExpiryClient).SetExpireAt(key, expireAt)
+		}
+	} else if ttl := expiry.relative(); ttl > 0 {
+		return target.SetTTL(key, ttl)
+	}
+	return nil
+}
+
+// logExpiredDuringTransfer logs a key that expired or was deleted while it was being migrated
+func (p *migrationProcessor) logExpiredDuringTransfer(key string) {
+	p.logger.Warnf("Key '%s' expired or was deleted during migration. Skipping migration.", key)
+}
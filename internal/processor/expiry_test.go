@@ -0,0 +1,139 @@
+package processor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockExpiryClient extends MockDatabaseClient with absolute, millisecond-precise expiry
+type mockExpiryClient struct {
+	*MockDatabaseClient
+	expireAts  map[string]time.Time
+	deleted    map[string]bool
+	expiryErr  error
+	atomicSets int
+}
+
+func newMockExpiryClient() *mockExpiryClient {
+	return &mockExpiryClient{
+		MockDatabaseClient: NewMockDatabaseClient(),
+		expireAts:          make(map[string]time.Time),
+		deleted:            make(map[string]bool),
+	}
+}
+
+func (m *mockExpiryClient) GetExpireAt(key string) (time.Time, bool, error) {
+	if m.expiryErr != nil {
+		return time.Time{}, false, m.expiryErr
+	}
+	if _, exists := m.data[key]; !exists || m.deleted[key] {
+		return time.Time{}, false, nil
+	}
+	return m.expireAts[key], true, nil
+}
+
+func (m *mockExpiryClient) SetValueWithExpiry(key string, value interface{}, expireAt time.Time) error {
+	m.atomicSets++
+	m.data[key] = value
+	if !expireAt.IsZero() {
+		m.expireAts[key] = expireAt
+	}
+	return nil
+}
+
+func (m *mockExpiryClient) SetExpireAt(key string, expireAt time.Time) error {
+	m.expireAts[key] = expireAt
+	return nil
+}
+
+func TestProcessString_PreservesAbsoluteExpiry(t *testing.T) {
+	source := newMockExpiryClient()
+	target := newMockExpiryClient()
+	mockLogger := newBatchTestLogger()
+
+	expireAt := time.Now().Add(1500 * time.Millisecond)
+	source.data["session"] = "token"
+	source.expireAts["session"] = expireAt
+
+	processor := NewDataProcessor(mockLogger)
+	require.NoError(t, processor.ProcessString("session", source, target))
+
+	assert.Equal(t, "token", target.data["session"])
+	assert.Equal(t, expireAt, target.expireAts["session"])
+	assert.Equal(t, 1, target.atomicSets)
+	assert.Empty(t, target.ttls, "relative TTL should not be used when the target supports absolute expiry")
+}
+
+func TestProcessString_AbsoluteExpiryToRelativeTarget(t *testing.T) {
+	source := newMockExpiryClient()
+	target := NewMockDatabaseClient()
+	mockLogger := newBatchTestLogger()
+
+	source.data["session"] = "token"
+	source.expireAts["session"] = time.Now().Add(time.Minute)
+
+	processor := NewDataProcessor(mockLogger)
+	require.NoError(t, processor.ProcessString("session", source, target))
+
+	assert.Equal(t, "token", target.data["session"])
+	ttl := target.ttls["session"]
+	assert.True(t, ttl > 0 && ttl <= time.Minute, "unexpected TTL %v", ttl)
+}
+
+func TestProcessString_SkipsKeyExpiredDuringTransfer(t *testing.T) {
+	testCases := []struct {
+		name  string
+		setup func(source *mockExpiryClient)
+	}{
+		{
+			name: "expiry passed",
+			setup: func(source *mockExpiryClient) {
+				source.expireAts["session"] = time.Now().Add(-time.Millisecond)
+			},
+		},
+		{
+			name: "key deleted after its value was read",
+			setup: func(source *mockExpiryClient) {
+				source.deleted["session"] = true
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			source := newMockExpiryClient()
+			target := newMockExpiryClient()
+			mockLogger := newBatchTestLogger()
+
+			source.data["session"] = "token"
+			tc.setup(source)
+
+			processor := NewDataProcessor(mockLogger)
+			require.NoError(t, processor.ProcessString("session", source, target))
+
+			_, written := target.data["session"]
+			assert.False(t, written)
+			mockLogger.AssertCalled(t, "Warnf", "Key '%s' expired or was deleted during migration. Skipping migration.", []interface{}{"session"})
+		})
+	}
+}
+
+func TestProcessString_ExpiryReadFailureFailsKey(t *testing.T) {
+	source := newMockExpiryClient()
+	target := newMockExpiryClient()
+	mockLogger := newBatchTestLogger()
+
+	source.data["session"] = "token"
+	source.expireAts["session"] = time.Now().Add(time.Minute)
+	source.expiryErr = errors.New("i/o timeout")
+
+	processor := NewDataProcessor(mockLogger)
+	err := processor.ProcessString("session", source, target)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to get expiry for key session")
+	assert.NotContains(t, target.data, "session", "the key must not be written without its expiry")
+}
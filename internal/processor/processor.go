@@ -30,12 +30,36 @@ type DataProcessor interface {
 
 	// ProcessSortedSet handles sorted set migration with scores
 	ProcessSortedSet(key string, source, target client.DatabaseClient) error
+
+	// ProcessStream handles stream migration with entry IDs and consumer groups
+	ProcessStream(key string, source, target client.DatabaseClient) error
+}
+
+// Options holds optional processor settings
+type Options struct {
+	// TimeoutConfig enables large data detection logging
+	TimeoutConfig *config.TimeoutConfig
+
+	// CopyStreamPending copies the pending entries lists of stream consumer groups.
+	// Without it, consumer groups are recreated with no pending entries.
+	CopyStreamPending bool
+
+	// MergeExisting merges values into keys that already exist on the target instead of
+	// replacing them. See client.MergeValue for how each type is merged.
+	MergeExisting bool
+
+	// RenameKey returns the name a source key is written to on the target. Without it,
+	// keys keep their names.
+	RenameKey func(key string) string
 }
 
 // migrationProcessor implements DataProcessor interface
 type migrationProcessor struct {
-	logger        logger.Logger
-	timeoutConfig *config.TimeoutConfig
+	logger            logger.Logger
+	timeoutConfig     *config.TimeoutConfig
+	copyStreamPending bool
+	mergeExisting     bool
+	renameKey         func(key string) string
 }
 
 // NewDataProcessor creates a new DataProcessor instance
@@ -53,6 +77,25 @@ func NewDataProcessorWithTimeout(logger logger.Logger, timeoutConfig *config.Tim
 	}
 }
 
+// NewDataProcessorWithOptions creates a new DataProcessor instance with optional settings
+func NewDataProcessorWithOptions(logger logger.Logger, options Options) DataProcessor {
+	return &migrationProcessor{
+		logger:            logger,
+		timeoutConfig:     options.TimeoutConfig,
+		copyStreamPending: options.CopyStreamPending,
+		mergeExisting:     options.MergeExisting,
+		renameKey:         options.RenameKey,
+	}
+}
+
+// targetKey returns the name key is written to on the target
+func (p *migrationProcessor) targetKey(key string) string {
+	if p.renameKey == nil {
+		return key
+	}
+	return p.renameKey(key)
+}
+
 // ProcessKey handles key migration based on its type
 func (p *migrationProcessor) ProcessKey(key, keyType string, source, target client.DatabaseClient) error {
 	switch keyType {
@@ -66,6 +109,8 @@ func (p *migrationProcessor) ProcessKey(key, keyType string, source, target clie
 		return p.ProcessSet(key, source, target)
 	case "zset":
 		return p.ProcessSortedSet(key, source, target)
+	case "stream":
+		return p.ProcessStream(key, source, target)
 	case "none":
 		// Key doesn't exist (expired, deleted, or temporary)
 		p.logger.Warnf("Key '%s' no longer exists (type: none). Skipping migration.", key)
@@ -106,27 +151,25 @@ func (p *migrationProcessor) ProcessString(key string, source, target client.Dat
 	// Log large data detection for strings (based on byte length)
 	p.logLargeDataDetection(key, "string", int64(len(stringValue)))
 
-	// Get TTL from source
-	ttl, err := source.GetTTL(key)
+	// Get expiry from source, as an absolute time so the transfer doesn't extend it
+	expiry, exists, err := p.readExpiry(key, source)
 	if err != nil {
-		p.logger.Warnf("Failed to get TTL for key %s: %v", key, err)
-		ttl = -1 // No TTL
+		duration := time.Since(startTime)
+		p.logger.LogKeyTransfer(key, "string", int64(len(stringValue)), false, duration, err.Error())
+		return fmt.Errorf("failed to get expiry for key %s: %w", key, err)
+	}
+	if !exists || expiry.expired() {
+		p.logExpiredDuringTransfer(key)
+		return nil
 	}
 
-	// Set the string value in target
-	if err := target.SetValue(key, stringValue); err != nil {
+	// Set the string value and its expiry in target
+	if err := p.writeValue(key, stringValue, expiry, target); err != nil {
 		duration := time.Since(startTime)
 		p.logger.LogKeyTransfer(key, "string", int64(len(stringValue)), false, duration, err.Error())
 		return fmt.Errorf("failed to set string value for key %s: %w", key, err)
 	}
 
-	// Set TTL if it exists
-	if ttl > 0 {
-		if err := target.SetTTL(key, ttl); err != nil {
-			p.logger.Warnf("Failed to set TTL for key %s: %v", key, err)
-		}
-	}
-
 	duration := time.Since(startTime)
 	p.logger.LogKeyTransfer(key, "string", int64(len(stringValue)), true, duration, "")
 	return nil
@@ -134,6 +177,12 @@ func (p *migrationProcessor) ProcessString(key string, source, target client.Dat
 
 // ProcessHash handles hash field migration
 func (p *migrationProcessor) ProcessHash(key string, source, target client.DatabaseClient) error {
+	// Collections above the large data threshold are transferred in chunks
+	done, readExpiry, err := p.migrateLargeKey(key, "hash", source, target)
+	if done {
+		return err
+	}
+
 	startTime := time.Now()
 
 	// Get the hash value from source
@@ -158,27 +207,25 @@ func (p *migrationProcessor) ProcessHash(key string, source, target client.Datab
 	// Log large data detection
 	p.logLargeDataDetection(key, "hash", size)
 
-	// Get TTL from source
-	ttl, err := source.GetTTL(key)
+	// Get expiry from source, as an absolute time so the transfer doesn't extend it
+	expiry, exists, err := p.collectionExpiry(key, readExpiry, size, source)
 	if err != nil {
-		p.logger.Warnf("Failed to get TTL for key %s: %v", key, err)
-		ttl = -1 // No TTL
+		duration := time.Since(startTime)
+		p.logger.LogKeyTransfer(key, "hash", size, false, duration, err.Error())
+		return fmt.Errorf("failed to get expiry for key %s: %w", key, err)
+	}
+	if !exists || expiry.expired() {
+		p.logExpiredDuringTransfer(key)
+		return nil
 	}
 
-	// Set the hash value in target
-	if err := target.SetValue(key, hashValue); err != nil {
+	// Set the hash value and its expiry in target
+	if err := p.writeValue(key, hashValue, expiry, target); err != nil {
 		duration := time.Since(startTime)
 		p.logger.LogKeyTransfer(key, "hash", size, false, duration, err.Error())
 		return fmt.Errorf("failed to set hash value for key %s: %w", key, err)
 	}
 
-	// Set TTL if it exists
-	if ttl > 0 {
-		if err := target.SetTTL(key, ttl); err != nil {
-			p.logger.Warnf("Failed to set TTL for key %s: %v", key, err)
-		}
-	}
-
 	duration := time.Since(startTime)
 	p.logger.LogKeyTransfer(key, "hash", size, true, duration, "")
 	return nil
@@ -186,6 +233,12 @@ func (p *migrationProcessor) ProcessHash(key string, source, target client.Datab
 
 // ProcessList handles list element migration with order preservation
 func (p *migrationProcessor) ProcessList(key string, source, target client.DatabaseClient) error {
+	// Collections above the large data threshold are transferred in chunks
+	done, readExpiry, err := p.migrateLargeKey(key, "list", source, target)
+	if done {
+		return err
+	}
+
 	startTime := time.Now()
 
 	// Get the list value from source
@@ -210,27 +263,25 @@ func (p *migrationProcessor) ProcessList(key string, source, target client.Datab
 	// Log large data detection
 	p.logLargeDataDetection(key, "list", size)
 
-	// Get TTL from source
-	ttl, err := source.GetTTL(key)
+	// Get expiry from source, as an absolute time so the transfer doesn't extend it
+	expiry, exists, err := p.collectionExpiry(key, readExpiry, size, source)
 	if err != nil {
-		p.logger.Warnf("Failed to get TTL for key %s: %v", key, err)
-		ttl = -1 // No TTL
+		duration := time.Since(startTime)
+		p.logger.LogKeyTransfer(key, "list", size, false, duration, err.Error())
+		return fmt.Errorf("failed to get expiry for key %s: %w", key, err)
+	}
+	if !exists || expiry.expired() {
+		p.logExpiredDuringTransfer(key)
+		return nil
 	}
 
-	// Set the list value in target
-	if err := target.SetValue(key, listValue); err != nil {
+	// Set the list value and its expiry in target
+	if err := p.writeValue(key, listValue, expiry, target); err != nil {
 		duration := time.Since(startTime)
 		p.logger.LogKeyTransfer(key, "list", size, false, duration, err.Error())
 		return fmt.Errorf("failed to set list value for key %s: %w", key, err)
 	}
 
-	// Set TTL if it exists
-	if ttl > 0 {
-		if err := target.SetTTL(key, ttl); err != nil {
-			p.logger.Warnf("Failed to set TTL for key %s: %v", key, err)
-		}
-	}
-
 	duration := time.Since(startTime)
 	p.logger.LogKeyTransfer(key, "list", size, true, duration, "")
 	return nil
@@ -238,6 +289,12 @@ func (p *migrationProcessor) ProcessList(key string, source, target client.Datab
 
 // ProcessSet handles set member migration
 func (p *migrationProcessor) ProcessSet(key string, source, target client.DatabaseClient) error {
+	// Collections above the large data threshold are transferred in chunks
+	done, readExpiry, err := p.migrateLargeKey(key, "set", source, target)
+	if done {
+		return err
+	}
+
 	startTime := time.Now()
 
 	// Get the set value from source
@@ -268,27 +325,25 @@ func (p *migrationProcessor) ProcessSet(key string, source, target client.Databa
 		interfaceSlice[i] = v
 	}
 
-	// Get TTL from source
-	ttl, err := source.GetTTL(key)
+	// Get expiry from source, as an absolute time so the transfer doesn't extend it
+	expiry, exists, err := p.collectionExpiry(key, readExpiry, size, source)
 	if err != nil {
-		p.logger.Warnf("Failed to get TTL for key %s: %v", key, err)
-		ttl = -1 // No TTL
+		duration := time.Since(startTime)
+		p.logger.LogKeyTransfer(key, "set", size, false, duration, err.Error())
+		return fmt.Errorf("failed to get expiry for key %s: %w", key, err)
+	}
+	if !exists || expiry.expired() {
+		p.logExpiredDuringTransfer(key)
+		return nil
 	}
 
-	// Set the set value in target
-	if err := target.SetValue(key, interfaceSlice); err != nil {
+	// Set the set value and its expiry in target
+	if err := p.writeValue(key, interfaceSlice, expiry, target); err != nil {
 		duration := time.Since(startTime)
 		p.logger.LogKeyTransfer(key, "set", size, false, duration, err.Error())
 		return fmt.Errorf("failed to set set value for key %s: %w", key, err)
 	}
 
-	// Set TTL if it exists
-	if ttl > 0 {
-		if err := target.SetTTL(key, ttl); err != nil {
-			p.logger.Warnf("Failed to set TTL for key %s: %v", key, err)
-		}
-	}
-
 	duration := time.Since(startTime)
 	p.logger.LogKeyTransfer(key, "set", size, true, duration, "")
 	return nil
@@ -296,6 +351,12 @@ func (p *migrationProcessor) ProcessSet(key string, source, target client.Databa
 
 // ProcessSortedSet handles sorted set migration with scores
 func (p *migrationProcessor) ProcessSortedSet(key string, source, target client.DatabaseClient) error {
+	// Collections above the large data threshold are transferred in chunks
+	done, readExpiry, err := p.migrateLargeKey(key, "zset", source, target)
+	if done {
+		return err
+	}
+
 	startTime := time.Now()
 
 	// Get the sorted set value from source
@@ -320,28 +381,92 @@ func (p *migrationProcessor) ProcessSortedSet(key string, source, target client.
 	// Log large data detection
 	p.logLargeDataDetection(key, "zset", size)
 
-	// Get TTL from source
-	ttl, err := source.GetTTL(key)
+	// Get expiry from source, as an absolute time so the transfer doesn't extend it
+	expiry, exists, err := p.collectionExpiry(key, readExpiry, size, source)
 	if err != nil {
-		p.logger.Warnf("Failed to get TTL for key %s: %v", key, err)
-		ttl = -1 // No TTL
+		duration := time.Since(startTime)
+		p.logger.LogKeyTransfer(key, "zset", size, false, duration, err.Error())
+		return fmt.Errorf("failed to get expiry for key %s: %w", key, err)
+	}
+	if !exists || expiry.expired() {
+		p.logExpiredDuringTransfer(key)
+		return nil
 	}
 
-	// Set the sorted set value in target
-	if err := target.SetValue(key, zsetValue); err != nil {
+	// Set the sorted set value and its expiry in target
+	if err := p.writeValue(key, zsetValue, expiry, target); err != nil {
 		duration := time.Since(startTime)
 		p.logger.LogKeyTransfer(key, "zset", size, false, duration, err.Error())
 		return fmt.Errorf("failed to set sorted set value for key %s: %w", key, err)
 	}
 
-	// Set TTL if it exists
-	if ttl > 0 {
-		if err := target.SetTTL(key, ttl); err != nil {
-			p.logger.Warnf("Failed to set TTL for key %s: %v", key, err)
-		}
+	duration := time.Since(startTime)
+	p.logger.LogKeyTransfer(key, "zset", size, true, duration, "")
+	return nil
+}
+
+// ProcessStream handles stream migration with entry IDs and consumer groups
+func (p *migrationProcessor) ProcessStream(key string, source, target client.DatabaseClient) error {
+	startTime := time.Now()
+
+	// Get the stream value from source
+	value, err := source.GetValue(key)
+	if err != nil {
+		duration := time.Since(startTime)
+		p.logger.LogKeyTransfer(key, "stream", 0, false, duration, err.Error())
+		return fmt.Errorf("failed to get stream value for key %s: %w", key, err)
+	}
+
+	streamValue, ok := value.(*client.StreamValue)
+	if !ok {
+		duration := time.Since(startTime)
+		errMsg := fmt.Sprintf("expected *client.StreamValue value, got %T", value)
+		p.logger.LogKeyTransfer(key, "stream", 0, false, duration, errMsg)
+		return fmt.Errorf("expected *client.StreamValue value for key %s, got %T", key, value)
+	}
+
+	// Calculate size (number of entries for stream)
+	size := int64(len(streamValue.Entries))
+
+	// Log large data detection
+	p.logLargeDataDetection(key, "stream", size)
+
+	// Get expiry from source, as an absolute time so the transfer doesn't extend it
+	expiry, exists, err := p.readExpiry(key, source)
+	if err != nil {
+		duration := time.Since(startTime)
+		p.logger.LogKeyTransfer(key, "stream", size, false, duration, err.Error())
+		return fmt.Errorf("failed to get expiry for key %s: %w", key, err)
+	}
+	if !exists || expiry.expired() {
+		p.logExpiredDuringTransfer(key)
+		return nil
+	}
+
+	// Set the stream value and its expiry in target
+	if err := p.writeValue(key, p.streamForWrite(streamValue), expiry, target); err != nil {
+		duration := time.Since(startTime)
+		p.logger.LogKeyTransfer(key, "stream", size, false, duration, err.Error())
+		return fmt.Errorf("failed to set stream value for key %s: %w", key, err)
 	}
 
 	duration := time.Since(startTime)
-	p.logger.LogKeyTransfer(key, "zset", size, true, duration, "")
+	p.logger.LogKeyTransfer(key, "stream", size, true, duration, "")
 	return nil
 }
+
+// streamForWrite returns the stream to write to the target, without pending entries
+// unless copying them was requested
+func (p *migrationProcessor) streamForWrite(stream *client.StreamValue) *client.StreamValue {
+	if p.copyStreamPending {
+		return stream
+	}
+
+	stripped := *stream
+	stripped.Groups = make([]client.StreamGroup, len(stream.Groups))
+	for i, group := range stream.Groups {
+		group.Pending = nil
+		stripped.Groups[i] = group
+	}
+	return &stripped
+}
@@ -7,6 +7,9 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
 )
 
 // Test string value processing
@@ -471,6 +474,73 @@ func TestProcessSortedSet(t *testing.T) {
 	}
 }
 
+func TestProcessStream(t *testing.T) {
+	newStream := func() *client.StreamValue {
+		return &client.StreamValue{
+			Entries: []client.StreamEntry{
+				{ID: "1-0", Fields: []string{"event", "created", "id", "42"}},
+				{ID: "5-0", Fields: []string{"event", "updated"}},
+			},
+			LastGeneratedID: "7-0",
+			EntriesAdded:    4,
+			MaxDeletedID:    "7-0",
+			Groups: []client.StreamGroup{
+				{
+					Name:            "workers",
+					LastDeliveredID: "5-0",
+					EntriesRead:     2,
+					Consumers:       []string{"worker-1"},
+					Pending:         []client.StreamPendingEntry{{ID: "5-0", Consumer: "worker-1", Idle: time.Second, DeliveryCount: 1}},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name            string
+		copyPending     bool
+		expectedPending int
+	}{
+		{name: "consumer groups without pending entries", copyPending: false, expectedPending: 0},
+		{name: "consumer groups with pending entries", copyPending: true, expectedPending: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := NewMockDatabaseClient()
+			target := NewMockDatabaseClient()
+			mockLogger := &MockLogger{}
+
+			mockLogger.On("LogKeyTransfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+			mockLogger.On("Warnf", mock.Anything, mock.Anything, mock.Anything).Return()
+
+			processor := NewDataProcessorWithOptions(mockLogger, Options{CopyStreamPending: tt.copyPending})
+
+			sourceStream := newStream()
+			source.data["test:stream"] = sourceStream
+			source.ttls["test:stream"] = 60 * time.Second
+
+			err := processor.ProcessKey("test:stream", "stream", source, target)
+			require.NoError(t, err)
+
+			targetStream, ok := target.data["test:stream"].(*client.StreamValue)
+			require.True(t, ok)
+			assert.Equal(t, sourceStream.Entries, targetStream.Entries)
+			assert.Equal(t, "7-0", targetStream.LastGeneratedID)
+			require.Len(t, targetStream.Groups, 1)
+			assert.Equal(t, "5-0", targetStream.Groups[0].LastDeliveredID)
+			assert.Equal(t, []string{"worker-1"}, targetStream.Groups[0].Consumers)
+			assert.Len(t, targetStream.Groups[0].Pending, tt.expectedPending)
+			assert.Equal(t, 60*time.Second, target.ttls["test:stream"])
+
+			// The source value must not be modified when pending entries are dropped
+			assert.Len(t, sourceStream.Groups[0].Pending, 1)
+
+			mockLogger.AssertCalled(t, "LogKeyTransfer", "test:stream", "stream", int64(2), true, mock.Anything, "")
+		})
+	}
+}
+
 // Test error handling when source returns wrong type
 func TestProcessWrongType(t *testing.T) {
 	source := NewMockDatabaseClient()
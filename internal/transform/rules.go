@@ -0,0 +1,207 @@
+// Package transform renames keys between the source and the target, so that keys of
+// several applications can be namespaced, or grouped into cluster hash slots, as they
+// are migrated.
+package transform
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Rule kinds, written as the part of a rule before the first '='
+const (
+	// KindStripPrefix removes a prefix from keys that start with it
+	KindStripPrefix = "strip-prefix"
+
+	// KindAddPrefix adds a prefix to every key
+	KindAddPrefix = "add-prefix"
+
+	// KindReplace replaces every match of a regular expression, with $1 style
+	// references to its capture groups in the replacement
+	KindReplace = "replace"
+
+	// KindHashTag wraps the first match of a regular expression, or its first capture
+	// group, in braces so that keys sharing it are stored in the same cluster hash slot
+	KindHashTag = "hash-tag"
+)
+
+// replaceSeparator separates the expression of a replace rule from its replacement
+const replaceSeparator = "=>"
+
+// Rule renames a single key
+type Rule interface {
+	// Apply returns the new name of key
+	Apply(key string) string
+
+	// String returns the rule as it is written
+	String() string
+}
+
+// Rules is an ordered list of rules, each applied to the result of the one before
+type Rules []Rule
+
+// Apply returns the name key is migrated to
+func (r Rules) Apply(key string) string {
+	for _, rule := range r {
+		key = rule.Apply(key)
+	}
+	return key
+}
+
+// ParseRules parses rules written as kind=argument, for example strip-prefix=app1:,
+// add-prefix=tenant1:, replace=^user:(\d+)$=>users:$1 or hash-tag=^[^:]+:[^:]+
+func ParseRules(specs []string) (Rules, error) {
+	rules := make(Rules, 0, len(specs))
+	for _, spec := range specs {
+		rule, err := ParseRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// ParseRule parses a single rule, see ParseRules
+func ParseRule(spec string) (Rule, error) {
+	kind, arg, ok := strings.Cut(spec, "=")
+	if !ok {
+		return nil, fmt.Errorf("invalid key rule %q: expected kind=argument", spec)
+	}
+
+	switch kind {
+	case KindStripPrefix:
+		if arg == "" {
+			return nil, fmt.Errorf("invalid key rule %q: prefix cannot be empty", spec)
+		}
+		return stripPrefix(arg), nil
+	case KindAddPrefix:
+		if arg == "" {
+			return nil, fmt.Errorf("invalid key rule %q: prefix cannot be empty", spec)
+		}
+		return addPrefix(arg), nil
+	case KindReplace:
+		expr, replacement, ok := strings.Cut(arg, replaceSeparator)
+		if !ok {
+			return nil, fmt.Errorf("invalid key rule %q: expected %s=expression%sreplacement", spec, KindReplace, replaceSeparator)
+		}
+		re, err := compile(spec, expr)
+		if err != nil {
+			return nil, err
+		}
+		return &replace{re: re, replacement: replacement}, nil
+	case KindHashTag:
+		re, err := compile(spec, arg)
+		if err != nil {
+			return nil, err
+		}
+		if re.NumSubexp() > 0 {
+			// Hash tags are wrapped around the first group only
+			return &hashTag{re: re, group: 1}, nil
+		}
+		return &hashTag{re: re}, nil
+	default:
+		return nil, fmt.Errorf("invalid key rule %q: unknown kind %q, expected %s, %s, %s or %s",
+			spec, kind, KindStripPrefix, KindAddPrefix, KindReplace, KindHashTag)
+	}
+}
+
+// compile compiles the expression of a rule
+func compile(spec, expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, fmt.Errorf("invalid key rule %q: expression cannot be empty", spec)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key rule %q: %w", spec, err)
+	}
+	return re, nil
+}
+
+// stripPrefix removes a prefix from the keys that start with it
+type stripPrefix string
+
+// Apply removes the prefix from key
+func (s stripPrefix) Apply(key string) string {
+	return strings.TrimPrefix(key, string(s))
+}
+
+// String returns the rule as it is written
+func (s stripPrefix) String() string {
+	return KindStripPrefix + "=" + string(s)
+}
+
+// addPrefix adds a prefix to every key
+type addPrefix string
+
+// Apply adds the prefix to key
+func (a addPrefix) Apply(key string) string {
+	return string(a) + key
+}
+
+// String returns the rule as it is written
+func (a addPrefix) String() string {
+	return KindAddPrefix + "=" + string(a)
+}
+
+// replace replaces the matches of an expression
+type replace struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// Apply replaces every match in key
+func (r *replace) Apply(key string) string {
+	return r.re.ReplaceAllString(key, r.replacement)
+}
+
+// String returns the rule as it is written
+func (r *replace) String() string {
+	return KindReplace + "=" + r.re.String() + replaceSeparator + r.replacement
+}
+
+// hashTag wraps the first match of an expression, or of its first group, in braces
+type hashTag struct {
+	re    *regexp.Regexp
+	group int
+}
+
+// Apply wraps the match in key in braces, unless key already has a hash tag
+func (h *hashTag) Apply(key string) string {
+	if HasHashTag(key) {
+		// The key is already placed in a slot of its own choosing
+		return key
+	}
+
+	match := h.re.FindStringSubmatchIndex(key)
+	if match == nil {
+		return key
+	}
+	start, end := match[2*h.group], match[2*h.group+1]
+	if start < 0 || start == end || strings.ContainsAny(key[start:end], "{}") {
+		// The group did not take part in the match, or cannot be a hash tag
+		return key
+	}
+	if strings.IndexByte(key[:start], '{') >= 0 {
+		// An earlier brace would start the hash tag instead
+		return key
+	}
+	return key[:start] + "{" + key[start:end] + "}" + key[end:]
+}
+
+// String returns the rule as it is written
+func (h *hashTag) String() string {
+	return KindHashTag + "=" + h.re.String()
+}
+
+// HasHashTag reports whether a key has a cluster hash tag, a non-empty part between the
+// first '{' and the '}' that follows it
+func HasHashTag(key string) bool {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return false
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	return end > 0
+}
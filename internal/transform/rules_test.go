@@ -0,0 +1,69 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRules_Apply(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []string
+		key      string
+		expected string
+	}{
+		{"no rules", nil, "app1:user:1", "app1:user:1"},
+		{"strip prefix", []string{"strip-prefix=app1:"}, "app1:user:1", "user:1"},
+		{"strip missing prefix", []string{"strip-prefix=app1:"}, "app2:user:1", "app2:user:1"},
+		{"add prefix", []string{"add-prefix=tenant1:"}, "user:1", "tenant1:user:1"},
+		{"replace with groups", []string{`replace=^user:(\d+):(\w+)$=>users:$2:$1`}, "user:42:profile", "users:profile:42"},
+		{"replace no match", []string{`replace=^user:(\d+)$=>users:$1`}, "session:42", "session:42"},
+		{"replacement with equals", []string{`replace=:=>==`}, "a:b", "a==b"},
+		{"hash tag match", []string{`hash-tag=^[^:]+:[^:]+`}, "tenant1:user:1:cart", "{tenant1:user}:1:cart"},
+		{"hash tag group", []string{`hash-tag=^user:(\d+)`}, "user:42:cart", "user:{42}:cart"},
+		{"hash tag no match", []string{`hash-tag=^user:(\d+)`}, "session:42", "session:42"},
+		{"hash tag kept", []string{`hash-tag=^user:(\d+)`}, "user:42:{cart}", "user:42:{cart}"},
+		{"hash tag after brace", []string{`hash-tag=(\d+)$`}, "a{b:42", "a{b:42"},
+		{"in order", []string{"strip-prefix=app1:", "add-prefix=tenant1:", `hash-tag=^tenant1:user:(\d+)`}, "app1:user:7:orders", "tenant1:user:{7}:orders"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := ParseRules(tt.rules)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, rules.Apply(tt.key))
+		})
+	}
+}
+
+func TestParseRules_Invalid(t *testing.T) {
+	tests := []struct {
+		spec string
+		err  string
+	}{
+		{"strip-prefix", "expected kind=argument"},
+		{"add-prefix=", "prefix cannot be empty"},
+		{"rename=a", "unknown kind"},
+		{"replace=^a", "expected replace=expression=>replacement"},
+		{"replace==>b", "expression cannot be empty"},
+		{"hash-tag=(", "missing closing )"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := ParseRules([]string{"add-prefix=a:", tt.spec})
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestRule_String(t *testing.T) {
+	specs := []string{"strip-prefix=app1:", "add-prefix=tenant1:", `replace=^user:(\d+)$=>users:$1`, `hash-tag=^[^:]+`}
+	rules, err := ParseRules(specs)
+	require.NoError(t, err)
+	for i, rule := range rules {
+		assert.Equal(t, specs[i], rule.String())
+	}
+}
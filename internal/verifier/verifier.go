@@ -45,9 +45,17 @@ type DataVerifier interface {
 	CompareKeyContent(key string, source, target client.DatabaseClient) (bool, []string, error)
 }
 
+// Options holds optional verifier settings
+type Options struct {
+	// RenameKey returns the name a source key was written to on the target, so that the
+	// source key is compared against it. Without it, keys keep their names.
+	RenameKey func(key string) string
+}
+
 // migrationVerifier implements DataVerifier interface
 type migrationVerifier struct {
-	logger logger.Logger
+	logger    logger.Logger
+	renameKey func(key string) string
 }
 
 // NewDataVerifier creates a new DataVerifier instance
@@ -57,6 +65,22 @@ func NewDataVerifier(logger logger.Logger) DataVerifier {
 	}
 }
 
+// NewDataVerifierWithOptions creates a new DataVerifier instance with optional settings
+func NewDataVerifierWithOptions(logger logger.Logger, options Options) DataVerifier {
+	return &migrationVerifier{
+		logger:    logger,
+		renameKey: options.RenameKey,
+	}
+}
+
+// targetKey returns the name of key on the target
+func (v *migrationVerifier) targetKey(key string) string {
+	if v.renameKey == nil {
+		return key
+	}
+	return v.renameKey(key)
+}
+
 // VerifyKey verifies a single key between source and target databases
 func (v *migrationVerifier) VerifyKey(key string, source, target client.DatabaseClient) VerificationResult {
 	startTime := time.Now()
@@ -67,7 +91,8 @@ func (v *migrationVerifier) VerifyKey(key string, source, target client.Database
 	}
 
 	// Check if key exists in target
-	exists, err := target.Exists(key)
+	targetKey := v.targetKey(key)
+	exists, err := target.Exists(targetKey)
 	if err != nil {
 		result.ErrorMsg = fmt.Sprintf("failed to check key existence in target: %v", err)
 		result.Duration = time.Since(startTime)
@@ -77,6 +102,9 @@ func (v *migrationVerifier) VerifyKey(key string, source, target client.Database
 
 	if !exists {
 		result.ErrorMsg = "key does not exist in target database"
+		if targetKey != key {
+			result.ErrorMsg = fmt.Sprintf("key does not exist in target database as %s", targetKey)
+		}
 		result.Duration = time.Since(startTime)
 		v.logVerificationResult(result)
 		return result
@@ -92,7 +120,7 @@ func (v *migrationVerifier) VerifyKey(key string, source, target client.Database
 	}
 
 	// Get key type from target
-	targetType, err := target.GetKeyType(key)
+	targetType, err := target.GetKeyType(targetKey)
 	if err != nil {
 		result.ErrorMsg = fmt.Sprintf("failed to get key type from target: %v", err)
 		result.Duration = time.Since(startTime)
@@ -173,7 +201,7 @@ func (v *migrationVerifier) VerifyAllKeys(keys []string, source, target client.D
 
 // VerifyKeyExists checks if a key exists in the target database
 func (v *migrationVerifier) VerifyKeyExists(key string, target client.DatabaseClient) bool {
-	exists, err := target.Exists(key)
+	exists, err := target.Exists(v.targetKey(key))
 	if err != nil {
 		v.logger.Errorf("Failed to check existence of key %s: %v", key, err)
 		return false
@@ -189,7 +217,7 @@ func (v *migrationVerifier) CompareKeyContent(key string, source, target client.
 		return false, nil, fmt.Errorf("failed to get source value: %w", err)
 	}
 
-	targetValue, err := target.GetValue(key)
+	targetValue, err := target.GetValue(v.targetKey(key))
 	if err != nil {
 		return false, nil, fmt.Errorf("failed to get target value: %w", err)
 	}
@@ -212,6 +240,8 @@ func (v *migrationVerifier) CompareKeyContent(key string, source, target client.
 		return v.compareSetValues(sourceValue, targetValue)
 	case "zset":
 		return v.compareSortedSetValues(sourceValue, targetValue)
+	case "stream":
+		return v.compareStreamValues(sourceValue, targetValue)
 	default:
 		return false, []string{fmt.Sprintf("unsupported data type: %s", keyType)}, nil
 	}
@@ -392,6 +422,83 @@ func (v *migrationVerifier) compareSortedSetValues(source, target interface{}) (
 	return len(mismatches) == 0, mismatches, nil
 }
 
+// compareStreamValues compares stream values entry by entry (IDs, field order and values matter).
+// Pending entries lists are only copied on request, so they are not compared.
+func (v *migrationVerifier) compareStreamValues(source, target interface{}) (bool, []string, error) {
+	sourceStream, ok := source.(*client.StreamValue)
+	if !ok {
+		return false, []string{"source value is not a stream"}, nil
+	}
+
+	targetStream, ok := target.(*client.StreamValue)
+	if !ok {
+		return false, []string{"target value is not a stream"}, nil
+	}
+
+	var mismatches []string
+
+	if len(sourceStream.Entries) != len(targetStream.Entries) {
+		mismatches = append(mismatches, fmt.Sprintf("stream length mismatch: source=%d, target=%d", len(sourceStream.Entries), len(targetStream.Entries)))
+	}
+
+	// Compare entries up to the shorter length
+	minLen := len(sourceStream.Entries)
+	if len(targetStream.Entries) < minLen {
+		minLen = len(targetStream.Entries)
+	}
+
+	for i := 0; i < minLen; i++ {
+		sourceEntry, targetEntry := sourceStream.Entries[i], targetStream.Entries[i]
+		if sourceEntry.ID != targetEntry.ID {
+			mismatches = append(mismatches, fmt.Sprintf("entry at index %d ID mismatch: source=%s, target=%s", i, sourceEntry.ID, targetEntry.ID))
+		} else if !equalFields(sourceEntry.Fields, targetEntry.Fields) {
+			mismatches = append(mismatches, fmt.Sprintf("entry %s fields mismatch", sourceEntry.ID))
+		}
+	}
+
+	if sourceStream.LastGeneratedID != targetStream.LastGeneratedID {
+		mismatches = append(mismatches, fmt.Sprintf("last generated ID mismatch: source=%s, target=%s", sourceStream.LastGeneratedID, targetStream.LastGeneratedID))
+	}
+
+	// Compare consumer groups by name
+	targetGroups := make(map[string]client.StreamGroup)
+	for _, group := range targetStream.Groups {
+		targetGroups[group.Name] = group
+	}
+
+	for _, sourceGroup := range sourceStream.Groups {
+		targetGroup, exists := targetGroups[sourceGroup.Name]
+		if !exists {
+			mismatches = append(mismatches, fmt.Sprintf("consumer group '%s' missing in target", sourceGroup.Name))
+			continue
+		}
+		if sourceGroup.LastDeliveredID != targetGroup.LastDeliveredID {
+			mismatches = append(mismatches, fmt.Sprintf("consumer group '%s' last delivered ID mismatch: source=%s, target=%s", sourceGroup.Name, sourceGroup.LastDeliveredID, targetGroup.LastDeliveredID))
+		}
+		delete(targetGroups, sourceGroup.Name)
+	}
+
+	// Check for extra groups in target
+	for name := range targetGroups {
+		mismatches = append(mismatches, fmt.Sprintf("extra consumer group '%s' in target", name))
+	}
+
+	return len(mismatches) == 0, mismatches, nil
+}
+
+// equalFields compares the field/value pairs of two stream entries
+func equalFields(source, target []string) bool {
+	if len(source) != len(target) {
+		return false
+	}
+	for i := range source {
+		if source[i] != target[i] {
+			return false
+		}
+	}
+	return true
+}
+
 // logVerificationResult logs the result of a verification operation
 func (v *migrationVerifier) logVerificationResult(result VerificationResult) {
 	fields := map[string]interface{}{
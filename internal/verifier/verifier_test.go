@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/kinyelo/redis-valkey-migration/internal/client"
 	"github.com/kinyelo/redis-valkey-migration/pkg/logger"
 )
 
@@ -96,6 +97,36 @@ func TestVerifyKey_KeyNotExistsInTarget(t *testing.T) {
 	assert.Empty(t, result.Mismatches, "Should have no mismatches when key is missing")
 }
 
+func TestVerifyKey_RenamedKey(t *testing.T) {
+	sourceClient := &mockDatabaseClient{
+		data:     map[string]interface{}{"app1:user:1": map[string]string{"name": "a"}},
+		keyTypes: map[string]string{"app1:user:1": "hash"},
+	}
+	targetClient := &mockDatabaseClient{
+		data:     map[string]interface{}{"tenant1:user:1": map[string]string{"name": "a"}},
+		keyTypes: map[string]string{"tenant1:user:1": "hash"},
+	}
+
+	testLogger, err := logger.NewLogger(logger.Config{Level: "error", Format: "text"})
+	require.NoError(t, err)
+
+	verifier := NewDataVerifierWithOptions(testLogger, Options{
+		RenameKey: func(key string) string { return "tenant1:" + key[len("app1:"):] },
+	})
+
+	// The source key is compared against the renamed target key
+	result := verifier.VerifyKey("app1:user:1", sourceClient, targetClient)
+	assert.True(t, result.Success, result.ErrorMsg)
+	assert.Equal(t, "app1:user:1", result.Key)
+	assert.True(t, verifier.VerifyKeyExists("app1:user:1", targetClient))
+
+	sourceClient.data["app1:user:2"] = "v"
+	sourceClient.keyTypes["app1:user:2"] = "string"
+	result = verifier.VerifyKey("app1:user:2", sourceClient, targetClient)
+	assert.False(t, result.Success)
+	assert.Equal(t, "key does not exist in target database as tenant1:user:2", result.ErrorMsg)
+}
+
 func TestVerifyKey_TypeMismatch(t *testing.T) {
 	// Create mock clients
 	sourceClient := &mockDatabaseClient{
@@ -381,6 +412,84 @@ func TestVerifyKey_SortedSetScoreMismatch(t *testing.T) {
 	assert.Contains(t, mismatchStr, "member2")
 }
 
+func TestVerifyKey_StreamMismatch(t *testing.T) {
+	// Create mock clients
+	sourceClient := &mockDatabaseClient{
+		data:     make(map[string]interface{}),
+		keyTypes: make(map[string]string),
+	}
+	targetClient := &mockDatabaseClient{
+		data:     make(map[string]interface{}),
+		keyTypes: make(map[string]string),
+	}
+
+	// Create logger
+	loggerConfig := logger.Config{
+		Level:  "error",
+		Format: "text",
+	}
+	testLogger, err := logger.NewLogger(loggerConfig)
+	require.NoError(t, err)
+
+	// Create verifier
+	verifier := NewDataVerifier(testLogger)
+
+	key := "stream_key"
+	sourceClient.data[key] = &client.StreamValue{
+		Entries: []client.StreamEntry{
+			{ID: "1-0", Fields: []string{"a", "1"}},
+			{ID: "2-0", Fields: []string{"b", "2"}},
+		},
+		LastGeneratedID: "3-0",
+		Groups: []client.StreamGroup{
+			{Name: "g1", LastDeliveredID: "2-0"},
+			{Name: "g2", LastDeliveredID: "0-0"},
+		},
+	}
+	sourceClient.keyTypes[key] = "stream"
+
+	// Identical stream verifies successfully, even without pending entries
+	targetClient.data[key] = &client.StreamValue{
+		Entries: []client.StreamEntry{
+			{ID: "1-0", Fields: []string{"a", "1"}},
+			{ID: "2-0", Fields: []string{"b", "2"}},
+		},
+		LastGeneratedID: "3-0",
+		Groups: []client.StreamGroup{
+			{Name: "g2", LastDeliveredID: "0-0"},
+			{Name: "g1", LastDeliveredID: "2-0"},
+		},
+	}
+	targetClient.keyTypes[key] = "stream"
+
+	result := verifier.VerifyKey(key, sourceClient, targetClient)
+	assert.True(t, result.Success, "Verification should succeed for identical streams")
+	assert.Equal(t, "stream", result.DataType)
+
+	// Different entry values, last generated ID and group offsets
+	targetClient.data[key] = &client.StreamValue{
+		Entries: []client.StreamEntry{
+			{ID: "1-0", Fields: []string{"a", "1"}},
+			{ID: "2-0", Fields: []string{"b", "changed"}},
+		},
+		LastGeneratedID: "2-0",
+		Groups: []client.StreamGroup{
+			{Name: "g1", LastDeliveredID: "1-0"},
+			{Name: "g3", LastDeliveredID: "0-0"},
+		},
+	}
+
+	result = verifier.VerifyKey(key, sourceClient, targetClient)
+	assert.False(t, result.Success, "Verification should fail for stream mismatch")
+
+	mismatchStr := fmt.Sprintf("%v", result.Mismatches)
+	assert.Contains(t, mismatchStr, "entry 2-0 fields mismatch")
+	assert.Contains(t, mismatchStr, "last generated ID mismatch")
+	assert.Contains(t, mismatchStr, "consumer group 'g1' last delivered ID mismatch")
+	assert.Contains(t, mismatchStr, "consumer group 'g2' missing in target")
+	assert.Contains(t, mismatchStr, "extra consumer group 'g3' in target")
+}
+
 func TestVerifyAllKeys_MixedResults(t *testing.T) {
 	// Create mock clients
 	sourceClient := &mockDatabaseClient{
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/signal"
@@ -10,6 +12,8 @@ import (
 	"github.com/kinyelo/redis-valkey-migration/internal/client"
 	"github.com/kinyelo/redis-valkey-migration/internal/config"
 	"github.com/kinyelo/redis-valkey-migration/internal/engine"
+	"github.com/kinyelo/redis-valkey-migration/internal/monitor"
+	"github.com/kinyelo/redis-valkey-migration/internal/transform"
 	"github.com/kinyelo/redis-valkey-migration/internal/version"
 	"github.com/kinyelo/redis-valkey-migration/pkg/logger"
 
@@ -30,7 +34,7 @@ that provides complete data migration between Redis and Valkey databases.
 It supports all Redis data types and provides comprehensive progress monitoring 
 and verification capabilities.
 
-The tool handles strings, hashes, lists, sets, and sorted sets with full
+The tool handles strings, hashes, lists, sets, sorted sets, and streams with full
 data integrity verification and comprehensive error handling.`,
 	Example: `  # Basic migration with default settings
   redis-valkey-migration migrate
@@ -54,7 +58,11 @@ data integrity verification and comprehensive error handling.`,
   redis-valkey-migration migrate --batch-size 500 --log-level debug
 
   # Resume a previous migration
-  redis-valkey-migration migrate --resume-file /path/to/resume.json`,
+  redis-valkey-migration migrate --resume-file /path/to/resume.json
+
+  # Migrate without a network path between Redis and Valkey
+  redis-valkey-migration export --output redis.rvma
+  redis-valkey-migration import --input redis.rvma`,
 }
 
 var migrateCmd = &cobra.Command{
@@ -65,13 +73,14 @@ var migrateCmd = &cobra.Command{
 This command will:
 1. Connect to both Redis and Valkey databases
 2. Discover keys in the Redis database (all keys or filtered by patterns)
-3. Transfer each key with its data and type information
+3. Transfer each key with its data and type information as it is discovered
 4. Verify data integrity after transfer
 5. Provide comprehensive progress reporting and logging
 
 The migration supports all Redis data types including strings, hashes, 
-lists, sets, and sorted sets. It includes automatic retry logic for 
-network errors and can resume interrupted migrations.
+lists, sets, sorted sets, and streams. It includes automatic retry logic for 
+network errors and can resume interrupted migrations. Keys that fail are
+recorded in --dead-letter-file, and retry-failed migrates them again.
 
 Collection Filtering:
 You can migrate specific collections of keys using glob-style patterns:
@@ -79,7 +88,52 @@ You can migrate specific collections of keys using glob-style patterns:
 - Use --collections as an alias for --pattern
 - Patterns support wildcards: * matches any characters
 - Multiple patterns can be specified to migrate different collections
-- If no patterns are specified, all keys will be migrated`,
+- If no patterns are specified, all keys will be migrated
+
+Key Rules:
+Keys can be renamed on their way to Valkey with --key-rule, applied in order:
+- strip-prefix=P removes the prefix P from keys that start with it
+- add-prefix=P adds the prefix P to every key
+- replace=REGEX=>REPLACEMENT rewrites matches, with $1 for capture groups
+- hash-tag=REGEX wraps the match, or its first group, in {} for cluster targets
+Patterns match the Redis key names, and verification compares each Redis key
+with its renamed Valkey key. Two keys renamed to the same name fail the
+migration before the second one is copied.
+
+All Databases:
+With --all-databases, every database of Redis holding keys, as listed by INFO
+keyspace, is migrated one after the other. --database-map renumbers or
+consolidates databases, for example 0->0,3->1; databases it does not mention
+keep their number. Each database is verified on its own and keeps its progress
+in a resume file of its own, named after --resume-file, and a migration started
+again skips the databases already completed.
+
+Live Sync:
+With --sync, the tool subscribes to keyspace notifications before the bulk copy
+starts, then keeps copying keys that change on Redis and deleting keys that are
+deleted there, until the --cutover-file is created. With --sync-method psync, the
+tool connects as a replica of Redis instead, loads the snapshot Redis sends and
+replays every write command after it; the replication offset is kept in the
+resume file so a restarted sync continues where it stopped.
+
+Cutover pauses writes on Redis (CLIENT PAUSE WRITE) for at most
+--cutover-pause-timeout, applies the remaining changes, verifies the recently
+changed keys and writes the outcome, including how long writes were frozen, as
+JSON to --cutover-result-file. Use the cutover command to request it and wait
+for the result.
+
+RDB File Source:
+With --redis-rdb-file, keys are read from an RDB snapshot such as a dump.rdb
+backup instead of a running Redis. --redis-database selects the database of the
+file to migrate, and keys that have expired by the time they are read are
+skipped. Live sync is not available for an RDB file.
+
+Command File Target:
+With --valkey-command-file, the migration is written as raw RESP commands to a
+file, or to stdout with -, instead of a running Valkey. Each key is written in a
+MULTI/EXEC transaction that replaces it and sets its expiry with PEXPIREAT, so the
+file can be reviewed and replayed later with valkey-cli --pipe. Verification and
+live sync are not available for a command file.`,
 	Example: `  # Basic migration (all keys)
   redis-valkey-migration migrate
 
@@ -112,11 +166,132 @@ You can migrate specific collections of keys using glob-style patterns:
   # Dry run to preview migration with patterns
   redis-valkey-migration migrate --dry-run --pattern "user:*"
 
+  # Move the keys of one app into a tenant namespace
+  redis-valkey-migration migrate --pattern "app1:*" \
+    --key-rule "strip-prefix=app1:" --key-rule "add-prefix=tenant1:"
+
+  # Migrate every database, moving database 3 into database 1
+  redis-valkey-migration migrate --all-databases --database-map "3->1"
+
   # Resume interrupted migration
-  redis-valkey-migration migrate --resume-file migration_state.json`,
+  redis-valkey-migration migrate --resume-file migration_state.json
+
+  # Resume after changing the patterns the migration was started with
+  redis-valkey-migration migrate --resume-file migration_state.json --force-resume --pattern "user:*"
+
+  # Keep syncing changes until "touch /tmp/cutover" requests cutover
+  redis-valkey-migration migrate --sync --cutover-file /tmp/cutover
+
+  # Follow Redis as a replica instead of through keyspace notifications
+  redis-valkey-migration migrate --sync --sync-method psync
+
+  # Populate Valkey from a backup, without a running Redis
+  redis-valkey-migration migrate --redis-rdb-file /backups/dump.rdb
+
+  # Write the migration to a file and replay it later
+  redis-valkey-migration migrate --valkey-command-file migration.resp
+  valkey-cli --pipe < migration.resp`,
 	RunE: runMigration,
 }
 
+var cutoverCmd = &cobra.Command{
+	Use:   "cutover",
+	Short: "Cut over a running live sync and wait for the result",
+	Long: `Request cutover of a running live sync (migrate --sync) and wait for it to finish.
+
+The command creates the cutover file the sync watches, waits for the sync to
+write its cutover result and prints the result as JSON. It exits with an error
+unless the cutover succeeded, so a deploy pipeline can switch applications to
+Valkey only after a successful cutover. On success writes stay paused on Redis
+until the paused_until time of the result.`,
+	Example: `  # Cut over the sync started with the default cutover files
+  redis-valkey-migration cutover
+
+  # Cut over a sync started with custom cutover files
+  redis-valkey-migration cutover --cutover-file /tmp/cutover --cutover-result-file /tmp/cutover.json`,
+	RunE: runCutover,
+}
+
+var retryFailedCmd = &cobra.Command{
+	Use:   "retry-failed",
+	Short: "Migrate the keys that failed to migrate again",
+	Long: `Migrate the keys listed in the dead-letter file of a migration again.
+
+migrate records every key that fails in --dead-letter-file, one JSON object
+per line with the error type, operation and cause of the failure. retry-failed
+migrates exactly those keys again, with the retry settings given now, instead
+of the whole keyspace, and verifies the keys that succeed unless --verify is
+turned off. The dead-letter file is then left listing the keys that failed
+again, and the command fails if there are any.
+
+Use the --redis-*, --valkey-* and --key-rule settings of the migration. Keys
+of other databases than --redis-database and --valkey-database are left in
+the file.`,
+	Example: `  # Retry the keys that failed, with more retries
+  redis-valkey-migration retry-failed --retry-attempts 10 --retry-delay 5s
+
+  # Retry the keys of a custom dead-letter file, overwriting keys already on Valkey
+  redis-valkey-migration retry-failed --dead-letter-file /var/log/failed.ndjson --on-conflict overwrite`,
+	RunE: runRetryFailed,
+}
+
+var inspectResumeCmd = &cobra.Command{
+	Use:   "inspect-resume",
+	Short: "Print what a resume file contains",
+	Long: `Print what the resume file of an interrupted migration contains, as JSON.
+
+The output shows the migration the file was saved by (the Redis and Valkey
+endpoints and databases, patterns, key rules and tool version), the number of
+keys migrated and discovered, the scan position and the keys that failed. For
+the resume file of an --all-databases migration it lists the databases
+completed. migrate only resumes from a file saved by the same migration unless
+--force-resume is given.`,
+	Example: `  # Inspect the default resume file
+  redis-valkey-migration inspect-resume
+
+  # Inspect a custom resume file
+  redis-valkey-migration inspect-resume --resume-file /path/to/resume.json`,
+	RunE: runInspectResume,
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export keys from Redis to an archive file",
+	Long: `Export keys from Redis to an archive file that can be imported into Valkey later.
+
+The archive holds the type, value, remaining time to live and database number
+of every key, in gzip compressed chunks with SHA-256 checksums, followed by a
+manifest listing the chunks once the export is complete. Use it to migrate
+when Redis and Valkey are on networks that cannot reach each other: export on
+one side, carry the file over and import it on the other.
+
+An interrupted export is resumed by running it again with the same output file;
+keys already in the archive are not exported again. The --pattern and
+--redis-* flags select what is exported, as for migrate.`,
+	Example: `  # Export all keys
+  redis-valkey-migration export --output /backups/redis.rvma
+
+  # Export one collection from a backup file
+  redis-valkey-migration export --redis-rdb-file dump.rdb --pattern "user:*" --output users.rvma`,
+	RunE: runExport,
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import keys from an archive file into Valkey",
+	Long: `Import the keys of an archive written by export into Valkey.
+
+Every chunk is checked against the checksum in the manifest before it is
+imported, and imported keys are verified against the archive unless --verify
+is turned off. Keys keep the expiry they had on Redis, so keys that expired
+since the export are skipped. Progress is saved to --resume-file after every
+chunk, and running the import again continues an interrupted one. The
+--valkey-* flags select where keys are imported to, as for migrate.`,
+	Example: `  # Import an archive
+  redis-valkey-migration import --input /backups/redis.rvma --valkey-host valkey.example.com`,
+	RunE: runImport,
+}
+
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print version information",
@@ -139,47 +314,103 @@ func init() {
 	migrateCmd.Flags().Bool("verify", true, "verify data integrity after migration completion")
 	migrateCmd.Flags().Bool("continue-on-error", true, "continue migration even if some individual keys fail to transfer")
 	migrateCmd.Flags().String("resume-file", "migration_resume.json", "file to store migration state for resume capability")
+	migrateCmd.Flags().Bool("force-resume", false, "resume from the resume file even if it was saved by a migration with different settings")
+	migrateCmd.Flags().String("dead-letter-file", "migration_failed.ndjson", "file recording every key that fails to migrate, as NDJSON")
 	migrateCmd.Flags().Duration("progress-interval", 5000000000, "interval for progress reporting (e.g., 5s, 1m, 30s)")
 	migrateCmd.Flags().Int("max-concurrency", 10, "maximum number of concurrent key transfer operations")
+	migrateCmd.Flags().Bool("copy-stream-pending", false, "copy the pending entries lists of stream consumer groups")
+	migrateCmd.Flags().String("transfer-mode", "native", "how key values are copied: dump (DUMP/RESTORE), native (type-specific commands) or auto (dump when supported)")
+	migrateCmd.Flags().String("on-conflict", "overwrite", "what to do with keys that already exist on the target: overwrite, skip, fail or merge")
+	migrateCmd.Flags().Bool("sync", false, "keep copying changes from Redis after the bulk copy until cutover is requested")
+	migrateCmd.Flags().String("sync-method", "notifications", "in sync mode, how changes are followed: notifications (keyspace notifications) or psync (act as a replica of Redis)")
+	migrateCmd.Flags().String("cutover-file", "migration.cutover", "in sync mode, stop syncing once this file exists")
+	migrateCmd.Flags().Duration("sync-interval", 1000000000, "in sync mode, how often changed keys are copied (e.g., 1s, 500ms)")
+	migrateCmd.Flags().Duration("cutover-pause-timeout", 30000000000, "at cutover, how long writes on Redis are paused at most (e.g., 30s, 1m)")
+	migrateCmd.Flags().String("cutover-result-file", "migration_cutover.json", "at cutover, write the result as JSON to this file")
+
+	cutoverCmd.Flags().String("cutover-file", "migration.cutover", "cutover file the sync watches")
+	cutoverCmd.Flags().String("cutover-result-file", "migration_cutover.json", "file the sync writes the cutover result to")
+	cutoverCmd.Flags().Duration("timeout", 300000000000, "how long to wait for the cutover result (e.g., 5m)")
+
+	inspectResumeCmd.Flags().String("resume-file", "migration_resume.json", "resume file to inspect")
+
+	config.BindFlags(retryFailedCmd)
+	retryFailedCmd.Flags().String("dead-letter-file", "migration_failed.ndjson", "dead-letter file listing the keys to migrate again")
+	retryFailedCmd.Flags().Duration("retry-delay", 1000000000, "delay before the first retry of a failed operation, doubled for every retry (e.g., 1s, 5s)")
+	retryFailedCmd.Flags().Bool("verify", true, "verify the keys migrated after they are retried")
+	retryFailedCmd.Flags().Int("max-concurrency", 10, "maximum number of concurrent key transfer operations")
+	retryFailedCmd.Flags().Bool("copy-stream-pending", false, "copy the pending entries lists of stream consumer groups")
+	retryFailedCmd.Flags().String("transfer-mode", "native", "how key values are copied: dump (DUMP/RESTORE), native (type-specific commands) or auto (dump when supported)")
+	retryFailedCmd.Flags().String("on-conflict", "overwrite", "what to do with keys that already exist on the target: overwrite, skip, fail or merge")
 
 	// Set up command completion
 	rootCmd.CompletionOptions.DisableDefaultCmd = false
 
+	config.BindFlags(exportCmd)
+	exportCmd.Flags().StringP("output", "o", "", "archive file to export to (required)")
+	exportCmd.Flags().Int("chunk-size", 1000, "number of keys per archive chunk")
+	exportCmd.Flags().Int("max-concurrency", 10, "maximum number of keys read concurrently")
+	exportCmd.MarkFlagRequired("output")
+
+	config.BindFlags(importCmd)
+	importCmd.Flags().StringP("input", "i", "", "archive file to import (required)")
+	importCmd.Flags().String("resume-file", "migration_import.json", "file to store import progress for resume capability")
+	importCmd.Flags().Int("max-concurrency", 10, "maximum number of keys written concurrently")
+	importCmd.Flags().Bool("verify", true, "verify every imported key against the archive")
+	importCmd.Flags().Bool("copy-stream-pending", false, "copy the pending entries lists of stream consumer groups")
+	importCmd.MarkFlagRequired("input")
+
 	// Add commands
 	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(cutoverCmd)
+	rootCmd.AddCommand(retryFailedCmd)
+	rootCmd.AddCommand(inspectResumeCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
 	rootCmd.AddCommand(versionCmd)
 }
 
 func runMigration(cmd *cobra.Command, args []string) error {
 	// Load configuration
+	config.UseFlags(cmd)
 	cfg, err := config.LoadConfigWithFlags()
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
 
 	// Set up logger
-	logLevel := cfg.Migration.LogLevel
-	if verbose {
-		logLevel = "debug"
+	log, err := createLogger(cfg)
+	if err != nil {
+		return err
 	}
 
-	logConfig := logger.Config{
-		Level:      logLevel,
-		OutputFile: "migration.log",
-		MaxSize:    10 * 1024 * 1024, // 10MB
-		MaxAge:     7,                // 7 days
-		Format:     "text",
+	log.Info("Starting Redis to Valkey migration")
+	redisSource := fmt.Sprintf("%s:%d", cfg.Redis.Host, cfg.Redis.Port)
+	if cfg.Redis.RDBFile != "" {
+		redisSource = cfg.Redis.RDBFile
+	}
+	valkeyTarget := fmt.Sprintf("%s:%d", cfg.Valkey.Host, cfg.Valkey.Port)
+	if cfg.Valkey.CommandFile != "" {
+		valkeyTarget = cfg.Valkey.CommandFile
 	}
+	log.Infof("Configuration: Redis=%s DB=%d, Valkey=%s DB=%d",
+		redisSource, cfg.Redis.Database,
+		valkeyTarget, cfg.Valkey.Database)
 
-	log, err := logger.NewLogger(logConfig)
-	if err != nil {
-		return fmt.Errorf("failed to create logger: %w", err)
+	syncMode, _ := cmd.Flags().GetBool("sync")
+	if syncMode && cfg.Redis.RDBFile != "" {
+		return fmt.Errorf("sync mode needs a running Redis, it cannot follow an RDB file")
+	}
+	if syncMode && cfg.Valkey.CommandFile != "" {
+		return fmt.Errorf("sync mode needs a running Valkey, it cannot write to a command file")
 	}
 
-	log.Info("Starting Redis to Valkey migration")
-	log.Infof("Configuration: Redis=%s:%d DB=%d, Valkey=%s:%d DB=%d",
-		cfg.Redis.Host, cfg.Redis.Port, cfg.Redis.Database,
-		cfg.Valkey.Host, cfg.Valkey.Port, cfg.Valkey.Database)
+	if cfg.Migration.AllDatabases {
+		if syncMode {
+			return fmt.Errorf("sync mode follows a single database, it cannot be combined with --all-databases")
+		}
+		return runAllDatabases(cmd, cfg, log)
+	}
 
 	if dryRun {
 		log.Info("DRY RUN MODE: No data will be actually migrated")
@@ -199,10 +430,14 @@ func runMigration(cmd *cobra.Command, args []string) error {
 
 	// Create engine configuration
 	engineConfig := createEngineConfig(cmd, cfg)
+	if cfg.Valkey.CommandFile != "" && engineConfig.VerifyAfterMigration {
+		log.Info("Skipping verification, keys written to a command file cannot be read back")
+		engineConfig.VerifyAfterMigration = false
+	}
 
 	// Create client configurations with proper timeouts
-	redisConfig := client.NewClientConfig(cfg.Redis.Host, cfg.Redis.Port, cfg.Redis.Password, cfg.Redis.Database)
-	valkeyConfig := client.NewClientConfig(cfg.Valkey.Host, cfg.Valkey.Port, cfg.Valkey.Password, cfg.Valkey.Database)
+	redisConfig := client.NewClientConfigFromDatabaseConfig(&cfg.Redis, &cfg.Migration.TimeoutConfig)
+	valkeyConfig := client.NewClientConfigFromDatabaseConfig(&cfg.Valkey, &cfg.Migration.TimeoutConfig)
 
 	// Create migration engine
 	migrationEngine, err := engine.NewMigrationEngine(
@@ -227,29 +462,424 @@ func runMigration(cmd *cobra.Command, args []string) error {
 		migrationEngine.Shutdown()
 	}()
 
-	// Start migration
-	if err := migrationEngine.Migrate(); err != nil {
+	// Start migration, or live sync until cutover
+	if syncMode {
+		log.Infof("SYNC MODE: changes are copied until %s is created", engineConfig.CutoverFile)
+		if err := migrationEngine.Sync(); err != nil {
+			return fmt.Errorf("sync failed: %w", err)
+		}
+	} else if err := migrationEngine.Migrate(); err != nil {
 		return fmt.Errorf("migration failed: %w", err)
 	}
 
 	// Print final statistics
 	stats := migrationEngine.GetStats()
 	log.Info("Migration completed successfully")
-	log.Infof("Final statistics: Total=%d, Processed=%d, Failed=%d, Duration=%v, Throughput=%.2f keys/sec",
-		stats.TotalKeys, stats.ProcessedKeys, stats.FailedKeys, stats.Duration, stats.Throughput)
+	log.Infof("Final statistics: Total=%d, Processed=%d, Failed=%d, Skipped=%d, Conflicts=%d, Duration=%v, Throughput=%.2f keys/sec",
+		stats.TotalKeys, stats.ProcessedKeys, stats.FailedKeys, stats.SkippedKeys, stats.ConflictKeys, stats.Duration, stats.Throughput)
+
+	return nil
+}
+
+// runAllDatabases migrates every database of Redis holding keys, each to the database
+// the database map gives it
+func runAllDatabases(cmd *cobra.Command, cfg *config.Config, log logger.Logger) error {
+	plan, err := planDatabases(cfg, log)
+	if err != nil {
+		return err
+	}
+	if len(plan) == 0 {
+		log.Info("No database of Redis holds any keys, nothing to migrate")
+		return nil
+	}
+
+	log.Infof("Migrating %d databases:", len(plan))
+	for _, mapping := range plan {
+		log.Infof("  database %d -> database %d (%d keys)", mapping.Source, mapping.Target, mapping.Keys)
+	}
+
+	if dryRun {
+		log.Info("DRY RUN MODE: No data will be actually migrated")
+		for _, mapping := range plan {
+			dbCfg := databaseConfig(cfg, mapping)
+			log.Infof("Database %d:", mapping.Source)
+			if err := runDryRun(dbCfg, log); err != nil {
+				return fmt.Errorf("database %d: %w", mapping.Source, err)
+			}
+		}
+		return nil
+	}
+
+	engineConfig := createEngineConfig(cmd, cfg)
+	if cfg.Valkey.CommandFile != "" && engineConfig.VerifyAfterMigration {
+		log.Info("Skipping verification, keys written to a command file cannot be read back")
+		engineConfig.VerifyAfterMigration = false
+	}
+
+	databaseMap, err := config.ParseDatabaseMap(cfg.Migration.DatabaseMap)
+	if err != nil {
+		return err
+	}
+	fingerprint := engine.NewDatabasesFingerprint(
+		client.NewClientConfigFromDatabaseConfig(&cfg.Redis, &cfg.Migration.TimeoutConfig),
+		client.NewClientConfigFromDatabaseConfig(&cfg.Valkey, &cfg.Migration.TimeoutConfig),
+		databaseMap,
+		engineConfig,
+	)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	results, err := engine.MigrateDatabases(ctx, plan, engineConfig.ResumeFile, fingerprint, engineConfig.ForceResume, log,
+		func(mapping engine.DatabaseMapping, resumeFile string) (monitor.MigrationStats, error) {
+			dbCfg := databaseConfig(cfg, mapping)
+			redisClient, err := createRedisClient(dbCfg, log)
+			if err != nil {
+				return monitor.MigrationStats{}, fmt.Errorf("failed to create Redis client: %w", err)
+			}
+			valkeyClient, err := createValkeyClient(dbCfg, log)
+			if err != nil {
+				return monitor.MigrationStats{}, fmt.Errorf("failed to create Valkey client: %w", err)
+			}
+
+			dbEngineConfig := *engineConfig
+			dbEngineConfig.ResumeFile = resumeFile
+			migrationEngine, err := engine.NewMigrationEngine(
+				redisClient,
+				client.NewClientConfigFromDatabaseConfig(&dbCfg.Redis, &dbCfg.Migration.TimeoutConfig),
+				valkeyClient,
+				client.NewClientConfigFromDatabaseConfig(&dbCfg.Valkey, &dbCfg.Migration.TimeoutConfig),
+				log,
+				&dbEngineConfig,
+			)
+			if err != nil {
+				return monitor.MigrationStats{}, fmt.Errorf("failed to create migration engine: %w", err)
+			}
+
+			if err := migrationEngine.Migrate(); err != nil {
+				return monitor.MigrationStats{}, fmt.Errorf("migration failed: %w", err)
+			}
+			return migrationEngine.GetStats(), nil
+		})
+
+	// Print per-database and total statistics, also for a migration that stopped early
+	var total monitor.MigrationStats
+	for _, result := range results {
+		stats := result.Stats
+		previously := ""
+		if result.Resumed {
+			previously = " (previous run)"
+		}
+		log.Infof("Database %d -> %d%s: Total=%d, Processed=%d, Failed=%d, Skipped=%d, Conflicts=%d, Duration=%v",
+			result.Source, result.Target, previously, stats.TotalKeys, stats.ProcessedKeys, stats.FailedKeys, stats.SkippedKeys, stats.ConflictKeys, stats.Duration)
+		total.TotalKeys += stats.TotalKeys
+		total.ProcessedKeys += stats.ProcessedKeys
+		total.FailedKeys += stats.FailedKeys
+		total.SkippedKeys += stats.SkippedKeys
+		total.ConflictKeys += stats.ConflictKeys
+		total.Duration += stats.Duration
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Info("Migration completed successfully")
+	log.Infof("Final statistics: Databases=%d, Total=%d, Processed=%d, Failed=%d, Skipped=%d, Conflicts=%d, Duration=%v",
+		len(results), total.TotalKeys, total.ProcessedKeys, total.FailedKeys, total.SkippedKeys, total.ConflictKeys, total.Duration)
+	return nil
+}
+
+// planDatabases discovers the databases of Redis holding keys and the database each one
+// is migrated to
+func planDatabases(cfg *config.Config, log logger.Logger) ([]engine.DatabaseMapping, error) {
+	mapping, err := config.ParseDatabaseMap(cfg.Migration.DatabaseMap)
+	if err != nil {
+		return nil, err
+	}
+
+	redisClient, err := createRedisClient(cfg, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Redis client: %w", err)
+	}
+	keyspaceClient, ok := redisClient.(client.KeyspaceClient)
+	if !ok {
+		return nil, fmt.Errorf("the Redis source cannot list its databases")
+	}
+
+	if err := redisClient.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	defer redisClient.Disconnect()
+
+	keyspace, err := keyspaceClient.Keyspace()
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover databases: %w", err)
+	}
+
+	plan := engine.PlanDatabases(keyspace, mapping)
+	if cfg.Valkey.Cluster {
+		for _, db := range plan {
+			if db.Target != 0 {
+				return nil, fmt.Errorf("Valkey cluster only has database 0, map database %d to it with --database-map %d->0", db.Source, db.Source)
+			}
+		}
+	}
+	return plan, nil
+}
+
+// databaseConfig returns a copy of cfg that migrates one database of a multi-database
+// migration
+func databaseConfig(cfg *config.Config, mapping engine.DatabaseMapping) *config.Config {
+	dbCfg := *cfg
+	dbCfg.Redis.Database = mapping.Source
+	dbCfg.Valkey.Database = mapping.Target
+	return &dbCfg
+}
+
+func runCutover(cmd *cobra.Command, args []string) error {
+	cutoverFile, _ := cmd.Flags().GetString("cutover-file")
+	resultFile, _ := cmd.Flags().GetString("cutover-result-file")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+
+	result, err := engine.RequestCutover(cutoverFile, resultFile, timeout)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to format cutover result: %w", err)
+	}
+	fmt.Println(string(data))
+
+	if !result.Success {
+		return fmt.Errorf("cutover failed: %s", result.Error)
+	}
+	return nil
+}
+
+func runRetryFailed(cmd *cobra.Command, args []string) error {
+	config.UseFlags(cmd)
+	cfg, err := config.LoadConfigWithFlags()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	if cfg.Migration.AllDatabases {
+		return fmt.Errorf("retry the failed keys of each database with --redis-database and --valkey-database instead of --all-databases")
+	}
+
+	log, err := createLogger(cfg)
+	if err != nil {
+		return err
+	}
+
+	engineConfig := createEngineConfig(cmd, cfg)
+	letters, err := engine.ReadDeadLetterFile(engineConfig.DeadLetterFile)
+	if os.IsNotExist(err) {
+		log.Infof("No dead-letter file %s, no keys failed", engineConfig.DeadLetterFile)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// The dead-letter file is all a retry needs to continue, it does not touch the resume
+	// file of the migration
+	engineConfig.ResumeFile = ""
+	if cfg.Valkey.CommandFile != "" && engineConfig.VerifyAfterMigration {
+		log.Info("Skipping verification, keys written to a command file cannot be read back")
+		engineConfig.VerifyAfterMigration = false
+	}
+
+	redisClient, err := createRedisClient(cfg, log)
+	if err != nil {
+		return fmt.Errorf("failed to create Redis client: %w", err)
+	}
 
+	valkeyClient, err := createValkeyClient(cfg, log)
+	if err != nil {
+		return fmt.Errorf("failed to create Valkey client: %w", err)
+	}
+
+	migrationEngine, err := engine.NewMigrationEngine(
+		redisClient,
+		client.NewClientConfigFromDatabaseConfig(&cfg.Redis, &cfg.Migration.TimeoutConfig),
+		valkeyClient,
+		client.NewClientConfigFromDatabaseConfig(&cfg.Valkey, &cfg.Migration.TimeoutConfig),
+		log,
+		engineConfig,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create migration engine: %w", err)
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		<-sigChan
+		log.Info("Received shutdown signal, initiating graceful shutdown...")
+		migrationEngine.Shutdown()
+	}()
+
+	retryErr := migrationEngine.RetryFailed(letters)
+	stats := migrationEngine.GetStats()
+	log.Infof("Retry statistics: Total=%d, Processed=%d, Failed=%d, Skipped=%d, Duration=%v",
+		stats.TotalKeys, stats.ProcessedKeys, stats.FailedKeys, stats.SkippedKeys, stats.Duration)
+	if retryErr != nil {
+		return fmt.Errorf("retry failed: %w", retryErr)
+	}
+	return nil
+}
+
+func runInspectResume(cmd *cobra.Command, args []string) error {
+	resumeFile, _ := cmd.Flags().GetString("resume-file")
+
+	summary, err := engine.InspectResumeFile(resumeFile)
+	if err != nil {
+		return fmt.Errorf("failed to inspect resume file: %w", err)
+	}
+
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to format resume file contents: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	config.UseFlags(cmd)
+	cfg, err := config.LoadConfigWithFlags()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	if cfg.Migration.AllDatabases {
+		return fmt.Errorf("an archive holds a single database, export each one with --redis-database instead of --all-databases")
+	}
+
+	log, err := createLogger(cfg)
+	if err != nil {
+		return err
+	}
+
+	redisClient, err := createRedisClient(cfg, log)
+	if err != nil {
+		return fmt.Errorf("failed to create Redis client: %w", err)
+	}
+
+	archiveConfig := engine.DefaultArchiveConfig()
+	archiveConfig.Path, _ = cmd.Flags().GetString("output")
+	archiveConfig.ChunkKeys, _ = cmd.Flags().GetInt("chunk-size")
+	archiveConfig.MaxConcurrency, _ = cmd.Flags().GetInt("max-concurrency")
+	archiveConfig.CollectionPatterns = cfg.Migration.CollectionPatterns
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	redisConfig := client.NewClientConfigFromDatabaseConfig(&cfg.Redis, &cfg.Migration.TimeoutConfig)
+	stats, err := engine.ExportArchive(ctx, redisClient, redisConfig, log, archiveConfig)
+	if err != nil {
+		return fmt.Errorf("export failed: %w", err)
+	}
+
+	log.Infof("Final statistics: Keys=%d, Chunks=%d, Skipped=%d, Duration=%v", stats.Keys, stats.Chunks, stats.SkippedKeys, stats.Duration)
+	return nil
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	config.UseFlags(cmd)
+	cfg, err := config.LoadConfigWithFlags()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	log, err := createLogger(cfg)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Migration.AllDatabases {
+		return fmt.Errorf("an archive holds a single database, import it with --valkey-database instead of --all-databases")
+	}
+
+	valkeyClient, err := createValkeyClient(cfg, log)
+	if err != nil {
+		return fmt.Errorf("failed to create Valkey client: %w", err)
+	}
+
+	archiveConfig := engine.DefaultArchiveConfig()
+	archiveConfig.Path, _ = cmd.Flags().GetString("input")
+	archiveConfig.ResumeFile, _ = cmd.Flags().GetString("resume-file")
+	archiveConfig.MaxConcurrency, _ = cmd.Flags().GetInt("max-concurrency")
+	archiveConfig.Verify, _ = cmd.Flags().GetBool("verify")
+	archiveConfig.CopyStreamPending, _ = cmd.Flags().GetBool("copy-stream-pending")
+	archiveConfig.KeyRules = cfg.Migration.KeyRules
+	if cfg.Valkey.CommandFile != "" && archiveConfig.Verify {
+		log.Info("Skipping verification, keys written to a command file cannot be read back")
+		archiveConfig.Verify = false
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	valkeyConfig := client.NewClientConfigFromDatabaseConfig(&cfg.Valkey, &cfg.Migration.TimeoutConfig)
+	stats, err := engine.ImportArchive(ctx, valkeyClient, valkeyConfig, log, archiveConfig)
+	if err != nil {
+		return fmt.Errorf("import failed: %w", err)
+	}
+
+	log.Infof("Final statistics: Keys=%d, Skipped=%d, Duration=%v", stats.Keys, stats.SkippedKeys, stats.Duration)
 	return nil
 }
 
+func createLogger(cfg *config.Config) (logger.Logger, error) {
+	logLevel := cfg.Migration.LogLevel
+	if verbose {
+		logLevel = "debug"
+	}
+
+	// Commands written to standard output must not be mixed with logs and progress
+	if cfg.Valkey.CommandFile == client.CommandFileStdout {
+		os.Stdout = os.Stderr
+	}
+
+	logConfig := logger.Config{
+		Level:      logLevel,
+		OutputFile: "migration.log",
+		MaxSize:    10 * 1024 * 1024, // 10MB
+		MaxAge:     7,                // 7 days
+		Format:     "text",
+	}
+
+	log, err := logger.NewLogger(logConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create logger: %w", err)
+	}
+	return log, nil
+}
+
 func createRedisClient(cfg *config.Config, log logger.Logger) (client.DatabaseClient, error) {
-	clientConfig := client.NewClientConfig(cfg.Redis.Host, cfg.Redis.Port, cfg.Redis.Password, cfg.Redis.Database)
+	clientConfig := client.NewClientConfigFromDatabaseConfig(&cfg.Redis, &cfg.Migration.TimeoutConfig)
+
+	if clientConfig.RDBFile != "" {
+		log.Infof("Reading keys from RDB file %s instead of a running Redis", clientConfig.RDBFile)
+		return client.NewRDBFileClient(clientConfig), nil
+	}
 
 	redisClient := client.NewRedisClient(clientConfig)
 	return redisClient, nil
 }
 
 func createValkeyClient(cfg *config.Config, log logger.Logger) (client.DatabaseClient, error) {
-	clientConfig := client.NewClientConfig(cfg.Valkey.Host, cfg.Valkey.Port, cfg.Valkey.Password, cfg.Valkey.Database)
+	clientConfig := client.NewClientConfigFromDatabaseConfig(&cfg.Valkey, &cfg.Migration.TimeoutConfig)
+
+	if clientConfig.CommandFile != "" {
+		log.Infof("Writing RESP commands to %s instead of a running Valkey", clientConfig.CommandFile)
+		return client.NewRESPFileClient(clientConfig), nil
+	}
 
 	valkeyClient := client.NewValkeyClient(clientConfig)
 	return valkeyClient, nil
@@ -271,6 +901,21 @@ func createEngineConfig(cmd *cobra.Command, cfg *config.Config) *engine.EngineCo
 		engineConfig.ResumeFile = resumeFile
 	}
 
+	if forceResume, _ := cmd.Flags().GetBool("force-resume"); cmd.Flags().Changed("force-resume") {
+		engineConfig.ForceResume = forceResume
+	}
+
+	if deadLetterFile, _ := cmd.Flags().GetString("dead-letter-file"); cmd.Flags().Changed("dead-letter-file") {
+		engineConfig.DeadLetterFile = deadLetterFile
+	}
+
+	retry := engine.DefaultRetryConfig()
+	retry.MaxAttempts = cfg.Migration.RetryAttempts
+	if retryDelay, _ := cmd.Flags().GetDuration("retry-delay"); cmd.Flags().Changed("retry-delay") {
+		retry.InitialDelay = retryDelay
+	}
+	engineConfig.Retry = &retry
+
 	if progressInterval, _ := cmd.Flags().GetDuration("progress-interval"); cmd.Flags().Changed("progress-interval") {
 		engineConfig.ProgressInterval = progressInterval
 	}
@@ -279,11 +924,46 @@ func createEngineConfig(cmd *cobra.Command, cfg *config.Config) *engine.EngineCo
 		engineConfig.MaxConcurrency = maxConcurrency
 	}
 
+	if copyStreamPending, _ := cmd.Flags().GetBool("copy-stream-pending"); cmd.Flags().Changed("copy-stream-pending") {
+		engineConfig.CopyStreamPending = copyStreamPending
+	}
+
+	if transferMode, _ := cmd.Flags().GetString("transfer-mode"); cmd.Flags().Changed("transfer-mode") {
+		engineConfig.TransferMode = transferMode
+	}
+
+	if onConflict, _ := cmd.Flags().GetString("on-conflict"); cmd.Flags().Changed("on-conflict") {
+		engineConfig.OnConflict = onConflict
+	}
+
+	if cutoverFile, _ := cmd.Flags().GetString("cutover-file"); cmd.Flags().Changed("cutover-file") {
+		engineConfig.CutoverFile = cutoverFile
+	}
+
+	if syncInterval, _ := cmd.Flags().GetDuration("sync-interval"); cmd.Flags().Changed("sync-interval") {
+		engineConfig.SyncInterval = syncInterval
+	}
+
+	if syncMethod, _ := cmd.Flags().GetString("sync-method"); cmd.Flags().Changed("sync-method") {
+		engineConfig.SyncMethod = syncMethod
+	}
+
+	if pauseTimeout, _ := cmd.Flags().GetDuration("cutover-pause-timeout"); cmd.Flags().Changed("cutover-pause-timeout") {
+		engineConfig.CutoverPauseTimeout = pauseTimeout
+	}
+
+	if resultFile, _ := cmd.Flags().GetString("cutover-result-file"); cmd.Flags().Changed("cutover-result-file") {
+		engineConfig.CutoverResultFile = resultFile
+	}
+
 	// Use batch size from migration config
 	engineConfig.BatchSize = cfg.Migration.BatchSize
 
 	// Use collection patterns from migration config
 	engineConfig.CollectionPatterns = cfg.Migration.CollectionPatterns
+	engineConfig.KeyRules = cfg.Migration.KeyRules
+
+	engineConfig.MaxReplicaLag = cfg.Redis.MaxReplicaLag
 
 	return engineConfig
 }
@@ -337,6 +1017,11 @@ func runDryRun(cfg *config.Config, log logger.Logger) error {
 		sampleSize = len(keys)
 	}
 
+	keyRules, err := transform.ParseRules(cfg.Migration.KeyRules)
+	if err != nil {
+		return err
+	}
+
 	log.Info("Sample keys and types:")
 	for i := 0; i < sampleSize; i++ {
 		keyType, err := redisClient.GetKeyType(keys[i])
@@ -344,6 +1029,10 @@ func runDryRun(cfg *config.Config, log logger.Logger) error {
 			log.Warnf("Failed to get type for key %s: %v", keys[i], err)
 			continue
 		}
+		if targetKey := keyRules.Apply(keys[i]); targetKey != keys[i] {
+			log.Infof("  %s -> %s (%s)", keys[i], targetKey, keyType)
+			continue
+		}
 		log.Infof("  %s (%s)", keys[i], keyType)
 	}
 